@@ -1,19 +1,204 @@
 package main
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
+	"github.com/Nier704/arthur-leilao-server/config"
 	"github.com/Nier704/arthur-leilao-server/db"
+	"github.com/Nier704/arthur-leilao-server/internal/blob"
 	"github.com/Nier704/arthur-leilao-server/internal/domain"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/storage"
+	"github.com/Nier704/arthur-leilao-server/internal/tracing"
 )
 
+const usage = `usage:
+  server                     serve the api, migrating the database first
+  server migrate up          apply pending migrations
+  server migrate down [n]    roll back the last n migrations (default 1)
+  server migrate status      list migrations and when they were applied
+  server role <user> <role>  set the role of an account (user, moderator or admin)`
+
 func main() {
-	db, err := db.NewPostgreConnection()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+
+	args := os.Args[1:]
+
+	if len(args) > 0 && args[0] != "serve" && args[0] != "migrate" && args[0] != "role" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		var cfgErr *config.Error
+		if !errors.As(err, &cfgErr) {
+			fatal(err)
+		}
+
+		for _, problem := range cfgErr.Problems {
+			slog.Error("invalid configuration", "problem", problem)
+		}
+		os.Exit(1)
+	}
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		Level: cfg.Log.Level,
+	})))
+
+	if len(args) > 0 && args[0] == "migrate" {
+		conn, dialect, err := storage.OpenDB(&cfg.Storage)
+		if err != nil {
+			fatal(err)
+		}
+
+		if err = migrate(conn, dialect, args[1:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	store, err := storage.Open(context.Background(), &cfg.Storage)
+	if err != nil {
+		fatal(err)
+	}
+
+	if len(args) > 0 && args[0] == "role" {
+		if err = setRole(store, args[1:]); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	blobs, err := blob.Open(&cfg.Blob)
+	if err != nil {
+		fatal(err)
+	}
+
+	urls := &blob.URLs{
+		Store:  blobs,
+		Secret: []byte(cfg.Blob.URLSecret),
+		Prefix: "/api/images/",
+		TTL:    cfg.Blob.URLTTL,
+	}
+
+	shutdownTracing, err := tracing.Open(context.Background(), &cfg.Tracing)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// a second signal kills the server without waiting
+	context.AfterFunc(ctx, stop)
+
+	router := domain.NewRouter(cfg)
+	router.Init(store, urls)
+
+	if err = router.Run(ctx); err != nil {
+		fatal(err)
+	}
+
+	flush, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err = shutdownTracing(flush); err != nil {
+		slog.Error("error flushing spans", "err", err)
 	}
 
-	router := domain.NewRouter()
-	router.Init(db)
-	router.Start()
+	if err = store.Close(); err != nil {
+		slog.Error("error closing the database", "err", err)
+	}
+
+	slog.Info("server stopped")
+}
+
+// fatal logs err and exits.
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+func migrate(conn *sql.DB, dialect db.Dialect, args []string) error {
+	m, err := db.NewMigrator(conn, dialect)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		for _, mig := range applied {
+			slog.Info("applied migration", "version", mig.Version, "name", mig.Name)
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if _, err := fmt.Sscan(args[1], &steps); err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps: %s", args[1])
+			}
+		}
+
+		rolledBack, err := m.Down(ctx, steps)
+		for _, mig := range rolledBack {
+			slog.Info("rolled back migration", "version", mig.Version, "name", mig.Name)
+		}
+		return err
+
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s  %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q\n%s", args[0], usage)
+	}
+}
+
+func setRole(store *storage.Store, args []string) error {
+	if len(args) != 2 || !slices.Contains(account.Roles, args[1]) {
+		return fmt.Errorf("invalid role command\n%s", usage)
+	}
+
+	ctx := context.Background()
+
+	acc, err := store.Accounts.GetByUsername(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("account %s: %w", args[0], err)
+	}
+
+	if err = store.Accounts.SetRole(ctx, acc.ID, args[1]); err != nil {
+		return err
+	}
+
+	slog.Info("role set", "username", acc.Username, "role", args[1])
+
+	return nil
 }
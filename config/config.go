@@ -1,33 +1,272 @@
+// Package config holds the settings of the server. Load reads them, in
+// increasing order of precedence, from the defaults, an optional YAML file
+// named by CONFIG_FILE, a .env file and the environment.
 package config
 
 import (
-	"os"
-
-	"github.com/joho/godotenv"
+	"log/slog"
+	"net/http"
+	"time"
 )
 
-type DBConfig struct {
-	Host string
-	Port string
-	User string
-	Pass string
-	Name string
-}
-
-func NewDBConfig() *DBConfig {
-	godotenv.Load()
-
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	pass := os.Getenv("DB_PASS")
-	name := os.Getenv("DB_NAME")
-
-	return &DBConfig{
-		Host: host,
-		Port: port,
-		User: user,
-		Pass: pass,
-		Name: name,
+type Config struct {
+	Server  ServerConfig  `yaml:"server"`
+	CORS    CORSConfig    `yaml:"cors"`
+	Auth    AuthConfig    `yaml:"auth"`
+	Storage StorageConfig `yaml:"storage"`
+	Log     LogConfig     `yaml:"log"`
+	Metrics MetricsConfig `yaml:"metrics"`
+	Tracing TracingConfig `yaml:"tracing"`
+	OIDC    OIDCConfig    `yaml:"oidc"`
+	Bid     BidConfig     `yaml:"bids"`
+	Blob    BlobConfig    `yaml:"blobs"`
+	Image   ImageConfig   `yaml:"images"`
+}
+
+// Default returns the configuration used for what is not set.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            3000,
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			RequestTimeout:  15 * time.Second,
+			ShutdownTimeout: 8 * time.Second,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"https://yuraibids.netlify.app", "https://arthur-leilao-api-production.up.railway.app", "http://localhost:5173"},
+		},
+		Auth: AuthConfig{
+			SessionTTL: 24 * time.Hour,
+			Cookie: CookieConfig{
+				Path:     "/",
+				Secure:   true,
+				SameSite: "none",
+			},
+		},
+		Storage: StorageConfig{
+			Backend:    "postgres",
+			SQLitePath: "leilao.db",
+			DB: &DBConfig{
+				Port:            "5432",
+				SSLMode:         "disable",
+				MaxOpenConns:    25,
+				MaxIdleConns:    5,
+				ConnMaxLifetime: 30 * time.Minute,
+				ConnMaxIdleTime: 5 * time.Minute,
+			},
+		},
+		Log: LogConfig{Level: slog.LevelInfo},
+		Tracing: TracingConfig{
+			Exporter: "none",
+			Endpoint: "http://localhost:4318",
+			Headers:  map[string]string{},
+			Service:  "arthur-leilao-server",
+			Ratio:    1,
+		},
+		Bid: BidConfig{
+			MinAuction:     time.Hour,
+			MaxAuction:     30 * 24 * time.Hour,
+			RetractWindow:  10 * time.Minute,
+			RetractClosing: time.Hour,
+			MaxRetractions: 3,
+			RetractPeriod:  30 * 24 * time.Hour,
+		},
+		Blob: BlobConfig{
+			Backend: "local",
+			Dir:     "uploads",
+			S3:      &S3Config{Region: "us-east-1"},
+			URLTTL:  15 * time.Minute,
+		},
+		Image: ImageConfig{
+			MaxBytes: 10 << 20,
+			MaxCount: 10,
+			Workers:  2,
+		},
+	}
+}
+
+type ServerConfig struct {
+	// Port is listened on on every interface, from PORT as Railway sets it.
+	Port int `yaml:"port"`
+	// ReadTimeout bounds reading a request, body included, and WriteTimeout
+	// writing its response. Routes given a longer deadline extend both.
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// IdleTimeout is how long a kept-alive connection waits for the next
+	// request.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// RequestTimeout is the deadline of handlers, which their queries are
+	// cancelled at.
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// ShutdownTimeout is how long in-flight requests and background work
+	// are given to finish on SIGTERM. It must stay under the grace period
+	// of the platform, 10s for Docker.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins may call the API from a browser, with credentials.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+type AuthConfig struct {
+	// JWTSecret signs session tokens and login flows. It must be at least
+	// 32 bytes.
+	JWTSecret string `yaml:"jwt_secret"`
+	// SessionTTL is how long a login lasts.
+	SessionTTL time.Duration `yaml:"session_ttl"`
+	Cookie     CookieConfig  `yaml:"cookie"`
+}
+
+// CookieConfig sets the attributes of the session cookie.
+type CookieConfig struct {
+	// Domain is left unset by default, tying the cookie to the API host.
+	Domain string `yaml:"domain"`
+	Path   string `yaml:"path"`
+	// Secure may only be turned off to develop over plain HTTP.
+	Secure bool `yaml:"secure"`
+	// SameSite is "lax", "strict" or "none", which a frontend on another
+	// site needs and which browsers only accept on secure cookies.
+	SameSite string `yaml:"same_site"`
+}
+
+// SameSiteMode returns SameSite as net/http spells it.
+func (c *CookieConfig) SameSiteMode() http.SameSite {
+	switch c.SameSite {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	default:
+		return http.SameSiteNoneMode
 	}
 }
+
+type StorageConfig struct {
+	// Backend is "postgres" (the default), "sqlite" or "memory".
+	Backend    string `yaml:"backend"`
+	SQLitePath string `yaml:"sqlite_path"`
+	// DB is the PostgreSQL connection and, for both SQL backends, the
+	// connection pool.
+	DB *DBConfig `yaml:"db"`
+}
+
+type DBConfig struct {
+	Host string `yaml:"host"`
+	Port string `yaml:"port"`
+	User string `yaml:"user"`
+	Pass string `yaml:"pass"`
+	Name string `yaml:"name"`
+	// SSLMode is passed on to PostgreSQL: disable (the default), allow,
+	// prefer, require, verify-ca or verify-full.
+	SSLMode string `yaml:"ssl_mode"`
+	// SSLRootCert is the CA file that verify-ca and verify-full check the
+	// server against.
+	SSLRootCert string `yaml:"ssl_root_cert"`
+
+	// MaxOpenConns caps the connections open at once, 0 for no cap.
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+type LogConfig struct {
+	// Level is the least severe level logged: debug, info (the default),
+	// warn or error.
+	Level slog.Level `yaml:"level"`
+}
+
+type MetricsConfig struct {
+	// Token, when set, must be sent as a bearer token to scrape /metrics.
+	Token string `yaml:"token"`
+}
+
+// TracingConfig is read from the standard OpenTelemetry variables.
+type TracingConfig struct {
+	// Exporter is "none" (the default), "otlp" or "console", which writes
+	// spans to stdout.
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the OTLP/HTTP collector, http://localhost:4318 by default.
+	Endpoint string `yaml:"endpoint"`
+	// Headers are sent with every export, from "key=value,key=value".
+	Headers map[string]string `yaml:"headers"`
+	Service string            `yaml:"service"`
+	// Ratio is the share of new traces sampled, from 0 to 1.
+	Ratio float64 `yaml:"ratio"`
+}
+
+type OIDCProvider struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+	// AuthURL, TokenURL and JWKSURL override the endpoints found through
+	// the issuer's discovery document, e.g. to point at a local fake server.
+	AuthURL  string `yaml:"auth_url"`
+	TokenURL string `yaml:"token_url"`
+	JWKSURL  string `yaml:"jwks_url"`
+}
+
+// OIDCConfig lists the login providers. In the environment they are named
+// in OIDC_PROVIDERS, each configured through OIDC_<NAME>_* variables.
+type OIDCConfig struct {
+	Providers         []OIDCProvider `yaml:"providers"`
+	PostLoginRedirect string         `yaml:"post_login_redirect"`
+}
+
+// BidConfig holds the rules for auctions and for retracting bids.
+type BidConfig struct {
+	// MinAuction and MaxAuction bound how long after being published an
+	// auction may end.
+	MinAuction time.Duration `yaml:"min_auction"`
+	MaxAuction time.Duration `yaml:"max_auction"`
+	// RetractWindow is how long after being placed a bid may be retracted.
+	RetractWindow time.Duration `yaml:"retract_window"`
+	// RetractClosing is how long before an auction ends retractions stop.
+	RetractClosing time.Duration `yaml:"retract_closing"`
+	// MaxRetractions is how many bids an account may retract within
+	// RetractPeriod.
+	MaxRetractions int           `yaml:"max_retractions"`
+	RetractPeriod  time.Duration `yaml:"retract_period"`
+}
+
+type S3Config struct {
+	// Endpoint is the base URL of the service, e.g. https://s3.amazonaws.com
+	// or http://localhost:9000 for MinIO.
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	// PathStyle addresses the bucket as a path below the endpoint instead of
+	// a subdomain, as MinIO and most local stand-ins expect.
+	PathStyle bool `yaml:"path_style"`
+}
+
+type BlobConfig struct {
+	// Backend is "local" (the default) or "s3".
+	Backend string `yaml:"backend"`
+	// Dir is where the local backend keeps files.
+	Dir string    `yaml:"dir"`
+	S3  *S3Config `yaml:"s3"`
+	// URLSecret signs the links to blobs served by the API, so the local
+	// backend requires it. Stores that presign their own links ignore it.
+	URLSecret string `yaml:"url_secret"`
+	// URLTTL is how long links to blobs stay valid.
+	URLTTL time.Duration `yaml:"url_ttl"`
+}
+
+// ImageConfig limits the photos sellers upload.
+type ImageConfig struct {
+	// MaxBytes is the largest file accepted.
+	MaxBytes int64 `yaml:"max_bytes"`
+	// MaxCount is how many images a product may have.
+	MaxCount int `yaml:"max_count"`
+	// Workers is how many images are resized at once.
+	Workers int `yaml:"workers"`
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Error lists every problem found in the configuration, so they can all be
+// fixed at once.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
+// Load reads the configuration and checks it. The environment takes
+// precedence over the .env file, which takes precedence over the YAML file
+// named by CONFIG_FILE. Secrets may also be read from the file a *_FILE
+// variable names, such as JWT_SECRET_FILE for Docker secrets. Problems are
+// returned together in an *Error.
+func Load() (*Config, error) {
+	l := &loader{}
+
+	// variables already set are kept, which gives the environment precedence
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		l.problem(".env: %v", err)
+	}
+
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		l.file(cfg, path)
+	}
+
+	l.env(cfg)
+
+	l.problems = append(l.problems, cfg.validate()...)
+
+	if len(l.problems) > 0 {
+		return nil, &Error{Problems: l.problems}
+	}
+
+	return cfg, nil
+}
+
+type loader struct {
+	problems []string
+}
+
+func (l *loader) problem(format string, args ...any) {
+	l.problems = append(l.problems, fmt.Sprintf(format, args...))
+}
+
+// file reads the YAML file at path over cfg. Keys it does not know are
+// problems, as they are most likely typos.
+func (l *loader) file(cfg *Config, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		l.problem("CONFIG_FILE: %v", err)
+		return
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	err = dec.Decode(cfg)
+
+	var typeErr *yaml.TypeError
+	switch {
+	case err == nil, errors.Is(err, io.EOF):
+	case errors.As(err, &typeErr):
+		for _, e := range typeErr.Errors {
+			l.problem("%s: %s", path, e)
+		}
+	default:
+		l.problem("%s: %v", path, err)
+	}
+
+	// a section set to null, such as storage: {db: null}, is taken as
+	// unset rather than left nil
+	def := Default()
+	if cfg.Storage.DB == nil {
+		cfg.Storage.DB = def.Storage.DB
+	}
+	if cfg.Blob.S3 == nil {
+		cfg.Blob.S3 = def.Blob.S3
+	}
+}
+
+// env reads the environment over cfg. Unset and empty variables leave the
+// setting as it is.
+func (l *loader) env(cfg *Config) {
+	s := &cfg.Server
+	set(l, &s.Port, "PORT", "a port", strconv.Atoi)
+	set(l, &s.ReadTimeout, "SERVER_READ_TIMEOUT", "a duration", time.ParseDuration)
+	set(l, &s.WriteTimeout, "SERVER_WRITE_TIMEOUT", "a duration", time.ParseDuration)
+	set(l, &s.IdleTimeout, "SERVER_IDLE_TIMEOUT", "a duration", time.ParseDuration)
+	set(l, &s.RequestTimeout, "REQUEST_TIMEOUT", "a duration", time.ParseDuration)
+	set(l, &s.ShutdownTimeout, "SHUTDOWN_TIMEOUT", "a duration", time.ParseDuration)
+
+	set(l, &cfg.CORS.AllowedOrigins, "CORS_ALLOWED_ORIGINS", "a list", parseList)
+
+	a := &cfg.Auth
+	l.secret(&a.JWTSecret, "JWT_SECRET")
+	set(l, &a.SessionTTL, "SESSION_TTL", "a duration", time.ParseDuration)
+	l.string(&a.Cookie.Domain, "COOKIE_DOMAIN")
+	l.string(&a.Cookie.Path, "COOKIE_PATH")
+	set(l, &a.Cookie.Secure, "COOKIE_SECURE", "true or false", strconv.ParseBool)
+	set(l, &a.Cookie.SameSite, "COOKIE_SAMESITE", "a mode", parseLower)
+
+	st := &cfg.Storage
+	set(l, &st.Backend, "STORAGE_BACKEND", "a backend", parseLower)
+	l.string(&st.SQLitePath, "SQLITE_PATH")
+
+	db := st.DB
+	l.string(&db.Host, "DB_HOST")
+	l.string(&db.Port, "DB_PORT")
+	l.string(&db.User, "DB_USER")
+	l.secret(&db.Pass, "DB_PASS")
+	l.string(&db.Name, "DB_NAME")
+	set(l, &db.SSLMode, "DB_SSLMODE", "an SSL mode", parseLower)
+	l.string(&db.SSLRootCert, "DB_SSLROOTCERT")
+	set(l, &db.MaxOpenConns, "DB_MAX_OPEN_CONNS", "a number", strconv.Atoi)
+	set(l, &db.MaxIdleConns, "DB_MAX_IDLE_CONNS", "a number", strconv.Atoi)
+	set(l, &db.ConnMaxLifetime, "DB_CONN_MAX_LIFETIME", "a duration", time.ParseDuration)
+	set(l, &db.ConnMaxIdleTime, "DB_CONN_MAX_IDLE_TIME", "a duration", time.ParseDuration)
+
+	set(l, &cfg.Log.Level, "LOG_LEVEL", "a level", parseLevel)
+
+	l.secret(&cfg.Metrics.Token, "METRICS_TOKEN")
+
+	t := &cfg.Tracing
+	set(l, &t.Exporter, "OTEL_TRACES_EXPORTER", "an exporter", parseLower)
+	l.string(&t.Endpoint, "OTEL_EXPORTER_OTLP_ENDPOINT")
+	set(l, &t.Headers, "OTEL_EXPORTER_OTLP_HEADERS", "a list of key=value pairs", parseHeaders)
+	l.string(&t.Service, "OTEL_SERVICE_NAME")
+	set(l, &t.Ratio, "OTEL_TRACES_SAMPLER_ARG", "a number", parseFloat)
+
+	l.oidc(&cfg.OIDC)
+
+	b := &cfg.Bid
+	set(l, &b.MinAuction, "AUCTION_MIN_DURATION", "a duration", time.ParseDuration)
+	set(l, &b.MaxAuction, "AUCTION_MAX_DURATION", "a duration", time.ParseDuration)
+	set(l, &b.RetractWindow, "BID_RETRACT_WINDOW", "a duration", time.ParseDuration)
+	set(l, &b.RetractClosing, "BID_RETRACT_CLOSING", "a duration", time.ParseDuration)
+	set(l, &b.MaxRetractions, "BID_RETRACT_MAX", "a number", strconv.Atoi)
+	set(l, &b.RetractPeriod, "BID_RETRACT_PERIOD", "a duration", time.ParseDuration)
+
+	bl := &cfg.Blob
+	set(l, &bl.Backend, "BLOB_BACKEND", "a backend", parseLower)
+	l.string(&bl.Dir, "BLOB_DIR")
+	l.secret(&bl.URLSecret, "BLOB_URL_SECRET")
+	set(l, &bl.URLTTL, "BLOB_URL_TTL", "a duration", time.ParseDuration)
+	l.string(&bl.S3.Endpoint, "S3_ENDPOINT")
+	l.string(&bl.S3.Region, "S3_REGION")
+	l.string(&bl.S3.Bucket, "S3_BUCKET")
+	l.secret(&bl.S3.AccessKey, "S3_ACCESS_KEY")
+	l.secret(&bl.S3.SecretKey, "S3_SECRET_KEY")
+	set(l, &bl.S3.PathStyle, "S3_PATH_STYLE", "true or false", strconv.ParseBool)
+
+	i := &cfg.Image
+	set(l, &i.MaxBytes, "IMAGE_MAX_BYTES", "a number", parseInt64)
+	set(l, &i.MaxCount, "IMAGE_MAX_COUNT", "a number", strconv.Atoi)
+	set(l, &i.Workers, "IMAGE_WORKERS", "a number", strconv.Atoi)
+}
+
+// oidc adds the providers named in OIDC_PROVIDERS to those of the file,
+// then reads the OIDC_<NAME>_* variables of each.
+func (l *loader) oidc(cfg *OIDCConfig) {
+	l.string(&cfg.PostLoginRedirect, "OIDC_POST_LOGIN_REDIRECT")
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+
+		known := false
+		for _, p := range cfg.Providers {
+			known = known || p.Name == name
+		}
+		if !known {
+			cfg.Providers = append(cfg.Providers, OIDCProvider{Name: name})
+		}
+	}
+
+	for i := range cfg.Providers {
+		p := &cfg.Providers[i]
+		prefix := "OIDC_" + strings.ToUpper(p.Name) + "_"
+
+		l.string(&p.Issuer, prefix+"ISSUER")
+		l.string(&p.ClientID, prefix+"CLIENT_ID")
+		l.secret(&p.ClientSecret, prefix+"CLIENT_SECRET")
+		l.string(&p.RedirectURL, prefix+"REDIRECT_URL")
+		set(l, &p.Scopes, prefix+"SCOPES", "a list", func(v string) ([]string, error) { return strings.Fields(v), nil })
+		l.string(&p.AuthURL, prefix+"AUTH_URL")
+		l.string(&p.TokenURL, prefix+"TOKEN_URL")
+		l.string(&p.JWKSURL, prefix+"JWKS_URL")
+
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "email", "profile"}
+		}
+	}
+}
+
+// set parses the variable name into dst, when it is set.
+func set[T any](l *loader, dst *T, name string, want string, parse func(string) (T, error)) {
+	v := os.Getenv(name)
+	if v == "" {
+		return
+	}
+
+	x, err := parse(v)
+	if err != nil {
+		l.problem("%s: %q is not %s", name, v, want)
+		return
+	}
+
+	*dst = x
+}
+
+func (l *loader) string(dst *string, name string) {
+	if v := os.Getenv(name); v != "" {
+		*dst = v
+	}
+}
+
+// secret reads the variable name, or the file that name_FILE points to.
+// Setting both is a problem, as it is unclear which should win.
+func (l *loader) secret(dst *string, name string) {
+	v, path := os.Getenv(name), os.Getenv(name+"_FILE")
+
+	switch {
+	case v != "" && path != "":
+		l.problem("%s and %s_FILE are both set", name, name)
+
+	case path != "":
+		b, err := os.ReadFile(path)
+		if err != nil {
+			l.problem("%s_FILE: %v", name, err)
+			return
+		}
+		// files written by editors and echo end in a newline
+		*dst = strings.TrimRight(string(b), "\r\n")
+
+	case v != "":
+		*dst = v
+	}
+}
+
+func parseList(v string) ([]string, error) {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+
+	return out, nil
+}
+
+func parseHeaders(v string) (map[string]string, error) {
+	headers := make(map[string]string)
+
+	for _, pair := range strings.Split(v, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q has no =", pair)
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	return headers, nil
+}
+
+func parseLower(v string) (string, error) {
+	return strings.ToLower(strings.TrimSpace(v)), nil
+}
+
+func parseLevel(v string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(v))
+
+	return level, err
+}
+
+func parseFloat(v string) (float64, error) {
+	return strconv.ParseFloat(v, 64)
+}
+
+func parseInt64(v string) (int64, error) {
+	return strconv.ParseInt(v, 10, 64)
+}
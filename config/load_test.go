@@ -0,0 +1,243 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/config"
+)
+
+// secret is long enough for every secret setting.
+const secret = "0123456789abcdef0123456789abcdef"
+
+// load runs Load in an empty directory and environment, with files written
+// there, such as config.yaml or .env, and env set. It returns the problems
+// Load found.
+func load(t *testing.T, files map[string]string, env map[string]string) (*config.Config, []string) {
+	t.Helper()
+
+	// Load reads the whole environment: every variable is unset, and
+	// restored after the test. Those .env adds are unset then too, after
+	// the restores, which run first.
+	before := os.Environ()
+	t.Cleanup(func() {
+		for _, kv := range os.Environ() {
+			if !slices.Contains(before, kv) {
+				name, _, _ := strings.Cut(kv, "=")
+				os.Unsetenv(name)
+			}
+		}
+	})
+
+	for _, kv := range before {
+		name, _, _ := strings.Cut(kv, "=")
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for name, v := range env {
+		t.Setenv(name, strings.ReplaceAll(v, "$DIR", dir))
+	}
+
+	cfg, err := config.Load()
+
+	var cfgErr *config.Error
+	switch {
+	case err == nil:
+		return cfg, nil
+	case errors.As(err, &cfgErr):
+		if cfg != nil {
+			t.Errorf("Load returned a configuration with %v", err)
+		}
+		return nil, cfgErr.Problems
+	default:
+		t.Fatalf("Load: %v", err)
+		return nil, nil
+	}
+}
+
+// valid is the least environment Load accepts.
+func valid(env map[string]string) map[string]string {
+	out := map[string]string{
+		"STORAGE_BACKEND": "memory",
+		"JWT_SECRET":      secret,
+		"BLOB_URL_SECRET": secret,
+	}
+	for name, v := range env {
+		out[name] = v
+	}
+
+	return out
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+server:
+  port: 4000
+  request_timeout: 20s
+  shutdown_timeout: 5s
+auth:
+  session_ttl: 1h
+`,
+		".env": "PORT=5000\nREQUEST_TIMEOUT=25s\n",
+	}
+
+	cfg, problems := load(t, files, valid(map[string]string{
+		"CONFIG_FILE": "config.yaml",
+		"PORT":        "6000",
+	}))
+	if problems != nil {
+		t.Fatal(problems)
+	}
+
+	def := config.Default()
+
+	for _, tt := range []struct {
+		name      string
+		got, want any
+	}{
+		// the environment over .env
+		{"port", cfg.Server.Port, 6000},
+		// .env over the file
+		{"request timeout", cfg.Server.RequestTimeout, 25 * time.Second},
+		// the file over the defaults
+		{"shutdown timeout", cfg.Server.ShutdownTimeout, 5 * time.Second},
+		{"session ttl", cfg.Auth.SessionTTL, time.Hour},
+		// the defaults where nothing is set
+		{"idle timeout", cfg.Server.IdleTimeout, def.Server.IdleTimeout},
+		{"db port", cfg.Storage.DB.Port, def.Storage.DB.Port},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadNullSections(t *testing.T) {
+	files := map[string]string{"config.yaml": "storage:\n  db: null\nblobs:\n  s3: null\n"}
+
+	cfg, problems := load(t, files, valid(map[string]string{
+		"CONFIG_FILE": "config.yaml",
+		"DB_HOST":     "db.internal",
+	}))
+	if problems != nil {
+		t.Fatal(problems)
+	}
+
+	def := config.Default()
+
+	if cfg.Storage.DB == nil || cfg.Storage.DB.Host != "db.internal" || cfg.Storage.DB.MaxOpenConns != def.Storage.DB.MaxOpenConns {
+		t.Errorf("storage.db = %+v, want the defaults with DB_HOST", cfg.Storage.DB)
+	}
+	if cfg.Blob.S3 == nil || cfg.Blob.S3.Region != def.Blob.S3.Region {
+		t.Errorf("blobs.s3 = %+v, want the defaults", cfg.Blob.S3)
+	}
+}
+
+func TestLoadSecretFiles(t *testing.T) {
+	files := map[string]string{
+		"jwt":    secret + "\n",
+		"db":     "hunter2\r\n",
+		"metric": "token",
+	}
+
+	cfg, problems := load(t, files, valid(map[string]string{
+		"JWT_SECRET":         "",
+		"JWT_SECRET_FILE":    "$DIR/jwt",
+		"DB_PASS_FILE":       "$DIR/db",
+		"METRICS_TOKEN_FILE": "$DIR/metric",
+	}))
+	if problems != nil {
+		t.Fatal(problems)
+	}
+
+	if cfg.Auth.JWTSecret != secret {
+		t.Errorf("jwt secret = %q, want the file without its newline", cfg.Auth.JWTSecret)
+	}
+	if cfg.Storage.DB.Pass != "hunter2" {
+		t.Errorf("db pass = %q, want hunter2", cfg.Storage.DB.Pass)
+	}
+	if cfg.Metrics.Token != "token" {
+		t.Errorf("metrics token = %q, want token", cfg.Metrics.Token)
+	}
+
+	_, problems = load(t, files, valid(map[string]string{
+		"JWT_SECRET_FILE": "$DIR/jwt",
+		"DB_PASS_FILE":    "$DIR/missing",
+	}))
+
+	for _, want := range []string{
+		"JWT_SECRET and JWT_SECRET_FILE are both set",
+		"DB_PASS_FILE: ",
+	} {
+		if !slices.ContainsFunc(problems, func(p string) bool { return strings.HasPrefix(p, want) }) {
+			t.Errorf("problems %q lack %q", problems, want)
+		}
+	}
+}
+
+func TestLoadProblems(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": "server:\n  prot: 4000\nbids:\n  min_auction: soon\n",
+		".env":        "SESSION_TTL=-1h\n",
+	}
+
+	_, problems := load(t, files, map[string]string{
+		"CONFIG_FILE":                 "config.yaml",
+		"PORT":                        "http",
+		"STORAGE_BACKEND":             "mongo",
+		"COOKIE_SAMESITE":             "none",
+		"COOKIE_SECURE":               "false",
+		"OTEL_TRACES_EXPORTER":        "otlp",
+		"OTEL_EXPORTER_OTLP_ENDPOINT": "localhost:4318",
+		"IMAGE_WORKERS":               "0",
+	})
+
+	want := []string{
+		// the file
+		"config.yaml: line 2: field prot not found",
+		"config.yaml: line 4: cannot unmarshal !!str `soon`",
+		// the environment
+		`PORT: "http" is not a port`,
+		// validation, of what was read
+		"JWT_SECRET (auth.jwt_secret) must be at least 32 bytes",
+		"SESSION_TTL (auth.session_ttl) must be positive",
+		"COOKIE_SECURE (auth.cookie.secure) must be true",
+		"STORAGE_BACKEND (storage.backend) is \"mongo\"",
+		"OTEL_EXPORTER_OTLP_ENDPOINT (tracing.endpoint) is \"localhost:4318\"",
+		"BLOB_URL_SECRET (blobs.url_secret) must be at least 32 bytes",
+		"IMAGE_WORKERS (images.workers) must be positive",
+	}
+
+	for _, w := range want {
+		if !slices.ContainsFunc(problems, func(p string) bool { return strings.HasPrefix(p, w) }) {
+			t.Errorf("problems lack %q", w)
+		}
+	}
+
+	if len(problems) != len(want) {
+		t.Errorf("%d problems, want %d:\n%s", len(problems), len(want), strings.Join(problems, "\n"))
+	}
+}
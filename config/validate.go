@@ -0,0 +1,196 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// minJWTSecret is the shortest JWT secret accepted, the size of the
+// HS256 hash.
+const minJWTSecret = 32
+
+// minURLSecret is the shortest secret accepted for signing links to blobs,
+// the size of the HMAC-SHA256 hash.
+const minURLSecret = 32
+
+type problems []string
+
+// add records a problem with a setting, named by its variable and its key
+// in the YAML file.
+func (p *problems) add(env string, key string, format string, args ...any) {
+	*p = append(*p, fmt.Sprintf("%s (%s) ", env, key)+fmt.Sprintf(format, args...))
+}
+
+func (p *problems) oneOf(env string, key string, v string, allowed ...string) {
+	if !slices.Contains(allowed, v) {
+		p.add(env, key, "is %q, want one of %q", v, allowed)
+	}
+}
+
+func (p *problems) positive(env string, key string, d time.Duration) {
+	if d <= 0 {
+		p.add(env, key, "must be positive, is %s", d)
+	}
+}
+
+func (p *problems) url(env string, key string, v string) {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		p.add(env, key, "is %q, want an http or https URL", v)
+	}
+}
+
+// validate returns every problem with the settings.
+func (c *Config) validate() []string {
+	var p problems
+
+	s := c.Server
+	if s.Port < 1 || s.Port > 65535 {
+		p.add("PORT", "server.port", "is %d, want 1 to 65535", s.Port)
+	}
+	p.positive("SERVER_READ_TIMEOUT", "server.read_timeout", s.ReadTimeout)
+	p.positive("SERVER_WRITE_TIMEOUT", "server.write_timeout", s.WriteTimeout)
+	p.positive("SERVER_IDLE_TIMEOUT", "server.idle_timeout", s.IdleTimeout)
+	p.positive("REQUEST_TIMEOUT", "server.request_timeout", s.RequestTimeout)
+	p.positive("SHUTDOWN_TIMEOUT", "server.shutdown_timeout", s.ShutdownTimeout)
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			// credentials are allowed, which rules out *
+			p.add("CORS_ALLOWED_ORIGINS", "cors.allowed_origins", "has %q, want scheme://host[:port]", origin)
+		}
+	}
+
+	a := c.Auth
+	if len(a.JWTSecret) < minJWTSecret {
+		p.add("JWT_SECRET", "auth.jwt_secret", "must be at least %d bytes", minJWTSecret)
+	}
+	p.positive("SESSION_TTL", "auth.session_ttl", a.SessionTTL)
+	p.oneOf("COOKIE_SAMESITE", "auth.cookie.same_site", a.Cookie.SameSite, "lax", "strict", "none")
+	if a.Cookie.SameSite == "none" && !a.Cookie.Secure {
+		p.add("COOKIE_SECURE", "auth.cookie.secure", "must be true when COOKIE_SAMESITE is none, or browsers drop the cookie")
+	}
+	if len(a.Cookie.Path) == 0 || a.Cookie.Path[0] != '/' {
+		p.add("COOKIE_PATH", "auth.cookie.path", "is %q, want an absolute path such as /", a.Cookie.Path)
+	}
+
+	st := c.Storage
+	p.oneOf("STORAGE_BACKEND", "storage.backend", st.Backend, "postgres", "sqlite", "memory")
+
+	db := st.DB
+	if st.Backend == "postgres" {
+		for _, f := range []struct{ env, key, v string }{
+			{"DB_HOST", "storage.db.host", db.Host},
+			{"DB_USER", "storage.db.user", db.User},
+			{"DB_NAME", "storage.db.name", db.Name},
+		} {
+			if f.v == "" {
+				p.add(f.env, f.key, "is required for the postgres backend")
+			}
+		}
+
+		p.oneOf("DB_SSLMODE", "storage.db.ssl_mode", db.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	}
+	if st.Backend == "sqlite" && st.SQLitePath == "" {
+		p.add("SQLITE_PATH", "storage.sqlite_path", "is required for the sqlite backend")
+	}
+	if db.MaxOpenConns < 0 {
+		p.add("DB_MAX_OPEN_CONNS", "storage.db.max_open_conns", "must not be negative")
+	}
+	if db.MaxIdleConns < 0 {
+		p.add("DB_MAX_IDLE_CONNS", "storage.db.max_idle_conns", "must not be negative")
+	}
+	if db.ConnMaxLifetime < 0 {
+		p.add("DB_CONN_MAX_LIFETIME", "storage.db.conn_max_lifetime", "must not be negative")
+	}
+	if db.ConnMaxIdleTime < 0 {
+		p.add("DB_CONN_MAX_IDLE_TIME", "storage.db.conn_max_idle_time", "must not be negative")
+	}
+
+	t := c.Tracing
+	p.oneOf("OTEL_TRACES_EXPORTER", "tracing.exporter", t.Exporter, "none", "otlp", "console")
+	if t.Exporter == "otlp" {
+		p.url("OTEL_EXPORTER_OTLP_ENDPOINT", "tracing.endpoint", t.Endpoint)
+	}
+	if t.Ratio < 0 || t.Ratio > 1 {
+		p.add("OTEL_TRACES_SAMPLER_ARG", "tracing.ratio", "is %g, want 0 to 1", t.Ratio)
+	}
+
+	seen := make(map[string]bool)
+	for _, pr := range c.OIDC.Providers {
+		prefix := "OIDC_" + strings.ToUpper(pr.Name) + "_"
+		key := "oidc.providers[" + pr.Name + "]."
+
+		if pr.Name == "" {
+			p.add("OIDC_PROVIDERS", "oidc.providers", "has a provider without a name")
+			continue
+		}
+		if seen[pr.Name] {
+			p.add("OIDC_PROVIDERS", "oidc.providers", "has %s twice", pr.Name)
+		}
+		seen[pr.Name] = true
+
+		if pr.ClientID == "" {
+			p.add(prefix+"CLIENT_ID", key+"client_id", "is required")
+		}
+		if pr.RedirectURL == "" {
+			p.add(prefix+"REDIRECT_URL", key+"redirect_url", "is required")
+		}
+		if pr.Issuer == "" && (pr.AuthURL == "" || pr.TokenURL == "" || pr.JWKSURL == "") {
+			p.add(prefix+"ISSUER", key+"issuer", "is required unless the auth, token and JWKS URLs are all set")
+		}
+	}
+
+	b := c.Bid
+	p.positive("AUCTION_MIN_DURATION", "bids.min_auction", b.MinAuction)
+	if b.MaxAuction < b.MinAuction {
+		p.add("AUCTION_MAX_DURATION", "bids.max_auction", "is %s, shorter than the minimum of %s", b.MaxAuction, b.MinAuction)
+	}
+	if b.RetractWindow < 0 {
+		p.add("BID_RETRACT_WINDOW", "bids.retract_window", "must not be negative")
+	}
+	if b.RetractClosing < 0 {
+		p.add("BID_RETRACT_CLOSING", "bids.retract_closing", "must not be negative")
+	}
+	if b.MaxRetractions < 0 {
+		p.add("BID_RETRACT_MAX", "bids.max_retractions", "must not be negative")
+	}
+	p.positive("BID_RETRACT_PERIOD", "bids.retract_period", b.RetractPeriod)
+
+	bl := c.Blob
+	p.oneOf("BLOB_BACKEND", "blobs.backend", bl.Backend, "local", "s3")
+	if bl.Backend == "local" && bl.Dir == "" {
+		p.add("BLOB_DIR", "blobs.dir", "is required for the local backend")
+	}
+	if bl.Backend == "local" && len(bl.URLSecret) < minURLSecret {
+		// every replica must sign links alike, and keep doing so across restarts
+		p.add("BLOB_URL_SECRET", "blobs.url_secret", "must be at least %d bytes for the local backend", minURLSecret)
+	}
+	if bl.Backend == "s3" {
+		p.url("S3_ENDPOINT", "blobs.s3.endpoint", bl.S3.Endpoint)
+		if bl.S3.Bucket == "" {
+			p.add("S3_BUCKET", "blobs.s3.bucket", "is required for the s3 backend")
+		}
+		if bl.S3.AccessKey == "" || bl.S3.SecretKey == "" {
+			p.add("S3_ACCESS_KEY", "blobs.s3.access_key", "and S3_SECRET_KEY are required for the s3 backend")
+		}
+	}
+	p.positive("BLOB_URL_TTL", "blobs.url_ttl", bl.URLTTL)
+
+	i := c.Image
+	if i.MaxBytes <= 0 {
+		p.add("IMAGE_MAX_BYTES", "images.max_bytes", "must be positive")
+	}
+	if i.MaxCount <= 0 {
+		p.add("IMAGE_MAX_COUNT", "images.max_count", "must be positive")
+	}
+	if i.Workers <= 0 {
+		p.add("IMAGE_WORKERS", "images.workers", "must be positive")
+	}
+
+	return p
+}
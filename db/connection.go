@@ -2,88 +2,80 @@ package db
 
 import (
 	"database/sql"
-	"fmt"
+	"net/url"
+	"strings"
 
 	"github.com/Nier704/arthur-leilao-server/config"
+	"github.com/Nier704/arthur-leilao-server/internal/utils"
 	_ "github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
 )
 
-func NewPostgreConnection() (*sql.DB, error) {
-	cfg := config.NewDBConfig()
-
-	dns := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Host, cfg.Port, cfg.User, cfg.Pass, cfg.Name)
+// sqliteDriver is go-sqlite3 with fold(text), which SQLite lacks, added to
+// every connection. Product search uses it to match words regardless of
+// case and accents.
+const sqliteDriver = "sqlite3_leilao"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("fold", utils.Fold, true)
+		},
+	})
+}
 
-	db, err := sql.Open("postgres", dns)
-	if err != nil {
-		return nil, err
+// NewPostgreConnection opens the database cfg describes.
+func NewPostgreConnection(cfg *config.DBConfig) (*sql.DB, error) {
+	params := []string{
+		"host=" + quoteDSN(cfg.Host),
+		"port=" + quoteDSN(cfg.Port),
+		"user=" + quoteDSN(cfg.User),
+		"password=" + quoteDSN(cfg.Pass),
+		"dbname=" + quoteDSN(cfg.Name),
+		"sslmode=" + quoteDSN(cfg.SSLMode),
+	}
+	if cfg.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+quoteDSN(cfg.SSLRootCert))
 	}
 
-	if err = createTable(db); err != nil {
+	db, err := sql.Open("postgres", strings.Join(params, " "))
+	if err != nil {
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func createTable(db *sql.DB) error {
-	sql := `CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`
-	if _, err := db.Exec(sql); err != nil {
-		return err
-	}
-
-	sql = `
-	CREATE TABLE IF NOT EXISTS accounts (
-		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
-		username VARCHAR(30) NOT NULL,
-		password VARCHAR(255) NOT NULL,
-		UNIQUE(username)
-	);`
-
-	if _, err := db.Exec(sql); err != nil {
-		return err
-	}
-
-	sql = `
-	CREATE TABLE IF NOT EXISTS products (
-		id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
-		account_id UUID NOT NULL,
-		title VARCHAR(255) NOT NULL,
-		description VARCHAR(255) NOT NULL,
-		price NUMERIC(7, 2) NOT NULL,
-		image_url TEXT
-	);`
-
-	if _, err := db.Exec(sql); err != nil {
-		return err
-	}
+// quoteDSN quotes a value of a key=value connection string, so passwords
+// may hold spaces and quotes.
+func quoteDSN(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
 
-	sql = `
-	CREATE TABLE IF NOT EXISTS account_product (
-		account_id UUID NOT NULL,
-		product_id UUID NOT NULL,
-		PRIMARY KEY (account_id, product_id),
-		FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
-		FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
-	);`
+// ConfigurePool applies the pool settings of cfg to db.
+func ConfigurePool(db *sql.DB, cfg *config.DBConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
 
-	if _, err := db.Exec(sql); err != nil {
-		return err
+// NewSQLiteConnection opens the database file at path. Foreign keys are off
+// by default in SQLite and must be enabled on every connection, and
+// transactions take the write lock up front so read-then-write transactions
+// wait for each other instead of failing with SQLITE_BUSY.
+func NewSQLiteConnection(path string) (*sql.DB, error) {
+	q := url.Values{
+		"_foreign_keys": {"on"},
+		"_journal_mode": {"WAL"},
+		"_busy_timeout": {"5000"},
+		"_txlock":       {"immediate"},
 	}
 
-	sql = `
-	CREATE TABLE IF NOT EXISTS account_bid (
-		account_id UUID NOT NULL,
-		product_id UUID NOT NULL,
-		bid_value NUMERIC(5, 2) NOT NULL,
-		bid_message TEXT NOT NULL,
-		FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
-		FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
-	);`
-
-	if _, err := db.Exec(sql); err != nil {
-		return err
+	db, err := sql.Open(sqliteDriver, "file:"+path+"?"+q.Encode())
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return db, nil
 }
@@ -0,0 +1,60 @@
+-- Baseline matching the tables that used to be created on boot, so
+-- databases created before migrations existed are adopted as-is.
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE IF NOT EXISTS accounts (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+	username VARCHAR(30) NOT NULL,
+	password VARCHAR(255) NOT NULL,
+	UNIQUE(username)
+);
+
+CREATE TABLE IF NOT EXISTS products (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+	account_id UUID NOT NULL,
+	title VARCHAR(255) NOT NULL,
+	description VARCHAR(255) NOT NULL,
+	price NUMERIC(7, 2) NOT NULL,
+	image_url TEXT
+);
+
+CREATE TABLE IF NOT EXISTS account_product (
+	account_id UUID NOT NULL,
+	product_id UUID NOT NULL,
+	PRIMARY KEY (account_id, product_id),
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS account_bid (
+	account_id UUID NOT NULL,
+	product_id UUID NOT NULL,
+	bid_value NUMERIC(5, 2) NOT NULL,
+	bid_message TEXT NOT NULL,
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+	account_id UUID NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	prefix VARCHAR(20) NOT NULL,
+	hash CHAR(64) NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ,
+	UNIQUE(hash),
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS account_identities (
+	provider VARCHAR(50) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	account_id UUID NOT NULL,
+	email VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (provider, subject),
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
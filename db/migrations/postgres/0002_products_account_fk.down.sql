@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS account_bid_account_id_idx;
+DROP INDEX IF EXISTS account_bid_product_id_idx;
+DROP INDEX IF EXISTS products_account_id_idx;
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_account_id_fkey;
@@ -0,0 +1,27 @@
+-- products.account_id never had a foreign key, so listings of deleted
+-- accounts may have been left behind. They are not deleted here: the
+-- migration stops and lists them, to be removed or given to an existing
+-- account by hand before running it again.
+DO $$
+DECLARE
+	orphans TEXT;
+BEGIN
+	SELECT string_agg(format('%s (account %s)', p.id, p.account_id), ', ' ORDER BY p.id)
+	INTO orphans
+	FROM products p
+	WHERE NOT EXISTS (SELECT 1 FROM accounts a WHERE a.id = p.account_id);
+
+	IF orphans IS NOT NULL THEN
+		RAISE EXCEPTION 'products of accounts that no longer exist: %', orphans
+			USING HINT = 'Delete these products, or UPDATE their account_id to an existing account, then migrate again.';
+	END IF;
+END
+$$;
+
+ALTER TABLE products
+	ADD CONSTRAINT products_account_id_fkey
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE;
+
+CREATE INDEX products_account_id_idx ON products (account_id);
+CREATE INDEX account_bid_product_id_idx ON account_bid (product_id);
+CREATE INDEX account_bid_account_id_idx ON account_bid (account_id);
@@ -0,0 +1,11 @@
+-- Amounts that no longer fit the old precision make this fail rather than
+-- being truncated.
+ALTER TABLE account_bid DROP COLUMN currency;
+ALTER TABLE account_bid RENAME COLUMN bid_minor TO bid_value;
+ALTER TABLE account_bid
+	ALTER COLUMN bid_value TYPE NUMERIC(5,2) USING bid_value / 100.0;
+
+ALTER TABLE products DROP COLUMN currency;
+ALTER TABLE products RENAME COLUMN price_minor TO price;
+ALTER TABLE products
+	ALTER COLUMN price TYPE NUMERIC(7,2) USING price / 100.0;
@@ -0,0 +1,12 @@
+-- Money is stored as integer minor units (centavos for BRL) next to its
+-- ISO 4217 currency code, instead of NUMERIC read back into float64.
+-- BIGINT also lifts the NUMERIC(7,2) and NUMERIC(5,2) ceilings.
+ALTER TABLE products
+	ALTER COLUMN price TYPE BIGINT USING ROUND(price * 100)::BIGINT;
+ALTER TABLE products RENAME COLUMN price TO price_minor;
+ALTER TABLE products ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'BRL';
+
+ALTER TABLE account_bid
+	ALTER COLUMN bid_value TYPE BIGINT USING ROUND(bid_value * 100)::BIGINT;
+ALTER TABLE account_bid RENAME COLUMN bid_value TO bid_minor;
+ALTER TABLE account_bid ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'BRL';
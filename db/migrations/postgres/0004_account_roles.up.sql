@@ -0,0 +1 @@
+ALTER TABLE accounts ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
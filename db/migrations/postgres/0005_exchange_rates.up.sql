@@ -0,0 +1,9 @@
+-- One unit of base is worth rate units of quote. Rates are maintained by
+-- admins; the reverse direction is derived when only one is stored.
+CREATE TABLE exchange_rates (
+	base CHAR(3) NOT NULL,
+	quote CHAR(3) NOT NULL,
+	rate NUMERIC(24,10) NOT NULL CHECK (rate > 0),
+	updated_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (base, quote)
+);
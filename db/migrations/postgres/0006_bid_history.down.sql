@@ -0,0 +1,10 @@
+DROP INDEX account_bid_history_idx;
+DROP INDEX account_bid_active_idx;
+
+ALTER TABLE account_bid DROP CONSTRAINT account_bid_pkey;
+ALTER TABLE account_bid
+	DROP COLUMN user_agent,
+	DROP COLUMN ip,
+	DROP COLUMN status,
+	DROP COLUMN created_at,
+	DROP COLUMN id;
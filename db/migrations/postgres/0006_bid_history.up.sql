@@ -0,0 +1,24 @@
+-- Bids become records of their own. Existing bids get fresh ids and, since
+-- nobody knows when they were placed, the migration time.
+ALTER TABLE account_bid
+	ADD COLUMN id UUID NOT NULL DEFAULT uuid_generate_v4(),
+	ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'outbid',
+	ADD COLUMN ip VARCHAR(45) NOT NULL DEFAULT '',
+	ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE account_bid ADD PRIMARY KEY (id);
+ALTER TABLE account_bid ALTER COLUMN status DROP DEFAULT;
+
+-- the highest existing bid on each product is the standing one
+UPDATE account_bid b
+SET status = 'active'
+WHERE b.id = (
+	SELECT x.id FROM account_bid x
+	WHERE x.product_id = b.product_id
+	ORDER BY x.bid_minor DESC, x.id
+	LIMIT 1
+);
+
+CREATE UNIQUE INDEX account_bid_active_idx ON account_bid (product_id) WHERE status = 'active';
+CREATE INDEX account_bid_history_idx ON account_bid (product_id, created_at DESC, id DESC);
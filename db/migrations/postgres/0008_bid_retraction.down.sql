@@ -0,0 +1,5 @@
+DROP INDEX account_bid_retracted_idx;
+
+ALTER TABLE account_bid
+	DROP COLUMN retraction_reason,
+	DROP COLUMN retracted_at;
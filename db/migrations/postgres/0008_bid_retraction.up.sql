@@ -0,0 +1,5 @@
+ALTER TABLE account_bid
+	ADD COLUMN retracted_at TIMESTAMPTZ,
+	ADD COLUMN retraction_reason TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX account_bid_retracted_idx ON account_bid (account_id, retracted_at) WHERE retracted_at IS NOT NULL;
@@ -0,0 +1,10 @@
+CREATE TABLE notifications (
+	id UUID PRIMARY KEY,
+	account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+	kind VARCHAR(50) NOT NULL,
+	message TEXT NOT NULL,
+	product_id UUID,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX notifications_account_id_idx ON notifications (account_id, created_at DESC);
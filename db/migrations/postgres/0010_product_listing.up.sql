@@ -0,0 +1,7 @@
+-- Listings are sorted by age, so products learn when they were created.
+-- Existing products get the migration time.
+ALTER TABLE products ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE INDEX products_created_at_idx ON products (created_at DESC, id DESC);
+CREATE INDEX products_ends_at_idx ON products (ends_at, id) WHERE ends_at IS NOT NULL;
+CREATE INDEX products_price_idx ON products (currency, price_minor, id);
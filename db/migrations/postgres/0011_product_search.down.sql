@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS products_search_idx;
+DROP TRIGGER IF EXISTS products_search_update ON products;
+DROP FUNCTION IF EXISTS products_search_update();
+
+ALTER TABLE products DROP COLUMN search;
+
+DROP TEXT SEARCH CONFIGURATION IF EXISTS portuguese_unaccent;
+DROP EXTENSION IF EXISTS unaccent;
@@ -0,0 +1,33 @@
+-- Full-text search over products. unaccent runs before the portuguese
+-- stemmer, so "eletrica" finds "Guitarras elétricas". The search column
+-- weighs the title over the description and is kept up to date by a
+-- trigger.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE TEXT SEARCH CONFIGURATION portuguese_unaccent (COPY = portuguese);
+
+ALTER TEXT SEARCH CONFIGURATION portuguese_unaccent
+	ALTER MAPPING FOR hword, hword_part, word
+	WITH unaccent, portuguese_stem;
+
+ALTER TABLE products ADD COLUMN search TSVECTOR;
+
+CREATE FUNCTION products_search_update() RETURNS TRIGGER AS $$
+BEGIN
+	NEW.search :=
+		setweight(to_tsvector('portuguese_unaccent', COALESCE(NEW.title, '')), 'A') ||
+		setweight(to_tsvector('portuguese_unaccent', COALESCE(NEW.description, '')), 'B');
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER products_search_update
+	BEFORE INSERT OR UPDATE OF title, description ON products
+	FOR EACH ROW EXECUTE FUNCTION products_search_update();
+
+-- fires the trigger for the existing products
+UPDATE products SET title = title;
+
+ALTER TABLE products ALTER COLUMN search SET NOT NULL;
+
+CREATE INDEX products_search_idx ON products USING GIN (search);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS product_tags;
+DROP TABLE IF EXISTS tags;
+
+DROP INDEX IF EXISTS products_category_id_idx;
+ALTER TABLE products DROP COLUMN category_id;
+
+DROP TABLE IF EXISTS categories;
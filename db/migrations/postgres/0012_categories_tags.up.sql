@@ -0,0 +1,28 @@
+-- A category tree managed by admins, with products in its leaves, and
+-- free-form tags shared by products.
+CREATE TABLE categories (
+	id UUID PRIMARY KEY,
+	parent_id UUID REFERENCES categories(id),
+	name VARCHAR(100) NOT NULL
+);
+
+-- sibling names are unique regardless of case
+CREATE UNIQUE INDEX categories_name_idx ON categories (parent_id, lower(name)) WHERE parent_id IS NOT NULL;
+CREATE UNIQUE INDEX categories_root_name_idx ON categories (lower(name)) WHERE parent_id IS NULL;
+
+ALTER TABLE products ADD COLUMN category_id UUID REFERENCES categories(id);
+
+CREATE INDEX products_category_id_idx ON products (category_id);
+
+CREATE TABLE tags (
+	id UUID PRIMARY KEY,
+	name VARCHAR(30) NOT NULL UNIQUE
+);
+
+CREATE TABLE product_tags (
+	product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+	PRIMARY KEY (product_id, tag_id)
+);
+
+CREATE INDEX product_tags_tag_id_idx ON product_tags (tag_id);
@@ -0,0 +1,12 @@
+-- Photos of products, kept in the blob store. Position orders them.
+CREATE TABLE product_images (
+	id UUID PRIMARY KEY,
+	product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	blob_key TEXT NOT NULL,
+	content_type VARCHAR(50) NOT NULL,
+	size BIGINT NOT NULL,
+	position INT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX product_images_product_id_idx ON product_images (product_id, position);
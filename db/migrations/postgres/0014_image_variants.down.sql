@@ -0,0 +1,6 @@
+ALTER TABLE products ADD COLUMN image_url TEXT;
+
+DROP TABLE IF EXISTS product_image_variants;
+
+DROP INDEX IF EXISTS product_images_status_idx;
+ALTER TABLE product_images DROP COLUMN status;
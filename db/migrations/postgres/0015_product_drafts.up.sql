@@ -0,0 +1,7 @@
+-- Products are saved as drafts and published once complete. Products that
+-- already exist stay listed.
+ALTER TABLE products ADD COLUMN published_at TIMESTAMPTZ;
+
+UPDATE products SET published_at = created_at;
+
+CREATE INDEX products_published_at_idx ON products (published_at);
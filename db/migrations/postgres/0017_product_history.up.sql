@@ -0,0 +1,29 @@
+-- Every change to a product is kept, with who made it. Products created
+-- before have no entry for their creation. Addenda are notes sellers append
+-- once bids lock the description and price.
+CREATE TABLE product_revisions (
+	id UUID PRIMARY KEY,
+	product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	editor_id UUID REFERENCES accounts(id) ON DELETE SET NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX product_revisions_product_id_idx ON product_revisions (product_id, created_at);
+
+CREATE TABLE product_revision_changes (
+	revision_id UUID NOT NULL REFERENCES product_revisions(id) ON DELETE CASCADE,
+	position INT NOT NULL,
+	field VARCHAR(30) NOT NULL,
+	old_value TEXT NOT NULL,
+	new_value TEXT NOT NULL,
+	PRIMARY KEY (revision_id, position)
+);
+
+CREATE TABLE product_addenda (
+	id UUID PRIMARY KEY,
+	product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+	text TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX product_addenda_product_id_idx ON product_addenda (product_id, created_at);
@@ -0,0 +1,3 @@
+-- Each resized copy is also stored as WebP, for the browsers that accept
+-- it. Copies made before have none.
+ALTER TABLE product_image_variants ADD COLUMN webp_key TEXT;
@@ -0,0 +1,13 @@
+UPDATE account_bid SET bid_minor = bid_minor * 100
+WHERE currency IN ('BIF', 'DJF', 'GNF', 'ISK', 'KMF', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF');
+UPDATE account_bid SET bid_minor = ROUND(bid_minor / 100.0)::BIGINT
+WHERE currency IN ('CLF', 'UYW');
+UPDATE account_bid SET bid_minor = ROUND(bid_minor / 10.0)::BIGINT
+WHERE currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND');
+
+UPDATE products SET price_minor = price_minor * 100
+WHERE currency IN ('BIF', 'DJF', 'GNF', 'ISK', 'KMF', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF');
+UPDATE products SET price_minor = ROUND(price_minor / 100.0)::BIGINT
+WHERE currency IN ('CLF', 'UYW');
+UPDATE products SET price_minor = ROUND(price_minor / 10.0)::BIGINT
+WHERE currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND');
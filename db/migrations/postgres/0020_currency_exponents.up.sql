@@ -0,0 +1,17 @@
+-- Amounts were stored in hundredths for every currency but CLP, JPY, KRW
+-- and PYG. They now follow the ISO 4217 exponent of their currency, so the
+-- ones stored before are rescaled. Currencies without minor units round
+-- to whole units.
+UPDATE products SET price_minor = price_minor * 10
+WHERE currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND');
+UPDATE products SET price_minor = price_minor * 100
+WHERE currency IN ('CLF', 'UYW');
+UPDATE products SET price_minor = ROUND(price_minor / 100.0)::BIGINT
+WHERE currency IN ('BIF', 'DJF', 'GNF', 'ISK', 'KMF', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF');
+
+UPDATE account_bid SET bid_minor = bid_minor * 10
+WHERE currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND');
+UPDATE account_bid SET bid_minor = bid_minor * 100
+WHERE currency IN ('CLF', 'UYW');
+UPDATE account_bid SET bid_minor = ROUND(bid_minor / 100.0)::BIGINT
+WHERE currency IN ('BIF', 'DJF', 'GNF', 'ISK', 'KMF', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF');
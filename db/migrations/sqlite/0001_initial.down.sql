@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS account_identities;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS account_bid;
+DROP TABLE IF EXISTS account_product;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS accounts;
@@ -0,0 +1,65 @@
+-- SQLite has no UUID type or generator: ids are TEXT and generated by the
+-- application. Money columns keep NUMERIC affinity and are read back rounded
+-- to cents.
+CREATE TABLE accounts (
+	id TEXT PRIMARY KEY,
+	username VARCHAR(30) NOT NULL,
+	password VARCHAR(255) NOT NULL,
+	UNIQUE(username)
+);
+
+CREATE TABLE products (
+	id TEXT PRIMARY KEY,
+	account_id TEXT NOT NULL,
+	title VARCHAR(255) NOT NULL,
+	description VARCHAR(255) NOT NULL,
+	price NUMERIC NOT NULL,
+	image_url TEXT,
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX products_account_id_idx ON products (account_id);
+
+CREATE TABLE account_product (
+	account_id TEXT NOT NULL,
+	product_id TEXT NOT NULL,
+	PRIMARY KEY (account_id, product_id),
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE TABLE account_bid (
+	account_id TEXT NOT NULL,
+	product_id TEXT NOT NULL,
+	bid_value NUMERIC NOT NULL,
+	bid_message TEXT NOT NULL,
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX account_bid_product_id_idx ON account_bid (product_id);
+CREATE INDEX account_bid_account_id_idx ON account_bid (account_id);
+
+CREATE TABLE api_keys (
+	id TEXT PRIMARY KEY,
+	account_id TEXT NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	prefix VARCHAR(20) NOT NULL,
+	hash CHAR(64) NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	UNIQUE(hash),
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE account_identities (
+	provider VARCHAR(50) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	account_id TEXT NOT NULL,
+	email VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (provider, subject),
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
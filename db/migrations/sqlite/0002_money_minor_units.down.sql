@@ -0,0 +1,9 @@
+ALTER TABLE account_bid ADD COLUMN bid_value NUMERIC NOT NULL DEFAULT 0;
+UPDATE account_bid SET bid_value = bid_minor / 100.0;
+ALTER TABLE account_bid DROP COLUMN bid_minor;
+ALTER TABLE account_bid DROP COLUMN currency;
+
+ALTER TABLE products ADD COLUMN price NUMERIC NOT NULL DEFAULT 0;
+UPDATE products SET price = price_minor / 100.0;
+ALTER TABLE products DROP COLUMN price_minor;
+ALTER TABLE products DROP COLUMN currency;
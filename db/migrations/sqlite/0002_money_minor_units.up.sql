@@ -0,0 +1,12 @@
+-- Money is stored as integer minor units next to its ISO 4217 currency code.
+-- SQLite cannot change a column's type, so the amounts are copied into new
+-- INTEGER columns and the NUMERIC ones dropped.
+ALTER TABLE products ADD COLUMN price_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE products ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'BRL';
+UPDATE products SET price_minor = CAST(ROUND(price * 100) AS INTEGER);
+ALTER TABLE products DROP COLUMN price;
+
+ALTER TABLE account_bid ADD COLUMN bid_minor INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE account_bid ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'BRL';
+UPDATE account_bid SET bid_minor = CAST(ROUND(bid_value * 100) AS INTEGER);
+ALTER TABLE account_bid DROP COLUMN bid_value;
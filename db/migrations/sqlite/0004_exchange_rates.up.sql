@@ -0,0 +1,9 @@
+-- One unit of base is worth rate units of quote. The rate is TEXT so SQLite
+-- keeps the exact decimal instead of converting it to a REAL.
+CREATE TABLE exchange_rates (
+	base CHAR(3) NOT NULL,
+	quote CHAR(3) NOT NULL,
+	rate TEXT NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (base, quote)
+);
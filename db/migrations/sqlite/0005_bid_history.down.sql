@@ -0,0 +1,18 @@
+CREATE TABLE account_bid_old (
+	account_id TEXT NOT NULL,
+	product_id TEXT NOT NULL,
+	bid_message TEXT NOT NULL,
+	bid_minor INTEGER NOT NULL DEFAULT 0,
+	currency CHAR(3) NOT NULL DEFAULT 'BRL',
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+INSERT INTO account_bid_old (account_id, product_id, bid_message, bid_minor, currency)
+SELECT account_id, product_id, bid_message, bid_minor, currency FROM account_bid;
+
+DROP TABLE account_bid;
+ALTER TABLE account_bid_old RENAME TO account_bid;
+
+CREATE INDEX account_bid_product_id_idx ON account_bid (product_id);
+CREATE INDEX account_bid_account_id_idx ON account_bid (account_id);
@@ -0,0 +1,41 @@
+-- Bids become records of their own. SQLite cannot add a primary key to an
+-- existing table, so account_bid is rebuilt. Existing bids get random ids
+-- and, since nobody knows when they were placed, the migration time.
+CREATE TABLE account_bid_new (
+	id TEXT PRIMARY KEY,
+	account_id TEXT NOT NULL,
+	product_id TEXT NOT NULL,
+	bid_minor INTEGER NOT NULL,
+	currency CHAR(3) NOT NULL,
+	bid_message TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	ip VARCHAR(45) NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+INSERT INTO account_bid_new
+(id, account_id, product_id, bid_minor, currency, bid_message, status, created_at)
+SELECT
+	lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(2)) || '-' || hex(randomblob(6))),
+	account_id, product_id, bid_minor, currency, bid_message, 'outbid', CURRENT_TIMESTAMP
+FROM account_bid;
+
+DROP TABLE account_bid;
+ALTER TABLE account_bid_new RENAME TO account_bid;
+
+-- the highest existing bid on each product is the standing one
+UPDATE account_bid
+SET status = 'active'
+WHERE id = (
+	SELECT x.id FROM account_bid x
+	WHERE x.product_id = account_bid.product_id
+	ORDER BY x.bid_minor DESC, x.id
+	LIMIT 1
+);
+
+CREATE INDEX account_bid_account_id_idx ON account_bid (account_id);
+CREATE UNIQUE INDEX account_bid_active_idx ON account_bid (product_id) WHERE status = 'active';
+CREATE INDEX account_bid_history_idx ON account_bid (product_id, created_at DESC, id DESC);
@@ -0,0 +1,2 @@
+-- When bidding on a product closes. NULL keeps the auction open.
+ALTER TABLE products ADD COLUMN ends_at TIMESTAMP;
@@ -0,0 +1,4 @@
+DROP INDEX account_bid_retracted_idx;
+
+ALTER TABLE account_bid DROP COLUMN retraction_reason;
+ALTER TABLE account_bid DROP COLUMN retracted_at;
@@ -0,0 +1,11 @@
+CREATE TABLE notifications (
+	id TEXT PRIMARY KEY,
+	account_id TEXT NOT NULL,
+	kind VARCHAR(50) NOT NULL,
+	message TEXT NOT NULL,
+	product_id TEXT,
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_account_id_idx ON notifications (account_id, created_at DESC);
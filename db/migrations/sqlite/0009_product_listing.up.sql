@@ -0,0 +1,11 @@
+-- Listings are sorted by age, so products learn when they were created.
+-- SQLite only adds columns with constant defaults; existing products get
+-- the migration time, written the way the driver writes times so that
+-- cursors compare equal to it.
+ALTER TABLE products ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+
+UPDATE products SET created_at = strftime('%Y-%m-%d %H:%M:%S+00:00', 'now');
+
+CREATE INDEX products_created_at_idx ON products (created_at DESC, id DESC);
+CREATE INDEX products_ends_at_idx ON products (ends_at, id) WHERE ends_at IS NOT NULL;
+CREATE INDEX products_price_idx ON products (currency, price_minor, id);
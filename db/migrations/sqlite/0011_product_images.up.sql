@@ -0,0 +1,13 @@
+-- Photos of products, kept in the blob store. Position orders them.
+CREATE TABLE product_images (
+	id TEXT PRIMARY KEY,
+	product_id TEXT NOT NULL,
+	blob_key TEXT NOT NULL,
+	content_type VARCHAR(50) NOT NULL,
+	size INTEGER NOT NULL,
+	position INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX product_images_product_id_idx ON product_images (product_id, position);
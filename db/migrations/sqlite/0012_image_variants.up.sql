@@ -0,0 +1,18 @@
+-- Uploads are processed into resized copies in the background. Images
+-- uploaded before are processed on the next start.
+ALTER TABLE product_images ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'processing';
+
+CREATE INDEX product_images_status_idx ON product_images (status);
+
+CREATE TABLE product_image_variants (
+	image_id TEXT NOT NULL REFERENCES product_images(id) ON DELETE CASCADE,
+	size VARCHAR(20) NOT NULL,
+	blob_key TEXT NOT NULL,
+	content_type VARCHAR(50) NOT NULL,
+	width INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	PRIMARY KEY (image_id, size)
+);
+
+-- product photos are uploaded now instead of linked
+ALTER TABLE products DROP COLUMN image_url;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS products_published_at_idx;
+ALTER TABLE products DROP COLUMN published_at;
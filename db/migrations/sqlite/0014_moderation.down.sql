@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS content_reports;
+
+DROP INDEX IF EXISTS products_review_status_idx;
+ALTER TABLE products DROP COLUMN review_reason;
+ALTER TABLE products DROP COLUMN review_status;
+
+ALTER TABLE accounts DROP COLUMN verified;
@@ -0,0 +1,32 @@
+-- Listings of unverified sellers are reviewed by a moderator before they
+-- are published, and any account can report a product or a bid message.
+ALTER TABLE accounts ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE products ADD COLUMN review_status VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE products ADD COLUMN review_reason TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX products_review_status_idx ON products (review_status);
+
+CREATE TABLE content_reports (
+	id TEXT PRIMARY KEY,
+	reporter_id TEXT NOT NULL,
+	product_id TEXT,
+	bid_id TEXT,
+	reason TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'open',
+	created_at TIMESTAMP NOT NULL,
+	resolved_by TEXT,
+	resolved_at TIMESTAMP,
+	note TEXT NOT NULL DEFAULT '',
+	CHECK ((product_id IS NULL) <> (bid_id IS NULL)),
+	FOREIGN KEY (reporter_id) REFERENCES accounts(id) ON DELETE CASCADE,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+	FOREIGN KEY (bid_id) REFERENCES account_bid(id) ON DELETE CASCADE,
+	FOREIGN KEY (resolved_by) REFERENCES accounts(id) ON DELETE SET NULL
+);
+
+-- an account has at most one open report on the same content
+CREATE UNIQUE INDEX content_reports_open_idx ON content_reports (reporter_id, COALESCE(product_id, bid_id)) WHERE status = 'open';
+CREATE INDEX content_reports_status_idx ON content_reports (status, created_at);
+CREATE INDEX content_reports_product_id_idx ON content_reports (product_id);
+CREATE INDEX content_reports_bid_id_idx ON content_reports (bid_id);
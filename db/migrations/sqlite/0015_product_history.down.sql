@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS product_addenda;
+DROP TABLE IF EXISTS product_revision_changes;
+DROP TABLE IF EXISTS product_revisions;
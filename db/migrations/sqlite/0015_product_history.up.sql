@@ -0,0 +1,33 @@
+-- Every change to a product is kept, with who made it. Products created
+-- before have no entry for their creation. Addenda are notes sellers append
+-- once bids lock the description and price.
+CREATE TABLE product_revisions (
+	id TEXT PRIMARY KEY,
+	product_id TEXT NOT NULL,
+	editor_id TEXT,
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE,
+	FOREIGN KEY (editor_id) REFERENCES accounts(id) ON DELETE SET NULL
+);
+
+CREATE INDEX product_revisions_product_id_idx ON product_revisions (product_id, created_at);
+
+CREATE TABLE product_revision_changes (
+	revision_id TEXT NOT NULL,
+	position INTEGER NOT NULL,
+	field VARCHAR(30) NOT NULL,
+	old_value TEXT NOT NULL,
+	new_value TEXT NOT NULL,
+	PRIMARY KEY (revision_id, position),
+	FOREIGN KEY (revision_id) REFERENCES product_revisions(id) ON DELETE CASCADE
+);
+
+CREATE TABLE product_addenda (
+	id TEXT PRIMARY KEY,
+	product_id TEXT NOT NULL,
+	text TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX product_addenda_product_id_idx ON product_addenda (product_id, created_at);
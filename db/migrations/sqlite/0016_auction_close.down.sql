@@ -0,0 +1,3 @@
+UPDATE account_bid SET status = 'active' WHERE status = 'winning';
+
+ALTER TABLE products DROP COLUMN closed_at;
@@ -0,0 +1,3 @@
+-- When the auction was closed and its active bid marked winning. NULL for
+-- drafts and auctions still running.
+ALTER TABLE products ADD COLUMN closed_at TIMESTAMP;
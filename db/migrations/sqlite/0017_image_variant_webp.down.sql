@@ -0,0 +1 @@
+ALTER TABLE product_image_variants DROP COLUMN webp_key;
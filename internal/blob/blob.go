@@ -0,0 +1,64 @@
+// Package blob stores uploaded files, such as product photos, outside the
+// database.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/config"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+)
+
+// BlobStore keeps blobs under slash separated keys like
+// "products/<id>/<image>.jpg".
+type BlobStore interface {
+	// Put stores the size bytes read from r under key, replacing any blob
+	// already there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns the blob under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Deleting a missing blob is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Presigner is implemented by stores clients can download from directly,
+// without going through the API.
+type Presigner interface {
+	// PresignGet returns a URL that downloads key until ttl has passed.
+	PresignGet(key string, ttl time.Duration) (string, error)
+}
+
+// Open returns the store the configuration asks for.
+func Open(cfg *config.BlobConfig) (BlobStore, error) {
+	switch cfg.Backend {
+	case "local":
+		return NewLocalStore(cfg.Dir)
+
+	case "s3":
+		return NewS3Store(cfg.S3)
+
+	default:
+		return nil, fmt.Errorf("unknown blob backend %q", cfg.Backend)
+	}
+}
+
+// validKey reports whether key is a clean relative path, so it cannot
+// escape the directory or bucket prefix it is stored under.
+func validKey(key string) bool {
+	return key != "" &&
+		!strings.HasPrefix(key, "/") &&
+		path.Clean(key) == key &&
+		key != ".." && !strings.HasPrefix(key, "../") &&
+		!strings.ContainsAny(key, "\\\x00")
+}
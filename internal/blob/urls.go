@@ -0,0 +1,57 @@
+package blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// URLs hands out expiring links to blobs. Stores implementing Presigner
+// link to themselves; the others are served by the API under Prefix, with
+// the expiry signed with Secret so links cannot be forged or extended.
+type URLs struct {
+	Store  BlobStore
+	Secret []byte
+	// Prefix is the path the API serves blobs under, e.g. "/api/images/".
+	Prefix string
+	TTL    time.Duration
+}
+
+// URL returns a link to key valid for TTL.
+func (u *URLs) URL(key string) (string, error) {
+	if p, ok := u.Store.(Presigner); ok {
+		return p.PresignGet(key, u.TTL)
+	}
+
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(u.TTL).Unix(), 10)
+
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("sig", u.sign(key, expires))
+
+	return u.Prefix + uriEncode(key, false) + "?" + q.Encode(), nil
+}
+
+// Verify reports whether expires and sig come from a link to key made by
+// URL that has not expired yet.
+func (u *URLs) Verify(key string, expires string, sig string) bool {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+
+	return hmac.Equal([]byte(sig), []byte(u.sign(key, expires)))
+}
+
+func (u *URLs) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, u.Secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,63 @@
+// Package buildinfo describes the running binary. Release builds set the
+// variables with the linker:
+//
+//	go build -ldflags "-X github.com/Nier704/arthur-leilao-server/internal/buildinfo.Version=v1.2.0 \
+//		-X github.com/Nier704/arthur-leilao-server/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//		-X github.com/Nier704/arthur-leilao-server/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// started is when the process started, near enough.
+var started = time.Now()
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information. The commit and build time fall back to
+// what the go command stamped from version control, when it did.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// Started returns when the process started.
+func Started() time.Time {
+	return started
+}
+
+// Uptime returns how long the process has been running.
+func Uptime() time.Duration {
+	return time.Since(started)
+}
@@ -1,9 +1,47 @@
 package account
 
-import "github.com/google/uuid"
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound      = errors.New("account not found")
+	ErrUsernameTaken = errors.New("username already taken")
+)
+
+const (
+	RoleUser = "user"
+	// RoleModerator reviews listings and reports. Admins may moderate too.
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
 
 type Account struct {
 	ID       uuid.UUID `json:"id"`
 	Username string    `json:"username"`
 	Password string    `json:"password"`
+	Role     string    `json:"role"`
+	// Verified sellers publish without waiting for a moderator.
+	Verified bool `json:"verified"`
+}
+
+type AccountRepository interface {
+	GetAll(ctx context.Context) ([]Account, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*Account, error)
+	GetByUsername(ctx context.Context, username string) (*Account, error)
+	// Create inserts acc and sets its ID, Role and Verified. New accounts
+	// are always unverified RoleUser. It returns ErrUsernameTaken when the
+	// username is in use.
+	Create(ctx context.Context, acc *Account) error
+	// Update changes the username and password; the role and verification
+	// are left alone.
+	Update(ctx context.Context, acc *Account) error
+	SetRole(ctx context.Context, id uuid.UUID, role string) error
+	SetVerified(ctx context.Context, id uuid.UUID, verified bool) error
+	Delete(ctx context.Context, id uuid.UUID) error
 }
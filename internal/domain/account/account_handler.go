@@ -1,65 +1,55 @@
 package account
 
 import (
-	"context"
-	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
 	"github.com/Nier704/arthur-leilao-server/internal/utils"
+	"github.com/google/uuid"
 )
 
 type AccountHandler struct {
-	DB *sql.DB
+	Repo AccountRepository
 }
 
-func NewAccountHandler(db *sql.DB) *AccountHandler {
+func NewAccountHandler(repo AccountRepository) *AccountHandler {
 	return &AccountHandler{
-		DB: db,
+		Repo: repo,
 	}
 }
 
 func (h *AccountHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	logger := middlewares.Logger(r.Context())
 
-	id := r.PathValue("accountId")
+	w.Header().Set("Content-Type", "application/json")
 
-	if id == "" {
-		log.Println("Invalid id")
-		http.Error(w, "invalid id", 400)
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	sql := `DELETE FROM accounts WHERE id = $1`
-
-	ctx := context.Background()
-
-	stmt, err := h.DB.PrepareContext(ctx, sql)
+	id, err := uuid.Parse(r.PathValue("accountId"))
 	if err != nil {
-		log.Printf("Error preparing statement: %v", err)
-		http.Error(w, "error preparing statement", http.StatusInternalServerError)
+		logger.Info("invalid id")
+		http.Error(w, "invalid id", 400)
 		return
 	}
-	defer stmt.Close()
 
-	result, err := stmt.ExecContext(ctx, id)
-	if err != nil {
-		log.Printf("Error deleting account: %v", err)
-		http.Error(w, "not found", http.StatusNotFound)
+	if id != principal.AccountID && principal.Role != RoleAdmin {
+		logger.Info("account is not the caller's", "path_account_id", id)
+		http.Error(w, "you can only delete your own account", http.StatusForbidden)
 		return
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		log.Printf("Error getting rows affected: %v", err)
-		http.Error(w, "error getting rows affected", http.StatusInternalServerError)
-		return
-	}
+	ctx := r.Context()
 
-	if rows > 1 {
-		log.Printf("Error more than one line was affected: %v", err)
-		http.Error(w, "error: more than one line was affected", http.StatusInternalServerError)
+	if err = h.Repo.Delete(ctx, id); err != nil {
+		logger.Info("error deleting account", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
 
@@ -70,25 +60,39 @@ func (h *AccountHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 
 	if err := json.NewEncoder(w).Encode(res); err != nil {
-		log.Printf("Error encoding response: %v", err)
+		logger.Error("error encoding response", "err", err)
 		http.Error(w, "error encoding response", 500)
 	}
 }
 
 func (h *AccountHandler) Update(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
 
-	id := r.PathValue("accountId")
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	if id == "" {
-		log.Println("Invalid id")
+	id, err := uuid.Parse(r.PathValue("accountId"))
+	if err != nil {
+		logger.Info("invalid id")
 		http.Error(w, "invalid id", 400)
 		return
 	}
 
+	if id != principal.AccountID && principal.Role != RoleAdmin {
+		logger.Info("account is not the caller's", "path_account_id", id)
+		http.Error(w, "you can only change your own account", http.StatusForbidden)
+		return
+	}
+
 	var body Account
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		log.Printf("Error decoding body: %v", err)
+		logger.Error("error decoding body", "err", err)
 		http.Error(w, "Error decoding body", 500)
 		return
 	}
@@ -97,34 +101,27 @@ func (h *AccountHandler) Update(w http.ResponseWriter, r *http.Request) {
 
 		hash, err := utils.HashPassword(body.Password)
 		if err != nil {
-			log.Printf("Error generating encrypted password: %v", err)
+			logger.Error("error generating encrypted password", "err", err)
 			http.Error(w, "Error generating encrypted password", 500)
 			return
 		}
 
-		body.Password = string(hash)
-
-		sql := `
-		UPDATE users
-		SET username = $1,
-				password = $2
-		WHERE id = $3
-		RETURNING id, username, password;
-	`
-		ctx := context.Background()
-
-		stmt, err := h.DB.PrepareContext(ctx, sql)
-		if err != nil {
-			log.Printf("Error preparing statement: %v", err)
-			http.Error(w, "error preparing statement", http.StatusInternalServerError)
-			return
+		acc := Account{
+			ID:       id,
+			Username: body.Username,
+			Password: string(hash),
 		}
-		defer stmt.Close()
 
-		var acc Account
+		ctx := r.Context()
 
-		if err = stmt.QueryRowContext(ctx, body.Username, body.Password).Scan(&acc.ID, &acc.Username, &acc.Password); err != nil {
-			log.Printf("not found: %v", err)
+		if err = h.Repo.Update(ctx, &acc); err != nil {
+			if errors.Is(err, ErrUsernameTaken) {
+				logger.Info("username taken", "err", err)
+				http.Error(w, "username already taken", http.StatusConflict)
+				return
+			}
+
+			logger.Info("not found", "err", err)
 			http.Error(w, "not found", 404)
 			return
 		}
@@ -132,7 +129,7 @@ func (h *AccountHandler) Update(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
 
 		if err := json.NewEncoder(w).Encode(acc); err != nil {
-			log.Printf("Error encoding response: %v", err)
+			logger.Error("error encoding response", "err", err)
 			http.Error(w, "error encoding response", 500)
 			return
 		}
@@ -140,87 +137,107 @@ func (h *AccountHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Invalid credentials")
+	logger.Info("invalid credentials")
 	http.Error(w, "invalid credentials", http.StatusBadRequest)
 }
 
 func (h *AccountHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
 
-	sql := `
-		SELECT * FROM accounts;
-	`
-	ctx := context.Background()
+	ctx := r.Context()
 
-	stmt, err := h.DB.PrepareContext(ctx, sql)
+	accounts, err := h.Repo.GetAll(ctx)
 	if err != nil {
-		log.Printf("Error preparing context: %v", err)
-		http.Error(w, "error preparing context", http.StatusInternalServerError)
+		logger.Error("error getting all accounts", "err", err)
+		http.Error(w, "error getting accounts", http.StatusInternalServerError)
 		return
 	}
 
-	accounts := make([]Account, 0)
-	rows, err := stmt.QueryContext(ctx)
+	w.WriteHeader(200)
+
+	if err := json.NewEncoder(w).Encode(accounts); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *AccountHandler) GetById(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.PathValue("accountId"))
 	if err != nil {
-		log.Printf("Error getting all accounts: %v", err)
-		http.Error(w, "error getting accounts", http.StatusInternalServerError)
+		logger.Info("invalid id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
 
-	for rows.Next() {
-		var acc Account
-		if err = rows.Scan(&acc.ID, &acc.Username, &acc.Password); err != nil {
-			log.Printf("Error scanning account: %v", err)
-			http.Error(w, "error scanning account", http.StatusInternalServerError)
-			break
-		}
-		accounts = append(accounts, acc)
+	ctx := r.Context()
+
+	acc, err := h.Repo.GetByID(ctx, id)
+	if err != nil {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
 	}
 
 	w.WriteHeader(200)
 
-	if err := json.NewEncoder(w).Encode(accounts); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(acc); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", 500)
 	}
 }
 
-func (h *AccountHandler) GetById(w http.ResponseWriter, r *http.Request) {
+// SetVerified marks an account as a verified seller, or takes that away.
+func (h *AccountHandler) SetVerified(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
 
-	id := r.PathValue("accountId")
-	if id == "" {
-		log.Printf("Invalid id")
+	id, err := uuid.Parse(r.PathValue("accountId"))
+	if err != nil {
+		logger.Info("invalid id")
 		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
 
-	sql := `
-		SELECT * FROM accounts WHERE id = $1;
-	`
-	ctx := context.Background()
+	var body struct {
+		Verified *bool `json:"verified"`
+	}
 
-	stmt, err := h.DB.PrepareContext(ctx, sql)
-	if err != nil {
-		log.Printf("Error preparing statement: %v", err)
-		http.Error(w, "error preparing statement", http.StatusInternalServerError)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Verified == nil {
+		logger.Info("invalid verification body", "err", err)
+		http.Error(w, "verified must be true or false", http.StatusBadRequest)
 		return
 	}
-	defer stmt.Close()
 
-	var acc Account
+	ctx := r.Context()
 
-	if err = stmt.QueryRowContext(ctx, id).Scan(&acc.ID, &acc.Username, &acc.Password); err != nil {
-		log.Printf("not found: %v", err)
-		http.Error(w, "not found", http.StatusNotFound)
+	if err = h.Repo.SetVerified(ctx, id, *body.Verified); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			logger.Info("not found", "err", err)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		logger.Error("error verifying account", "err", err)
+		http.Error(w, "error verifying account", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(200)
+	res := map[string]string{
+		"message": "account updated",
+	}
 
-	if err := json.NewEncoder(w).Encode(acc); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "error encoding response", 500)
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
 	}
 }
 
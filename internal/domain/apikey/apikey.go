@@ -0,0 +1,104 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Header is the request header carrying a personal API key.
+const Header = "X-API-Key"
+
+const keyPrefix = "alk_"
+
+var ErrNotFound = errors.New("api key not found")
+
+const (
+	ScopeAccountsRead  = "accounts:read"
+	ScopeAccountsWrite = "accounts:write"
+	ScopeProductsRead  = "products:read"
+	ScopeProductsWrite = "products:write"
+	ScopeBidsRead      = "bids:read"
+	ScopeBidsWrite     = "bids:write"
+)
+
+var Scopes = []string{
+	ScopeAccountsRead,
+	ScopeAccountsWrite,
+	ScopeProductsRead,
+	ScopeProductsWrite,
+	ScopeBidsRead,
+	ScopeBidsWrite,
+}
+
+type ApiKey struct {
+	ID         uuid.UUID  `json:"id"`
+	AccountID  uuid.UUID  `json:"account_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+type ApiKeyRepository interface {
+	// Create inserts key and sets its ID and CreatedAt.
+	Create(ctx context.Context, key *ApiKey) error
+	GetByAccount(ctx context.Context, accountID uuid.UUID) ([]ApiKey, error)
+	// Revoke revokes a live key owned by accountID.
+	Revoke(ctx context.Context, id uuid.UUID, accountID uuid.UUID) error
+	// Use returns the live key with the given hash and records it as used at.
+	Use(ctx context.Context, hash string, at time.Time) (*ApiKey, error)
+}
+
+// GenerateKey returns a new raw key together with the prefix shown to the
+// owner when listing keys. Only the hash of the raw key is ever stored.
+func GenerateKey() (raw string, prefix string, err error) {
+	id := make([]byte, 4)
+	if _, err = rand.Read(id); err != nil {
+		return "", "", err
+	}
+
+	secret := make([]byte, 32)
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	prefix = keyPrefix + hex.EncodeToString(id)
+	raw = prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	return raw, prefix, nil
+}
+
+func HashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func LooksLikeKey(raw string) bool {
+	return strings.HasPrefix(raw, keyPrefix)
+}
+
+func ValidateScopes(scopes []string) bool {
+	if len(scopes) == 0 {
+		return false
+	}
+
+	for _, s := range scopes {
+		if !slices.Contains(Scopes, s) {
+			return false
+		}
+	}
+
+	return true
+}
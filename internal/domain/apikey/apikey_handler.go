@@ -0,0 +1,186 @@
+package apikey
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/google/uuid"
+)
+
+type ApiKeyHandler struct {
+	Repo ApiKeyRepository
+}
+
+func NewApiKeyHandler(repo ApiKeyRepository) *ApiKeyHandler {
+	return &ApiKeyHandler{
+		Repo: repo,
+	}
+}
+
+// sessionAccount returns the account behind a cookie session. API keys are
+// not allowed to manage API keys.
+func sessionAccount(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	logger := middlewares.Logger(r.Context())
+
+	p, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+
+	if p.IsAPIKey() {
+		logger.Info("api key tried to manage api keys", "api_key_id", p.APIKeyID)
+		http.Error(w, "api keys can only be managed from a login session", http.StatusForbidden)
+		return uuid.Nil, false
+	}
+
+	return p.AccountID, true
+}
+
+func (h *ApiKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	accountID, ok := sessionAccount(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logger.Info("error decoding body", "err", err)
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if body.Name == "" || !ValidateScopes(body.Scopes) {
+		logger.Info("invalid name or scopes")
+		http.Error(w, "invalid name or scopes", http.StatusBadRequest)
+		return
+	}
+
+	raw, prefix, err := GenerateKey()
+	if err != nil {
+		logger.Error("error generating api key", "err", err)
+		http.Error(w, "error generating api key", http.StatusInternalServerError)
+		return
+	}
+
+	key := ApiKey{
+		AccountID: accountID,
+		Name:      body.Name,
+		Prefix:    prefix,
+		Hash:      HashKey(raw),
+		Scopes:    body.Scopes,
+	}
+
+	ctx := r.Context()
+
+	if err = h.Repo.Create(ctx, &key); err != nil {
+		logger.Error("error creating api key", "err", err)
+		http.Error(w, "error creating api key", http.StatusInternalServerError)
+		return
+	}
+
+	// the raw key is only ever shown once
+	res := struct {
+		ApiKey
+		Key string `json:"key"`
+	}{key, raw}
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *ApiKeyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	accountID, ok := sessionAccount(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	keys, err := h.Repo.GetByAccount(ctx, accountID)
+	if err != nil {
+		logger.Error("error getting api keys", "err", err)
+		http.Error(w, "error getting api keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *ApiKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	accountID, ok := sessionAccount(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("keyId"))
+	if err != nil {
+		logger.Info("invalid keyId format", "err", err)
+		http.Error(w, "invalid keyId format", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err = h.Repo.Revoke(ctx, id, accountID); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			logger.Info("api key not found", "api_key_id", id)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		logger.Error("error revoking api key", "err", err)
+		http.Error(w, "error revoking api key", http.StatusInternalServerError)
+		return
+	}
+
+	res := map[string]string{
+		"message": "api key revoked",
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// Lookup resolves a raw API key to a live key and records its use.
+func (h *ApiKeyHandler) Lookup(ctx context.Context, raw string) (*ApiKey, error) {
+	if !LooksLikeKey(raw) {
+		return nil, errors.New("malformed api key")
+	}
+
+	return h.Repo.Use(ctx, HashKey(raw), time.Now())
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"net/http"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/apikey"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/jwt"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+)
+
+// authenticator accepts either an API key header or the jwt session cookie.
+type authenticator struct {
+	jwt      *jwt.Jwt
+	apiKeys  *apikey.ApiKeyHandler
+	accounts account.AccountRepository
+}
+
+func (a *authenticator) Authenticate(r *http.Request) (*middlewares.Principal, error) {
+	p, err := a.credentials(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// the role is read on every request so a demotion applies at once
+	acc, err := a.accounts.GetByID(r.Context(), p.AccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Role = acc.Role
+	p.Verified = acc.Verified
+
+	return p, nil
+}
+
+func (a *authenticator) credentials(r *http.Request) (*middlewares.Principal, error) {
+	if raw := r.Header.Get(apikey.Header); raw != "" {
+		key, err := a.apiKeys.Lookup(r.Context(), raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return &middlewares.Principal{
+			AccountID: key.AccountID,
+			APIKeyID:  key.ID,
+			Scopes:    key.Scopes,
+		}, nil
+	}
+
+	if _, err := r.Cookie("jwt"); err != nil {
+		return nil, middlewares.ErrNoCredentials
+	}
+
+	id, err := a.jwt.AccountID(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &middlewares.Principal{AccountID: id}, nil
+}
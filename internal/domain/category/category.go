@@ -0,0 +1,107 @@
+package category
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound  = errors.New("category not found")
+	ErrNameTaken = errors.New("a sibling category already has that name")
+	// ErrInUse is returned when deleting a category with subcategories or
+	// products.
+	ErrInUse = errors.New("category has subcategories or products")
+)
+
+// Category is a node of the category tree. Products may only be put in
+// leaves, the categories without subcategories.
+type Category struct {
+	ID       uuid.UUID     `json:"id"`
+	ParentID uuid.NullUUID `json:"parent_id"`
+	Name     string        `json:"name"`
+}
+
+type CategoryRepository interface {
+	// GetAll returns every category, sorted by name.
+	GetAll(ctx context.Context) ([]Category, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*Category, error)
+	// Create inserts c and sets its ID. It returns ErrNotFound when the
+	// parent does not exist and ErrNameTaken when a sibling has the name.
+	Create(ctx context.Context, c *Category) error
+	// Update renames c and moves it under c.ParentID.
+	Update(ctx context.Context, c *Category) error
+	// Delete removes an unused category, or returns ErrInUse.
+	Delete(ctx context.Context, id uuid.UUID) error
+	// HasProducts reports whether any product is in the category itself.
+	HasProducts(ctx context.Context, id uuid.UUID) (bool, error)
+}
+
+// Tree indexes categories by parent, for walking the hierarchy.
+type Tree struct {
+	byID     map[uuid.UUID]Category
+	children map[uuid.UUID][]uuid.UUID
+}
+
+func NewTree(categories []Category) *Tree {
+	t := &Tree{
+		byID:     make(map[uuid.UUID]Category, len(categories)),
+		children: make(map[uuid.UUID][]uuid.UUID),
+	}
+
+	for _, c := range categories {
+		t.byID[c.ID] = c
+		if c.ParentID.Valid {
+			t.children[c.ParentID.UUID] = append(t.children[c.ParentID.UUID], c.ID)
+		}
+	}
+
+	return t
+}
+
+// LoadTree reads the whole category tree.
+func LoadTree(ctx context.Context, categories CategoryRepository) (*Tree, error) {
+	all, err := categories.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTree(all), nil
+}
+
+func (t *Tree) Has(id uuid.UUID) bool {
+	_, ok := t.byID[id]
+	return ok
+}
+
+func (t *Tree) IsLeaf(id uuid.UUID) bool {
+	return t.Has(id) && len(t.children[id]) == 0
+}
+
+// Descendants returns id and the ids of every category below it.
+func (t *Tree) Descendants(id uuid.UUID) []uuid.UUID {
+	ids := []uuid.UUID{id}
+
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, t.children[ids[i]]...)
+	}
+
+	return ids
+}
+
+// IsDescendant reports whether id is ancestor or lies below it.
+func (t *Tree) IsDescendant(id uuid.UUID, ancestor uuid.UUID) bool {
+	for {
+		if id == ancestor {
+			return true
+		}
+
+		c, ok := t.byID[id]
+		if !ok || !c.ParentID.Valid {
+			return false
+		}
+
+		id = c.ParentID.UUID
+	}
+}
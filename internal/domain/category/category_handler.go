@@ -0,0 +1,246 @@
+package category
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/google/uuid"
+)
+
+type CategoryHandler struct {
+	Categories CategoryRepository
+}
+
+func NewCategoryHandler(categories CategoryRepository) *CategoryHandler {
+	return &CategoryHandler{
+		Categories: categories,
+	}
+}
+
+func (h *CategoryHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+
+	categories, err := h.Categories.GetAll(ctx)
+	if err != nil {
+		logger.Error("error getting categories", "err", err)
+		http.Error(w, "error getting categories", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(categories); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *CategoryHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.PathValue("categoryId"))
+	if err != nil {
+		logger.Info("invalid category id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	c, err := h.Categories.GetByID(ctx, id)
+	if err != nil {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	var body Category
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logger.Info("error decoding body", "err", err)
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	if !h.validate(ctx, w, &body) {
+		return
+	}
+
+	if err := h.Categories.Create(ctx, &body); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// Update renames a category or moves it elsewhere in the tree.
+func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.PathValue("categoryId"))
+	if err != nil {
+		logger.Info("invalid category id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var body Category
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logger.Info("error decoding body", "err", err)
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	body.ID = id
+
+	ctx := r.Context()
+
+	if !h.validate(ctx, w, &body) {
+		return
+	}
+
+	if err := h.Categories.Update(ctx, &body); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.PathValue("categoryId"))
+	if err != nil {
+		logger.Info("invalid category id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := h.Categories.Delete(ctx, id); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	res := map[string]string{
+		"message": "category deleted",
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// validate checks the name and parent of c. Products live in leaves only,
+// so a category holding products cannot become a parent, and a category
+// cannot be moved below itself.
+func (h *CategoryHandler) validate(ctx context.Context, w http.ResponseWriter, c *Category) bool {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" || utf8.RuneCountInString(c.Name) > 100 {
+		middlewares.Logger(ctx).Info("invalid category name")
+		http.Error(w, "a name of up to 100 characters is required", http.StatusBadRequest)
+		return false
+	}
+
+	if !c.ParentID.Valid {
+		return true
+	}
+
+	tree, err := LoadTree(ctx, h.Categories)
+	if err != nil {
+		middlewares.Logger(ctx).Error("error loading categories", "err", err)
+		http.Error(w, "error loading categories", http.StatusInternalServerError)
+		return false
+	}
+
+	if !tree.Has(c.ParentID.UUID) {
+		middlewares.Logger(ctx).Info("parent category not found", "parent_id", c.ParentID.UUID)
+		http.Error(w, "parent category not found", http.StatusBadRequest)
+		return false
+	}
+
+	if c.ID != uuid.Nil && tree.IsDescendant(c.ParentID.UUID, c.ID) {
+		middlewares.Logger(ctx).Info("category moved below itself", "category_id", c.ID)
+		http.Error(w, "a category cannot be moved below itself", http.StatusBadRequest)
+		return false
+	}
+
+	hasProducts, err := h.Categories.HasProducts(ctx, c.ParentID.UUID)
+	if err != nil {
+		middlewares.Logger(ctx).Error("error checking category products", "err", err)
+		http.Error(w, "error checking category products", http.StatusInternalServerError)
+		return false
+	}
+
+	if hasProducts {
+		middlewares.Logger(ctx).Info("parent category has products", "parent_id", c.ParentID.UUID)
+		http.Error(w, "the parent category has products", http.StatusConflict)
+		return false
+	}
+
+	return true
+}
+
+func (h *CategoryHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := middlewares.Logger(r.Context())
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, ErrNameTaken):
+		logger.Info("category name taken", "err", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrInUse):
+		logger.Info("category in use", "err", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logger.Error("error saving category", "err", err)
+		http.Error(w, "error saving category", http.StatusInternalServerError)
+	}
+}
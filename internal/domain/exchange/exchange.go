@@ -0,0 +1,148 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+)
+
+var (
+	ErrNoRate      = errors.New("no exchange rate")
+	ErrInvalidRate = errors.New("invalid exchange rate")
+)
+
+// rateDecimals is the precision rates are stored with.
+const rateDecimals = 10
+
+// Rate says one unit of Base is worth Rate units of Quote.
+type Rate struct {
+	Base      string    `json:"base"`
+	Quote     string    `json:"quote"`
+	Rate      string    `json:"rate"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RateRepository interface {
+	GetAll(ctx context.Context) ([]Rate, error)
+	// Set inserts or replaces the rate from rate.Base to rate.Quote.
+	Set(ctx context.Context, rate *Rate) error
+	// Delete removes the rate from base to quote, or returns ErrNoRate.
+	Delete(ctx context.Context, base string, quote string) error
+}
+
+// ParseRate parses a positive decimal rate with at most rateDecimals places.
+func ParseRate(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" || len(whole) > 14 || len(frac) > rateDecimals || strings.ContainsAny(s, "+-eE/ ") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRate, s)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRate, s)
+	}
+
+	return r, nil
+}
+
+// FormatRate formats r as the shortest decimal with at most rateDecimals
+// places, so "5.4300000000" read back from the database becomes "5.43".
+func FormatRate(r *big.Rat) string {
+	s := r.FloatString(rateDecimals)
+	s = strings.TrimRight(s, "0")
+
+	return strings.TrimSuffix(s, ".")
+}
+
+// Table is a snapshot of every stored rate, so converting a whole listing
+// costs a single query.
+type Table map[[2]string]*big.Rat
+
+func LoadTable(ctx context.Context, rates RateRepository) (Table, error) {
+	all, err := rates.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	table := make(Table, len(all))
+
+	for _, rate := range all {
+		r, err := ParseRate(rate.Rate)
+		if err != nil {
+			return nil, err
+		}
+		table[[2]string{rate.Base, rate.Quote}] = r
+	}
+
+	return table, nil
+}
+
+// rate returns the rate from base to quote, inverting the opposite rate when
+// only that one is stored.
+func (t Table) rate(base string, quote string) (*big.Rat, bool) {
+	if r, ok := t[[2]string{base, quote}]; ok {
+		return r, true
+	}
+
+	if r, ok := t[[2]string{quote, base}]; ok {
+		return new(big.Rat).Inv(r), true
+	}
+
+	return nil, false
+}
+
+// Convert returns m in currency to, rounded half away from zero to its minor
+// unit.
+func (t Table) Convert(m money.Money, to string) (money.Money, error) {
+	if !money.ValidCurrency(to) {
+		return money.Money{}, fmt.Errorf("%w: %q", money.ErrInvalidCurrency, to)
+	}
+
+	if m.Currency == to {
+		return m, nil
+	}
+
+	r, ok := t.rate(m.Currency, to)
+	if !ok {
+		return money.Money{}, fmt.Errorf("%w from %s to %s", ErrNoRate, m.Currency, to)
+	}
+
+	x := new(big.Rat).SetInt64(m.Amount)
+	x.Mul(x, r)
+	x.Mul(x, scale(money.Decimals(to)))
+	x.Quo(x, scale(money.Decimals(m.Currency)))
+
+	amount, ok := round(x)
+	if !ok {
+		return money.Money{}, fmt.Errorf("%w: converted amount overflows", money.ErrInvalidAmount)
+	}
+
+	return money.New(amount, to), nil
+}
+
+func scale(decimals int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+}
+
+// round rounds x half away from zero.
+func round(x *big.Rat) (int64, bool) {
+	num := new(big.Int).Mul(x.Num(), big.NewInt(2))
+	den := new(big.Int).Mul(x.Denom(), big.NewInt(2))
+
+	if num.Sign() >= 0 {
+		num.Add(num, x.Denom())
+	} else {
+		num.Sub(num, x.Denom())
+	}
+
+	q := num.Quo(num, den)
+
+	return q.Int64(), q.IsInt64()
+}
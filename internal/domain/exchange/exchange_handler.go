@@ -0,0 +1,149 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+)
+
+type ExchangeHandler struct {
+	Rates RateRepository
+}
+
+func NewExchangeHandler(rates RateRepository) *ExchangeHandler {
+	return &ExchangeHandler{
+		Rates: rates,
+	}
+}
+
+// pair reads the {base} and {quote} path values.
+func pair(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	logger := middlewares.Logger(r.Context())
+
+	base := strings.ToUpper(r.PathValue("base"))
+	quote := strings.ToUpper(r.PathValue("quote"))
+
+	if !money.ValidCurrency(base) || !money.ValidCurrency(quote) || base == quote {
+		logger.Info("invalid currency pair", "base", base, "quote", quote)
+		http.Error(w, "invalid currency pair", http.StatusBadRequest)
+		return "", "", false
+	}
+
+	return base, quote, true
+}
+
+func (h *ExchangeHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+
+	rates, err := h.Rates.GetAll(ctx)
+	if err != nil {
+		logger.Error("error getting exchange rates", "err", err)
+		http.Error(w, "error getting exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(rates); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *ExchangeHandler) Set(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	base, quote, ok := pair(w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Rate json.Number `json:"rate"`
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+
+	if err := dec.Decode(&body); err != nil {
+		logger.Info("error decoding body", "err", err)
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	parsed, err := ParseRate(body.Rate.String())
+	if err != nil {
+		logger.Info("invalid rate", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rate := Rate{
+		Base:      base,
+		Quote:     quote,
+		Rate:      FormatRate(parsed),
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	ctx := r.Context()
+
+	if err = h.Rates.Set(ctx, &rate); err != nil {
+		logger.Error("error setting exchange rate", "err", err)
+		http.Error(w, "error setting exchange rate", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(rate); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *ExchangeHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	base, quote, ok := pair(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := h.Rates.Delete(ctx, base, quote); err != nil {
+		if errors.Is(err, ErrNoRate) {
+			logger.Info("exchange rate not found", "base", base, "quote", quote)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		logger.Error("error deleting exchange rate", "err", err)
+		http.Error(w, "error deleting exchange rate", http.StatusInternalServerError)
+		return
+	}
+
+	res := map[string]string{
+		"message": "exchange rate deleted",
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,225 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/db"
+	"github.com/Nier704/arthur-leilao-server/internal/buildinfo"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+)
+
+// checkTimeout bounds each readiness check, so a hung database fails the
+// probe instead of hanging it.
+const checkTimeout = 2 * time.Second
+
+// Worker is a background job the server is not ready without.
+type Worker interface {
+	// Workers returns how many goroutines of the job run and how many
+	// should.
+	Workers() (int, int)
+}
+
+type HealthHandler struct {
+	// DB and Migrations are nil for the memory backend, which has nothing
+	// to check.
+	DB         *sql.DB
+	Migrations *db.Migrator
+	Workers    map[string]Worker
+}
+
+func NewHealthHandler(conn *sql.DB, migrations *db.Migrator, workers map[string]Worker) *HealthHandler {
+	return &HealthHandler{
+		DB:         conn,
+		Migrations: migrations,
+		Workers:    workers,
+	}
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+func (h *HealthHandler) checks() []check {
+	var checks []check
+
+	if h.DB != nil {
+		checks = append(checks, check{"database", h.DB.PingContext})
+	}
+
+	if h.Migrations != nil {
+		checks = append(checks, check{"migrations", func(ctx context.Context) error {
+			pending, err := h.Migrations.Pending(ctx)
+			if err != nil {
+				return err
+			}
+			if pending > 0 {
+				return fmt.Errorf("%d migrations pending", pending)
+			}
+			return nil
+		}})
+	}
+
+	names := make([]string, 0, len(h.Workers))
+	for name := range h.Workers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		worker := h.Workers[name]
+		checks = append(checks, check{name, func(ctx context.Context) error {
+			if running, want := worker.Workers(); running < want {
+				return fmt.Errorf("%d of %d workers running", running, want)
+			}
+			return nil
+		}})
+	}
+
+	return checks
+}
+
+// run runs every check and returns the error of each by name, nil for
+// those that passed.
+func (h *HealthHandler) run(ctx context.Context) (map[string]error, bool) {
+	results := make(map[string]error)
+	ok := true
+
+	for _, c := range h.checks() {
+		cctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := c.run(cctx)
+		cancel()
+
+		results[c.name] = err
+		if err != nil {
+			ok = false
+		}
+	}
+
+	return results, ok
+}
+
+// Live answers as long as the process serves requests.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Ready answers 503 until the database answers, its schema is up to date
+// and the background workers run. Why a check fails is only logged, as the
+// probe is public.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	results, ok := h.run(r.Context())
+
+	checks := make(map[string]string, len(results))
+	for name, err := range results {
+		checks[name] = "ok"
+		if err != nil {
+			logger.Error("readiness check failed", "check", name, "err", err)
+			checks[name] = "failing"
+		}
+	}
+
+	status, code := "ok", http.StatusOK
+	if !ok {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	w.WriteHeader(code)
+
+	json.NewEncoder(w).Encode(map[string]any{"status": status, "checks": checks})
+}
+
+type poolStats struct {
+	MaxOpenConnections int     `json:"max_open_connections"`
+	OpenConnections    int     `json:"open_connections"`
+	InUse              int     `json:"in_use"`
+	Idle               int     `json:"idle"`
+	WaitCount          int64   `json:"wait_count"`
+	WaitDurationMs     float64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64   `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64   `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64   `json:"max_lifetime_closed"`
+}
+
+type workerStats struct {
+	Running int `json:"running"`
+	Want    int `json:"want"`
+}
+
+// Status describes the server for admins: its build, uptime, the result of
+// every readiness check and the database pool.
+func (h *HealthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	results, ok := h.run(r.Context())
+
+	checks := make(map[string]string, len(results))
+	for name, err := range results {
+		checks[name] = "ok"
+		if err != nil {
+			checks[name] = err.Error()
+		}
+	}
+
+	workers := make(map[string]workerStats, len(h.Workers))
+	for name, worker := range h.Workers {
+		running, want := worker.Workers()
+		workers[name] = workerStats{Running: running, Want: want}
+	}
+
+	var pool *poolStats
+	if h.DB != nil {
+		s := h.DB.Stats()
+		pool = &poolStats{
+			MaxOpenConnections: s.MaxOpenConnections,
+			OpenConnections:    s.OpenConnections,
+			InUse:              s.InUse,
+			Idle:               s.Idle,
+			WaitCount:          s.WaitCount,
+			WaitDurationMs:     float64(s.WaitDuration.Microseconds()) / 1000,
+			MaxIdleClosed:      s.MaxIdleClosed,
+			MaxIdleTimeClosed:  s.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  s.MaxLifetimeClosed,
+		}
+	}
+
+	res := struct {
+		Ready         bool                   `json:"ready"`
+		Build         buildinfo.Info         `json:"build"`
+		StartedAt     time.Time              `json:"started_at"`
+		UptimeSeconds float64                `json:"uptime_seconds"`
+		Checks        map[string]string      `json:"checks"`
+		Workers       map[string]workerStats `json:"workers"`
+		Pool          *poolStats             `json:"pool"`
+	}{
+		Ready:         ok,
+		Build:         buildinfo.Get(),
+		StartedAt:     buildinfo.Started().UTC(),
+		UptimeSeconds: buildinfo.Uptime().Round(time.Second).Seconds(),
+		Checks:        checks,
+		Workers:       workers,
+		Pool:          pool,
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,25 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrIdentityNotFound = errors.New("identity not found")
+
+// Identity links an account to a subject at an external OIDC provider.
+type Identity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	AccountID uuid.UUID `json:"account_id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type IdentityRepository interface {
+	Get(ctx context.Context, provider string, subject string) (*Identity, error)
+	Create(ctx context.Context, identity *Identity) error
+}
@@ -2,74 +2,85 @@ package jwt
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
-	"os"
 	"time"
 
+	"github.com/Nier704/arthur-leilao-server/config"
 	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/metrics"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
 	"github.com/Nier704/arthur-leilao-server/internal/utils"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-var secret_key = []byte(os.Getenv("JWT_SECRET"))
-
 type Jwt struct {
-	DB *sql.DB
+	Accounts          account.AccountRepository
+	Identities        IdentityRepository
+	providers         map[string]*oidcProvider
+	postLoginRedirect string
+	secret            []byte
+	auth              *config.AuthConfig
 }
 
-func NewJwt(db *sql.DB) *Jwt {
+func NewJwt(accounts account.AccountRepository, identities IdentityRepository, auth *config.AuthConfig, oidc *config.OIDCConfig) *Jwt {
+	providers := make(map[string]*oidcProvider)
+	for _, p := range oidc.Providers {
+		providers[p.Name] = newOIDCProvider(p)
+	}
+
 	return &Jwt{
-		DB: db,
+		Accounts:          accounts,
+		Identities:        identities,
+		providers:         providers,
+		postLoginRedirect: oidc.PostLoginRedirect,
+		secret:            []byte(auth.JWTSecret),
+		auth:              auth,
 	}
 }
 
 func (jwt *Jwt) Authenticate(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
 
 	cookie, err := r.Cookie("jwt")
 	if err != nil {
-		log.Printf("Error getting jwt cookie")
+		logger.Error("error getting jwt cookie")
 		http.Error(w, "error getting jwt cookie", 500)
 		return
 	}
 
 	tokenString := cookie.Value
 
-	token, err := verifyToken(tokenString)
+	token, err := verifyToken(tokenString, jwt.secret)
 	if err != nil {
-		log.Printf("token verification failed")
+		logger.Info("token verification failed")
 		http.Error(w, "token verification failed", 500)
 		return
 	}
 
 	id, err := token.Claims.GetSubject()
 	if err != nil {
-		log.Printf("error getting token subject")
+		logger.Error("error getting token subject")
 		http.Error(w, "error getting token subject", 500)
 		return
 	}
 
-	sql := `
-		SELECT * FROM accounts WHERE id = $1;
-	`
-	ctx := context.Background()
-
-	stmt, err := jwt.DB.PrepareContext(ctx, sql)
+	accountID, err := uuid.Parse(id)
 	if err != nil {
-		log.Printf("Error preparing statement: %v", err)
-		http.Error(w, "error preparing statement", http.StatusInternalServerError)
+		logger.Info("invalid token subject", "err", err)
+		http.Error(w, "invalid token subject", http.StatusUnauthorized)
 		return
 	}
-	defer stmt.Close()
 
-	var acc account.Account
+	ctx := r.Context()
 
-	if err = stmt.QueryRowContext(ctx, id).Scan(&acc.ID, &acc.Username, &acc.Password); err != nil {
-		log.Printf("Error getting account: %v", err)
+	acc, err := jwt.Accounts.GetByID(ctx, accountID)
+	if err != nil {
+		logger.Info("error getting account", "err", err)
 		http.Error(w, "error getting account", http.StatusNotFound)
 		return
 	}
@@ -77,16 +88,36 @@ func (jwt *Jwt) Authenticate(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 
 	if err = json.NewEncoder(w).Encode(acc); err != nil {
-		log.Printf("Error encoding response")
+		logger.Error("error encoding response")
 		http.Error(w, "error encoding response", 500)
 	}
 }
 
-func generateToken(id string, secret_key []byte) (string, error) {
+// AccountID returns the account behind the request's jwt cookie.
+func (jwt *Jwt) AccountID(r *http.Request) (uuid.UUID, error) {
+	cookie, err := r.Cookie("jwt")
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	token, err := verifyToken(cookie.Value, jwt.secret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	sub, err := token.Claims.GetSubject()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return uuid.Parse(sub)
+}
+
+func generateToken(id string, secret_key []byte, ttl time.Duration) (string, error) {
 	claims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"sub": id,
 		"iss": "arthurleilao",
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+		"exp": time.Now().Add(ttl).Unix(),
 	})
 
 	token_string, err := claims.SignedString(secret_key)
@@ -97,7 +128,7 @@ func generateToken(id string, secret_key []byte) (string, error) {
 	return token_string, nil
 }
 
-func verifyToken(tokenString string) (*jwt.Token, error) {
+func verifyToken(tokenString string, secret_key []byte) (*jwt.Token, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		return secret_key, nil
 	})
@@ -122,46 +153,39 @@ func verifyToken(tokenString string) (*jwt.Token, error) {
 }
 
 func (jwt *Jwt) Login(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
 
 	var body account.Account
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		log.Printf("Error decoding body: %v", err)
+		logger.Error("error decoding body", "err", err)
 		http.Error(w, "invalid body", http.StatusInternalServerError)
 		return
 	}
 
 	if ok := account.ValidateCredentials(&body); !ok {
-		log.Printf("invalid credentials")
+		logger.Info("invalid credentials")
 		http.Error(w, "invalid credentials", http.StatusBadRequest)
 		return
 	}
 
-	acc, err := tryLogin(&body, jwt.DB)
+	acc, err := jwt.tryLogin(r.Context(), &body)
+	metrics.Login(metrics.MethodPassword, err == nil)
 	if err != nil {
-		log.Printf("not found: %v", err)
+		logger.Info("not found", "err", err)
 		http.Error(w, "not found", 404)
 		return
 	}
 
-	token, err := generateToken(acc.ID.String(), secret_key)
+	token, err := generateToken(acc.ID.String(), jwt.secret, jwt.auth.SessionTTL)
 	if err != nil {
-		log.Printf("error generating jwt token: %v", err)
+		logger.Error("error generating jwt token", "err", err)
 		http.Error(w, "error generating jwt token", 500)
 		return
 	}
 
-	// create and set cookie
-	cookie := http.Cookie{
-		Name:     "jwt",
-		Value:    token,
-		Expires:  time.Now().Add(time.Hour * 24),
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
-	}
-
-	http.SetCookie(w, &cookie)
+	http.SetCookie(w, jwt.sessionCookie(token, time.Now().Add(jwt.auth.SessionTTL)))
 
 	res := map[string]string{
 		"message": "success",
@@ -170,28 +194,30 @@ func (jwt *Jwt) Login(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 
 	if err = json.NewEncoder(w).Encode(res); err != nil {
-		log.Printf("Error encoding response: %v", err)
+		logger.Error("error encoding response", "err", err)
 		http.Error(w, "error encoding response", 500)
 	}
 }
 
-func tryLogin(body *account.Account, db *sql.DB) (*account.Account, error) {
-	sql := `
-			SELECT * FROM accounts
-			WHERE username = $1; 
-		`
-	ctx := context.Background()
+// sessionCookie returns the jwt cookie holding value until expires, with
+// the attributes configured. Clearing the cookie must use the same ones.
+func (jwt *Jwt) sessionCookie(value string, expires time.Time) *http.Cookie {
+	c := jwt.auth.Cookie
 
-	stmt, err := db.PrepareContext(ctx, sql)
-	if err != nil {
-		return nil, err
+	return &http.Cookie{
+		Name:     "jwt",
+		Value:    value,
+		Path:     c.Path,
+		Domain:   c.Domain,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   c.Secure,
+		SameSite: c.SameSiteMode(),
 	}
-	defer stmt.Close()
-
-	var acc account.Account
+}
 
-	err = stmt.QueryRowContext(ctx, body.Username).
-		Scan(&acc.ID, &acc.Username, &acc.Password)
+func (jwt *Jwt) tryLogin(ctx context.Context, body *account.Account) (*account.Account, error) {
+	acc, err := jwt.Accounts.GetByUsername(ctx, body.Username)
 	if err != nil {
 		return nil, err
 	}
@@ -200,22 +226,13 @@ func tryLogin(body *account.Account, db *sql.DB) (*account.Account, error) {
 		return nil, err
 	}
 
-	return &acc, nil
+	return acc, nil
 }
 
 func (jwt *Jwt) Logout(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	cookie := http.Cookie{
-		Name:     "jwt",
-		Value:    "",
-		Expires:  time.Now().Add(-(time.Hour * 24)),
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
-	}
-
-	http.SetCookie(w, &cookie)
+	http.SetCookie(w, jwt.sessionCookie("", time.Now().Add(-(time.Hour*24))))
 
 	res := map[string]string{
 		"status":  "disconnected",
@@ -230,99 +247,57 @@ func (jwt *Jwt) Logout(w http.ResponseWriter, r *http.Request) {
 }
 
 func (jwt *Jwt) Signup(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
 
 	var body account.Account
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		log.Printf("Error decoding body: %v", err)
+		logger.Error("error decoding body", "err", err)
 		http.Error(w, "invalid body", http.StatusInternalServerError)
 		return
 	}
 
 	if ok := account.ValidateCredentials(&body); !ok {
-		log.Printf("invalid credentials")
+		logger.Info("invalid credentials")
 		http.Error(w, "invalid credentials", http.StatusBadRequest)
 		return
 	}
 
-	err := jwt.account_exists(&body)
-	if err == nil {
-		log.Printf("Error searching existing account: %v", err)
-		http.Error(w, "Account already exists", http.StatusInternalServerError)
-		return
-	}
-
 	hash, err := utils.HashPassword(body.Password)
 	if err != nil {
-		log.Printf("Error hashing password: %v", err)
+		logger.Error("error hashing password", "err", err)
 		http.Error(w, "error hashing password", http.StatusInternalServerError)
 		return
 	}
 
 	body.Password = string(hash)
 
-	inserted_id, err := jwt.insert_account(&body)
-	if err != nil {
-		log.Println(err)
+	ctx := r.Context()
+
+	if err = jwt.Accounts.Create(ctx, &body); err != nil {
+		if errors.Is(err, account.ErrUsernameTaken) {
+			logger.Error("account already exists", "err", err)
+			http.Error(w, "Account already exists", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Error("error creating account", "err", err)
 		http.Error(w, "error creating new account", http.StatusInternalServerError)
 		return
 	}
 
+	metrics.Signups.WithLabelValues(metrics.MethodPassword).Inc()
+
 	res := map[string]string{
 		"status":      "created",
-		"inserted_id": inserted_id,
+		"inserted_id": body.ID.String(),
 	}
 
 	w.WriteHeader(201)
 
 	if err = json.NewEncoder(w).Encode(res); err != nil {
-		log.Printf("Error encoding response: %v", err)
+		logger.Error("error encoding response", "err", err)
 		http.Error(w, "error encoding response", http.StatusInternalServerError)
 	}
 }
-
-func (jwt *Jwt) insert_account(body *account.Account) (string, error) {
-	sql := `
-		INSERT INTO accounts
-		(username, password)
-		VALUES ($1, $2)
-		RETURNING id;
-	`
-	ctx := context.Background()
-
-	stmt, err := jwt.DB.PrepareContext(ctx, sql)
-	if err != nil {
-		return "", errors.New("error preparing context")
-	}
-	defer stmt.Close()
-
-	var inserted_id string
-	err = stmt.QueryRowContext(ctx, body.Username, body.Password).Scan(&inserted_id)
-	if err != nil {
-		return "", err
-	}
-
-	return inserted_id, nil
-}
-
-func (jwt *Jwt) account_exists(body *account.Account) error {
-	sql := `
-	SELECT username FROM accounts
-	WHERE username = $1;
-	`
-	ctx := context.Background()
-
-	stmt, err := jwt.DB.PrepareContext(ctx, sql)
-	if err != nil {
-		return err
-	}
-	defer stmt.Close()
-
-	var existing_username string
-	err = stmt.QueryRowContext(ctx, body.Username).Scan(&existing_username)
-	if err != nil {
-		return err
-	}
-
-	return err
-}
@@ -0,0 +1,292 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/metrics"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/Nier704/arthur-leilao-server/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const oidcFlowCookie = "oidc_flow"
+
+// oidcFlow is kept in a short lived signed cookie between the redirect to the
+// provider and the callback, so any replica can finish the flow.
+type oidcFlow struct {
+	jwt.RegisteredClaims
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// OIDCLogin redirects the user agent to the provider's authorization endpoint.
+func (jwt *Jwt) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	provider, ok := jwt.providers[r.PathValue("provider")]
+	if !ok {
+		logger.Info("unknown oidc provider", "provider", r.PathValue("provider"))
+		http.Error(w, "unknown provider", http.StatusNotFound)
+		return
+	}
+
+	flow := oidcFlow{
+		RegisteredClaims: newFlowClaims(),
+		Provider:         provider.cfg.Name,
+		State:            randomString(),
+		Nonce:            randomString(),
+		Verifier:         randomString(),
+	}
+
+	authURL, err := provider.authCodeURL(r.Context(), flow.State, flow.Nonce, flow.Verifier)
+	if err != nil {
+		logger.Error("error building authorization url", "err", err)
+		http.Error(w, "error contacting provider", http.StatusBadGateway)
+		return
+	}
+
+	signed, err := newSignedToken(flow, jwt.secret)
+	if err != nil {
+		logger.Error("error signing oidc flow", "err", err)
+		http.Error(w, "error starting login", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    signed,
+		Path:     "/api/account/oidc",
+		Expires:  flow.ExpiresAt.Time,
+		HttpOnly: true,
+		Secure:   jwt.auth.Cookie.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback finishes the authorization code flow, links the external
+// identity to an account and issues our own session cookie.
+func (jwt *Jwt) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	provider, ok := jwt.providers[r.PathValue("provider")]
+	if !ok {
+		logger.Info("unknown oidc provider", "provider", r.PathValue("provider"))
+		http.Error(w, "unknown provider", http.StatusNotFound)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcFlowCookie)
+	if err != nil {
+		logger.Info("error getting oidc flow cookie", "err", err)
+		http.Error(w, "login flow expired", http.StatusBadRequest)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    "",
+		Path:     "/api/account/oidc",
+		Expires:  time.Now().Add(-(time.Hour * 24)),
+		HttpOnly: true,
+		Secure:   jwt.auth.Cookie.Secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	var flow oidcFlow
+	if err = parseSignedToken(cookie.Value, &flow, jwt.secret); err != nil {
+		logger.Info("error verifying oidc flow", "err", err)
+		http.Error(w, "login flow expired", http.StatusBadRequest)
+		return
+	}
+
+	if flow.Provider != provider.cfg.Name || flow.State != r.URL.Query().Get("state") {
+		logger.Info("oidc state mismatch")
+		http.Error(w, "invalid state", http.StatusBadRequest)
+		return
+	}
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		logger.Info("oidc provider returned an error", "error", e)
+		metrics.Login(metrics.MethodOIDC, false)
+		http.Error(w, "login refused by provider", http.StatusUnauthorized)
+		return
+	}
+
+	claims, err := provider.exchange(r.Context(), r.URL.Query().Get("code"), flow.Verifier, flow.Nonce)
+	if err != nil {
+		logger.Info("error exchanging authorization code", "err", err)
+		metrics.Login(metrics.MethodOIDC, false)
+		http.Error(w, "error verifying login", http.StatusUnauthorized)
+		return
+	}
+
+	accountID, err := jwt.linkIdentity(r, provider.cfg.Name, claims)
+	if err != nil {
+		logger.Error("error linking identity", "err", err)
+		http.Error(w, "error linking identity", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := generateToken(accountID.String(), jwt.secret, jwt.auth.SessionTTL)
+	if err != nil {
+		logger.Error("error generating jwt token", "err", err)
+		http.Error(w, "error generating jwt token", 500)
+		return
+	}
+
+	http.SetCookie(w, jwt.sessionCookie(token, time.Now().Add(jwt.auth.SessionTTL)))
+
+	metrics.Login(metrics.MethodOIDC, true)
+
+	if jwt.postLoginRedirect != "" {
+		http.Redirect(w, r, jwt.postLoginRedirect, http.StatusFound)
+		return
+	}
+
+	res := map[string]string{
+		"message": "success",
+	}
+
+	w.WriteHeader(200)
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", 500)
+	}
+}
+
+// linkIdentity returns the account linked to the external identity. Unknown
+// identities are linked to the logged in account, or to a new account when
+// nobody is logged in.
+func (jwt *Jwt) linkIdentity(r *http.Request, provider string, claims *oidcClaims) (uuid.UUID, error) {
+	ctx := r.Context()
+
+	identity, err := jwt.Identities.Get(ctx, provider, claims.Subject)
+	if err == nil {
+		return identity.AccountID, nil
+	}
+
+	if !errors.Is(err, ErrIdentityNotFound) {
+		return uuid.Nil, err
+	}
+
+	accountID, err := jwt.AccountID(r)
+	if err != nil {
+		accountID, err = jwt.insertExternalAccount(ctx, claims)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	identity = &Identity{
+		Provider:  provider,
+		Subject:   claims.Subject,
+		AccountID: accountID,
+		Email:     claims.Email,
+	}
+
+	if err = jwt.Identities.Create(ctx, identity); err != nil {
+		return uuid.Nil, err
+	}
+
+	return accountID, nil
+}
+
+var usernameUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// insertExternalAccount creates an account for a first time social login. The
+// account gets a random password, so it can only log in through the provider
+// until the owner sets one.
+func (jwt *Jwt) insertExternalAccount(ctx context.Context, claims *oidcClaims) (uuid.UUID, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	base = usernameUnsafe.ReplaceAllString(base, "")
+	if base == "" {
+		base = "user"
+	}
+
+	if len(base) > 24 {
+		base = base[:24]
+	}
+
+	hash, err := utils.HashPassword(randomString())
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	acc := account.Account{
+		Username: base,
+		Password: string(hash),
+	}
+
+	for i := 0; i < 5; i++ {
+		err = jwt.Accounts.Create(ctx, &acc)
+		if err == nil {
+			metrics.Signups.WithLabelValues(metrics.MethodOIDC).Inc()
+			return acc.ID, nil
+		}
+
+		if !errors.Is(err, account.ErrUsernameTaken) {
+			return uuid.Nil, err
+		}
+
+		acc.Username = fmt.Sprintf("%s_%s", base, randomString()[:5])
+	}
+
+	return uuid.Nil, errors.New("could not find a free username")
+}
+
+func newFlowClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Issuer:    "arthurleilao",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(10 * time.Minute)),
+	}
+}
+
+func newSignedToken(claims jwt.Claims, secret_key []byte) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret_key)
+}
+
+func parseSignedToken(tokenString string, claims jwt.Claims, secret_key []byte) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return secret_key, nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+
+	return err
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,252 @@
+package jwt_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/config"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/jwt"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/jwt/oidctest"
+	"github.com/Nier704/arthur-leilao-server/internal/storage"
+	"github.com/Nier704/arthur-leilao-server/internal/utils"
+)
+
+const loginPath = "/api/account/oidc/fake/login"
+
+// app serves the login routes against a fake provider, with a client that
+// keeps cookies and stops at every redirect.
+type app struct {
+	*httptest.Server
+
+	provider *oidctest.Server
+	store    *storage.Store
+	client   *http.Client
+}
+
+func newApp(t *testing.T) *app {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	provider := oidctest.NewServer(oidctest.User{
+		Subject:           "sub-123",
+		Email:             "ana@example.com",
+		PreferredUsername: "ana",
+	})
+	t.Cleanup(provider.Close)
+
+	store := storage.NewMemoryStore()
+
+	auth := &config.AuthConfig{
+		JWTSecret:  strings.Repeat("s", 32),
+		SessionTTL: time.Hour,
+		// the test servers speak plain HTTP
+		Cookie: config.CookieConfig{Path: "/", SameSite: "lax"},
+	}
+	oidc := &config.OIDCConfig{
+		Providers: []config.OIDCProvider{provider.Provider("fake", srv.URL+"/api/account/oidc/fake/callback")},
+	}
+
+	j := jwt.NewJwt(store.Accounts, store.Identities, auth, oidc)
+	mux.HandleFunc("POST /api/account/login", j.Login)
+	mux.HandleFunc("GET /api/account/oidc/{provider}/login", j.OIDCLogin)
+	mux.HandleFunc("GET /api/account/oidc/{provider}/callback", j.OIDCCallback)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &app{Server: srv, provider: provider, store: store, client: client}
+}
+
+// get requests rawURL and returns the response status and its Location.
+func (a *app) get(t *testing.T, rawURL string) (int, *url.URL) {
+	t.Helper()
+
+	res, err := a.client.Get(rawURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", rawURL, err)
+	}
+	res.Body.Close()
+
+	loc, _ := res.Location()
+
+	return res.StatusCode, loc
+}
+
+// login starts a login and returns the authorization URL it redirects to.
+func (a *app) login(t *testing.T) *url.URL {
+	t.Helper()
+
+	status, authURL := a.get(t, a.URL+loginPath)
+	if status != http.StatusFound || authURL == nil {
+		t.Fatalf("login = %d, want a redirect to the provider", status)
+	}
+
+	return authURL
+}
+
+// authorize has the provider approve authURL and returns the callback URL
+// it sends the user agent back to.
+func (a *app) authorize(t *testing.T, authURL *url.URL) *url.URL {
+	t.Helper()
+
+	status, callback := a.get(t, authURL.String())
+	if status != http.StatusFound || callback == nil {
+		t.Fatalf("authorize = %d, want a redirect to the callback", status)
+	}
+
+	return callback
+}
+
+func (a *app) session(t *testing.T) string {
+	t.Helper()
+
+	u, _ := url.Parse(a.URL)
+	for _, c := range a.client.Jar.Cookies(u) {
+		if c.Name == "jwt" {
+			return c.Value
+		}
+	}
+
+	return ""
+}
+
+func TestOIDCFirstLoginCreatesAccount(t *testing.T) {
+	a := newApp(t)
+	ctx := context.Background()
+
+	callback := a.authorize(t, a.login(t))
+
+	if got := callback.Query().Get("code"); got == "" {
+		t.Fatalf("callback %s has no code", callback)
+	}
+
+	if status, _ := a.get(t, callback.String()); status != http.StatusOK {
+		t.Fatalf("callback = %d, want 200", status)
+	}
+
+	if a.session(t) == "" {
+		t.Fatal("no session cookie after the callback")
+	}
+
+	acc, err := a.store.Accounts.GetByUsername(ctx, "ana")
+	if err != nil {
+		t.Fatalf("account for the new identity: %v", err)
+	}
+
+	identity, err := a.store.Identities.Get(ctx, "fake", "sub-123")
+	if err != nil || identity.AccountID != acc.ID || identity.Email != "ana@example.com" {
+		t.Fatalf("identity = %+v, %v; want it linked to %s", identity, err, acc.ID)
+	}
+
+	// logging in again reuses the account
+	a.client.Jar, _ = cookiejar.New(nil)
+
+	if status, _ := a.get(t, a.authorize(t, a.login(t)).String()); status != http.StatusOK {
+		t.Fatalf("second callback = %d, want 200", status)
+	}
+
+	accounts, err := a.store.Accounts.GetAll(ctx)
+	if err != nil || len(accounts) != 1 {
+		t.Fatalf("accounts = %d, %v; want the one account", len(accounts), err)
+	}
+}
+
+func TestOIDCLinksLoggedInAccount(t *testing.T) {
+	a := newApp(t)
+	ctx := context.Background()
+
+	hash, err := utils.HashPassword("secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acc := account.Account{Username: "bia", Password: string(hash)}
+	if err = a.store.Accounts.Create(ctx, &acc); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := a.client.Post(a.URL+"/api/account/login", "application/json", strings.NewReader(`{"username":"bia","password":"secret123"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK || a.session(t) == "" {
+		t.Fatalf("password login = %d, want 200 and a session", res.StatusCode)
+	}
+
+	if status, _ := a.get(t, a.authorize(t, a.login(t)).String()); status != http.StatusOK {
+		t.Fatalf("callback = %d, want 200", status)
+	}
+
+	identity, err := a.store.Identities.Get(ctx, "fake", "sub-123")
+	if err != nil || identity.AccountID != acc.ID {
+		t.Fatalf("identity = %+v, %v; want it linked to the logged in %s", identity, err, acc.ID)
+	}
+
+	if _, err = a.store.Accounts.GetByUsername(ctx, "ana"); err == nil {
+		t.Fatal("linking created a new account")
+	}
+}
+
+func TestOIDCStateMismatch(t *testing.T) {
+	a := newApp(t)
+
+	callback := a.authorize(t, a.login(t))
+
+	q := callback.Query()
+	q.Set("state", "forged")
+	callback.RawQuery = q.Encode()
+
+	if status, _ := a.get(t, callback.String()); status != http.StatusBadRequest {
+		t.Fatalf("callback with a forged state = %d, want 400", status)
+	}
+
+	if _, err := a.store.Identities.Get(context.Background(), "fake", "sub-123"); err == nil {
+		t.Fatal("identity linked despite the state mismatch")
+	}
+}
+
+func TestOIDCNonceMismatch(t *testing.T) {
+	a := newApp(t)
+
+	// the provider is asked for the first flow's nonce, while the cookie
+	// holds the second flow, whose state and verifier still match
+	first := a.login(t)
+	second := a.login(t)
+
+	q := second.Query()
+	q.Set("nonce", first.Query().Get("nonce"))
+	second.RawQuery = q.Encode()
+
+	if status, _ := a.get(t, a.authorize(t, second).String()); status != http.StatusUnauthorized {
+		t.Fatalf("callback with another flow's nonce = %d, want 401", status)
+	}
+
+	if a.session(t) != "" {
+		t.Fatal("session issued despite the nonce mismatch")
+	}
+
+	if _, err := a.store.Identities.Get(context.Background(), "fake", "sub-123"); err == nil {
+		t.Fatal("identity linked despite the nonce mismatch")
+	}
+}
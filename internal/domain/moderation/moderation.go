@@ -0,0 +1,99 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrAlreadyReported is returned when the reporter still has an open
+	// report on the same target.
+	ErrAlreadyReported = errors.New("already reported")
+	// ErrTargetNotFound is returned when the product or bid reported does
+	// not exist.
+	ErrTargetNotFound = errors.New("reported content not found")
+)
+
+// Kinds of content that can be reported.
+const (
+	TargetProduct = "product"
+	// TargetBid reports the message of a bid.
+	TargetBid = "bid"
+)
+
+// Report statuses. Reports are open until a moderator resolves them, by
+// acting on the content, or dismisses them.
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// Target is the content a report is about.
+type Target struct {
+	Type string    `json:"type"`
+	ID   uuid.UUID `json:"id"`
+}
+
+// Report is a complaint about a product or a bid message.
+type Report struct {
+	ID         uuid.UUID `json:"id"`
+	ReporterID uuid.UUID `json:"reporter_id"`
+	Target     Target    `json:"target"`
+	Reason     string    `json:"reason"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+	// ResolvedBy is the moderator who closed the report, and Note what
+	// they said about it.
+	ResolvedBy uuid.NullUUID `json:"resolved_by"`
+	ResolvedAt *time.Time    `json:"resolved_at"`
+	Note       string        `json:"note,omitempty"`
+}
+
+// maxSummaryReasons is how many reasons a Summary carries.
+const maxSummaryReasons = 5
+
+// Summary gathers the reports on one target.
+type Summary struct {
+	Target Target `json:"target"`
+	// Excerpt is the title of the product or the message of the bid.
+	Excerpt         string    `json:"excerpt"`
+	Reports         int       `json:"reports"`
+	FirstReportedAt time.Time `json:"first_reported_at"`
+	LastReportedAt  time.Time `json:"last_reported_at"`
+	// Reasons are the latest reasons given, newest first.
+	Reasons []string `json:"reasons"`
+}
+
+// Add counts r, which must come in newest first, into s.
+func (s *Summary) Add(r Report) {
+	s.Reports++
+
+	if s.LastReportedAt.IsZero() {
+		s.LastReportedAt = r.CreatedAt
+	}
+	s.FirstReportedAt = r.CreatedAt
+
+	if len(s.Reasons) < maxSummaryReasons {
+		s.Reasons = append(s.Reasons, r.Reason)
+	}
+}
+
+type ReportRepository interface {
+	// Create files r and sets its ID, Status and CreatedAt. It returns
+	// ErrTargetNotFound and ErrAlreadyReported.
+	Create(ctx context.Context, r *Report) error
+	// GetByTarget returns the reports on a target, newest first.
+	GetByTarget(ctx context.Context, target Target) ([]Report, error)
+	// Summarize returns a page of the targets with reports in status, the
+	// most reported first, and how many targets there are in all.
+	Summarize(ctx context.Context, status string, limit int, offset int) ([]Summary, int, error)
+	// Count counts the reports in each status.
+	Count(ctx context.Context) (map[string]int, error)
+	// Resolve closes the open reports on a target with status and returns
+	// how many it closed.
+	Resolve(ctx context.Context, target Target, status string, moderatorID uuid.UUID, note string, at time.Time) (int, error)
+}
@@ -0,0 +1,368 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/google/uuid"
+)
+
+const (
+	maxReasonLength = 500
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type ModerationHandler struct {
+	Reports  ReportRepository
+	Products product.ProductRepository
+	Bids     product.BidRepository
+}
+
+func NewModerationHandler(reports ReportRepository, products product.ProductRepository, bids product.BidRepository) *ModerationHandler {
+	return &ModerationHandler{
+		Reports:  reports,
+		Products: products,
+		Bids:     bids,
+	}
+}
+
+// ReportProduct lets any account report a published product.
+func (h *ModerationHandler) ReportProduct(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	p, err := h.Products.GetByID(ctx, id)
+	if err != nil || p.PublishedAt == nil {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	h.report(ctx, w, r, Target{Type: TargetProduct, ID: id})
+}
+
+// ReportBid lets any account report the message of a bid.
+func (h *ModerationHandler) ReportBid(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.PathValue("bidId"))
+	if err != nil {
+		logger.Info("invalid bid id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err = h.Bids.GetByID(ctx, id); err != nil {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	h.report(ctx, w, r, Target{Type: TargetBid, ID: id})
+}
+
+func (h *ModerationHandler) report(ctx context.Context, w http.ResponseWriter, r *http.Request, target Target) {
+	logger := middlewares.Logger(r.Context())
+
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logger.Info("error decoding body", "err", err)
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" || len(body.Reason) > maxReasonLength {
+		logger.Info("invalid report reason")
+		http.Error(w, fmt.Sprintf("a reason of up to %d characters is required", maxReasonLength), http.StatusBadRequest)
+		return
+	}
+
+	report := Report{
+		ReporterID: principal.AccountID,
+		Target:     target,
+		Reason:     body.Reason,
+	}
+
+	if err := h.Reports.Create(ctx, &report); err != nil {
+		switch {
+		case errors.Is(err, ErrAlreadyReported):
+			logger.Info("account already reported the target", "target_type", target.Type, "target_id", target.ID)
+			http.Error(w, "you already reported this", http.StatusConflict)
+		case errors.Is(err, ErrTargetNotFound):
+			logger.Info("not found", "err", err)
+			http.Error(w, "not found", http.StatusNotFound)
+		default:
+			logger.Error("error creating report", "err", err)
+			http.Error(w, "error creating report", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res := map[string]string{
+		"message": "report received",
+		"id":      report.ID.String(),
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// Dashboard sums up the work waiting for moderators: the products to
+// review, the reports in each status, and a page of the reported content in
+// ?status= (open by default), the most reported first.
+func (h *ModerationHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = ReportOpen
+	case ReportOpen, ReportResolved, ReportDismissed:
+	default:
+		logger.Info("invalid report status", "status", status)
+		http.Error(w, fmt.Sprintf("status must be %s, %s or %s", ReportOpen, ReportResolved, ReportDismissed), http.StatusBadRequest)
+		return
+	}
+
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		logger.Info("invalid pagination", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	pending, err := h.Products.GetPending(ctx)
+	if err != nil {
+		logger.Error("error getting products to review", "err", err)
+		http.Error(w, "error getting products to review", http.StatusInternalServerError)
+		return
+	}
+
+	counts, err := h.Reports.Count(ctx)
+	if err != nil {
+		logger.Error("error counting reports", "err", err)
+		http.Error(w, "error counting reports", http.StatusInternalServerError)
+		return
+	}
+
+	summaries, total, err := h.Reports.Summarize(ctx, status, limit, offset)
+	if err != nil {
+		logger.Error("error summarizing reports", "err", err)
+		http.Error(w, "error summarizing reports", http.StatusInternalServerError)
+		return
+	}
+
+	res := struct {
+		PendingProducts int            `json:"pending_products"`
+		Reports         map[string]int `json:"reports"`
+		Status          string         `json:"status"`
+		Targets         []Summary      `json:"targets"`
+		Total           int            `json:"total"`
+		Limit           int            `json:"limit"`
+		Offset          int            `json:"offset"`
+	}{
+		PendingProducts: len(pending),
+		Reports: map[string]int{
+			ReportOpen:      counts[ReportOpen],
+			ReportResolved:  counts[ReportResolved],
+			ReportDismissed: counts[ReportDismissed],
+		},
+		Status:  status,
+		Targets: summaries,
+		Total:   total,
+		Limit:   limit,
+		Offset:  offset,
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// GetByTarget lists every report on a product or bid, newest first.
+func (h *ModerationHandler) GetByTarget(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	target, err := targetParam(r)
+	if err != nil {
+		logger.Info("invalid target", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	reports, err := h.Reports.GetByTarget(ctx, target)
+	if err != nil {
+		logger.Error("error getting reports", "err", err)
+		http.Error(w, "error getting reports", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(reports); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// Resolve closes the open reports on a product or bid, as resolved once
+// the moderator has acted on the content or as dismissed.
+func (h *ModerationHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	target, err := targetParam(r)
+	if err != nil {
+		logger.Info("invalid target", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logger.Info("error decoding body", "err", err)
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if body.Status != ReportResolved && body.Status != ReportDismissed {
+		logger.Info("invalid resolution", "status", body.Status)
+		http.Error(w, fmt.Sprintf("status must be %s or %s", ReportResolved, ReportDismissed), http.StatusBadRequest)
+		return
+	}
+
+	body.Note = strings.TrimSpace(body.Note)
+	if len(body.Note) > maxReasonLength {
+		logger.Info("note too long")
+		http.Error(w, fmt.Sprintf("note must be at most %d characters", maxReasonLength), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	closed, err := h.Reports.Resolve(ctx, target, body.Status, principal.AccountID, body.Note, time.Now().UTC())
+	if err != nil {
+		logger.Error("error resolving reports", "err", err)
+		http.Error(w, "error resolving reports", http.StatusInternalServerError)
+		return
+	}
+
+	if closed == 0 {
+		logger.Info("no open reports", "target_type", target.Type, "target_id", target.ID)
+		http.Error(w, "no open reports", http.StatusNotFound)
+		return
+	}
+
+	res := struct {
+		Message string `json:"message"`
+		Closed  int    `json:"closed"`
+	}{"reports " + body.Status, closed}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// targetParam reads the {targetType} and {targetId} of a path.
+func targetParam(r *http.Request) (Target, error) {
+	target := Target{Type: r.PathValue("targetType")}
+
+	if target.Type != TargetProduct && target.Type != TargetBid {
+		return target, fmt.Errorf("reports are on a %s or a %s", TargetProduct, TargetBid)
+	}
+
+	id, err := uuid.Parse(r.PathValue("targetId"))
+	if err != nil {
+		return target, errors.New("invalid id")
+	}
+	target.ID = id
+
+	return target, nil
+}
+
+// pageParams reads ?limit= and ?offset=.
+func pageParams(r *http.Request) (int, int, error) {
+	limit, offset := defaultPageSize, 0
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		limit = n
+	}
+
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must not be negative")
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
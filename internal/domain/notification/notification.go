@@ -0,0 +1,42 @@
+package notification
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// KindBidRetracted tells a bidder that a bid on a product they bid on
+	// was retracted.
+	KindBidRetracted = "bid_retracted"
+	// KindHighBidder tells a bidder their bid became the standing one again.
+	KindHighBidder = "high_bidder"
+	// KindListingApproved tells a seller a moderator published their
+	// product.
+	KindListingApproved = "listing_approved"
+	// KindListingRejected tells a seller a moderator rejected or took down
+	// their product.
+	KindListingRejected = "listing_rejected"
+	// KindProductAddendum tells a bidder the seller added a note to a
+	// product they bid on.
+	KindProductAddendum = "product_addendum"
+)
+
+// Notification is a message in an account's in-app inbox.
+type Notification struct {
+	ID        uuid.UUID     `json:"id"`
+	AccountID uuid.UUID     `json:"account_id"`
+	Kind      string        `json:"kind"`
+	Message   string        `json:"message"`
+	ProductID uuid.NullUUID `json:"product_id"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type NotificationRepository interface {
+	// Create inserts n and sets its ID and CreatedAt.
+	Create(ctx context.Context, n *Notification) error
+	// GetByAccount returns the account's notifications, newest first.
+	GetByAccount(ctx context.Context, accountID uuid.UUID) ([]Notification, error)
+}
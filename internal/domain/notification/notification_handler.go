@@ -0,0 +1,48 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+)
+
+type NotificationHandler struct {
+	Repo NotificationRepository
+}
+
+func NewNotificationHandler(repo NotificationRepository) *NotificationHandler {
+	return &NotificationHandler{
+		Repo: repo,
+	}
+}
+
+// GetAll returns the caller's own notifications.
+func (h *NotificationHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	p, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+
+	notifications, err := h.Repo.GetByAccount(ctx, p.AccountID)
+	if err != nil {
+		logger.Error("error getting notifications", "err", err)
+		http.Error(w, "error getting notifications", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(notifications); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
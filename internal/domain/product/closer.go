@@ -0,0 +1,91 @@
+package product
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/metrics"
+)
+
+// AuctionCloser closes auctions once they end, so their standing bid is
+// marked BidWinning. Every replica may run one; each auction is closed once.
+type AuctionCloser struct {
+	Products ProductRepository
+	interval time.Duration
+	running  atomic.Int32
+	wg       sync.WaitGroup
+}
+
+func NewAuctionCloser(products ProductRepository, interval time.Duration) *AuctionCloser {
+	return &AuctionCloser{
+		Products: products,
+		interval: interval,
+	}
+}
+
+// Start closes the auctions that ended while the server was down, then
+// checks again every interval until ctx is done.
+func (c *AuctionCloser) Start(ctx context.Context) {
+	c.running.Add(1)
+	c.wg.Add(1)
+	go c.run(ctx)
+}
+
+// Workers returns whether the closer is running, as a count of one worker
+// that should be.
+func (c *AuctionCloser) Workers() (int, int) {
+	return int(c.running.Load()), 1
+}
+
+// Wait waits for the closer to return once the context given to Start is
+// done, or for ctx to be done.
+func (c *AuctionCloser) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *AuctionCloser) run(ctx context.Context) {
+	defer c.wg.Done()
+	defer c.running.Add(-1)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.close(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *AuctionCloser) close(ctx context.Context) {
+	closed, err := c.Products.CloseEnded(ctx, time.Now())
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("error closing auctions", "err", err)
+		}
+		return
+	}
+
+	if closed > 0 {
+		metrics.AuctionsClosed.Add(float64(closed))
+		slog.Info("closed auctions", "count", closed)
+	}
+}
@@ -0,0 +1,190 @@
+package product_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/config"
+	"github.com/Nier704/arthur-leilao-server/internal/blob"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+	"github.com/Nier704/arthur-leilao-server/internal/storage"
+	"github.com/google/uuid"
+)
+
+// principals authenticates the requests whose bearer token is the name of
+// one of them.
+type principals map[string]*middlewares.Principal
+
+func (p principals) Authenticate(r *http.Request) (*middlewares.Principal, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, middlewares.ErrNoCredentials
+	}
+
+	if principal, ok := p[token]; ok {
+		return principal, nil
+	}
+
+	return nil, errors.New("unknown token")
+}
+
+// app serves the product routes from a memory store.
+type app struct {
+	mux        *http.ServeMux
+	store      *storage.Store
+	principals principals
+	cfg        *config.Config
+	// category is a leaf every product is put in.
+	category uuid.UUID
+}
+
+func newApp(t *testing.T) *app {
+	t.Helper()
+
+	files, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := &app{
+		mux:        http.NewServeMux(),
+		store:      storage.NewMemoryStore(),
+		principals: make(principals),
+		cfg:        config.Default(),
+	}
+
+	c := category.Category{Name: "Instruments"}
+	if err = a.store.Categories.Create(context.Background(), &c); err != nil {
+		t.Fatal(err)
+	}
+	a.category = c.ID
+
+	urls := &blob.URLs{Store: files, Secret: bytes.Repeat([]byte("s"), 32), Prefix: "/api/images/", TTL: time.Hour}
+	processor := product.NewImageProcessor(a.store.Images, files, 1)
+
+	ph := product.NewProductHandler(a.store.Products, a.store.Bids, a.store.Rates, a.store.Notifications, a.store.Categories, a.store.Images, urls, &a.cfg.Bid)
+	ih := product.NewImageHandler(a.store.Products, a.store.Images, urls, processor, &a.cfg.Image)
+
+	for pattern, h := range map[string]http.HandlerFunc{
+		"PUT /api/product/{productId}":                          ph.Update,
+		"POST /api/product/{productId}/images":                  ih.Upload,
+		"POST /api/bid/account/{accountId}/product/{productId}": ph.AddBid,
+		"POST /api/bid/{bidId}/retract":                         ph.RetractBid,
+		"POST /api/products/import":                             ph.Import,
+	} {
+		a.mux.Handle(pattern, middlewares.RequireScope(a.principals, "", h))
+	}
+
+	return a
+}
+
+// account creates an account with role, and a token for it named after
+// username.
+func (a *app) account(t *testing.T, username string, role string, verified bool) account.Account {
+	t.Helper()
+
+	ctx := context.Background()
+
+	acc := account.Account{Username: username, Password: "hash"}
+	if err := a.store.Accounts.Create(ctx, &acc); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.store.Accounts.SetRole(ctx, acc.ID, role); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.store.Accounts.SetVerified(ctx, acc.ID, verified); err != nil {
+		t.Fatal(err)
+	}
+
+	acc.Role, acc.Verified = role, verified
+	a.principals[username] = &middlewares.Principal{AccountID: acc.ID, Role: role, Verified: verified}
+
+	return acc
+}
+
+// product creates a draft of seller, in the app's category.
+func (a *app) product(t *testing.T, seller account.Account) product.Product {
+	t.Helper()
+
+	p := product.Product{
+		AccountID:   seller.ID,
+		Title:       "Guitar",
+		Description: "Vintage",
+		Price:       money.New(10000, money.DefaultCurrency),
+		CategoryID:  uuid.NullUUID{UUID: a.category, Valid: true},
+		Tags:        []string{"strings"},
+	}
+	if err := a.store.Products.Create(context.Background(), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+// publish lists p from at until endsAt.
+func (a *app) publish(t *testing.T, p *product.Product, at time.Time, endsAt time.Time) {
+	t.Helper()
+
+	p.EndsAt = &endsAt
+	if err := a.store.Products.Update(context.Background(), p, p.AccountID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.store.Products.Publish(context.Background(), p.ID, at); err != nil {
+		t.Fatal(err)
+	}
+	p.PublishedAt = &at
+}
+
+// do sends a request as the account named token, or anonymously when token
+// is empty. body is sent as is when it is a string and as JSON otherwise.
+func (a *app) do(t *testing.T, token string, method string, path string, body any) (int, string) {
+	t.Helper()
+
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rec := httptest.NewRecorder()
+	a.mux.ServeHTTP(rec, req)
+
+	return rec.Code, rec.Body.String()
+}
+
+// edit is the body of an update of p.
+func edit(p product.Product) map[string]any {
+	return map[string]any{
+		"title":       p.Title,
+		"description": p.Description,
+		"price":       p.Price,
+		"category_id": p.CategoryID,
+		"tags":        p.Tags,
+		"ends_at":     p.EndsAt,
+	}
+}
@@ -0,0 +1,120 @@
+package product
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrLocked is returned when changing one of MaterialFields of a product
+// that has bids.
+var ErrLocked = errors.New("product has bids")
+
+// Fields of a product kept in its history.
+const (
+	FieldTitle       = "title"
+	FieldDescription = "description"
+	FieldPrice       = "price"
+	FieldCategory    = "category_id"
+	FieldTags        = "tags"
+	FieldEndsAt      = "ends_at"
+	// FieldAddendum records an addendum; it is never changed, only added.
+	FieldAddendum = "addendum"
+)
+
+// MaterialFields are the fields bidders rely on, the end of the auction
+// included. They are locked once the first bid is placed; sellers add an
+// Addendum instead.
+var MaterialFields = []string{FieldDescription, FieldPrice, FieldEndsAt}
+
+// Change is one field of a Revision. Values are rendered as text, empty
+// when unset.
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Revision is one change to a product, creation included.
+type Revision struct {
+	ID        uuid.UUID `json:"id"`
+	ProductID uuid.UUID `json:"product_id"`
+	// EditorID is the account that made the change, invalid once that
+	// account is deleted.
+	EditorID  uuid.NullUUID `json:"editor_id"`
+	Changes   []Change      `json:"changes"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// Addendum is a note a seller appends to a product. Addenda cannot be
+// edited or removed.
+type Addendum struct {
+	ID        uuid.UUID `json:"id"`
+	ProductID uuid.UUID `json:"product_id"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Diff lists the fields that differ from old to p, in a fixed order. A nil
+// old diffs against an empty product, for the revision creating p.
+func Diff(old *Product, p *Product) []Change {
+	changes := make([]Change, 0)
+
+	var previous [][2]string
+	if old != nil {
+		previous = fieldValues(old)
+	}
+
+	for i, field := range fieldValues(p) {
+		var before string
+		if previous != nil {
+			before = previous[i][1]
+		}
+
+		if before != field[1] {
+			changes = append(changes, Change{Field: field[0], Old: before, New: field[1]})
+		}
+	}
+
+	return changes
+}
+
+// LockedChanges returns the MaterialFields among changes.
+func LockedChanges(changes []Change) []string {
+	locked := make([]string, 0)
+	for _, c := range changes {
+		if slices.Contains(MaterialFields, c.Field) {
+			locked = append(locked, c.Field)
+		}
+	}
+
+	return locked
+}
+
+func fieldValues(p *Product) [][2]string {
+	var price, category, endsAt string
+
+	if p.Price.Currency != "" {
+		price = p.Price.String() + " " + p.Price.Currency
+	}
+
+	if p.CategoryID.Valid {
+		category = p.CategoryID.UUID.String()
+	}
+
+	if p.EndsAt != nil {
+		endsAt = p.EndsAt.UTC().Format(time.RFC3339)
+	}
+
+	return [][2]string{
+		{FieldTitle, p.Title},
+		{FieldDescription, p.Description},
+		{FieldPrice, price},
+		{FieldCategory, category},
+		{FieldTags, strings.Join(p.Tags, ", ")},
+		{FieldEndsAt, endsAt},
+	}
+}
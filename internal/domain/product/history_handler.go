@@ -0,0 +1,161 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/notification"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/google/uuid"
+)
+
+// maxAddendumLength is how long an addendum may be.
+const maxAddendumLength = 2000
+
+// History lists every change to a product, newest first.
+func (h *ProductHandler) History(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	p, err := h.Products.GetByID(ctx, id)
+	if err != nil || !visible(r, p) {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	revisions, err := h.Products.GetRevisions(ctx, id)
+	if err != nil {
+		logger.Error("error getting history", "err", err)
+		http.Error(w, "error getting history", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(revisions); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// AddAddendum appends a note to a product. It is how sellers correct or
+// complete a product once bids lock its description and price, so the
+// bidders are told about it.
+func (h *ProductHandler) AddAddendum(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Text string `json:"text"`
+	}
+
+	if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logger.Info("error decoding body", "err", err)
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	body.Text = strings.TrimSpace(body.Text)
+	if body.Text == "" || len(body.Text) > maxAddendumLength {
+		logger.Info("invalid addendum")
+		http.Error(w, fmt.Sprintf("a text of up to %d characters is required", maxAddendumLength), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	p, err := h.Products.GetByID(ctx, id)
+	if err != nil || !visible(r, p) {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if p.AccountID != principal.AccountID {
+		logger.Info("account does not own the product", "product_id", id)
+		http.Error(w, "only the seller can add to a product", http.StatusForbidden)
+		return
+	}
+
+	addendum := Addendum{ProductID: id, Text: body.Text}
+
+	if err = h.Products.AddAddendum(ctx, &addendum, principal.AccountID); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			logger.Info("not found", "err", err)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		logger.Error("error adding addendum", "err", err)
+		http.Error(w, "error adding addendum", http.StatusInternalServerError)
+		return
+	}
+
+	h.notifyAddendum(ctx, p)
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err = json.NewEncoder(w).Encode(addendum); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// notifyAddendum tells the bidders on p that its seller added to it.
+// Failures are only logged, the addendum is already saved.
+func (h *ProductHandler) notifyAddendum(ctx context.Context, p *Product) {
+	ctx = context.WithoutCancel(ctx)
+
+	bidders, err := h.Bids.Bidders(ctx, p.ID)
+	if err != nil {
+		middlewares.Logger(ctx).Error("error getting bidders to notify", "err", err)
+		return
+	}
+
+	for _, accountID := range bidders {
+		if accountID == p.AccountID {
+			continue
+		}
+
+		n := notification.Notification{
+			AccountID: accountID,
+			Kind:      notification.KindProductAddendum,
+			Message:   fmt.Sprintf("The seller added a note to %q", p.Title),
+			ProductID: uuid.NullUUID{UUID: p.ID, Valid: true},
+		}
+
+		if err := h.Notifications.Create(ctx, &n); err != nil {
+			middlewares.Logger(ctx).Error("error notifying", "recipient_id", accountID, "err", err)
+		}
+	}
+}
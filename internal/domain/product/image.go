@@ -0,0 +1,147 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/blob"
+	"github.com/Nier704/arthur-leilao-server/internal/imaging"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrImageNotFound = errors.New("image not found")
+	// ErrInvalidOrder is returned by Reorder unless it is given each image
+	// of the product exactly once.
+	ErrInvalidOrder = errors.New("the order must list every image of the product once")
+)
+
+// ImageTypes maps the content types accepted for product photos to the
+// extension their blobs are stored with.
+var ImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Image statuses. Uploads are rendered into ImageSizes in the background.
+const (
+	ImageProcessing = "processing"
+	ImageReady      = "ready"
+	// ImageFailed could not be decoded. Sellers should delete it.
+	ImageFailed = "failed"
+)
+
+// ImageSizes are the copies made of every upload.
+var ImageSizes = []imaging.Size{
+	{Name: "thumbnail", Width: 200, Height: 200, Crop: true},
+	{Name: "card", Width: 600, Height: 600},
+	{Name: "full", Width: 1600, Height: 1600},
+}
+
+// Image is a photo of a product. The upload lives in the blob store under
+// Key until it is processed; buyers only ever see the Variants, which carry
+// none of its metadata.
+type Image struct {
+	ID          uuid.UUID `json:"id"`
+	ProductID   uuid.UUID `json:"product_id"`
+	Key         string    `json:"-"`
+	ContentType string    `json:"-"`
+	Size        int64     `json:"-"`
+	// Position orders the images of a product, starting at 0. The first
+	// image is the cover.
+	Position  int       `json:"position"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	// Variants holds a copy for each of ImageSizes, by name, once the
+	// image is ready.
+	Variants map[string]Variant `json:"variants"`
+}
+
+// Variant is a resized copy of an image.
+type Variant struct {
+	Key         string `json:"-"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	// URL is an expiring link to the file. It is never stored.
+	URL string `json:"url,omitempty"`
+	// WebPKey holds the same copy as WebP. It is empty for images
+	// processed before WebP copies were made.
+	WebPKey string `json:"-"`
+	WebPURL string `json:"webp_url,omitempty"`
+}
+
+// ImageKey is where the upload of an image is kept in the blob store.
+func ImageKey(productID uuid.UUID, imageID uuid.UUID, contentType string) string {
+	return "products/" + productID.String() + "/" + imageID.String() + ImageTypes[contentType]
+}
+
+// VariantKey is where a resized copy of an image is kept.
+func VariantKey(img *Image, size string, contentType string) string {
+	return "products/" + img.ProductID.String() + "/" + img.ID.String() + "/" + size + ImageTypes[contentType]
+}
+
+// Keys lists the blobs of an image, to delete along with it.
+func (img *Image) Keys() []string {
+	keys := []string{img.Key}
+	for _, v := range img.Variants {
+		keys = append(keys, v.Key)
+		if v.WebPKey != "" {
+			keys = append(keys, v.WebPKey)
+		}
+	}
+
+	return keys
+}
+
+// LinkImages fills in the URL of each variant.
+func LinkImages(urls *blob.URLs, images []Image) error {
+	for _, img := range images {
+		for size, v := range img.Variants {
+			url, err := urls.URL(v.Key)
+			if err != nil {
+				return err
+			}
+
+			v.URL = url
+
+			if v.WebPKey != "" {
+				if v.WebPURL, err = urls.URL(v.WebPKey); err != nil {
+					return err
+				}
+			}
+
+			img.Variants[size] = v
+		}
+	}
+
+	return nil
+}
+
+type ImageRepository interface {
+	// Create appends img to the images of its product, setting its
+	// Position, Status and CreatedAt. img.ID and img.Key must be set
+	// already, since the file is stored first. It returns ErrNotFound when
+	// the product does not exist.
+	Create(ctx context.Context, img *Image) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Image, error)
+	// GetByProduct returns the images of a product in order.
+	GetByProduct(ctx context.Context, productID uuid.UUID) ([]Image, error)
+	// GetByProducts returns the images of several products by product id,
+	// each in order.
+	GetByProducts(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID][]Image, error)
+	// GetProcessing returns the images waiting to be processed, oldest
+	// first.
+	GetProcessing(ctx context.Context) ([]Image, error)
+	// SetVariants stores the copies made of an image and marks it ready.
+	SetVariants(ctx context.Context, id uuid.UUID, variants map[string]Variant) error
+	// SetStatus marks an image with one of the image statuses.
+	SetStatus(ctx context.Context, id uuid.UUID, status string) error
+	// Delete removes an image, moving the ones after it up.
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Reorder sets the order of the images of a product to ids.
+	Reorder(ctx context.Context, productID uuid.UUID, ids []uuid.UUID) error
+}
@@ -0,0 +1,464 @@
+package product
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Nier704/arthur-leilao-server/config"
+	"github.com/Nier704/arthur-leilao-server/internal/blob"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/google/uuid"
+)
+
+// ImageHandler manages the photos of products. Files go to the blob store
+// and are handed out as expiring links.
+type ImageHandler struct {
+	Products  ProductRepository
+	Images    ImageRepository
+	URLs      *blob.URLs
+	Processor *ImageProcessor
+	cfg       *config.ImageConfig
+}
+
+func NewImageHandler(products ProductRepository, images ImageRepository, urls *blob.URLs, processor *ImageProcessor, cfg *config.ImageConfig) *ImageHandler {
+	return &ImageHandler{
+		Products:  products,
+		Images:    images,
+		URLs:      urls,
+		Processor: processor,
+		cfg:       cfg,
+	}
+}
+
+// Upload adds the files sent as "image" fields of a multipart form to the
+// product, after the ones it has. The type of each file is sniffed from its
+// contents; the one the client claims is ignored. The images are processing
+// until their copies are ready. Products that went to review take no new
+// images, as those would skip it.
+func (h *ImageHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+
+	p, ok := h.editableProduct(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	// editableProduct answered already if there is no principal
+	if principal, _ := middlewares.PrincipalFromContext(ctx); reviewLocked(p, principal) {
+		logger.Info("product was reviewed, its images are locked", "product_id", p.ID)
+		http.Error(w, "images cannot be added while a product is listed or awaiting review", http.StatusConflict)
+		return
+	}
+
+	existing, err := h.Images.GetByProduct(ctx, p.ID)
+	if err != nil {
+		logger.Error("error getting images", "err", err)
+		http.Error(w, "error getting images", http.StatusInternalServerError)
+		return
+	}
+
+	// room for every image the product may still take, plus the form around them
+	r.Body = http.MaxBytesReader(w, r.Body, int64(h.cfg.MaxCount-len(existing))*h.cfg.MaxBytes+1<<20)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		logger.Info("error reading multipart body", "err", err)
+		http.Error(w, "a multipart/form-data body is required", http.StatusBadRequest)
+		return
+	}
+
+	images := make([]Image, 0)
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.discard(ctx, images)
+			h.writeError(w, r, fmt.Errorf("%w: %w", errInvalidUpload, err))
+			return
+		}
+
+		if part.FormName() != "image" {
+			continue
+		}
+
+		if len(existing)+len(images) >= h.cfg.MaxCount {
+			h.discard(ctx, images)
+			logger.Info("product has too many images", "product_id", p.ID)
+			http.Error(w, fmt.Sprintf("a product may have at most %d images", h.cfg.MaxCount), http.StatusBadRequest)
+			return
+		}
+
+		img, err := h.store(ctx, p.ID, part)
+		if err != nil {
+			h.discard(ctx, images)
+			h.writeError(w, r, err)
+			return
+		}
+
+		images = append(images, *img)
+	}
+
+	if len(images) == 0 {
+		logger.Info("no image in upload")
+		http.Error(w, `no "image" field in the form`, http.StatusBadRequest)
+		return
+	}
+
+	for _, img := range images {
+		h.Processor.Enqueue(img.ID)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := json.NewEncoder(w).Encode(images); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// GetByProduct lists the images of a product in order.
+func (h *ImageHandler) GetByProduct(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	p, err := h.Products.GetByID(ctx, id)
+	if err != nil || !visible(r, p) {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	images, err := h.Images.GetByProduct(ctx, id)
+	if err != nil {
+		logger.Error("error getting images", "err", err)
+		http.Error(w, "error getting images", http.StatusInternalServerError)
+		return
+	}
+
+	if !h.link(w, r, images) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(images); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *ImageHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+
+	p, ok := h.editableProduct(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("imageId"))
+	if err != nil {
+		logger.Info("invalid image id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	img, err := h.Images.GetByID(ctx, id)
+	if err != nil || img.ProductID != p.ID {
+		logger.Info("image not found", "image_id", id, "product_id", p.ID, "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if err = h.Images.Delete(ctx, id); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	deleteFiles(ctx, h.URLs.Store, *img)
+
+	res := map[string]string{
+		"message": "image deleted",
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// Reorder sets the order of the images of a product. The body lists the
+// ids of every image, cover first.
+func (h *ImageHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+
+	p, ok := h.editableProduct(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logger.Info("error decoding body", "err", err)
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Images.Reorder(ctx, p.ID, body.IDs); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	images, err := h.Images.GetByProduct(ctx, p.ID)
+	if err != nil {
+		logger.Error("error getting images", "err", err)
+		http.Error(w, "error getting images", http.StatusInternalServerError)
+		return
+	}
+
+	if !h.link(w, r, images) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(images); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// Serve streams a blob to the holder of a link made by URLs.
+func (h *ImageHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	key := r.PathValue("key")
+	q := r.URL.Query()
+
+	if !h.URLs.Verify(key, q.Get("expires"), q.Get("sig")) {
+		logger.Info("invalid or expired link", "key", key)
+		http.Error(w, "invalid or expired link", http.StatusForbidden)
+		return
+	}
+
+	ctx := r.Context()
+
+	f, err := h.URLs.Store.Open(ctx, key)
+	if errors.Is(err, blob.ErrNotFound) {
+		logger.Info("blob not found", "key", key)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logger.Error("error opening blob", "key", key, "err", err)
+		http.Error(w, "error opening image", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", imageType(key))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+
+	if _, err = io.Copy(w, f); err != nil {
+		logger.Error("error sending blob", "key", key, "err", err)
+	}
+}
+
+// store saves one uploaded file and records it as an image of the product.
+func (h *ImageHandler) store(ctx context.Context, productID uuid.UUID, part io.Reader) (*Image, error) {
+	data, err := io.ReadAll(io.LimitReader(part, h.cfg.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > h.cfg.MaxBytes {
+		return nil, errImageTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if _, ok := ImageTypes[contentType]; !ok {
+		return nil, fmt.Errorf("%w: %s", errImageType, contentType)
+	}
+
+	img := &Image{
+		ID:          uuid.New(),
+		ProductID:   productID,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	img.Key = ImageKey(productID, img.ID, contentType)
+
+	if err = h.URLs.Store.Put(ctx, img.Key, bytes.NewReader(data), img.Size, contentType); err != nil {
+		return nil, err
+	}
+
+	if err = h.Images.Create(ctx, img); err != nil {
+		if err := h.URLs.Store.Delete(context.WithoutCancel(ctx), img.Key); err != nil {
+			middlewares.Logger(ctx).Error("error deleting blob", "key", img.Key, "err", err)
+		}
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// discard removes images uploaded by a request that failed later on, so
+// an upload is kept whole or not at all.
+func (h *ImageHandler) discard(ctx context.Context, images []Image) {
+	// the request may have failed because its caller went away
+	ctx = context.WithoutCancel(ctx)
+
+	for _, img := range images {
+		if err := h.Images.Delete(ctx, img.ID); err != nil {
+			middlewares.Logger(ctx).Error("error deleting image", "image_id", img.ID, "err", err)
+		}
+		deleteFiles(ctx, h.URLs.Store, img)
+	}
+}
+
+// deleteFiles removes the blobs of images whose rows are gone. Failures
+// only leave orphaned files, so they are logged.
+func deleteFiles(ctx context.Context, blobs blob.BlobStore, images ...Image) {
+	// the rows are gone whether or not the caller is still there
+	ctx = context.WithoutCancel(ctx)
+
+	for _, img := range images {
+		for _, key := range img.Keys() {
+			if err := blobs.Delete(ctx, key); err != nil {
+				middlewares.Logger(ctx).Error("error deleting blob", "key", key, "err", err)
+			}
+		}
+	}
+}
+
+// link fills in the URLs of the variants of images.
+func (h *ImageHandler) link(w http.ResponseWriter, r *http.Request, images []Image) bool {
+	logger := middlewares.Logger(r.Context())
+
+	if err := LinkImages(h.URLs, images); err != nil {
+		logger.Error("error linking images", "err", err)
+		http.Error(w, "error linking images", http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
+// editableProduct returns the product in the path if the caller put it up
+// or is an admin.
+func (h *ImageHandler) editableProduct(ctx context.Context, w http.ResponseWriter, r *http.Request) (*Product, bool) {
+	logger := middlewares.Logger(r.Context())
+
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	id, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return nil, false
+	}
+
+	p, err := h.Products.GetByID(ctx, id)
+	if err != nil {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if p.AccountID != principal.AccountID && principal.Role != account.RoleAdmin {
+		logger.Info("account does not own the product", "product_id", id)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil, false
+	}
+
+	return p, true
+}
+
+var (
+	errImageTooLarge = errors.New("image too large")
+	errImageType     = errors.New("unsupported image type")
+	errInvalidUpload = errors.New("invalid multipart body")
+)
+
+func (h *ImageHandler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := middlewares.Logger(r.Context())
+
+	var bodyTooLarge *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &bodyTooLarge):
+		logger.Info("upload too large", "err", err)
+		http.Error(w, "upload too large", http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errInvalidUpload):
+		logger.Info("invalid upload", "err", err)
+		http.Error(w, "invalid multipart body", http.StatusBadRequest)
+	case errors.Is(err, errImageTooLarge):
+		logger.Info("image too large", "err", err)
+		http.Error(w, fmt.Sprintf("images may be at most %d bytes", h.cfg.MaxBytes), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, errImageType):
+		logger.Info("unsupported image", "err", err)
+		http.Error(w, "images must be JPEG, PNG, GIF or WebP", http.StatusUnsupportedMediaType)
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrImageNotFound):
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, ErrInvalidOrder):
+		logger.Info("invalid image order", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		logger.Error("error saving image", "err", err)
+		http.Error(w, "error saving image", http.StatusInternalServerError)
+	}
+}
+
+// imageType returns the content type of a blob from its extension.
+func imageType(key string) string {
+	for contentType, ext := range ImageTypes {
+		if strings.HasSuffix(key, ext) {
+			return contentType
+		}
+	}
+
+	return "application/octet-stream"
+}
@@ -0,0 +1,199 @@
+package product
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Nier704/arthur-leilao-server/internal/blob"
+	"github.com/Nier704/arthur-leilao-server/internal/imaging"
+	"github.com/google/uuid"
+)
+
+// ImageProcessor renders uploaded images into ImageSizes in the
+// background. Once the copies are stored the upload itself is deleted, so
+// the metadata it carried is gone for good.
+type ImageProcessor struct {
+	Images  ImageRepository
+	Blobs   blob.BlobStore
+	workers int
+	queue   chan uuid.UUID
+	running atomic.Int32
+	wg      sync.WaitGroup
+}
+
+func NewImageProcessor(images ImageRepository, blobs blob.BlobStore, workers int) *ImageProcessor {
+	return &ImageProcessor{
+		Images:  images,
+		Blobs:   blobs,
+		workers: max(1, workers),
+		queue:   make(chan uuid.UUID, 100),
+	}
+}
+
+// Start runs the workers until ctx is done, queueing first the images a
+// previous run left processing. Images being processed when ctx is done
+// are finished; see Wait.
+func (p *ImageProcessor) Start(ctx context.Context) error {
+	pending, err := p.Images.GetProcessing(ctx)
+	if err != nil {
+		return err
+	}
+
+	for range p.workers {
+		p.running.Add(1)
+		p.wg.Add(1)
+		go p.work(ctx)
+	}
+
+	for _, img := range pending {
+		p.Enqueue(img.ID)
+	}
+
+	return nil
+}
+
+// Enqueue asks for an image to be processed. It does not wait for a worker
+// to be free.
+func (p *ImageProcessor) Enqueue(id uuid.UUID) {
+	select {
+	case p.queue <- id:
+	default:
+		go func() { p.queue <- id }()
+	}
+}
+
+// Workers returns how many workers are running and how many there should be.
+func (p *ImageProcessor) Workers() (int, int) {
+	return int(p.running.Load()), p.workers
+}
+
+// Wait waits for the workers to return once the context given to Start is
+// done, or for ctx to be done. Images left queued are processed on the next
+// start.
+func (p *ImageProcessor) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *ImageProcessor) work(ctx context.Context) {
+	defer p.wg.Done()
+	defer p.running.Add(-1)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-p.queue:
+			if err := p.process(context.WithoutCancel(ctx), id); err != nil {
+				slog.Error("error processing image", "image_id", id, "err", err)
+			}
+		}
+	}
+}
+
+// process renders one image. Images that cannot be decoded, or whose
+// upload is missing, are marked failed; other errors leave the image
+// processing, to be retried on the next start.
+func (p *ImageProcessor) process(ctx context.Context, id uuid.UUID) error {
+	img, err := p.Images.GetByID(ctx, id)
+	if errors.Is(err, ErrImageNotFound) {
+		// deleted while queued
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if img.Status != ImageProcessing {
+		return nil
+	}
+
+	data, err := p.read(ctx, img.Key)
+	if errors.Is(err, blob.ErrNotFound) {
+		slog.Info("upload of image is missing", "image_id", id)
+		return p.Images.SetStatus(ctx, id, ImageFailed)
+	}
+	if err != nil {
+		return err
+	}
+
+	derivatives, err := imaging.Derive(data, ImageSizes)
+	if errors.Is(err, imaging.ErrDecode) {
+		slog.Info("image cannot be decoded", "image_id", id, "err", err)
+		p.delete(ctx, img.Key)
+		return p.Images.SetStatus(ctx, id, ImageFailed)
+	}
+	if err != nil {
+		return err
+	}
+
+	variants := make(map[string]Variant, len(derivatives))
+
+	var keys []string
+
+	for _, d := range derivatives {
+		key := VariantKey(img, d.Size, d.ContentType)
+
+		if err = p.Blobs.Put(ctx, key, bytes.NewReader(d.Data), int64(len(d.Data)), d.ContentType); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+
+		// each size comes as a JPEG and a WebP
+		v := variants[d.Size]
+		if d.ContentType == "image/webp" {
+			v.WebPKey = key
+		} else {
+			v.Key, v.ContentType = key, d.ContentType
+		}
+		v.Width, v.Height = d.Width, d.Height
+
+		variants[d.Size] = v
+	}
+
+	if err = p.Images.SetVariants(ctx, id, variants); err != nil {
+		// the image or its product went away meanwhile
+		for _, key := range keys {
+			p.delete(ctx, key)
+		}
+		if errors.Is(err, ErrImageNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	p.delete(ctx, img.Key)
+
+	return nil
+}
+
+func (p *ImageProcessor) read(ctx context.Context, key string) ([]byte, error) {
+	f, err := p.Blobs.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+func (p *ImageProcessor) delete(ctx context.Context, key string) {
+	if err := p.Blobs.Delete(ctx, key); err != nil {
+		slog.Error("error deleting blob", "key", key, "err", err)
+	}
+}
@@ -1,12 +1,338 @@
 package product
 
-import "github.com/google/uuid"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound    = errors.New("product not found")
+	ErrInvalidTag  = errors.New("invalid tag")
+	ErrBidNotFound = errors.New("bid not found")
+	// ErrBidNotRetractable is returned for bids already retracted or won.
+	ErrBidNotRetractable = errors.New("bid cannot be retracted")
+	// ErrRetractionLimit is returned when the bidder already retracted as
+	// many bids as a RetractionLimit allows.
+	ErrRetractionLimit  = errors.New("retraction limit reached")
+	ErrAlreadyPublished = errors.New("product already published")
+	// ErrNotPublished is returned when bidding on a draft.
+	ErrNotPublished = errors.New("product is not published")
+	// ErrAuctionEnded is returned when bidding after ends_at, or once the
+	// auction is closed.
+	ErrAuctionEnded = errors.New("auction has ended")
+	// ErrBidTooLow is returned for bids not above the active bid.
+	ErrBidTooLow = errors.New("bid must be higher than the current bid")
+	// ErrNotPending is returned when approving a product that is not
+	// awaiting review, or rejecting a draft that is not.
+	ErrNotPending = errors.New("product is not awaiting review")
+)
+
+const (
+	// BidActive is the standing highest bid on a product.
+	BidActive = "active"
+	// BidOutbid has been beaten by a higher bid.
+	BidOutbid    = "outbid"
+	BidRetracted = "retracted"
+	// BidWinning is the active bid when the auction closed.
+	BidWinning = "winning"
+)
+
+// RetractionLimit allows an account at most Max retractions since Since.
+type RetractionLimit struct {
+	Max   int
+	Since time.Time
+}
+
+// RemovedMessage replaces bid messages a moderator took down.
+const RemovedMessage = "[removed by a moderator]"
+
+// Review statuses. Products from unverified sellers, and products a
+// moderator rejected before, wait for a moderator when published.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	// ReviewRejected products are drafts again, with the reason given.
+	ReviewRejected = "rejected"
+)
 
 type Product struct {
-	ID          uuid.UUID `json:"id"`
-	AccountID   uuid.UUID `json:"account_id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	ImageURL    string    `json:"image_url"`
+	ID          uuid.UUID   `json:"id"`
+	AccountID   uuid.UUID   `json:"account_id"`
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	// CategoryID is a leaf of the category tree.
+	CategoryID uuid.NullUUID `json:"category_id"`
+	Tags       []string      `json:"tags"`
+	// EndsAt is when bidding closes; nil leaves the auction open.
+	EndsAt    *time.Time `json:"ends_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	// PublishedAt is nil while the product is a draft. Drafts are only
+	// shown to their seller and take no bids.
+	PublishedAt *time.Time `json:"published_at"`
+	// ReviewStatus is empty until a moderator is asked to check the
+	// product. ReviewReason explains the moderator's decision.
+	ReviewStatus string `json:"review_status,omitempty"`
+	ReviewReason string `json:"review_reason,omitempty"`
+	// BidCount counts the bids placed on the product, leaving out
+	// retracted ones.
+	BidCount int `json:"bid_count"`
+	// Images are the photos of the product in order. ProductRepository
+	// leaves them out; they come from ImageRepository.
+	Images []Image `json:"images"`
+	// Addenda are the notes the seller appended, oldest first. Only the
+	// product page carries them.
+	Addenda []Addendum `json:"addenda,omitempty"`
+	// DisplayPrice is Price converted to the currency asked for with
+	// ?currency=, absent when there is no rate to convert it. It is never
+	// stored.
+	DisplayPrice *money.Money `json:"display_price,omitempty"`
+}
+
+type Bid struct {
+	ID        uuid.UUID `json:"id"`
+	AccountID uuid.UUID `json:"account_id"`
+	ProductID uuid.UUID `json:"product_id"`
+	// BidValue is always in the currency of the product.
+	BidValue   money.Money `json:"bid_value"`
+	BidMessage string      `json:"bid_message"`
+	Status     string      `json:"status"`
+	// IP and UserAgent identify the client that placed the bid. They are
+	// kept for audits and never shown.
+	IP               string     `json:"-"`
+	UserAgent        string     `json:"-"`
+	CreatedAt        time.Time  `json:"created_at"`
+	RetractedAt      *time.Time `json:"retracted_at,omitempty"`
+	RetractionReason string     `json:"retraction_reason,omitempty"`
+	// DisplayValue is BidValue converted to the currency asked for with
+	// ?currency=, absent when there is no rate to convert it. It is never
+	// stored.
+	DisplayValue *money.Money `json:"display_value,omitempty"`
+}
+
+const (
+	maxTags      = 10
+	maxTagLength = 30
+	// maxTextLength is how many characters titles and descriptions hold,
+	// as their VARCHAR(255) columns do.
+	maxTextLength = 255
+)
+
+// NormalizeTags lowercases tags and squeezes their spaces, dropping
+// duplicates, and sorts them. Tags are made of letters, digits, spaces and
+// hyphens.
+func NormalizeTags(tags []string) ([]string, error) {
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("%w: at most %d tags", ErrInvalidTag, maxTags)
+	}
+
+	normalized := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength || strings.ContainsFunc(tag, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != ' ' && r != '-'
+		}) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+		}
+
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+
+	slices.Sort(normalized)
+
+	return normalized, nil
+}
+
+// Sort orders for ProductRepository.List. Every order ends with the id so
+// cursors are stable.
+const (
+	SortNewest     = "newest"
+	SortEndingSoon = "ending_soon"
+	// SortPriceAsc and SortPriceDesc group products by currency, since
+	// amounts in different currencies do not compare.
+	SortPriceAsc  = "price_asc"
+	SortPriceDesc = "price_desc"
+	SortMostBids  = "most_bids"
+)
+
+// Auction statuses, derived from EndsAt.
+const (
+	StatusOpen  = "open"
+	StatusEnded = "ended"
+)
+
+// ListOptions filters and orders ProductRepository.List.
+type ListOptions struct {
+	// MinPrice and MaxPrice bound the price. Either one leaves out the
+	// products in other currencies.
+	MinPrice *money.Money
+	MaxPrice *money.Money
+	// Seller keeps the products the account put up.
+	Seller uuid.NullUUID
+	// Categories keeps the products in any of the categories; empty keeps
+	// every product.
+	Categories []uuid.UUID
+	Tag        string
+	// Status is StatusOpen, StatusEnded or empty for both.
+	Status string
+	// EndingBefore keeps the open auctions that end by then.
+	EndingBefore *time.Time
+	// Now decides which auctions are open.
+	Now   time.Time
+	Sort  string
+	Limit int
+	// After continues a listing from where the previous page stopped.
+	After *Cursor
+	// Drafts lists the drafts instead of the published products. It is
+	// only set together with Seller.
+	Drafts bool
+}
+
+// Cursor is the position of a product in a listing: the values it was
+// sorted by and its id.
+type Cursor struct {
+	Sort      string       `json:"s"`
+	CreatedAt *time.Time   `json:"c,omitempty"`
+	EndsAt    *time.Time   `json:"e,omitempty"`
+	Price     *money.Money `json:"p,omitempty"`
+	BidCount  *int         `json:"b,omitempty"`
+	ID        uuid.UUID    `json:"i"`
+}
+
+// CursorOf returns the position of p in a listing sorted by sort.
+func CursorOf(p Product, sort string) Cursor {
+	c := Cursor{Sort: sort, ID: p.ID}
+
+	switch sort {
+	case SortNewest:
+		createdAt := p.CreatedAt.UTC()
+		c.CreatedAt = &createdAt
+	case SortEndingSoon:
+		if p.EndsAt != nil {
+			endsAt := p.EndsAt.UTC()
+			c.EndsAt = &endsAt
+		}
+	case SortPriceAsc, SortPriceDesc:
+		price := p.Price
+		c.Price = &price
+	case SortMostBids:
+		count := p.BidCount
+		c.BidCount = &count
+	}
+
+	return c
+}
+
+// Valid reports whether c carries the value its sort order needs.
+func (c Cursor) Valid() bool {
+	switch c.Sort {
+	case SortNewest:
+		return c.CreatedAt != nil
+	case SortEndingSoon:
+		return c.EndsAt != nil
+	case SortPriceAsc, SortPriceDesc:
+		return c.Price != nil
+	case SortMostBids:
+		return c.BidCount != nil
+	}
+
+	return false
+}
+
+type ProductPage struct {
+	Products []Product
+	// Total counts the products matching the filters across all pages.
+	Total   int
+	HasMore bool
+}
+
+type ProductRepository interface {
+	GetAll(ctx context.Context) ([]Product, error)
+	// List returns a page of the products matching opts. Listings sorted
+	// by SortEndingSoon leave out the auctions without an end.
+	List(ctx context.Context, opts ListOptions) (*ProductPage, error)
+	// Search returns a page of the published products matching the query,
+	// best match first, and how many match in all.
+	Search(ctx context.Context, query string, limit int, offset int) ([]SearchResult, int, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*Product, error)
+	// GetByAccount returns the products associated with an account.
+	GetByAccount(ctx context.Context, accountID uuid.UUID) ([]Product, error)
+	// Create inserts p, recording its creation as the first revision by
+	// the seller.
+	Create(ctx context.Context, p *Product) error
+	// CreateMany creates products like Create, all of them or, on error,
+	// none.
+	CreateMany(ctx context.Context, products []Product) error
+	// Update saves the details of p and records what changed as a revision
+	// by editorID. Once the product has bids, changing any of
+	// MaterialFields returns ErrLocked. It does not publish p.
+	Update(ctx context.Context, p *Product, editorID uuid.UUID) error
+	// Publish sets the PublishedAt of a draft. It returns
+	// ErrAlreadyPublished for products that are not drafts.
+	Publish(ctx context.Context, id uuid.UUID, at time.Time) error
+	// Submit puts a draft in the review queue. It returns
+	// ErrAlreadyPublished for products that are not drafts.
+	Submit(ctx context.Context, id uuid.UUID) error
+	// GetPending returns the products awaiting review, oldest first.
+	GetPending(ctx context.Context) ([]Product, error)
+	// Approve publishes a product awaiting review.
+	Approve(ctx context.Context, id uuid.UUID, reason string, at time.Time) error
+	// Reject takes a product awaiting review, or a published one, back to
+	// draft.
+	Reject(ctx context.Context, id uuid.UUID, reason string) error
+	// GetRevisions returns the history of a product, newest first.
+	GetRevisions(ctx context.Context, productID uuid.UUID) ([]Revision, error)
+	// AddAddendum appends a to its product, recording it as a revision by
+	// editorID, and sets its ID and CreatedAt.
+	AddAddendum(ctx context.Context, a *Addendum, editorID uuid.UUID) error
+	// GetAddenda returns the addenda of a product, oldest first.
+	GetAddenda(ctx context.Context, productID uuid.UUID) ([]Addendum, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	Associate(ctx context.Context, accountID uuid.UUID, productID uuid.UUID) error
+	// CloseEnded closes the published auctions that ended by now, marking
+	// their active bid BidWinning, and returns how many it closed. Each
+	// auction is closed once, whoever calls it.
+	CloseEnded(ctx context.Context, now time.Time) (int, error)
+}
+
+type BidRepository interface {
+	// Create places b as the product's active bid, outbidding the previous
+	// one, and sets its ID, CreatedAt and Status. Bids not above the
+	// active bid fail with ErrBidTooLow. The product is checked again
+	// while it is locked: bids on drafts fail with ErrNotPublished, and
+	// bids on ended or closed auctions with ErrAuctionEnded.
+	Create(ctx context.Context, b *Bid) error
+	GetByID(ctx context.Context, id uuid.UUID) (*Bid, error)
+	GetByAccount(ctx context.Context, accountID uuid.UUID) ([]Bid, error)
+	// GetByProduct returns a page of the product's bids, newest first, and
+	// how many bids the product has in all.
+	GetByProduct(ctx context.Context, productID uuid.UUID, limit int, offset int) ([]Bid, int, error)
+	// Get returns the account's highest bid on the product, the earliest
+	// one winning ties.
+	Get(ctx context.Context, accountID uuid.UUID, productID uuid.UUID) (*Bid, error)
+	// Retract marks the bid retracted with reason, unless its bidder
+	// already reached limit. When it was the active bid, the highest
+	// remaining bid (the earliest on ties) becomes active and is returned;
+	// otherwise the returned bid is nil. The limit is checked in the same
+	// transaction, so concurrent retractions cannot go over it.
+	Retract(ctx context.Context, id uuid.UUID, reason string, at time.Time, limit RetractionLimit) (*Bid, error)
+	// CountRetractions counts the bids the account retracted since then.
+	CountRetractions(ctx context.Context, accountID uuid.UUID, since time.Time) (int, error)
+	// Bidders returns every account that bid on the product.
+	Bidders(ctx context.Context, productID uuid.UUID) ([]uuid.UUID, error)
+	// RemoveMessage replaces the message of a bid with RemovedMessage.
+	RemoveMessage(ctx context.Context, id uuid.UUID) error
 }
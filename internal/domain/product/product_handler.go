@@ -2,598 +2,1677 @@ package product
 
 import (
 	"context"
-	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/config"
+	"github.com/Nier704/arthur-leilao-server/internal/blob"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/exchange"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/notification"
+	"github.com/Nier704/arthur-leilao-server/internal/metrics"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+	"github.com/Nier704/arthur-leilao-server/internal/utils"
 	"github.com/google/uuid"
 )
 
 type ProductHandler struct {
-	DB *sql.DB
+	Products      ProductRepository
+	Bids          BidRepository
+	Rates         exchange.RateRepository
+	Notifications notification.NotificationRepository
+	Categories    category.CategoryRepository
+	Images        ImageRepository
+	URLs          *blob.URLs
+	cfg           *config.BidConfig
 }
 
-func NewProductHandler(db *sql.DB) *ProductHandler {
+func NewProductHandler(products ProductRepository, bids BidRepository, rates exchange.RateRepository, notifications notification.NotificationRepository, categories category.CategoryRepository, images ImageRepository, urls *blob.URLs, cfg *config.BidConfig) *ProductHandler {
 	return &ProductHandler{
-		DB: db,
+		Products:      products,
+		Bids:          bids,
+		Rates:         rates,
+		Notifications: notifications,
+		Categories:    categories,
+		Images:        images,
+		URLs:          urls,
+		cfg:           cfg,
 	}
 }
 
+// Create saves a draft. It is listed once published with Publish.
 func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
 
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	var body Product
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		log.Printf("Error getting product body: %v", err)
+		logger.Error("error getting product body", "err", err)
 		http.Error(w, "error getting product body", http.StatusInternalServerError)
 		return
 	}
 
+	// products are listed under the caller; only admins list for others
+	if body.AccountID == uuid.Nil {
+		body.AccountID = principal.AccountID
+	}
+
+	if body.AccountID != principal.AccountID && principal.Role != account.RoleAdmin {
+		logger.Info("product for another account", "product_account_id", body.AccountID)
+		http.Error(w, "products can only be created for your own account", http.StatusForbidden)
+		return
+	}
+
 	if ok := validateCredentials(&body); ok {
+		if body.EndsAt != nil && !body.EndsAt.After(time.Now()) {
+			logger.Info("auction ends in the past")
+			http.Error(w, "ends_at must be in the future", http.StatusBadRequest)
+			return
+		}
 
-		sql := `
-		INSERT INTO products
-		(title, account_id ,description, price, image_url)
-		VALUES
-		($1, $2, $3, $4, $5)
-		RETURNING id;
-		`
-		ctx := context.Background()
+		ctx := r.Context()
 
-		stmt, err := h.DB.PrepareContext(ctx, sql)
-		if err != nil {
-			log.Printf("Error preparing context: %v", err)
-			http.Error(w, "error preparing context", http.StatusInternalServerError)
+		body.PublishedAt = nil
+
+		if !h.classify(ctx, w, &body) {
 			return
 		}
-		defer stmt.Close()
 
-		var inserted_id string
-		err = stmt.QueryRowContext(ctx, body.Title, body.AccountID, body.Description, body.Price, body.ImageURL).
-			Scan(&inserted_id)
-		if err != nil {
-			log.Printf("Error creating product: %v", err)
+		if err := h.Products.Create(ctx, &body); err != nil {
+			logger.Error("error creating product", "err", err)
 			http.Error(w, "error creating product", http.StatusInternalServerError)
 			return
 		}
 
 		res := map[string]string{
 			"status":      "created",
-			"inserted_id": inserted_id,
+			"inserted_id": body.ID.String(),
 		}
 
 		w.WriteHeader(201)
 
 		if err := json.NewEncoder(w).Encode(res); err != nil {
-			log.Printf("Error encoding response: %v", err)
+			logger.Error("error encoding response", "err", err)
 			http.Error(w, "error encoding response", 500)
 		}
 
 		return
 	}
 
-	fmt.Println("Invalid Body")
+	logger.Info("invalid body")
 	http.Error(w, "invalid body", http.StatusBadRequest)
 }
 
 func (h *ProductHandler) GetById(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
 
-	id := r.PathValue("productId")
-	if id == "" {
-		log.Printf("Invalid id")
+	id, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid id")
 		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
 
-	sql := `
-		SELECT id, account_id, title, description, price, image_url FROM products WHERE id = $1;
-	`
-	ctx := context.Background()
+	ctx := r.Context()
 
-	stmt, err := h.DB.PrepareContext(ctx, sql)
-	if err != nil {
-		log.Printf("Error preparing statement: %v", err)
-		http.Error(w, "error preparing statement", http.StatusInternalServerError)
+	product, err := h.Products.GetByID(ctx, id)
+	if err != nil || !visible(r, product) {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
-	defer stmt.Close()
-
-	var product Product
 
-	if err = stmt.QueryRowContext(ctx, id).
-		Scan(&product.ID, &product.AccountID, &product.Title, &product.Description, &product.Price, &product.ImageURL); err != nil {
-		log.Printf("not found: %v", err)
-		http.Error(w, "not found", http.StatusNotFound)
+	products := []Product{*product}
+	if !h.displayPrices(ctx, w, r, products) || !h.withImages(ctx, w, products) {
 		return
 	}
 
-	_, err = uuid.Parse(product.ID.String())
+	products[0].Addenda, err = h.Products.GetAddenda(ctx, id)
 	if err != nil {
-		log.Printf("Invalid accountId format: %v", err)
-		http.Error(w, "invalid accountId format", 400)
+		logger.Error("error getting addenda", "err", err)
+		http.Error(w, "error getting addenda", http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(200)
 
-	if err := json.NewEncoder(w).Encode(product); err != nil {
-		log.Printf("Error encoding response: %v", err)
+	if err := json.NewEncoder(w).Encode(products[0]); err != nil {
+		logger.Error("error encoding response", "err", err)
 		http.Error(w, "error encoding response", 500)
 	}
 }
 
+// GetAll lists products a page at a time. The next page is asked for with
+// the next_cursor of the previous one and the same filters and sort.
 func (h *ProductHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	logger := middlewares.Logger(r.Context())
 
-	sql := `
-		SELECT id, account_id, title, description, price, image_url FROM products;
-	`
-	ctx := context.Background()
+	w.Header().Set("Content-Type", "application/json")
 
-	stmt, err := h.DB.PrepareContext(ctx, sql)
+	opts, err := listOptions(r, time.Now())
 	if err != nil {
-		log.Printf("Error preparing context: %v", err)
-		http.Error(w, "error preparing context", http.StatusInternalServerError)
+		logger.Info("invalid listing", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	products := make([]Product, 0)
-	rows, err := stmt.QueryContext(ctx)
-	if err != nil {
-		log.Printf("Error getting all products: %v", err)
-		http.Error(w, "error getting products", http.StatusInternalServerError)
-		return
-	}
+	ctx := r.Context()
 
-	for rows.Next() {
-		var product Product
-		if err = rows.Scan(&product.ID, &product.AccountID, &product.Title, &product.Description, &product.Price, &product.ImageURL); err != nil {
-			log.Printf("Error scanning account: %v", err)
-			http.Error(w, "error scanning account", http.StatusInternalServerError)
-			break
+	if v := r.URL.Query().Get("category"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			logger.Info("invalid category id")
+			http.Error(w, "invalid category", http.StatusBadRequest)
+			return
 		}
-		products = append(products, product)
-	}
-
-	w.WriteHeader(http.StatusOK)
 
-	if err := json.NewEncoder(w).Encode(products); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "error encoding response", http.StatusInternalServerError)
+		var ok bool
+		if opts.Categories, ok = h.categoryProducts(ctx, w, id); !ok {
+			return
+		}
 	}
+
+	h.writeList(ctx, w, r, opts)
 }
 
-func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// GetByCategory lists the products in a category and the categories below
+// it, taking the same parameters as GetAll.
+func (h *ProductHandler) GetByCategory(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
 
-	id := r.PathValue("productId")
+	w.Header().Set("Content-Type", "application/json")
 
-	if id == "" {
-		log.Println("Invalid id")
-		http.Error(w, "invalid id", 400)
+	id, err := uuid.Parse(r.PathValue("categoryId"))
+	if err != nil {
+		logger.Info("invalid category id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
 
-	var body Product
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		log.Printf("Error decoding body: %v", err)
-		http.Error(w, "invalid body", 500)
+	opts, err := listOptions(r, time.Now())
+	if err != nil {
+		logger.Info("invalid listing", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if ok := validateCredentials(&body); ok {
-
-		sql := `
-		UPDATE products
-		SET title = $1,
-			description = $2,
-			price = $3,
-			image_url = $4
-		WHERE id = $5
-		RETURNING id, title, description, price, image_url;
-		`
-		ctx := context.Background()
-
-		stmt, err := h.DB.PrepareContext(ctx, sql)
-		if err != nil {
-			log.Printf("Error preparing statement: %v", err)
-			http.Error(w, "error preparing statement", http.StatusInternalServerError)
-			return
-		}
-		defer stmt.Close()
+	ctx := r.Context()
 
-		var product Product
+	var ok bool
+	if opts.Categories, ok = h.categoryProducts(ctx, w, id); !ok {
+		return
+	}
 
-		if err = stmt.QueryRowContext(ctx, body.Title, body.Description, body.Price, body.ImageURL, id).
-			Scan(&product.ID, &product.Title, &product.Description, &product.Price, &product.ImageURL); err != nil {
-			log.Printf("not found: %v", err)
-			http.Error(w, "not found", 404)
-			return
-		}
+	h.writeList(ctx, w, r, opts)
+}
 
-		w.WriteHeader(200)
+// writeList writes a page of the products matching opts.
+func (h *ProductHandler) writeList(ctx context.Context, w http.ResponseWriter, r *http.Request, opts ListOptions) {
+	logger := middlewares.Logger(r.Context())
 
-		if err := json.NewEncoder(w).Encode(product); err != nil {
-			log.Printf("Error encoding response: %v", err)
-			http.Error(w, "error encoding response", 500)
-			return
-		}
+	page, err := h.Products.List(ctx, opts)
+	if err != nil {
+		logger.Error("error getting all products", "err", err)
+		http.Error(w, "error getting products", http.StatusInternalServerError)
+		return
+	}
 
+	if !h.displayPrices(ctx, w, r, page.Products) || !h.withImages(ctx, w, page.Products) {
 		return
 	}
 
-	log.Printf("Invalid credentials")
-	http.Error(w, "invalid credentials", http.StatusBadRequest)
-}
+	res := struct {
+		Products   []Product `json:"products"`
+		Total      int       `json:"total"`
+		HasMore    bool      `json:"has_more"`
+		NextCursor string    `json:"next_cursor,omitempty"`
+	}{
+		Products: page.Products,
+		Total:    page.Total,
+		HasMore:  page.HasMore,
+	}
 
-func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	if page.HasMore {
+		res.NextCursor = encodeCursor(CursorOf(page.Products[len(page.Products)-1], opts.Sort))
+	}
 
-	id := r.PathValue("productId")
+	w.WriteHeader(http.StatusOK)
 
-	if id == "" {
-		log.Println("Invalid id")
-		http.Error(w, "invalid id", 400)
-		return
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
 	}
+}
 
-	sql := `DELETE FROM products WHERE id = $1`
+// Search finds products by keywords in ?q=, best match first.
+func (h *ProductHandler) Search(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
 
-	ctx := context.Background()
+	w.Header().Set("Content-Type", "application/json")
 
-	stmt, err := h.DB.PrepareContext(ctx, sql)
-	if err != nil {
-		log.Printf("Error preparing statement: %v", err)
-		http.Error(w, "error preparing statement", http.StatusInternalServerError)
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		logger.Info("missing search query")
+		http.Error(w, "q is required", http.StatusBadRequest)
 		return
 	}
-	defer stmt.Close()
 
-	result, err := stmt.ExecContext(ctx, id)
+	limit, offset, err := pageParams(r)
 	if err != nil {
-		log.Printf("Error deleting product: %v", err)
-		http.Error(w, "not found", http.StatusNotFound)
+		logger.Info("invalid pagination", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	rows, err := result.RowsAffected()
+	ctx := r.Context()
+
+	results, total, err := h.Products.Search(ctx, q, limit, offset)
 	if err != nil {
-		log.Printf("Error getting rows affected: %v", err)
-		http.Error(w, "error getting rows affected", http.StatusInternalServerError)
+		logger.Error("error searching products", "err", err)
+		http.Error(w, "error searching products", http.StatusInternalServerError)
 		return
 	}
 
-	if rows > 1 {
-		log.Printf("Error more than one line was affected: %v", err)
-		http.Error(w, "error: more than one line was affected", http.StatusInternalServerError)
+	products := make([]Product, len(results))
+	for i := range results {
+		products[i] = results[i].Product
+	}
+
+	if !h.displayPrices(ctx, w, r, products) || !h.withImages(ctx, w, products) {
 		return
 	}
 
-	res := map[string]string{
-		"message": "product deleted",
+	for i := range results {
+		results[i].DisplayPrice = products[i].DisplayPrice
+		results[i].Images = products[i].Images
 	}
 
-	w.WriteHeader(200)
+	res := struct {
+		Results []SearchResult `json:"results"`
+		Total   int            `json:"total"`
+		Limit   int            `json:"limit"`
+		Offset  int            `json:"offset"`
+	}{results, total, limit, offset}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "error encoding response", 500)
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
 	}
 }
 
-// AssociateProductWithAccount associates a product with an account
-func (h *ProductHandler) AssociateProductWithAccount(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// Update saves a product's details. Once bids are placed the description,
+// price and end are locked, and the seller adds an addendum instead. Once the
+// product goes to review, what the moderator checks is locked too (see
+// reviewLocked). A listed auction may only be moved within the bounds it
+// was published under (see endsAtProblem), and not at all once it ended.
+// Every change is kept in the product's history.
+func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
 
-	accountID := r.PathValue("accountId")
-	productID := r.PathValue("productId")
+	w.Header().Set("Content-Type", "application/json")
 
-	if accountID == "" || productID == "" {
-		log.Println("Invalid accountId or productId")
-		http.Error(w, "invalid accountId or productId", 400)
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	_, err := uuid.Parse(accountID)
+	id, err := uuid.Parse(r.PathValue("productId"))
 	if err != nil {
-		log.Printf("Invalid accountId format: %v", err)
-		http.Error(w, "invalid accountId format", 400)
+		logger.Info("invalid id")
+		http.Error(w, "invalid id", 400)
 		return
 	}
 
-	_, err = uuid.Parse(productID)
-	if err != nil {
-		log.Printf("Invalid accountId format: %v", err)
-		http.Error(w, "invalid accountId format", 400)
-		return
+	var body struct {
+		Product
+		Price json.RawMessage `json:"price"`
 	}
 
-	sql := `
-		INSERT INTO account_product (account_id, product_id)
-		VALUES ($1, $2);
-	`
-	ctx := context.Background()
-
-	stmt, err := h.DB.PrepareContext(ctx, sql)
-	if err != nil {
-		log.Printf("Error preparing statement: %v", err)
-		http.Error(w, "error preparing statement", http.StatusInternalServerError)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logger.Error("error decoding body", "err", err)
+		http.Error(w, "invalid body", 500)
 		return
 	}
-	defer stmt.Close()
 
-	if _, err := stmt.ExecContext(ctx, accountID, productID); err != nil {
-		log.Printf("Error associating product with account: %v", err)
-		http.Error(w, "error associating product with account", http.StatusInternalServerError)
+	ctx := r.Context()
+
+	existing, err := h.Products.GetByID(ctx, id)
+	if err != nil || !visible(r, existing) {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", 404)
 		return
 	}
 
-	res := map[string]string{
-		"message": "product associated with account",
+	if existing.AccountID != principal.AccountID && principal.Role != account.RoleAdmin {
+		logger.Info("account does not own the product", "product_id", id)
+		http.Error(w, "only the seller can edit a product", http.StatusForbidden)
+		return
 	}
 
-	w.WriteHeader(201)
-
-	if err := json.NewEncoder(w).Encode(res); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "error encoding response", 500)
+	// a bare price keeps the product's currency, which bids are stored in
+	body.Product.Price, err = money.ParseJSON(body.Price, existing.Price.Currency)
+	if err != nil {
+		logger.Info("invalid price", "err", err)
+		http.Error(w, "invalid price", http.StatusBadRequest)
+		return
 	}
-}
 
-func (h *ProductHandler) GetAllAccountBids(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	accountID := r.PathValue("accountId")
-
-	if accountID == "" {
-		log.Println("Invalid accountId")
-		http.Error(w, "invalid accountId", 400)
+	if body.Product.Price.Currency == "" {
+		logger.Info("price removed")
+		http.Error(w, "price is required", http.StatusBadRequest)
 		return
 	}
 
-	_, err := uuid.Parse(accountID)
-	if err != nil {
-		log.Printf("Invalid accountId format: %v", err)
-		http.Error(w, "invalid accountId format", 400)
+	if body.Product.Price.Currency != existing.Price.Currency {
+		logger.Info("currency change", "from", existing.Price.Currency, "to", body.Product.Price.Currency)
+		http.Error(w, "the currency of a product cannot be changed", http.StatusBadRequest)
 		return
 	}
 
-	sql := `
-		SELECT product_id FROM account_product
-		WHERE account_id = $1;
-	`
-	ctx := context.Background()
+	now := time.Now()
+	endsAtChanged := slices.ContainsFunc(Diff(existing, &body.Product), func(c Change) bool { return c.Field == FieldEndsAt })
 
-	stmt, err := h.DB.PrepareContext(ctx, sql)
-	if err != nil {
-		log.Printf("Error preparing statement: %v", err)
-		http.Error(w, "error preparing statement", http.StatusInternalServerError)
+	// reopening an ended auction would leave its winning bid standing
+	if existing.EndsAt != nil && !now.Before(*existing.EndsAt) && endsAtChanged {
+		logger.Info("ends_at change after the auction ended", "product_id", id)
+		http.Error(w, "the end of an auction cannot change once it has ended", http.StatusConflict)
 		return
 	}
-	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx, accountID)
-	if err != nil {
-		log.Printf("Error getting account product: %v", err)
-		http.Error(w, "error getting product account product", http.StatusInternalServerError)
-		return
+	if existing.PublishedAt != nil && endsAtChanged {
+		if problem := endsAtProblem(body.EndsAt, *existing.PublishedAt, h.cfg, now); problem != "" {
+			logger.Info("invalid ends_at", "product_id", id, "problem", problem)
+			http.Error(w, problem, http.StatusBadRequest)
+			return
+		}
 	}
 
-	var ids []string
-	for rows.Next() {
-		var id string
-		err = rows.Scan(&id)
-		if err != nil {
-			break
+	if ok := validateCredentials(&body.Product); ok {
+		body.ID = id
+		body.DisplayPrice = nil
+		body.PublishedAt = existing.PublishedAt
+
+		if body.PublishedAt != nil && !body.CategoryID.Valid {
+			logger.Info("missing category")
+			http.Error(w, "category_id is required", http.StatusBadRequest)
+			return
 		}
-		ids = append(ids, id)
-	}
 
-	var products []Product
-	for _, id := range ids {
-		sql = `
-		SELECT id, account_id, title, description, price, image_url FROM products
-		WHERE id = $1;
-		`
+		if !h.classify(ctx, w, &body.Product) {
+			return
+		}
 
-		stmt, err = h.DB.PrepareContext(ctx, sql)
-		if err != nil {
-			log.Printf("Error preparing statement: %v", err)
-			http.Error(w, "error preparing statement", http.StatusInternalServerError)
+		if fields := reviewedChanges(Diff(existing, &body.Product)); len(fields) > 0 && reviewLocked(existing, principal) {
+			logger.Info("product was reviewed, its reviewed fields are locked", "product_id", id, "fields", fields)
+			http.Error(w, fmt.Sprintf("%s cannot change while a product is listed or awaiting review", strings.Join(fields, " and ")), http.StatusConflict)
 			return
 		}
-		defer stmt.Close()
 
-		var product Product
-		if err = stmt.QueryRowContext(ctx, id).Scan(&product.ID, &product.AccountID, &product.Title, &product.Description, &product.Price, &product.ImageURL); err != nil {
-			log.Printf("Error getting account product: %v", err)
-			http.Error(w, "error getting product account product", http.StatusInternalServerError)
+		if err = h.Products.Update(ctx, &body.Product, principal.AccountID); err != nil {
+			switch {
+			case errors.Is(err, ErrLocked):
+				fields := LockedChanges(Diff(existing, &body.Product))
+				logger.Info("product has bids, its material fields are locked", "product_id", id, "fields", fields)
+				http.Error(w, fmt.Sprintf("%s cannot change once bids are placed; add an addendum instead", strings.Join(fields, " and ")), http.StatusConflict)
+			case errors.Is(err, ErrNotFound):
+				logger.Info("not found", "err", err)
+				http.Error(w, "not found", 404)
+			default:
+				logger.Error("error updating product", "err", err)
+				http.Error(w, "error updating product", http.StatusInternalServerError)
+			}
 			return
 		}
 
-		products = append(products, product)
-	}
+		body.ReviewStatus, body.ReviewReason = existing.ReviewStatus, existing.ReviewReason
 
-	w.WriteHeader(200)
+		products := []Product{body.Product}
+		if !h.withImages(ctx, w, products) {
+			return
+		}
+		body.Product = products[0]
 
-	if err := json.NewEncoder(w).Encode(products); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "error encoding response", 500)
+		w.WriteHeader(200)
+
+		if err := json.NewEncoder(w).Encode(body.Product); err != nil {
+			logger.Error("error encoding response", "err", err)
+			http.Error(w, "error encoding response", 500)
+			return
+		}
+
+		return
 	}
+
+	logger.Info("invalid credentials")
+	http.Error(w, "invalid credentials", http.StatusBadRequest)
 }
 
-func (h *ProductHandler) AddBid(w http.ResponseWriter, r *http.Request) {
+// Publish lists a draft. Drafts are checked in full first, and every
+// problem found is returned at once so sellers can fix them together.
+// Drafts of unverified sellers, and drafts a moderator rejected before, are
+// queued for review instead and answered with 202 Accepted.
+func (h *ProductHandler) Publish(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
 
-	accountID := r.PathValue("accountId")
-	productID := r.PathValue("productId")
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	if accountID == "" || productID == "" {
-		log.Println("Invalid accountId or productId")
-		http.Error(w, "invalid accountId or productId", 400)
+	id, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
 
-	var body struct {
-		BidValue   float64 `json:"bid_value"`
-		BidMessage string  `json:"bid_message"`
+	ctx := r.Context()
+
+	p, err := h.Products.GetByID(ctx, id)
+	if err != nil || !visible(r, p) {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		log.Println("Error decoding body")
-		http.Error(w, "error decoding body", 500)
+	if p.AccountID != principal.AccountID {
+		logger.Info("account does not own the product", "product_id", id)
+		http.Error(w, "only the seller can publish a product", http.StatusForbidden)
 		return
 	}
 
-	if body.BidValue <= 0 || body.BidMessage == "" {
-		log.Println("Invalid Bid Value or Bid Message")
-		http.Error(w, "invalid Bid Value or Bid Message", 400)
+	if p.PublishedAt != nil {
+		logger.Info("product is already published", "product_id", id)
+		http.Error(w, "product is already published", http.StatusConflict)
 		return
 	}
 
-	sql := `
-	INSERT INTO account_bid
-	(account_id, product_id, bid_value, bid_message)
-	VALUES ($1, $2, $3, $4);
-	`
-	ctx := context.Background()
+	if p.ReviewStatus == ReviewPending {
+		logger.Info("product is already awaiting review", "product_id", id)
+		http.Error(w, "product is already awaiting review", http.StatusConflict)
+		return
+	}
 
-	stmt, err := h.DB.PrepareContext(ctx, sql)
-	if err != nil {
-		log.Printf("Error preparing statement: %v", err)
-		http.Error(w, "error preparing statement", http.StatusInternalServerError)
+	now := time.Now().UTC()
+
+	if !h.publishable(ctx, w, p, now) {
 		return
 	}
-	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, accountID, productID, body.BidValue, body.BidMessage)
-	if err != nil {
-		log.Printf("Error creating a product bid: %v", err)
-		http.Error(w, "error creating a product bid", http.StatusInternalServerError)
+	trusted := principal.Verified || principal.Role != account.RoleUser
+
+	if !trusted || p.ReviewStatus == ReviewRejected {
+		h.submit(ctx, w, p)
 		return
 	}
 
-	res := map[string]string{
-		"status": "bid created",
+	if err = h.Products.Publish(ctx, id, now); err != nil {
+		switch {
+		case errors.Is(err, ErrAlreadyPublished):
+			logger.Info("product is already published", "product_id", id)
+			http.Error(w, "product is already published", http.StatusConflict)
+		case errors.Is(err, ErrNotFound):
+			logger.Info("not found", "err", err)
+			http.Error(w, "not found", http.StatusNotFound)
+		default:
+			logger.Error("error publishing product", "err", err)
+			http.Error(w, "error publishing product", http.StatusInternalServerError)
+		}
+		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	p.PublishedAt = &now
 
-	if err = json.NewEncoder(w).Encode(res); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	metrics.AuctionsOpened.Inc()
+
+	products := []Product{*p}
+	if !h.withImages(ctx, w, products) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(products[0]); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
 	}
 }
 
-func (h *ProductHandler) GetBidById(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+// submit queues p for review.
+func (h *ProductHandler) submit(ctx context.Context, w http.ResponseWriter, p *Product) {
+	if err := h.Products.Submit(ctx, p.ID); err != nil {
+		switch {
+		case errors.Is(err, ErrAlreadyPublished):
+			middlewares.Logger(ctx).Info("product is already published", "product_id", p.ID)
+			http.Error(w, "product is already published", http.StatusConflict)
+		case errors.Is(err, ErrNotFound):
+			middlewares.Logger(ctx).Info("not found", "err", err)
+			http.Error(w, "not found", http.StatusNotFound)
+		default:
+			middlewares.Logger(ctx).Error("error submitting product", "err", err)
+			http.Error(w, "error submitting product", http.StatusInternalServerError)
+		}
+		return
+	}
 
-	accountID := r.PathValue("accountId")
-	productID := r.PathValue("productId")
+	p.ReviewStatus, p.ReviewReason = ReviewPending, ""
 
-	if accountID == "" || productID == "" {
-		log.Println("Invalid accountId or productId")
-		http.Error(w, "invalid accountId or productId", 400)
+	products := []Product{*p}
+	if !h.withImages(ctx, w, products) {
 		return
 	}
 
-	sql := `
-	SELECT * FROM account_bid
-	WHERE account_id = $1 AND product_id = $2;
-	`
-	ctx := context.Background()
+	res := struct {
+		Message string  `json:"message"`
+		Product Product `json:"product"`
+	}{"product is awaiting review by a moderator", products[0]}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		middlewares.Logger(ctx).Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
 
-	stmt, err := h.DB.PrepareContext(ctx, sql)
+// publishable checks p with publishProblems and answers 422 with the
+// problems found, if any.
+func (h *ProductHandler) publishable(ctx context.Context, w http.ResponseWriter, p *Product, now time.Time) bool {
+	images, err := h.Images.GetByProduct(ctx, p.ID)
 	if err != nil {
-		log.Printf("Error preparing statement: %v", err)
-		http.Error(w, "error preparing statement", http.StatusInternalServerError)
-		return
+		middlewares.Logger(ctx).Error("error getting images", "err", err)
+		http.Error(w, "error getting images", http.StatusInternalServerError)
+		return false
 	}
-	defer stmt.Close()
 
-	var productBid struct {
-		AccountID  uuid.UUID `json:"account_id"`
-		ProductID  uuid.UUID `json:"product_id"`
-		BidValue   float64   `json:"bid_value"`
-		BidMessage string    `json:"bid_message"`
+	tree, err := category.LoadTree(ctx, h.Categories)
+	if err != nil {
+		middlewares.Logger(ctx).Error("error loading categories", "err", err)
+		http.Error(w, "error loading categories", http.StatusInternalServerError)
+		return false
 	}
 
-	if err = stmt.QueryRowContext(ctx, accountID, productID).
-		Scan(&productBid.AccountID, &productBid.ProductID, &productBid.BidValue, &productBid.BidMessage); err != nil {
-		log.Printf("Error getting product bid: %v", err)
-		http.Error(w, "error getting product bid", http.StatusInternalServerError)
-		return
+	problems := publishProblems(p, images, tree, h.cfg, now)
+	if len(problems) == 0 {
+		return true
 	}
 
-	w.WriteHeader(http.StatusOK)
+	middlewares.Logger(ctx).Info("product cannot be published", "product_id", p.ID, "problems", problems)
 
-	if err = json.NewEncoder(w).Encode(productBid); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	res := struct {
+		Message  string   `json:"message"`
+		Problems []string `json:"problems"`
+	}{"product cannot be published", problems}
+
+	w.WriteHeader(http.StatusUnprocessableEntity)
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		middlewares.Logger(ctx).Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
 	}
+
+	return false
 }
 
-func (h *ProductHandler) GetAllBids(w http.ResponseWriter, r *http.Request) {
+func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
 	w.Header().Set("Content-Type", "application/json")
 
-	accountId := r.PathValue("accountId")
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	if accountId == "" {
-		log.Println("Invalid account id")
+	id, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid id")
 		http.Error(w, "invalid id", 400)
 		return
 	}
 
-	sql := `
-	SELECT * FROM account_bid
-	WHERE account_id = $1;
-	`
-	ctx := context.Background()
+	ctx := r.Context()
 
-	stmt, err := h.DB.PrepareContext(ctx, sql)
-	if err != nil {
-		log.Printf("Error preparing statement: %v", err)
-		http.Error(w, "error preparing statement", http.StatusInternalServerError)
+	existing, err := h.Products.GetByID(ctx, id)
+	if err != nil || !visible(r, existing) {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
-	defer stmt.Close()
 
-	type bid struct {
-		AccountID  uuid.UUID `json:"account_id"`
-		ProductID  uuid.UUID `json:"product_id"`
-		BidValue   float64   `json:"bid_value"`
-		BidMessage string    `json:"bid_message"`
+	if existing.AccountID != principal.AccountID && principal.Role != account.RoleAdmin {
+		logger.Info("account does not own the product", "product_id", id)
+		http.Error(w, "only the seller can delete a product", http.StatusForbidden)
+		return
 	}
 
-	rows, err := stmt.QueryContext(ctx, accountId)
+	// the rows go with the product; the files are removed once it is gone
+	images, err := h.Images.GetByProduct(ctx, id)
 	if err != nil {
-		log.Printf("Error getting account bids: %v", err)
-		http.Error(w, "error getting account bids", http.StatusInternalServerError)
+		logger.Error("error getting images", "err", err)
+		http.Error(w, "error getting images", http.StatusInternalServerError)
 		return
 	}
 
-	bids := make([]bid, 0)
+	if err = h.Products.Delete(ctx, id); err != nil {
+		logger.Info("error deleting product", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
 
-	for rows.Next() {
-		var b bid
-		err = rows.Scan(&b.AccountID, &b.ProductID, &b.BidValue, &b.BidMessage)
-		if err != nil {
-			break
-		}
-		bids = append(bids, b)
+	deleteFiles(ctx, h.URLs.Store, images...)
+
+	res := map[string]string{
+		"message": "product deleted",
 	}
 
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(200)
 
-	if err = json.NewEncoder(w).Encode(bids); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", 500)
 	}
 }
 
-func validateCredentials(body *Product) bool {
-	return body.Title != "" && body.Description != "" && body.Price > 0
+// AssociateProductWithAccount associates a product with an account. Only
+// the seller may associate their own products with their own account,
+// unless the caller is an admin.
+func (h *ProductHandler) AssociateProductWithAccount(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	accountID, err := uuid.Parse(r.PathValue("accountId"))
+	if err != nil {
+		logger.Info("invalid accountId format", "err", err)
+		http.Error(w, "invalid accountId format", 400)
+		return
+	}
+
+	productID, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid productId format", "err", err)
+		http.Error(w, "invalid productId format", 400)
+		return
+	}
+
+	ctx := r.Context()
+
+	if principal.Role != account.RoleAdmin {
+		if accountID != principal.AccountID {
+			logger.Info("association for another account", "path_account_id", accountID)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		existing, err := h.Products.GetByID(ctx, productID)
+		if err != nil || !visible(r, existing) {
+			logger.Info("not found", "err", err)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		if existing.AccountID != principal.AccountID {
+			logger.Info("account does not own the product", "product_id", productID)
+			http.Error(w, "only the seller can associate a product", http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := h.Products.Associate(ctx, accountID, productID); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			logger.Info("error associating product with account", "err", err)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		logger.Error("error associating product with account", "err", err)
+		http.Error(w, "error associating product with account", http.StatusInternalServerError)
+		return
+	}
+
+	res := map[string]string{
+		"message": "product associated with account",
+	}
+
+	w.WriteHeader(201)
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", 500)
+	}
+}
+
+func (h *ProductHandler) GetAllAccountBids(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	accountID, err := uuid.Parse(r.PathValue("accountId"))
+	if err != nil {
+		logger.Info("invalid accountId format", "err", err)
+		http.Error(w, "invalid accountId format", 400)
+		return
+	}
+
+	ctx := r.Context()
+
+	products, err := h.Products.GetByAccount(ctx, accountID)
+	if err != nil {
+		logger.Error("error getting account product", "err", err)
+		http.Error(w, "error getting product account product", http.StatusInternalServerError)
+		return
+	}
+
+	products = slices.DeleteFunc(products, func(p Product) bool {
+		return !visible(r, &p)
+	})
+
+	if !h.displayPrices(ctx, w, r, products) {
+		return
+	}
+
+	w.WriteHeader(200)
+
+	if err := json.NewEncoder(w).Encode(products); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", 500)
+	}
+}
+
+func (h *ProductHandler) AddBid(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	accountID, err := uuid.Parse(r.PathValue("accountId"))
+	if err != nil {
+		logger.Info("invalid accountId or productId")
+		metrics.BidsRejected.WithLabelValues(metrics.RejectInvalid).Inc()
+		http.Error(w, "invalid accountId or productId", 400)
+		return
+	}
+
+	if accountID != principal.AccountID && principal.Role != account.RoleAdmin {
+		logger.Info("bid for another account", "path_account_id", accountID)
+		metrics.BidsRejected.WithLabelValues(metrics.RejectForbidden).Inc()
+		http.Error(w, "bids can only be placed as your own account", http.StatusForbidden)
+		return
+	}
+
+	productID, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid accountId or productId")
+		metrics.BidsRejected.WithLabelValues(metrics.RejectInvalid).Inc()
+		http.Error(w, "invalid accountId or productId", 400)
+		return
+	}
+
+	var body struct {
+		BidValue   json.RawMessage `json:"bid_value"`
+		BidMessage string          `json:"bid_message"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logger.Error("error decoding body", "err", err)
+		metrics.BidsRejected.WithLabelValues(metrics.RejectInvalid).Inc()
+		http.Error(w, "error decoding body", 500)
+		return
+	}
+
+	ctx := r.Context()
+
+	product, err := h.Products.GetByID(ctx, productID)
+	if err != nil || !visible(r, product) {
+		logger.Info("not found", "err", err)
+		metrics.BidsRejected.WithLabelValues(metrics.RejectNotFound).Inc()
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if product.PublishedAt == nil {
+		logger.Info("bid on a draft", "product_id", productID)
+		metrics.BidsRejected.WithLabelValues(metrics.RejectDraft).Inc()
+		http.Error(w, "product is not published", http.StatusConflict)
+		return
+	}
+
+	// a bare amount is taken to be in the product's currency
+	value, err := money.ParseJSON(body.BidValue, product.Price.Currency)
+	if err != nil || !value.IsPositive() || body.BidMessage == "" {
+		logger.Info("invalid Bid Value or Bid Message")
+		metrics.BidsRejected.WithLabelValues(metrics.RejectInvalid).Inc()
+		http.Error(w, "invalid Bid Value or Bid Message", 400)
+		return
+	}
+
+	if product.EndsAt != nil && !time.Now().Before(*product.EndsAt) {
+		logger.Info("bid after the auction ended", "product_id", productID)
+		metrics.BidsRejected.WithLabelValues(metrics.RejectEnded).Inc()
+		http.Error(w, "auction has ended", http.StatusConflict)
+		return
+	}
+
+	if value.Currency != product.Price.Currency {
+		logger.Info("bid currency does not match the product", "currency", value.Currency, "product_currency", product.Price.Currency)
+		metrics.BidsRejected.WithLabelValues(metrics.RejectCurrency).Inc()
+		http.Error(w, "bid must be in "+product.Price.Currency, http.StatusBadRequest)
+		return
+	}
+
+	bid := Bid{
+		AccountID:  accountID,
+		ProductID:  productID,
+		BidValue:   value,
+		BidMessage: body.BidMessage,
+		IP:         utils.ClientIP(r),
+		UserAgent:  r.UserAgent(),
+	}
+
+	// the checks above are repeated by Create with the product locked, in
+	// case it changed since it was read
+	if err = h.Bids.Create(ctx, &bid); err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			logger.Info("not found", "err", err)
+			metrics.BidsRejected.WithLabelValues(metrics.RejectNotFound).Inc()
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, ErrNotPublished):
+			logger.Info("bid on a draft", "product_id", productID)
+			metrics.BidsRejected.WithLabelValues(metrics.RejectDraft).Inc()
+			http.Error(w, "product is not published", http.StatusConflict)
+		case errors.Is(err, ErrAuctionEnded):
+			logger.Info("bid after the auction ended", "product_id", productID)
+			metrics.BidsRejected.WithLabelValues(metrics.RejectEnded).Inc()
+			http.Error(w, "auction has ended", http.StatusConflict)
+		case errors.Is(err, ErrBidTooLow):
+			logger.Info("bid not above the current bid", "product_id", productID)
+			metrics.BidsRejected.WithLabelValues(metrics.RejectTooLow).Inc()
+			http.Error(w, ErrBidTooLow.Error(), http.StatusConflict)
+		default:
+			logger.Error("error creating a product bid", "err", err)
+			http.Error(w, "error creating a product bid", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	metrics.BidsPlaced.Inc()
+
+	res := map[string]string{
+		"status":     "bid created",
+		"id":         bid.ID.String(),
+		"bid_status": bid.Status,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *ProductHandler) GetBidById(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	accountID, err := uuid.Parse(r.PathValue("accountId"))
+	if err != nil {
+		logger.Info("invalid accountId or productId")
+		http.Error(w, "invalid accountId or productId", 400)
+		return
+	}
+
+	productID, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid accountId or productId")
+		http.Error(w, "invalid accountId or productId", 400)
+		return
+	}
+
+	ctx := r.Context()
+
+	productBid, err := h.Bids.Get(ctx, accountID, productID)
+	if err != nil {
+		logger.Error("error getting product bid", "err", err)
+		http.Error(w, "error getting product bid", http.StatusInternalServerError)
+		return
+	}
+
+	bids := []Bid{*productBid}
+	if !h.displayBids(ctx, w, r, bids) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(bids[0]); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+func (h *ProductHandler) GetBid(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.PathValue("bidId"))
+	if err != nil {
+		logger.Info("invalid bid id")
+		http.Error(w, "invalid id", 400)
+		return
+	}
+
+	ctx := r.Context()
+
+	bid, err := h.Bids.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrBidNotFound) {
+			logger.Info("bid not found", "bid_id", id)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		logger.Error("error getting bid", "err", err)
+		http.Error(w, "error getting bid", http.StatusInternalServerError)
+		return
+	}
+
+	bids := []Bid{*bid}
+	if !h.displayBids(ctx, w, r, bids) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(bids[0]); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// GetProductBids returns a product's bid history, newest first, paginated
+// with ?limit= and ?offset=.
+func (h *ProductHandler) GetProductBids(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	productID, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid productId")
+		http.Error(w, "invalid productId", 400)
+		return
+	}
+
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		logger.Info("invalid pagination", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	product, err := h.Products.GetByID(ctx, productID)
+	if err != nil || !visible(r, product) {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	bids, total, err := h.Bids.GetByProduct(ctx, productID, limit, offset)
+	if err != nil {
+		logger.Error("error getting product bids", "err", err)
+		http.Error(w, "error getting product bids", http.StatusInternalServerError)
+		return
+	}
+
+	if !h.displayBids(ctx, w, r, bids) {
+		return
+	}
+
+	res := struct {
+		Bids   []Bid `json:"bids"`
+		Total  int   `json:"total"`
+		Limit  int   `json:"limit"`
+		Offset int   `json:"offset"`
+	}{bids, total, limit, offset}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// RetractBid withdraws one of the caller's bids. It is only allowed within
+// RetractWindow of placing the bid, not in the last RetractClosing of the
+// auction, and MaxRetractions times per RetractPeriod.
+func (h *ProductHandler) RetractBid(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	p, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(r.PathValue("bidId"))
+	if err != nil {
+		logger.Info("invalid bid id")
+		http.Error(w, "invalid id", 400)
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logger.Info("error decoding body", "err", err)
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" || len(body.Reason) > 500 {
+		logger.Info("invalid retraction reason")
+		http.Error(w, "a reason of up to 500 characters is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	bid, err := h.Bids.GetByID(ctx, id)
+	if err != nil {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if bid.AccountID != p.AccountID {
+		logger.Info("account tried to retract the bid of another account", "bid_id", id, "bidder_id", bid.AccountID)
+		http.Error(w, "only the bidder can retract a bid", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	if now.Sub(bid.CreatedAt) > h.cfg.RetractWindow {
+		logger.Info("bid is past the retraction window", "bid_id", id)
+		http.Error(w, fmt.Sprintf("bids can only be retracted within %s of being placed", h.cfg.RetractWindow), http.StatusForbidden)
+		return
+	}
+
+	product, err := h.Products.GetByID(ctx, bid.ProductID)
+	if err != nil {
+		logger.Error("error getting product", "err", err)
+		http.Error(w, "error getting product", http.StatusInternalServerError)
+		return
+	}
+
+	if product.EndsAt != nil && now.After(product.EndsAt.Add(-h.cfg.RetractClosing)) {
+		logger.Info("bid is in the closing period of its auction", "bid_id", id)
+		http.Error(w, fmt.Sprintf("bids cannot be retracted in the last %s of an auction", h.cfg.RetractClosing), http.StatusForbidden)
+		return
+	}
+
+	limit := RetractionLimit{Max: h.cfg.MaxRetractions, Since: now.Add(-h.cfg.RetractPeriod)}
+
+	promoted, err := h.Bids.Retract(ctx, id, body.Reason, now, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrBidNotRetractable):
+			logger.Info("bid cannot be retracted", "bid_id", id)
+			http.Error(w, "bid cannot be retracted", http.StatusConflict)
+			return
+		case errors.Is(err, ErrRetractionLimit):
+			logger.Info("account reached the retraction limit")
+			http.Error(w, fmt.Sprintf("at most %d bids can be retracted every %s", h.cfg.MaxRetractions, h.cfg.RetractPeriod), http.StatusForbidden)
+			return
+		}
+
+		logger.Error("error retracting bid", "err", err)
+		http.Error(w, "error retracting bid", http.StatusInternalServerError)
+		return
+	}
+
+	h.notifyRetraction(ctx, bid, product, promoted)
+
+	res := map[string]string{
+		"message": "bid retracted",
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// notifyRetraction tells the other bidders on the product that bid was
+// retracted, and the owner of promoted that they lead again. Failures are
+// only logged, the retraction has already happened.
+func (h *ProductHandler) notifyRetraction(ctx context.Context, bid *Bid, product *Product, promoted *Bid) {
+	// sent even if the caller leaves meanwhile
+	ctx = context.WithoutCancel(ctx)
+
+	bidders, err := h.Bids.Bidders(ctx, product.ID)
+	if err != nil {
+		middlewares.Logger(ctx).Error("error getting bidders to notify", "err", err)
+		return
+	}
+
+	productID := uuid.NullUUID{UUID: product.ID, Valid: true}
+
+	for _, accountID := range bidders {
+		if accountID == bid.AccountID {
+			continue
+		}
+
+		n := notification.Notification{
+			AccountID: accountID,
+			Kind:      notification.KindBidRetracted,
+			Message:   fmt.Sprintf("A bid of %s %s on %q was retracted", bid.BidValue, bid.BidValue.Currency, product.Title),
+			ProductID: productID,
+		}
+
+		if err := h.Notifications.Create(ctx, &n); err != nil {
+			middlewares.Logger(ctx).Error("error notifying", "recipient_id", accountID, "err", err)
+		}
+	}
+
+	if promoted == nil {
+		return
+	}
+
+	n := notification.Notification{
+		AccountID: promoted.AccountID,
+		Kind:      notification.KindHighBidder,
+		Message:   fmt.Sprintf("Your bid of %s %s on %q is the highest again", promoted.BidValue, promoted.BidValue.Currency, product.Title),
+		ProductID: productID,
+	}
+
+	if err := h.Notifications.Create(ctx, &n); err != nil {
+		middlewares.Logger(ctx).Error("error notifying", "recipient_id", promoted.AccountID, "err", err)
+	}
+}
+
+func (h *ProductHandler) GetAllBids(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	accountId, err := uuid.Parse(r.PathValue("accountId"))
+	if err != nil {
+		logger.Info("invalid account id")
+		http.Error(w, "invalid id", 400)
+		return
+	}
+
+	ctx := r.Context()
+
+	bids, err := h.Bids.GetByAccount(ctx, accountId)
+	if err != nil {
+		logger.Error("error getting account bids", "err", err)
+		http.Error(w, "error getting account bids", http.StatusInternalServerError)
+		return
+	}
+
+	if !h.displayBids(ctx, w, r, bids) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(bids); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// classify checks that p is in a leaf category and normalizes its tags.
+// Drafts may be saved without a category.
+func (h *ProductHandler) classify(ctx context.Context, w http.ResponseWriter, p *Product) bool {
+	if p.CategoryID.Valid {
+		tree, err := category.LoadTree(ctx, h.Categories)
+		if err != nil {
+			middlewares.Logger(ctx).Error("error loading categories", "err", err)
+			http.Error(w, "error loading categories", http.StatusInternalServerError)
+			return false
+		}
+
+		if !tree.IsLeaf(p.CategoryID.UUID) {
+			middlewares.Logger(ctx).Info("category is not a leaf", "category_id", p.CategoryID.UUID)
+			http.Error(w, "category_id must be a category without subcategories", http.StatusBadRequest)
+			return false
+		}
+	}
+
+	var err error
+
+	p.Tags, err = NormalizeTags(p.Tags)
+	if err != nil {
+		middlewares.Logger(ctx).Info("invalid tags", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// categoryProducts returns the ids of the category and the categories below
+// it, for listing the products in it.
+func (h *ProductHandler) categoryProducts(ctx context.Context, w http.ResponseWriter, id uuid.UUID) ([]uuid.UUID, bool) {
+	tree, err := category.LoadTree(ctx, h.Categories)
+	if err != nil {
+		middlewares.Logger(ctx).Error("error loading categories", "err", err)
+		http.Error(w, "error loading categories", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if !tree.Has(id) {
+		middlewares.Logger(ctx).Info("category not found", "category_id", id)
+		http.Error(w, "category not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return tree.Descendants(id), true
+}
+
+// withImages fills in the images of products, with links to their
+// copies.
+func (h *ProductHandler) withImages(ctx context.Context, w http.ResponseWriter, products []Product) bool {
+	ids := make([]uuid.UUID, len(products))
+	for i, p := range products {
+		ids[i] = p.ID
+	}
+
+	images, err := h.Images.GetByProducts(ctx, ids)
+	if err != nil {
+		middlewares.Logger(ctx).Error("error getting images", "err", err)
+		http.Error(w, "error getting images", http.StatusInternalServerError)
+		return false
+	}
+
+	for i := range products {
+		products[i].Images = images[products[i].ID]
+		if products[i].Images == nil {
+			products[i].Images = make([]Image, 0)
+		}
+
+		if err = LinkImages(h.URLs, products[i].Images); err != nil {
+			middlewares.Logger(ctx).Error("error linking images", "err", err)
+			http.Error(w, "error linking images", http.StatusInternalServerError)
+			return false
+		}
+	}
+
+	return true
+}
+
+// displayCurrency returns the currency asked for with ?currency= and the
+// rates to convert into it, or "" when none was asked for.
+func (h *ProductHandler) displayCurrency(ctx context.Context, w http.ResponseWriter, r *http.Request) (string, exchange.Table, bool) {
+	logger := middlewares.Logger(r.Context())
+
+	to := strings.ToUpper(r.URL.Query().Get("currency"))
+	if to == "" {
+		return "", nil, true
+	}
+
+	if !money.ValidCurrency(to) {
+		logger.Info("invalid display currency", "currency", to)
+		http.Error(w, "invalid currency", http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	table, err := exchange.LoadTable(ctx, h.Rates)
+	if err != nil {
+		logger.Error("error loading exchange rates", "err", err)
+		http.Error(w, "error loading exchange rates", http.StatusInternalServerError)
+		return "", nil, false
+	}
+
+	return to, table, true
+}
+
+// displayPrices sets DisplayPrice on products when ?currency= is given.
+// Products whose price cannot be converted, for lack of a rate, are left
+// without one rather than failing the whole list.
+func (h *ProductHandler) displayPrices(ctx context.Context, w http.ResponseWriter, r *http.Request, products []Product) bool {
+	logger := middlewares.Logger(r.Context())
+
+	to, table, ok := h.displayCurrency(ctx, w, r)
+	if !ok || to == "" {
+		return ok
+	}
+
+	for i := range products {
+		converted, err := table.Convert(products[i].Price, to)
+		if err != nil {
+			logger.Info("error converting price", "product_id", products[i].ID, "err", err)
+			continue
+		}
+		products[i].DisplayPrice = &converted
+	}
+
+	return true
+}
+
+// displayBids sets DisplayValue on bids when ?currency= is given, leaving
+// it out on the bids that cannot be converted like displayPrices.
+func (h *ProductHandler) displayBids(ctx context.Context, w http.ResponseWriter, r *http.Request, bids []Bid) bool {
+	logger := middlewares.Logger(r.Context())
+
+	to, table, ok := h.displayCurrency(ctx, w, r)
+	if !ok || to == "" {
+		return ok
+	}
+
+	for i := range bids {
+		converted, err := table.Convert(bids[i].BidValue, to)
+		if err != nil {
+			logger.Info("error converting bid", "bid_id", bids[i].ID, "err", err)
+			continue
+		}
+		bids[i].DisplayValue = &converted
+	}
+
+	return true
+}
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageParams reads ?limit= and ?offset=.
+func pageParams(r *http.Request) (int, int, error) {
+	offset := 0
+
+	limit, err := limitParam(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must not be negative")
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
+
+func limitParam(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return defaultPageSize, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > maxPageSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+
+	return n, nil
+}
+
+// listOptions reads the filters, sort and position of a product listing.
+// Price bounds are read in ?price_currency=, the default currency if unset.
+// ?drafts=true lists the caller's drafts instead of published products.
+func listOptions(r *http.Request, now time.Time) (ListOptions, error) {
+	q := r.URL.Query()
+
+	opts := ListOptions{
+		Now:  now,
+		Sort: SortNewest,
+	}
+
+	var err error
+	if opts.Limit, err = limitParam(r); err != nil {
+		return opts, err
+	}
+
+	if v := q.Get("sort"); v != "" {
+		switch v {
+		case SortNewest, SortEndingSoon, SortPriceAsc, SortPriceDesc, SortMostBids:
+			opts.Sort = v
+		default:
+			return opts, fmt.Errorf("unknown sort %q", v)
+		}
+	}
+
+	currency := money.DefaultCurrency
+	if v := q.Get("price_currency"); v != "" {
+		currency = strings.ToUpper(v)
+	}
+
+	if v := q.Get("min_price"); v != "" {
+		price, err := money.Parse(v, currency)
+		if err != nil {
+			return opts, fmt.Errorf("invalid min_price: %w", err)
+		}
+		opts.MinPrice = &price
+	}
+
+	if v := q.Get("max_price"); v != "" {
+		price, err := money.Parse(v, currency)
+		if err != nil {
+			return opts, fmt.Errorf("invalid max_price: %w", err)
+		}
+		opts.MaxPrice = &price
+	}
+
+	if v := q.Get("seller"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return opts, errors.New("invalid seller")
+		}
+		opts.Seller = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	if v := q.Get("tag"); v != "" {
+		tags, err := NormalizeTags([]string{v})
+		if err != nil {
+			return opts, err
+		}
+		opts.Tag = tags[0]
+	}
+
+	switch v := q.Get("status"); v {
+	case "", StatusOpen, StatusEnded:
+		opts.Status = v
+	default:
+		return opts, fmt.Errorf("status must be %s or %s", StatusOpen, StatusEnded)
+	}
+
+	if v := q.Get("ending_within"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return opts, errors.New("ending_within must be a positive duration such as 24h")
+		}
+		before := now.Add(d)
+		opts.EndingBefore = &before
+	}
+
+	// sellers list their own drafts
+	if q.Get("drafts") == "true" {
+		principal, ok := middlewares.PrincipalFromContext(r.Context())
+		if !ok {
+			return opts, errors.New("sign in to list your drafts")
+		}
+		opts.Drafts = true
+		opts.Seller = uuid.NullUUID{UUID: principal.AccountID, Valid: true}
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil || c.Sort != opts.Sort {
+			return opts, errors.New("invalid cursor")
+		}
+		opts.After = c
+	}
+
+	return opts, nil
+}
+
+// Cursors are opaque to clients: base64 of the JSON encoded Cursor.
+func encodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cursor
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+
+	if !c.Valid() {
+		return nil, errors.New("cursor does not match its sort")
+	}
+
+	return &c, nil
+}
+
+func validateCredentials(body *Product) bool {
+	return body.Title != "" && body.Description != "" && body.Price.IsPositive()
+}
+
+// visible reports whether the caller of r may see p: drafts are only shown
+// to their seller, and to moderators.
+func visible(r *http.Request, p *Product) bool {
+	if p.PublishedAt != nil {
+		return true
+	}
+
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+
+	return ok && (principal.AccountID == p.AccountID || principal.Role != account.RoleUser)
+}
+
+// endsAtProblem checks a new end for an auction published at publishedAt:
+// it must still leave MinAuction from now, must not run more than
+// MaxAuction from publication, and cannot be dropped to leave the auction
+// open. It returns "" when endsAt is fine.
+func endsAtProblem(endsAt *time.Time, publishedAt time.Time, cfg *config.BidConfig, now time.Time) string {
+	switch {
+	case endsAt == nil:
+		return "ends_at cannot be removed once the product is published"
+	case endsAt.Before(now.Add(cfg.MinAuction)):
+		return fmt.Sprintf("ends_at must be at least %s from now", cfg.MinAuction)
+	case endsAt.After(publishedAt.Add(cfg.MaxAuction)):
+		return fmt.Sprintf("ends_at must be at most %s after the product was published", cfg.MaxAuction)
+	}
+
+	return ""
+}
+
+// publishProblems lists everything that keeps p from being published.
+// images are the images of p.
+func publishProblems(p *Product, images []Image, tree *category.Tree, cfg *config.BidConfig, now time.Time) []string {
+	problems := make([]string, 0)
+
+	if strings.TrimSpace(p.Title) == "" {
+		problems = append(problems, "title is required")
+	}
+
+	if strings.TrimSpace(p.Description) == "" {
+		problems = append(problems, "description is required")
+	}
+
+	if !p.Price.IsPositive() {
+		problems = append(problems, "price must be positive")
+	}
+
+	switch {
+	case !p.CategoryID.Valid:
+		problems = append(problems, "category_id is required")
+	case !tree.IsLeaf(p.CategoryID.UUID):
+		problems = append(problems, "category_id must be a category without subcategories")
+	}
+
+	if len(images) == 0 {
+		problems = append(problems, "at least one image is required")
+	}
+
+	for _, img := range images {
+		switch img.Status {
+		case ImageProcessing:
+			problems = append(problems, fmt.Sprintf("image %d is still being processed", img.Position+1))
+		case ImageFailed:
+			problems = append(problems, fmt.Sprintf("image %d could not be processed and must be deleted", img.Position+1))
+		}
+	}
+
+	if p.EndsAt != nil {
+		switch {
+		case p.EndsAt.Before(now.Add(cfg.MinAuction)):
+			problems = append(problems, fmt.Sprintf("ends_at must be at least %s from now", cfg.MinAuction))
+		case p.EndsAt.After(now.Add(cfg.MaxAuction)):
+			problems = append(problems, fmt.Sprintf("ends_at must be at most %s from now", cfg.MaxAuction))
+		}
+	}
+
+	return problems
 }
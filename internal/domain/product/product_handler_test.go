@@ -0,0 +1,181 @@
+package product_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/Nier704/arthur-leilao-server/internal/metrics"
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestUpdateEndsAt(t *testing.T) {
+	hour := time.Hour
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name string
+		// published is how long ago the auction was published, and
+		// endsIn how long it has left.
+		published time.Duration
+		endsIn    time.Duration
+		// moveTo is the new end from now; 0 drops it.
+		moveTo time.Duration
+		want   int
+	}{
+		{"within the bounds", hour, day, 2 * day, http.StatusOK},
+		{"earlier, still leaving the minimum", hour, day, 2 * hour, http.StatusOK},
+		{"sooner than the minimum", hour, day, hour / 2, http.StatusBadRequest},
+		{"in the past", hour, day, -hour, http.StatusBadRequest},
+		{"past the maximum from publication", 20 * day, day, 11 * day, http.StatusBadRequest},
+		{"up to the maximum from publication", 20 * day, day, 10*day - hour, http.StatusOK},
+		{"far in the future", hour, day, 365 * day, http.StatusBadRequest},
+		{"dropped", hour, day, 0, http.StatusBadRequest},
+		{"after the auction ended", 2 * day, -hour, 2 * day, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newApp(t)
+			seller := a.account(t, "seller", account.RoleUser, true)
+
+			p := a.product(t, seller)
+			now := time.Now().UTC()
+
+			a.publish(t, &p, now.Add(-tt.published), now.Add(tt.endsIn))
+
+			changed := p
+			changed.EndsAt = nil
+			if tt.moveTo != 0 {
+				end := now.Add(tt.moveTo)
+				changed.EndsAt = &end
+			}
+
+			code, body := a.do(t, "seller", http.MethodPut, "/api/product/"+p.ID.String(), edit(changed))
+			if code != tt.want {
+				t.Fatalf("PUT = %d %s, want %d", code, body, tt.want)
+			}
+
+			got, err := a.store.Products.GetByID(context.Background(), p.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			moved := got.EndsAt == nil || !got.EndsAt.Equal(*p.EndsAt)
+			if moved != (tt.want == http.StatusOK) {
+				t.Errorf("ends_at = %v, want it moved = %v", got.EndsAt, tt.want == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestUpdateLockedByBids(t *testing.T) {
+	a := newApp(t)
+	seller := a.account(t, "seller", account.RoleUser, true)
+	bidder := a.account(t, "bidder", account.RoleUser, false)
+
+	p := a.product(t, seller)
+	now := time.Now().UTC()
+	a.publish(t, &p, now, now.Add(24*time.Hour))
+
+	// before any bid the end may move
+	moved := p
+	end := now.Add(48 * time.Hour)
+	moved.EndsAt = &end
+
+	if code, body := a.do(t, "seller", http.MethodPut, "/api/product/"+p.ID.String(), edit(moved)); code != http.StatusOK {
+		t.Fatalf("PUT before bids = %d %s", code, body)
+	}
+
+	bid := product.Bid{AccountID: bidder.ID, ProductID: p.ID, BidValue: money.New(15000, money.DefaultCurrency)}
+	if err := a.store.Bids.Create(context.Background(), &bid); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(p *product.Product)
+		want   int
+		field  string
+	}{
+		{"ends_at", func(p *product.Product) { e := now.Add(72 * time.Hour); p.EndsAt = &e }, http.StatusConflict, "ends_at"},
+		{"earlier ends_at", func(p *product.Product) { e := now.Add(2 * time.Hour); p.EndsAt = &e }, http.StatusConflict, "ends_at"},
+		{"price", func(p *product.Product) { p.Price = money.New(5000, money.DefaultCurrency) }, http.StatusConflict, "price"},
+		{"description", func(p *product.Product) { p.Description = "Broken" }, http.StatusConflict, "description"},
+		{"title", func(p *product.Product) { p.Title = "Fender" }, http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, err := a.store.Products.GetByID(context.Background(), p.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			changed := *current
+			tt.change(&changed)
+
+			code, body := a.do(t, "seller", http.MethodPut, "/api/product/"+p.ID.String(), edit(changed))
+			if code != tt.want || !strings.Contains(body, tt.field) {
+				t.Fatalf("PUT = %d %s, want %d naming %q", code, body, tt.want, tt.field)
+			}
+		})
+	}
+
+	got, err := a.store.Products.GetByID(context.Background(), p.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.EndsAt.Equal(end) {
+		t.Errorf("ends_at = %v, want %v kept", got.EndsAt, end)
+	}
+}
+
+func TestAddBidNotAboveCurrent(t *testing.T) {
+	a := newApp(t)
+	seller := a.account(t, "seller", account.RoleUser, true)
+	bidder := a.account(t, "bidder", account.RoleUser, false)
+
+	p := a.product(t, seller)
+	now := time.Now().UTC()
+	a.publish(t, &p, now, now.Add(24*time.Hour))
+
+	rejected := metrics.BidsRejected.WithLabelValues(metrics.RejectTooLow)
+	before := testutil.ToFloat64(rejected)
+
+	path := "/api/bid/account/" + bidder.ID.String() + "/product/" + p.ID.String()
+
+	tests := []struct {
+		amount string
+		want   int
+	}{
+		{"150.00", http.StatusCreated},
+		{"150.00", http.StatusConflict},
+		{"120.00", http.StatusConflict},
+		{"150.01", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		code, body := a.do(t, "bidder", http.MethodPost, path, map[string]any{"bid_value": tt.amount, "bid_message": "mine"})
+		if code != tt.want {
+			t.Fatalf("bid of %s = %d %s, want %d", tt.amount, code, body, tt.want)
+		}
+		if code == http.StatusCreated && !strings.Contains(body, product.BidActive) {
+			t.Errorf("bid of %s = %s, want it active", tt.amount, body)
+		}
+	}
+
+	if got := testutil.ToFloat64(rejected) - before; got != 2 {
+		t.Errorf("%v bids counted as too low, want 2", got)
+	}
+
+	bids, total, err := a.store.Bids.GetByProduct(context.Background(), p.ID, 10, 0)
+	if err != nil || total != 2 || bids[0].Status != product.BidActive || bids[1].Status != product.BidOutbid {
+		t.Errorf("GetByProduct = %+v, %d, %v; want the two accepted bids", bids, total, err)
+	}
+}
@@ -0,0 +1,287 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/notification"
+	"github.com/Nier704/arthur-leilao-server/internal/metrics"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/google/uuid"
+)
+
+// maxReviewReason is how long the reason of a review may be.
+const maxReviewReason = 500
+
+// ReviewedFields are the fields a moderator checks, along with the images.
+var ReviewedFields = []string{FieldTitle, FieldDescription, FieldTags}
+
+// reviewLocked reports whether the ReviewedFields and images of p are
+// locked for principal: changing them would go around a review when p is
+// awaiting one or was approved, or when p is listed and principal would
+// need a review to list it. Moderators and admins may approve it
+// themselves.
+func reviewLocked(p *Product, principal *middlewares.Principal) bool {
+	if principal.Role != account.RoleUser {
+		return false
+	}
+
+	if p.ReviewStatus == ReviewPending || p.ReviewStatus == ReviewApproved {
+		return true
+	}
+
+	return p.PublishedAt != nil && !principal.Verified
+}
+
+// reviewedChanges returns the ReviewedFields among changes.
+func reviewedChanges(changes []Change) []string {
+	reviewed := make([]string, 0)
+	for _, c := range changes {
+		if slices.Contains(ReviewedFields, c.Field) {
+			reviewed = append(reviewed, c.Field)
+		}
+	}
+
+	return reviewed
+}
+
+// Pending lists the products awaiting review, oldest first.
+func (h *ProductHandler) Pending(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx := r.Context()
+
+	products, err := h.Products.GetPending(ctx)
+	if err != nil {
+		logger.Error("error getting products to review", "err", err)
+		http.Error(w, "error getting products to review", http.StatusInternalServerError)
+		return
+	}
+
+	if !h.withImages(ctx, w, products) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(products); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// Approve publishes a product awaiting review. The product is checked
+// again, as its images or auction may have changed while it waited.
+func (h *ProductHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, reason, ok := reviewParams(w, r, false)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	p, err := h.Products.GetByID(ctx, id)
+	if err != nil {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if p.ReviewStatus != ReviewPending || p.PublishedAt != nil {
+		logger.Info("product is not awaiting review", "product_id", id)
+		http.Error(w, "product is not awaiting review", http.StatusConflict)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	if !h.publishable(ctx, w, p, now) {
+		return
+	}
+
+	if err = h.Products.Approve(ctx, id, reason, now); err != nil {
+		reviewError(w, r, err)
+		return
+	}
+
+	p.ReviewStatus, p.ReviewReason, p.PublishedAt = ReviewApproved, reason, &now
+
+	metrics.AuctionsOpened.Inc()
+
+	h.notifySeller(ctx, p, notification.KindListingApproved, fmt.Sprintf("%q was approved and is now listed", p.Title))
+
+	h.writeReviewed(ctx, w, p)
+}
+
+// Reject sends a product awaiting review back to its seller as a draft, or
+// takes down a published one. A reason is required, so the seller knows
+// what to fix.
+func (h *ProductHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, reason, ok := reviewParams(w, r, true)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	p, err := h.Products.GetByID(ctx, id)
+	if err != nil {
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if err = h.Products.Reject(ctx, id, reason); err != nil {
+		reviewError(w, r, err)
+		return
+	}
+
+	p.ReviewStatus, p.ReviewReason, p.PublishedAt = ReviewRejected, reason, nil
+
+	h.notifySeller(ctx, p, notification.KindListingRejected, fmt.Sprintf("%q was rejected by a moderator: %s", p.Title, reason))
+
+	h.writeReviewed(ctx, w, p)
+}
+
+// RemoveBidMessage takes down the message of a bid. The bid itself stands.
+func (h *ProductHandler) RemoveBidMessage(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := uuid.Parse(r.PathValue("bidId"))
+	if err != nil {
+		logger.Info("invalid bid id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err = h.Bids.RemoveMessage(ctx, id); err != nil {
+		if errors.Is(err, ErrBidNotFound) {
+			logger.Info("not found", "err", err)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		logger.Error("error removing bid message", "err", err)
+		http.Error(w, "error removing bid message", http.StatusInternalServerError)
+		return
+	}
+
+	res := map[string]string{
+		"message": "bid message removed",
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// reviewParams reads the product id and the reason of a review.
+func reviewParams(w http.ResponseWriter, r *http.Request, reasonRequired bool) (uuid.UUID, string, bool) {
+	logger := middlewares.Logger(r.Context())
+
+	id, err := uuid.Parse(r.PathValue("productId"))
+	if err != nil {
+		logger.Info("invalid id")
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return uuid.Nil, "", false
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+
+	if r.ContentLength != 0 {
+		if err = json.NewDecoder(r.Body).Decode(&body); err != nil {
+			logger.Info("error decoding body", "err", err)
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return uuid.Nil, "", false
+		}
+	}
+
+	body.Reason = strings.TrimSpace(body.Reason)
+
+	if reasonRequired && body.Reason == "" {
+		logger.Info("missing review reason")
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return uuid.Nil, "", false
+	}
+
+	if len(body.Reason) > maxReviewReason {
+		logger.Info("review reason too long")
+		http.Error(w, fmt.Sprintf("reason must be at most %d characters", maxReviewReason), http.StatusBadRequest)
+		return uuid.Nil, "", false
+	}
+
+	return id, body.Reason, true
+}
+
+func reviewError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := middlewares.Logger(r.Context())
+
+	switch {
+	case errors.Is(err, ErrNotPending):
+		logger.Info("error reviewing product", "err", err)
+		http.Error(w, "product is not awaiting review", http.StatusConflict)
+	case errors.Is(err, ErrNotFound):
+		logger.Info("not found", "err", err)
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		logger.Error("error reviewing product", "err", err)
+		http.Error(w, "error reviewing product", http.StatusInternalServerError)
+	}
+}
+
+func (h *ProductHandler) writeReviewed(ctx context.Context, w http.ResponseWriter, p *Product) {
+	products := []Product{*p}
+	if !h.withImages(ctx, w, products) {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(products[0]); err != nil {
+		middlewares.Logger(ctx).Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// notifySeller tells the seller of p about a review. Failures are only
+// logged, the review has already happened.
+func (h *ProductHandler) notifySeller(ctx context.Context, p *Product, kind string, message string) {
+	ctx = context.WithoutCancel(ctx)
+
+	n := notification.Notification{
+		AccountID: p.AccountID,
+		Kind:      kind,
+		Message:   message,
+		ProductID: uuid.NullUUID{UUID: p.ID, Valid: true},
+	}
+
+	if err := h.Notifications.Create(ctx, &n); err != nil {
+		middlewares.Logger(ctx).Error("error notifying", "recipient_id", p.AccountID, "err", err)
+	}
+}
@@ -0,0 +1,175 @@
+package product_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+)
+
+// state puts p of seller in a review state.
+type state func(t *testing.T, a *app, p *product.Product)
+
+func draft(t *testing.T, a *app, p *product.Product) {}
+
+func pending(t *testing.T, a *app, p *product.Product) {
+	if err := a.store.Products.Submit(context.Background(), p.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func approved(t *testing.T, a *app, p *product.Product) {
+	end := time.Now().Add(24 * time.Hour)
+	p.EndsAt = &end
+	if err := a.store.Products.Update(context.Background(), p, p.AccountID); err != nil {
+		t.Fatal(err)
+	}
+
+	pending(t, a, p)
+
+	if err := a.store.Products.Approve(context.Background(), p.ID, "", time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func rejected(t *testing.T, a *app, p *product.Product) {
+	pending(t, a, p)
+
+	if err := a.store.Products.Reject(context.Background(), p.ID, "blurry photos"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func published(t *testing.T, a *app, p *product.Product) {
+	a.publish(t, p, time.Now().UTC(), time.Now().Add(24*time.Hour))
+}
+
+func TestReviewedFieldsLocked(t *testing.T) {
+	tests := []struct {
+		name     string
+		editor   string
+		state    state
+		change   func(p *product.Product)
+		want     int
+		wantSave bool
+	}{
+		{"title of an approved product", "seller", approved, func(p *product.Product) { p.Title = "Fender" }, http.StatusConflict, false},
+		{"description of an approved product", "seller", approved, func(p *product.Product) { p.Description = "Mint" }, http.StatusConflict, false},
+		{"tags of an approved product", "seller", approved, func(p *product.Product) { p.Tags = []string{"free shipping"} }, http.StatusConflict, false},
+		{"price of an approved product", "seller", approved, func(p *product.Product) { p.Price = money.New(9000, money.DefaultCurrency) }, http.StatusOK, true},
+		{"title of a product awaiting review", "seller", pending, func(p *product.Product) { p.Title = "Fender" }, http.StatusConflict, false},
+		{"title of a rejected draft", "seller", rejected, func(p *product.Product) { p.Title = "Fender" }, http.StatusOK, true},
+		{"title of a draft", "seller", draft, func(p *product.Product) { p.Title = "Fender" }, http.StatusOK, true},
+		{"title listed by an unverified seller", "seller", published, func(p *product.Product) { p.Title = "Fender" }, http.StatusConflict, false},
+		{"title listed by a verified seller", "verified", published, func(p *product.Product) { p.Title = "Fender" }, http.StatusOK, true},
+		{"title of an approved product by an admin", "admin", approved, func(p *product.Product) { p.Title = "Fender" }, http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newApp(t)
+			seller := a.account(t, "seller", account.RoleUser, false)
+			a.account(t, "admin", account.RoleAdmin, false)
+
+			owner := seller
+			if tt.editor == "verified" {
+				owner = a.account(t, "verified", account.RoleUser, true)
+			}
+
+			p := a.product(t, owner)
+			tt.state(t, a, &p)
+
+			changed := p
+			tt.change(&changed)
+
+			code, body := a.do(t, tt.editor, http.MethodPut, "/api/product/"+p.ID.String(), edit(changed))
+			if code != tt.want {
+				t.Fatalf("PUT = %d %s, want %d", code, body, tt.want)
+			}
+
+			got, err := a.store.Products.GetByID(context.Background(), p.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if saved := got.Title == changed.Title && got.Description == changed.Description && got.Price == changed.Price && len(got.Tags) == len(changed.Tags) && got.Tags[0] == changed.Tags[0]; saved != tt.wantSave {
+				t.Errorf("saved %+v, want saved = %v", got, tt.wantSave)
+			}
+		})
+	}
+}
+
+// upload is a multipart form with one PNG as the "image" field.
+func upload(t *testing.T) (*bytes.Buffer, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	part, err := form.CreateFormFile("image", "photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = png.Encode(part, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	if err = form.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &body, form.FormDataContentType()
+}
+
+func TestUploadLockedAfterReview(t *testing.T) {
+	tests := []struct {
+		name  string
+		state state
+		want  int
+	}{
+		{"draft", draft, http.StatusCreated},
+		{"rejected", rejected, http.StatusCreated},
+		{"pending", pending, http.StatusConflict},
+		{"approved", approved, http.StatusConflict},
+		{"published", published, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newApp(t)
+			seller := a.account(t, "seller", account.RoleUser, false)
+
+			p := a.product(t, seller)
+			tt.state(t, a, &p)
+
+			body, contentType := upload(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/product/"+p.ID.String()+"/images", body)
+			req.Header.Set("Authorization", "Bearer seller")
+			req.Header.Set("Content-Type", contentType)
+
+			rec := httptest.NewRecorder()
+			a.mux.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("upload = %d %s, want %d", rec.Code, rec.Body, tt.want)
+			}
+
+			images, err := a.store.Images.GetByProduct(context.Background(), p.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored := len(images) == 1; stored != (tt.want == http.StatusCreated) {
+				t.Errorf("%d images stored", len(images))
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package product
+
+import (
+	"cmp"
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/Nier704/arthur-leilao-server/internal/utils"
+)
+
+type SearchResult struct {
+	Product
+	Rank float64 `json:"rank"`
+	// Snippet is an HTML excerpt of the title and description with the
+	// matched words wrapped in <mark>. Everything else is escaped.
+	Snippet string `json:"snippet"`
+}
+
+// HighlightStart and HighlightStop delimit matched words in snippets built
+// by a store, so Highlight can tell them apart from markup typed by a
+// seller. They are private use characters, which a seller could still type:
+// stores build snippets from text with them removed.
+const (
+	HighlightStart = "\uE000"
+	HighlightStop  = "\uE001"
+)
+
+// highlightDelimiters removes the delimiters from product text.
+var highlightDelimiters = strings.NewReplacer(HighlightStart, "", HighlightStop, "")
+
+// Highlight escapes snippet and turns its delimiters into <mark> tags.
+func Highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, HighlightStart, "<mark>")
+
+	return strings.ReplaceAll(snippet, HighlightStop, "</mark>")
+}
+
+// The stores without full-text search match words with the helpers below:
+// every term of the query must start a word of the title or description,
+// ignoring case and accents. There is no stemming, but matching prefixes
+// finds most plurals ("guitarra" finds "Guitarras").
+
+// stopwords are left out of queries, like the portuguese text search
+// configuration does.
+var stopwords = []string{
+	"a", "o", "as", "os", "e", "de", "da", "do", "das", "dos",
+	"em", "no", "na", "nos", "nas", "um", "uma", "para", "por", "com",
+}
+
+// SearchTerms splits a query into folded words.
+func SearchTerms(q string) []string {
+	var terms []string
+
+	folded := []rune(utils.Fold(q))
+	for _, w := range words(string(folded)) {
+		term := string(folded[w[0]:w[1]])
+		if !slices.Contains(stopwords, term) && !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+
+	return terms
+}
+
+// RankSearch returns the page of products matching every term, best match
+// first, and how many match in all.
+func RankSearch(products []Product, terms []string, limit int, offset int) ([]SearchResult, int) {
+	results := make([]SearchResult, 0)
+
+	for _, p := range products {
+		if rank, ok := matchSearch(p, terms); ok {
+			results = append(results, SearchResult{Product: p, Rank: rank})
+		}
+	}
+
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		if a.Rank != b.Rank {
+			return cmp.Compare(b.Rank, a.Rank)
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+
+	total := len(results)
+
+	results = results[min(offset, total):min(offset+limit, total)]
+	for i := range results {
+		results[i].Snippet = snippet(results[i].Product, terms)
+	}
+
+	return results, total
+}
+
+// matchSearch ranks p against the terms. A match in the title counts
+// double; ok is false unless every term matched.
+func matchSearch(p Product, terms []string) (rank float64, ok bool) {
+	title := matches(p.Title, terms)
+	description := matches(p.Description, terms)
+
+	for _, term := range terms {
+		if title[term]+description[term] == 0 {
+			return 0, false
+		}
+		rank += float64(2*title[term] + description[term])
+	}
+
+	return rank, len(terms) > 0
+}
+
+// snippetWords is how many words a snippet shows.
+const snippetWords = 30
+
+// snippet returns the words of the title and description around the first
+// match, highlighted like the full-text search does.
+func snippet(p Product, terms []string) string {
+	text := []rune(highlightDelimiters.Replace(p.Title + " " + p.Description))
+	folded := []rune(utils.Fold(string(text)))
+	spans := words(string(folded))
+
+	first := slices.IndexFunc(spans, func(w [2]int) bool {
+		return matchesWord(string(folded[w[0]:w[1]]), terms)
+	})
+	if first < 0 {
+		first = 0
+	}
+
+	start := max(0, first-snippetWords/4)
+	end := min(len(spans), start+snippetWords)
+
+	var b strings.Builder
+
+	for i := start; i < end; i++ {
+		w := spans[i]
+
+		if i > start {
+			b.WriteString(string(text[spans[i-1][1]:w[0]]))
+		}
+
+		if matchesWord(string(folded[w[0]:w[1]]), terms) {
+			b.WriteString(HighlightStart + string(text[w[0]:w[1]]) + HighlightStop)
+		} else {
+			b.WriteString(string(text[w[0]:w[1]]))
+		}
+	}
+
+	return Highlight(b.String())
+}
+
+// matches counts the words of text each term starts.
+func matches(text string, terms []string) map[string]int {
+	counts := make(map[string]int)
+
+	folded := []rune(utils.Fold(text))
+	for _, w := range words(string(folded)) {
+		word := string(folded[w[0]:w[1]])
+		for _, term := range terms {
+			if strings.HasPrefix(word, term) {
+				counts[term]++
+			}
+		}
+	}
+
+	return counts
+}
+
+func matchesWord(word string, terms []string) bool {
+	return slices.ContainsFunc(terms, func(term string) bool {
+		return strings.HasPrefix(word, term)
+	})
+}
+
+// words returns the rune offsets of the runs of letters and digits in s.
+func words(s string) [][2]int {
+	var spans [][2]int
+
+	start := -1
+	for i, r := range []rune(s) {
+		letter := unicode.IsLetter(r) || unicode.IsDigit(r)
+
+		switch {
+		case letter && start < 0:
+			start = i
+		case !letter && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len([]rune(s))})
+	}
+
+	return spans
+}
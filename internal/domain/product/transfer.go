@@ -0,0 +1,383 @@
+package product
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+	"github.com/google/uuid"
+)
+
+// Formats of product imports and exports.
+const (
+	FormatCSV = "csv"
+	// FormatJSONL has one product object per line.
+	FormatJSONL = "jsonl"
+)
+
+// MaxImportRows is how many products one import may carry.
+const MaxImportRows = 1000
+
+// ErrTooManyRows is returned when an import has more than MaxImportRows.
+var ErrTooManyRows = fmt.Errorf("an import has at most %d products", MaxImportRows)
+
+// transferColumns are the CSV columns, in the order exports write them.
+// Imports need a header naming title, description and price, in any
+// order; id and published_at are ignored, so exports import back as new
+// drafts. Tags are separated by commas.
+var transferColumns = []string{"id", "title", "description", "price", "currency", "category_id", "tags", "ends_at", "published_at"}
+
+var requiredColumns = []string{"title", "description", "price"}
+
+// RowError is a problem with one row of an import. Rows are numbered from
+// 1, the CSV header included, as spreadsheets number them.
+type RowError struct {
+	Row   int    `json:"row"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportRow is a product read from an import, with the problems found in
+// it. Only rows without errors may be created.
+type ImportRow struct {
+	Row     int
+	Product Product
+	Errors  []RowError
+}
+
+func (row *ImportRow) fail(field string, err string) {
+	row.Errors = append(row.Errors, RowError{Row: row.Row, Field: field, Error: err})
+}
+
+// transferProduct is a product as JSONL carries it.
+type transferProduct struct {
+	ID          *uuid.UUID      `json:"id,omitempty"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Price       json.RawMessage `json:"price"`
+	CategoryID  uuid.NullUUID   `json:"category_id"`
+	Tags        []string        `json:"tags"`
+	EndsAt      *time.Time      `json:"ends_at"`
+	PublishedAt *time.Time      `json:"published_at,omitempty"`
+}
+
+// ReadImport parses an import in format. Rows that cannot be parsed are
+// returned with their errors; an error is only returned when the import
+// as a whole cannot be read.
+func ReadImport(r io.Reader, format string) ([]ImportRow, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r)
+	case FormatJSONL:
+		return readJSONL(r)
+	}
+
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func readCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the header row is missing")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(header))
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(transferColumns, name) {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if _, ok := index[name]; ok {
+			return nil, fmt.Errorf("column %q appears twice", name)
+		}
+		index[name] = i
+	}
+
+	for _, name := range requiredColumns {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("column %q is missing", name)
+		}
+	}
+
+	rows := make([]ImportRow, 0)
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if len(rows) == MaxImportRows {
+			return nil, ErrTooManyRows
+		}
+
+		if err != nil {
+			// a malformed record is that row's problem, but the upload
+			// failing to arrive is the whole import's
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+
+			row := ImportRow{Row: parseErr.StartLine}
+			if errors.Is(err, csv.ErrFieldCount) {
+				row.fail("", fmt.Sprintf("expected %d columns, found %d", len(header), len(record)))
+			} else {
+				row.fail("", fmt.Sprintf("invalid CSV: %v", parseErr.Err))
+			}
+			rows = append(rows, row)
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := ImportRow{Row: line}
+
+		value := func(name string) string {
+			if i, ok := index[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		p := &row.Product
+		p.Title = value("title")
+		p.Description = value("description")
+
+		currency := strings.ToUpper(cmp.Or(value("currency"), money.DefaultCurrency))
+
+		if p.Price, err = money.Parse(value("price"), currency); err != nil {
+			if errors.Is(err, money.ErrInvalidCurrency) {
+				row.fail("currency", fmt.Sprintf("%q is not a currency code", currency))
+			} else {
+				row.fail("price", fmt.Sprintf("%q is not an amount", value("price")))
+			}
+		}
+
+		if v := value("category_id"); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				row.fail("category_id", fmt.Sprintf("%q is not an id", v))
+			}
+			p.CategoryID = uuid.NullUUID{UUID: id, Valid: err == nil}
+		}
+
+		p.Tags = make([]string, 0)
+		if v := value("tags"); v != "" {
+			p.Tags = strings.Split(v, ",")
+		}
+
+		if v := value("ends_at"); v != "" {
+			endsAt, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				row.fail("ends_at", fmt.Sprintf("%q is not an RFC 3339 time", v))
+			} else {
+				p.EndsAt = &endsAt
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func readJSONL(r io.Reader) ([]ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	rows := make([]ImportRow, 0)
+
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		if len(rows) == MaxImportRows {
+			return nil, ErrTooManyRows
+		}
+
+		row := ImportRow{Row: line}
+
+		var v transferProduct
+		if err := json.Unmarshal(data, &v); err != nil {
+			row.fail("", fmt.Sprintf("invalid JSON: %v", err))
+			rows = append(rows, row)
+			continue
+		}
+
+		p := &row.Product
+		p.Title = strings.TrimSpace(v.Title)
+		p.Description = strings.TrimSpace(v.Description)
+		p.CategoryID = v.CategoryID
+		p.Tags = v.Tags
+		p.EndsAt = v.EndsAt
+
+		if p.Tags == nil {
+			p.Tags = make([]string, 0)
+		}
+
+		var err error
+		switch p.Price, err = money.ParseJSON(v.Price, money.DefaultCurrency); {
+		case err != nil:
+			row.fail("price", err.Error())
+		case p.Price.Currency == "":
+			row.fail("price", "price is required")
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// Validate checks the product of row as Create would, normalizing its
+// tags. tree is the category tree. Rows that could not be read at all are
+// left alone.
+func (row *ImportRow) Validate(tree *category.Tree, now time.Time) {
+	if slices.ContainsFunc(row.Errors, func(e RowError) bool { return e.Field == "" }) {
+		return
+	}
+
+	p := &row.Product
+
+	switch {
+	case p.Title == "":
+		row.fail("title", "title is required")
+	case utf8.RuneCountInString(p.Title) > maxTextLength:
+		row.fail("title", fmt.Sprintf("title is at most %d characters", maxTextLength))
+	}
+
+	switch {
+	case p.Description == "":
+		row.fail("description", "description is required")
+	case utf8.RuneCountInString(p.Description) > maxTextLength:
+		row.fail("description", fmt.Sprintf("description is at most %d characters", maxTextLength))
+	}
+
+	if p.Price.Currency != "" && !p.Price.IsPositive() {
+		row.fail("price", "price must be positive")
+	}
+
+	if p.CategoryID.Valid && !tree.IsLeaf(p.CategoryID.UUID) {
+		row.fail("category_id", "category_id must be an existing category without subcategories")
+	}
+
+	tags, err := NormalizeTags(p.Tags)
+	if err != nil {
+		row.fail("tags", err.Error())
+	}
+	p.Tags = tags
+
+	if p.EndsAt != nil && !p.EndsAt.After(now) {
+		row.fail("ends_at", "ends_at must be in the future")
+	}
+}
+
+// ExportWriter writes products in an export format.
+type ExportWriter struct {
+	format string
+	csv    *csv.Writer
+	json   *json.Encoder
+}
+
+// NewExportWriter starts an export in format, writing the CSV header.
+func NewExportWriter(w io.Writer, format string) (*ExportWriter, error) {
+	e := &ExportWriter{format: format}
+
+	switch format {
+	case FormatCSV:
+		e.csv = csv.NewWriter(w)
+		if err := e.csv.Write(transferColumns); err != nil {
+			return nil, err
+		}
+	case FormatJSONL:
+		e.json = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+
+	return e, nil
+}
+
+// Write writes one product.
+func (e *ExportWriter) Write(p Product) error {
+	if e.format == FormatJSONL {
+		price, err := json.Marshal(p.Price)
+		if err != nil {
+			return err
+		}
+
+		id := p.ID
+
+		return e.json.Encode(transferProduct{
+			ID:          &id,
+			Title:       p.Title,
+			Description: p.Description,
+			Price:       price,
+			CategoryID:  p.CategoryID,
+			Tags:        p.Tags,
+			EndsAt:      p.EndsAt,
+			PublishedAt: p.PublishedAt,
+		})
+	}
+
+	var category, endsAt, publishedAt string
+
+	if p.CategoryID.Valid {
+		category = p.CategoryID.UUID.String()
+	}
+
+	if p.EndsAt != nil {
+		endsAt = p.EndsAt.UTC().Format(time.RFC3339)
+	}
+
+	if p.PublishedAt != nil {
+		publishedAt = p.PublishedAt.UTC().Format(time.RFC3339)
+	}
+
+	return e.csv.Write([]string{
+		p.ID.String(),
+		p.Title,
+		p.Description,
+		p.Price.String(),
+		p.Price.Currency,
+		category,
+		strings.Join(p.Tags, ", "),
+		endsAt,
+		publishedAt,
+	})
+}
+
+// Flush writes out what is buffered.
+func (e *ExportWriter) Flush() error {
+	if e.csv == nil {
+		return nil
+	}
+
+	e.csv.Flush()
+
+	return e.csv.Error()
+}
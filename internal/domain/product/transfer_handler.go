@@ -0,0 +1,302 @@
+package product
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/google/uuid"
+)
+
+// Import modes.
+const (
+	// ImportAtomic creates every product or, when any row has a problem,
+	// none.
+	ImportAtomic = "atomic"
+	// ImportBestEffort creates the rows without problems and reports the
+	// others.
+	ImportBestEffort = "best_effort"
+)
+
+const (
+	maxImportBytes = 5 << 20
+	exportPageSize = 100
+)
+
+// Import creates the caller's products from a CSV or JSON Lines upload.
+// Products are created as drafts, to be published once they have photos.
+// ?format= names the format, otherwise taken from the Content-Type;
+// ?mode= is atomic (the default) or best_effort; ?dry_run=true only
+// checks the rows.
+func (h *ProductHandler) Import(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	format, ok := importFormat(r)
+	if !ok {
+		logger.Info("unknown import format")
+		http.Error(w, fmt.Sprintf("format must be %s or %s", FormatCSV, FormatJSONL), http.StatusBadRequest)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = ImportAtomic
+	case ImportAtomic, ImportBestEffort:
+	default:
+		logger.Info("invalid import mode", "mode", mode)
+		http.Error(w, fmt.Sprintf("mode must be %s or %s", ImportAtomic, ImportBestEffort), http.StatusBadRequest)
+		return
+	}
+
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			logger.Info("invalid dry_run", "err", err)
+			http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rows, err := ReadImport(http.MaxBytesReader(w, r.Body, maxImportBytes), format)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			logger.Info("import too large", "err", err)
+			http.Error(w, fmt.Sprintf("an import has at most %d bytes", maxImportBytes), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, ErrTooManyRows):
+			logger.Info("import too large", "err", err)
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		default:
+			logger.Info("error reading import", "err", err)
+			http.Error(w, "invalid import: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	if len(rows) == 0 {
+		logger.Info("empty import")
+		http.Error(w, "the import has no products", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	tree, err := category.LoadTree(ctx, h.Categories)
+	if err != nil {
+		logger.Error("error loading categories", "err", err)
+		http.Error(w, "error loading categories", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	res := struct {
+		Format  string      `json:"format"`
+		Mode    string      `json:"mode"`
+		DryRun  bool        `json:"dry_run"`
+		Rows    int         `json:"rows"`
+		Valid   int         `json:"valid"`
+		Created []uuid.UUID `json:"created"`
+		Errors  []RowError  `json:"errors"`
+	}{
+		Format:  format,
+		Mode:    mode,
+		DryRun:  dryRun,
+		Rows:    len(rows),
+		Created: make([]uuid.UUID, 0),
+		Errors:  make([]RowError, 0),
+	}
+
+	valid := make([]ImportRow, 0, len(rows))
+
+	for _, row := range rows {
+		row.Product.AccountID = principal.AccountID
+		row.Validate(tree, now)
+
+		if len(row.Errors) > 0 {
+			res.Errors = append(res.Errors, row.Errors...)
+			continue
+		}
+
+		valid = append(valid, row)
+	}
+
+	res.Valid = len(valid)
+
+	switch {
+	case dryRun:
+	case mode == ImportAtomic && len(res.Errors) > 0:
+		logger.Info("import rejected", "rows", len(rows), "errors", len(res.Errors))
+	case mode == ImportAtomic:
+		products := make([]Product, len(valid))
+		for i, row := range valid {
+			products[i] = row.Product
+		}
+
+		if err = h.Products.CreateMany(ctx, products); err != nil {
+			logger.Error("error importing products", "err", err)
+			http.Error(w, "error importing products", http.StatusInternalServerError)
+			return
+		}
+
+		for _, p := range products {
+			res.Created = append(res.Created, p.ID)
+		}
+	default:
+		for _, row := range valid {
+			if err = h.Products.Create(ctx, &row.Product); err != nil {
+				logger.Error("error importing row", "row", row.Row, "err", err)
+				res.Errors = append(res.Errors, RowError{Row: row.Row, Error: "the product could not be created"})
+				continue
+			}
+
+			res.Created = append(res.Created, row.Product.ID)
+		}
+	}
+
+	status := http.StatusCreated
+	switch {
+	case dryRun:
+		status = http.StatusOK
+	case len(res.Created) == 0:
+		status = http.StatusUnprocessableEntity
+	}
+
+	w.WriteHeader(status)
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("error encoding response", "err", err)
+		http.Error(w, "error encoding response", http.StatusInternalServerError)
+	}
+}
+
+// Export streams the caller's products, published ones first and then the
+// drafts, in ?format= (csv by default). Exports import back as drafts.
+func (h *ProductHandler) Export(w http.ResponseWriter, r *http.Request) {
+	logger := middlewares.Logger(r.Context())
+
+	principal, ok := middlewares.PrincipalFromContext(r.Context())
+	if !ok {
+		logger.Info("missing principal")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = FormatCSV
+	case FormatCSV, FormatJSONL:
+	default:
+		logger.Info("unknown export format", "format", format)
+		http.Error(w, fmt.Sprintf("format must be %s or %s", FormatCSV, FormatJSONL), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+
+	opts := ListOptions{
+		Seller: uuid.NullUUID{UUID: principal.AccountID, Valid: true},
+		Now:    time.Now().UTC(),
+		Sort:   SortNewest,
+		Limit:  exportPageSize,
+	}
+
+	// the first page is read before answering, so a failing store still
+	// gets a proper error
+	page, err := h.Products.List(ctx, opts)
+	if err != nil {
+		logger.Error("error exporting products", "err", err)
+		http.Error(w, "error exporting products", http.StatusInternalServerError)
+		return
+	}
+
+	if format == FormatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products.%s"`, format))
+
+	out, err := NewExportWriter(w, format)
+	if err != nil {
+		logger.Error("error starting export", "err", err)
+		http.Error(w, "error exporting products", http.StatusInternalServerError)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	for {
+		for _, p := range page.Products {
+			if err = out.Write(p); err != nil {
+				logger.Error("error writing export", "err", err)
+				return
+			}
+		}
+
+		if err = out.Flush(); err != nil {
+			logger.Error("error writing export", "err", err)
+			return
+		}
+		// not every writer flushes; the export then goes out at the end
+		_ = rc.Flush()
+
+		switch {
+		case page.HasMore:
+			last := CursorOf(page.Products[len(page.Products)-1], opts.Sort)
+			opts.After = &last
+		case !opts.Drafts:
+			opts.Drafts = true
+			opts.After = nil
+		default:
+			return
+		}
+
+		if page, err = h.Products.List(ctx, opts); err != nil {
+			// the status is already sent; the export ends short
+			logger.Error("error exporting products", "err", err)
+			return
+		}
+	}
+}
+
+// importFormat reads ?format=, or the Content-Type of the upload.
+func importFormat(r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case FormatCSV, FormatJSONL:
+		return format, true
+	case "":
+	default:
+		return "", false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV, true
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return FormatJSONL, true
+	}
+
+	return "", false
+}
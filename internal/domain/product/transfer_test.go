@@ -0,0 +1,264 @@
+package product_test
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+	"github.com/google/uuid"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Now().UTC()
+	past := now.Add(-time.Hour)
+
+	tests := []struct {
+		name   string
+		change func(p *product.Product)
+		fields []string
+	}{
+		{"valid", func(p *product.Product) {}, nil},
+		{"title of 255 characters", func(p *product.Product) { p.Title = strings.Repeat("é", 255) }, nil},
+		{"title too long", func(p *product.Product) { p.Title = strings.Repeat("é", 256) }, []string{"title"}},
+		{"description too long", func(p *product.Product) { p.Description = strings.Repeat("a", 256) }, []string{"description"}},
+		{"both too long", func(p *product.Product) {
+			p.Title = strings.Repeat("a", 300)
+			p.Description = strings.Repeat("a", 300)
+		}, []string{"title", "description"}},
+		{"no title", func(p *product.Product) { p.Title = "" }, []string{"title"}},
+		{"price not positive", func(p *product.Product) { p.Price = money.New(0, money.DefaultCurrency) }, []string{"price"}},
+		{"bad tag", func(p *product.Product) { p.Tags = []string{"#1"} }, []string{"tags"}},
+		{"ends in the past", func(p *product.Product) { p.EndsAt = &past }, []string{"ends_at"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := product.ImportRow{Row: 4, Product: product.Product{
+				Title:       "Guitar",
+				Description: "Vintage",
+				Price:       money.New(10000, money.DefaultCurrency),
+				Tags:        []string{"strings"},
+			}}
+			tt.change(&row.Product)
+
+			row.Validate(category.NewTree(nil), now)
+
+			fields := make([]string, 0)
+			for _, e := range row.Errors {
+				if e.Row != 4 {
+					t.Errorf("error %+v is not for row 4", e)
+				}
+				fields = append(fields, e.Field)
+			}
+
+			if strings.Join(fields, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("errors %+v, want for %v", row.Errors, tt.fields)
+			}
+		})
+	}
+}
+
+// outcome sums up rows as "<row> <fields with errors>", a row that cannot
+// be read at all having the field "*".
+func outcome(rows []product.ImportRow) []string {
+	out := make([]string, len(rows))
+
+	for i, row := range rows {
+		fields := make([]string, 0, len(row.Errors))
+		for _, e := range row.Errors {
+			fields = append(fields, cmp.Or(e.Field, "*"))
+		}
+		out[i] = strings.TrimSpace(fmt.Sprintf("%d %s", row.Row, strings.Join(fields, ",")))
+	}
+
+	return out
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+		err   string
+	}{
+		{"rows", "title,description,price\nGuitar,Vintage,100\nDrum,Red,20.50\n", []string{"2", "3"}, ""},
+		{"columns in any order", "price, Description,TITLE\n100,Vintage,Guitar\n", []string{"2"}, ""},
+		{"wrong column count", "title,description,price\nGuitar,Vintage\nDrum,Red,20\n", []string{"2 *", "3"}, ""},
+		{"bare quote", "title,description,price\nGuitar,Vin\"tage,100\nDrum,Red,20\n", []string{"2 *", "3"}, ""},
+		{"unterminated quote", "title,description,price\nGuitar,Vintage,100\n\"Drum,Red,20\n", []string{"2", "3 *"}, ""},
+		{"quoted line breaks", "title,description,price\nGuitar,\"Vintage\nand loud\",100\nDrum,Red,20\n", []string{"2", "4"}, ""},
+		{"bad values", "title,description,price,currency,category_id,ends_at\nGuitar,Vintage,1.005,BRL,x,tomorrow\nDrum,Red,20,XX1,,\n", []string{"2 price,category_id,ends_at", "3 currency"}, ""},
+		{"no header", "", nil, "the header row is missing"},
+		{"unknown column", "title,description,price,colour\n", nil, `unknown column "colour"`},
+		{"missing column", "title,price\n", nil, `column "description" is missing`},
+		{"repeated column", "title,description,price,title\n", nil, `column "title" appears twice`},
+		{"malformed header", "title,\"description\n", nil, "parse error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := product.ReadImport(strings.NewReader(tt.input), product.FormatCSV)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := outcome(rows); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("rows %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadCSVValues(t *testing.T) {
+	input := "title,description,price,currency,category_id,tags,ends_at\n" +
+		" Guitar , Vintage ,1250.5,usd,9b6c7a52-3f7e-4a7e-9f55-4b1a1c1e2d3f,\"strings, Wood\",2030-01-02T15:04:05Z\n" +
+		"Drum,Red,20,,,,\n"
+
+	rows, err := product.ReadImport(strings.NewReader(input), product.FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("%d rows", len(rows))
+	}
+
+	p := rows[0].Product
+	if p.Title != "Guitar" || p.Description != "Vintage" || p.Price != money.New(125050, "USD") ||
+		p.CategoryID.UUID.String() != "9b6c7a52-3f7e-4a7e-9f55-4b1a1c1e2d3f" || len(p.Tags) != 2 ||
+		p.EndsAt == nil || !p.EndsAt.Equal(time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)) {
+		t.Errorf("first row read as %+v", p)
+	}
+
+	p = rows[1].Product
+	if p.Price != money.New(2000, money.DefaultCurrency) || p.CategoryID.Valid || len(p.Tags) != 0 || p.EndsAt != nil {
+		t.Errorf("second row read as %+v", p)
+	}
+}
+
+func TestReadCSVTooManyRows(t *testing.T) {
+	input := "title,description,price\n" + strings.Repeat("Guitar,Vintage,100\n", product.MaxImportRows+1)
+
+	if _, err := product.ReadImport(strings.NewReader(input), product.FormatCSV); !errors.Is(err, product.ErrTooManyRows) {
+		t.Errorf("err = %v, want ErrTooManyRows", err)
+	}
+}
+
+func TestReadJSONL(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"rows", `{"title":"Guitar","description":"Vintage","price":{"amount":"100.00","currency":"USD"}}` + "\n" + `{"title":"Drum","description":"Red","price":"20"}`, []string{"1", "2"}},
+		{"blank lines keep their numbers", "\n" + `{"title":"Guitar","description":"Vintage","price":100}` + "\n\n" + `{"title":"Drum","description":"Red","price":20}` + "\n", []string{"2", "4"}},
+		{"invalid JSON", `{"title":"Guitar",` + "\n" + `{"title":"Drum","description":"Red","price":20}`, []string{"1 *", "2"}},
+		{"bad price", `{"title":"Guitar","description":"Vintage","price":"a lot"}`, []string{"1 price"}},
+		{"null price", `{"title":"Guitar","description":"Vintage","price":null}`, []string{"1 price"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := product.ReadImport(strings.NewReader(tt.input), product.FormatJSONL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := outcome(rows); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("rows %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	rows, err := product.ReadImport(strings.NewReader(`{"title":" Guitar ","description":"Vintage","price":12.5,"tags":["wood"]}`), product.FormatJSONL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := rows[0].Product; p.Title != "Guitar" || p.Price != money.New(1250, money.DefaultCurrency) || len(p.Tags) != 1 {
+		t.Errorf("read as %+v", p)
+	}
+}
+
+func TestImportModes(t *testing.T) {
+	const (
+		good = "title,description,price\nGuitar,Vintage,100\nDrum,Red,20\n"
+		// the third line has a stray quote
+		bad = "title,description,price\nGuitar,Vintage,100\nDr\"um,Red,20\n"
+	)
+	// the title of the third line is too long
+	long := "title,description,price\nGuitar,Vintage,100\n" + strings.Repeat("a", 256) + ",Red,20\n"
+
+	tests := []struct {
+		name    string
+		mode    string
+		input   string
+		want    int
+		created int
+		errors  []string
+	}{
+		{"atomic", product.ImportAtomic, good, http.StatusCreated, 2, nil},
+		{"atomic with a malformed line", product.ImportAtomic, bad, http.StatusUnprocessableEntity, 0, []string{"3 *"}},
+		{"atomic with a long title", product.ImportAtomic, long, http.StatusUnprocessableEntity, 0, []string{"3 title"}},
+		{"best effort", product.ImportBestEffort, good, http.StatusCreated, 2, nil},
+		{"best effort with a malformed line", product.ImportBestEffort, bad, http.StatusCreated, 1, []string{"3 *"}},
+		{"best effort with a long title", product.ImportBestEffort, long, http.StatusCreated, 1, []string{"3 title"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newApp(t)
+			seller := a.account(t, "seller", account.RoleUser, false)
+
+			code, body := a.do(t, "seller", http.MethodPost, "/api/products/import?format=csv&mode="+tt.mode, tt.input)
+			if code != tt.want {
+				t.Fatalf("import = %d %s, want %d", code, body, tt.want)
+			}
+
+			var res struct {
+				Created []string           `json:"created"`
+				Errors  []product.RowError `json:"errors"`
+			}
+			if err := json.Unmarshal([]byte(body), &res); err != nil {
+				t.Fatal(err)
+			}
+
+			errs := make([]string, len(res.Errors))
+			for i, e := range res.Errors {
+				errs[i] = fmt.Sprintf("%d %s", e.Row, cmp.Or(e.Field, "*"))
+			}
+			if strings.Join(errs, "|") != strings.Join(tt.errors, "|") {
+				t.Errorf("errors %+v, want %q", res.Errors, tt.errors)
+			}
+
+			page, err := a.store.Products.List(context.Background(), product.ListOptions{
+				Seller: uuid.NullUUID{UUID: seller.ID, Valid: true},
+				Drafts: true,
+				Now:    time.Now(),
+				Sort:   product.SortNewest,
+				Limit:  10,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(res.Created) != tt.created || len(page.Products) != tt.created {
+				t.Errorf("created %d, stored %d, want %d", len(res.Created), len(page.Products), tt.created)
+			}
+		})
+	}
+}
@@ -1,83 +1,314 @@
 package domain
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/Nier704/arthur-leilao-server/config"
+	"github.com/Nier704/arthur-leilao-server/internal/blob"
 	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/apikey"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/exchange"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/health"
 	"github.com/Nier704/arthur-leilao-server/internal/domain/jwt"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/moderation"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/notification"
 	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/Nier704/arthur-leilao-server/internal/metrics"
 	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/Nier704/arthur-leilao-server/internal/storage"
 	"github.com/gorilla/handlers"
 )
 
 type Router struct {
-	accountHandler *account.AccountHandler
-	jwt            *jwt.Jwt
-	productHandler *product.ProductHandler
-	mux            *http.ServeMux
-	port           string
+	accountHandler      *account.AccountHandler
+	apiKeyHandler       *apikey.ApiKeyHandler
+	categoryHandler     *category.CategoryHandler
+	exchangeHandler     *exchange.ExchangeHandler
+	healthHandler       *health.HealthHandler
+	imageHandler        *product.ImageHandler
+	moderationHandler   *moderation.ModerationHandler
+	notificationHandler *notification.NotificationHandler
+	jwt                 *jwt.Jwt
+	productHandler      *product.ProductHandler
+	processor           *product.ImageProcessor
+	closer              *product.AuctionCloser
+	auth                middlewares.Authenticator
+	mux                 *http.ServeMux
+	cfg                 *config.Config
 }
 
-func NewRouter() *Router {
+func NewRouter(cfg *config.Config) *Router {
 	return &Router{
-		accountHandler: nil,
-		productHandler: nil,
-		jwt:            nil,
-		mux:            http.NewServeMux(),
-		port:           "3000",
+		accountHandler:      nil,
+		apiKeyHandler:       nil,
+		categoryHandler:     nil,
+		exchangeHandler:     nil,
+		healthHandler:       nil,
+		imageHandler:        nil,
+		moderationHandler:   nil,
+		notificationHandler: nil,
+		productHandler:      nil,
+		processor:           nil,
+		closer:              nil,
+		jwt:                 nil,
+		auth:                nil,
+		mux:                 http.NewServeMux(),
+		cfg:                 cfg,
 	}
 }
 
-func (r *Router) Init(db *sql.DB) {
-	ah := account.NewAccountHandler(db)
-	ph := product.NewProductHandler(db)
-	jwt := jwt.NewJwt(db)
+// Init wires the handlers. urls links to the blobs of urls.Store, which
+// the API serves under /api/images/ unless the store presigns its own.
+func (r *Router) Init(store *storage.Store, urls *blob.URLs) {
+	ah := account.NewAccountHandler(store.Accounts)
+	processor := product.NewImageProcessor(store.Images, urls.Store, r.cfg.Image.Workers)
+	ph := product.NewProductHandler(store.Products, store.Bids, store.Rates, store.Notifications, store.Categories, store.Images, urls, &r.cfg.Bid)
+	ih := product.NewImageHandler(store.Products, store.Images, urls, processor, &r.cfg.Image)
+	kh := apikey.NewApiKeyHandler(store.ApiKeys)
+	eh := exchange.NewExchangeHandler(store.Rates)
+	ch := category.NewCategoryHandler(store.Categories)
+	nh := notification.NewNotificationHandler(store.Notifications)
+	mh := moderation.NewModerationHandler(store.Reports, store.Products, store.Bids)
+	closer := product.NewAuctionCloser(store.Products, closeInterval)
+	hh := health.NewHealthHandler(store.DB, store.Migrations, map[string]health.Worker{
+		"image_processor": processor,
+		"auction_closer":  closer,
+	})
+	jwt := jwt.NewJwt(store.Accounts, store.Identities, &r.cfg.Auth, &r.cfg.OIDC)
 
 	r.accountHandler = ah
+	r.apiKeyHandler = kh
+	r.categoryHandler = ch
+	r.exchangeHandler = eh
+	r.healthHandler = hh
+	r.imageHandler = ih
+	r.moderationHandler = mh
+	r.notificationHandler = nh
 	r.productHandler = ph
+	r.processor = processor
+	r.closer = closer
 	r.jwt = jwt
+	r.auth = &authenticator{jwt: jwt, apiKeys: kh, accounts: store.Accounts}
 
 	r.setAccountsRoutes()
+	r.setApiKeysRoutes()
 	r.setProductsRoutes()
+	r.setImagesRoutes()
+	r.setExchangeRoutes()
+	r.setCategoriesRoutes()
+	r.setModerationRoutes()
+	r.setHealthRoutes()
+
+	if store.DB != nil {
+		metrics.RegisterDB(store.DB, r.cfg.Storage.Backend)
+	}
+
+	// scraped often, so neither logged nor measured
+	r.mux.Handle("GET /metrics", metrics.Handler(r.cfg.Metrics.Token))
+}
+
+// Run starts the background workers and serves until ctx is done. It then
+// stops taking connections and gives the requests in flight and the
+// workers the shutdown timeout to finish.
+func (r *Router) Run(ctx context.Context) error {
+	work, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	if err := r.processor.Start(work); err != nil {
+		slog.Error("error resuming image processing", "err", err)
+	}
+
+	r.closer.Start(work)
+
+	srv := &http.Server{
+		Addr: fmt.Sprintf("0.0.0.0:%d", r.cfg.Server.Port),
+		Handler: handlers.CORS(
+			handlers.AllowedOrigins(r.cfg.CORS.AllowedOrigins),
+			handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
+			handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", apikey.Header, middlewares.RequestIDHeader}),
+			handlers.ExposedHeaders([]string{middlewares.RequestIDHeader}),
+			handlers.AllowCredentials(),
+		)(middlewares.RequestID(r.mux)),
+		ReadTimeout:  r.cfg.Server.ReadTimeout,
+		WriteTimeout: r.cfg.Server.WriteTimeout,
+		IdleTimeout:  r.cfg.Server.IdleTimeout,
+		ErrorLog:     slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("server is running", "port", r.cfg.Server.Port)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "timeout", r.cfg.Server.ShutdownTimeout.String())
+
+	shutdown, cancel := context.WithTimeout(context.Background(), r.cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdown); err != nil {
+		return fmt.Errorf("draining requests: %w", err)
+	}
+
+	stop()
+
+	if err := r.processor.Wait(shutdown); err != nil {
+		return fmt.Errorf("stopping image processing: %w", err)
+	}
+
+	if err := r.closer.Wait(shutdown); err != nil {
+		return fmt.Errorf("stopping the auction closer: %w", err)
+	}
+
+	return nil
 }
 
-func (r *Router) Start() {
-	fmt.Println("server is already running at PORT: " + r.port)
+// closeInterval is how often ended auctions are closed. Bids are refused
+// from the end on, so this only delays marking the winning bid.
+const closeInterval = 30 * time.Second
 
-	if err := http.ListenAndServe("0.0.0.0:"+r.port, handlers.CORS(
-		handlers.AllowedOrigins([]string{"https://yuraibids.netlify.app", "https://arthur-leilao-api-production.up.railway.app", "http://localhost:5173"}),
-		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}),
-		handlers.AllowCredentials(),
-	)(r.mux)); err != nil {
-		log.Fatal(err)
+// deadlines are the routes given longer than the request timeout. They may
+// also take longer than the server's read and write timeouts.
+var deadlines = map[string]time.Duration{
+	"POST /api/products/import":            2 * time.Minute,
+	"GET /api/products/export":             5 * time.Minute,
+	"POST /api/product/{productId}/images": 2 * time.Minute,
+	"GET /api/images/{key...}":             time.Minute,
+}
+
+// register adds a route, traced, logged and measured, under the request
+// timeout or its own deadline.
+func (r *Router) register(pattern string, h http.Handler) {
+	if d, ok := deadlines[pattern]; ok {
+		h = middlewares.ExtendIO(d, middlewares.Deadline(d, h))
+	} else {
+		h = middlewares.Deadline(r.cfg.Server.RequestTimeout, h)
 	}
+
+	r.mux.Handle(pattern, middlewares.Trace(pattern, middlewares.Instrument(pattern, middlewares.Log(h))))
+}
+
+// handle registers a route that does its own authentication, if any.
+func (r *Router) handle(pattern string, h http.HandlerFunc) {
+	r.register(pattern, middlewares.Traced(h))
+}
+
+// public registers a route open to anonymous callers. API keys still need scope.
+func (r *Router) public(pattern string, scope string, h http.HandlerFunc) {
+	r.register(pattern, middlewares.OptionalScope(r.auth, scope, middlewares.Traced(h)))
+}
+
+// private registers a route that requires a session or an API key with scope.
+func (r *Router) private(pattern string, scope string, h http.HandlerFunc) {
+	r.register(pattern, middlewares.RequireScope(r.auth, scope, middlewares.Traced(h)))
+}
+
+// admin registers a route only admins may call.
+func (r *Router) admin(pattern string, h http.HandlerFunc) {
+	r.register(pattern, middlewares.RequireRole(r.auth, middlewares.Traced(h), account.RoleAdmin))
+}
+
+// moderator registers a route for moderators and admins.
+func (r *Router) moderator(pattern string, h http.HandlerFunc) {
+	r.register(pattern, middlewares.RequireRole(r.auth, middlewares.Traced(h), account.RoleModerator, account.RoleAdmin))
 }
 
 func (r *Router) setAccountsRoutes() {
-	r.mux.Handle("GET /api/accounts", middlewares.Log(http.HandlerFunc(r.accountHandler.GetAll)))
-	r.mux.Handle("GET /api/account/{accountId}", middlewares.Log(http.HandlerFunc(r.accountHandler.GetById)))
-	r.mux.Handle("GET /api/account/auth", middlewares.Log(http.HandlerFunc(r.jwt.Authenticate)))
-	r.mux.Handle("POST /api/account/signup", middlewares.Log(http.HandlerFunc(r.jwt.Signup)))
-	r.mux.Handle("POST /api/account/login", middlewares.Log(http.HandlerFunc(r.jwt.Login)))
-	r.mux.Handle("POST /api/account/logout", middlewares.Log(http.HandlerFunc(r.jwt.Logout)))
-	r.mux.Handle("PUT /api/account/{accountId}", middlewares.Log(http.HandlerFunc(r.accountHandler.Update)))
-	r.mux.Handle("DELETE /api/account/{accountId}", middlewares.Log(http.HandlerFunc(r.accountHandler.Delete)))
+	r.public("GET /api/accounts", apikey.ScopeAccountsRead, r.accountHandler.GetAll)
+	r.public("GET /api/account/{accountId}", apikey.ScopeAccountsRead, r.accountHandler.GetById)
+	r.handle("GET /api/account/auth", r.jwt.Authenticate)
+	r.handle("POST /api/account/signup", r.jwt.Signup)
+	r.handle("POST /api/account/login", r.jwt.Login)
+	r.handle("POST /api/account/logout", r.jwt.Logout)
+	r.handle("GET /api/account/oidc/{provider}/login", r.jwt.OIDCLogin)
+	r.handle("GET /api/account/oidc/{provider}/callback", r.jwt.OIDCCallback)
+	r.private("PUT /api/account/{accountId}", apikey.ScopeAccountsWrite, r.accountHandler.Update)
+	r.private("DELETE /api/account/{accountId}", apikey.ScopeAccountsWrite, r.accountHandler.Delete)
+	r.admin("PUT /api/account/{accountId}/verified", r.accountHandler.SetVerified)
+	r.private("GET /api/account/notifications", apikey.ScopeAccountsRead, r.notificationHandler.GetAll)
+}
+
+func (r *Router) setApiKeysRoutes() {
+	r.private("GET /api/account/keys", "", r.apiKeyHandler.GetAll)
+	r.private("POST /api/account/keys", "", r.apiKeyHandler.Create)
+	r.private("DELETE /api/account/keys/{keyId}", "", r.apiKeyHandler.Revoke)
 }
 
 func (r *Router) setProductsRoutes() {
-	r.mux.Handle("GET /api/products", middlewares.Log(http.HandlerFunc(r.productHandler.GetAll)))
-	r.mux.Handle("GET /api/product/{productId}", middlewares.Log(http.HandlerFunc(r.productHandler.GetById)))
-	r.mux.Handle("POST /api/product", middlewares.Log(http.HandlerFunc(r.productHandler.Create)))
-	r.mux.Handle("PUT /api/product/{productId}", middlewares.Log(http.HandlerFunc(r.productHandler.Update)))
-	r.mux.Handle("DELETE /api/product/{productId}", middlewares.Log(http.HandlerFunc(r.productHandler.Delete)))
-
-	r.mux.Handle("POST /api/account/{accountId}/product/{productId}", middlewares.Log(http.HandlerFunc(r.productHandler.AssociateProductWithAccount)))
-	r.mux.Handle("GET /api/bid/account/{accountId}", middlewares.Log(http.HandlerFunc(r.productHandler.GetAllBids)))
-	r.mux.Handle("GET /api/account/{accountId}/bids", middlewares.Log(http.HandlerFunc(r.productHandler.GetAllAccountBids)))
-	r.mux.Handle("POST /api/bid/account/{accountId}/product/{productId}", middlewares.Log(http.HandlerFunc(r.productHandler.AddBid)))
-	r.mux.Handle("GET /api/bid/account/{accountId}/product/{productId}", middlewares.Log(http.HandlerFunc(r.productHandler.GetBidById)))
+	r.public("GET /api/products", apikey.ScopeProductsRead, r.productHandler.GetAll)
+	r.public("GET /api/products/search", apikey.ScopeProductsRead, r.productHandler.Search)
+	r.private("POST /api/products/import", apikey.ScopeProductsWrite, r.productHandler.Import)
+	r.private("GET /api/products/export", apikey.ScopeProductsRead, r.productHandler.Export)
+	r.public("GET /api/product/{productId}", apikey.ScopeProductsRead, r.productHandler.GetById)
+	r.private("POST /api/product", apikey.ScopeProductsWrite, r.productHandler.Create)
+	r.private("PUT /api/product/{productId}", apikey.ScopeProductsWrite, r.productHandler.Update)
+	r.private("DELETE /api/product/{productId}", apikey.ScopeProductsWrite, r.productHandler.Delete)
+	r.private("POST /api/product/{productId}/publish", apikey.ScopeProductsWrite, r.productHandler.Publish)
+	r.public("GET /api/product/{productId}/history", apikey.ScopeProductsRead, r.productHandler.History)
+	r.private("POST /api/product/{productId}/addenda", apikey.ScopeProductsWrite, r.productHandler.AddAddendum)
+
+	r.private("POST /api/account/{accountId}/product/{productId}", apikey.ScopeProductsWrite, r.productHandler.AssociateProductWithAccount)
+	r.public("GET /api/bid/account/{accountId}", apikey.ScopeBidsRead, r.productHandler.GetAllBids)
+	r.public("GET /api/account/{accountId}/bids", apikey.ScopeBidsRead, r.productHandler.GetAllAccountBids)
+	r.private("POST /api/bid/account/{accountId}/product/{productId}", apikey.ScopeBidsWrite, r.productHandler.AddBid)
+	r.public("GET /api/bid/account/{accountId}/product/{productId}", apikey.ScopeBidsRead, r.productHandler.GetBidById)
+	r.public("GET /api/bid/{bidId}", apikey.ScopeBidsRead, r.productHandler.GetBid)
+	r.private("POST /api/bid/{bidId}/retract", apikey.ScopeBidsWrite, r.productHandler.RetractBid)
+	r.public("GET /api/product/{productId}/bids", apikey.ScopeBidsRead, r.productHandler.GetProductBids)
+}
+
+func (r *Router) setImagesRoutes() {
+	r.public("GET /api/product/{productId}/images", apikey.ScopeProductsRead, r.imageHandler.GetByProduct)
+	r.private("POST /api/product/{productId}/images", apikey.ScopeProductsWrite, r.imageHandler.Upload)
+	r.private("PUT /api/product/{productId}/images/order", apikey.ScopeProductsWrite, r.imageHandler.Reorder)
+	r.private("DELETE /api/product/{productId}/images/{imageId}", apikey.ScopeProductsWrite, r.imageHandler.Delete)
+	// the signature in the link is the credential
+	r.handle("GET /api/images/{key...}", r.imageHandler.Serve)
+}
+
+func (r *Router) setCategoriesRoutes() {
+	r.public("GET /api/categories", apikey.ScopeProductsRead, r.categoryHandler.GetAll)
+	r.public("GET /api/categories/{categoryId}", apikey.ScopeProductsRead, r.categoryHandler.GetByID)
+	r.public("GET /api/categories/{categoryId}/products", apikey.ScopeProductsRead, r.productHandler.GetByCategory)
+	r.admin("POST /api/categories", r.categoryHandler.Create)
+	r.admin("PUT /api/categories/{categoryId}", r.categoryHandler.Update)
+	r.admin("DELETE /api/categories/{categoryId}", r.categoryHandler.Delete)
+}
+
+func (r *Router) setExchangeRoutes() {
+	r.public("GET /api/exchange-rates", "", r.exchangeHandler.GetAll)
+	r.admin("PUT /api/exchange-rates/{base}/{quote}", r.exchangeHandler.Set)
+	r.admin("DELETE /api/exchange-rates/{base}/{quote}", r.exchangeHandler.Delete)
+}
+
+func (r *Router) setModerationRoutes() {
+	r.private("POST /api/product/{productId}/reports", apikey.ScopeProductsWrite, r.moderationHandler.ReportProduct)
+	r.private("POST /api/bid/{bidId}/reports", apikey.ScopeBidsWrite, r.moderationHandler.ReportBid)
+
+	r.moderator("GET /api/moderation/reports", r.moderationHandler.Dashboard)
+	r.moderator("GET /api/moderation/reports/{targetType}/{targetId}", r.moderationHandler.GetByTarget)
+	r.moderator("PUT /api/moderation/reports/{targetType}/{targetId}", r.moderationHandler.Resolve)
+	r.moderator("GET /api/moderation/products", r.productHandler.Pending)
+	r.moderator("POST /api/moderation/products/{productId}/approve", r.productHandler.Approve)
+	r.moderator("POST /api/moderation/products/{productId}/reject", r.productHandler.Reject)
+	r.moderator("DELETE /api/moderation/bids/{bidId}/message", r.productHandler.RemoveBidMessage)
+}
+
+func (r *Router) setHealthRoutes() {
+	// probed every few seconds, so neither logged nor measured
+	r.mux.HandleFunc("GET /healthz", r.healthHandler.Live)
+	r.mux.HandleFunc("GET /readyz", r.healthHandler.Ready)
+
+	r.admin("GET /status", r.healthHandler.Status)
 }
@@ -0,0 +1,323 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/config"
+	"github.com/Nier704/arthur-leilao-server/internal/blob"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/apikey"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+	"github.com/Nier704/arthur-leilao-server/internal/storage"
+	"github.com/google/uuid"
+)
+
+// server is the router over a memory store, with its real authentication.
+type server struct {
+	router *Router
+	store  *storage.Store
+}
+
+func newServer(t *testing.T) *server {
+	t.Helper()
+
+	files, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Auth.JWTSecret = strings.Repeat("j", 32)
+
+	s := &server{router: NewRouter(cfg), store: storage.NewMemoryStore()}
+	s.router.Init(s.store, &blob.URLs{Store: files, Secret: bytes.Repeat([]byte("s"), 32), Prefix: "/api/images/", TTL: time.Hour})
+
+	return s
+}
+
+// do sends a request through the routes, authenticated by cookie when it is
+// set and by key when it is not empty.
+func (s *server) do(t *testing.T, cookie *http.Cookie, key string, method string, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	if key != "" {
+		req.Header.Set(apikey.Header, key)
+	}
+
+	rec := httptest.NewRecorder()
+	s.router.mux.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// signup creates an account through the API and logs it in.
+func (s *server) signup(t *testing.T, username string) (uuid.UUID, *http.Cookie) {
+	t.Helper()
+
+	credentials := map[string]string{"username": username, "password": "secret123", "email": username + "@example.com"}
+
+	rec := s.do(t, nil, "", http.MethodPost, "/api/account/signup", credentials)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("signup = %d %s", rec.Code, rec.Body)
+	}
+
+	var created struct {
+		ID uuid.UUID `json:"inserted_id"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = s.do(t, nil, "", http.MethodPost, "/api/account/login", credentials)
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "jwt" {
+			return created.ID, c
+		}
+	}
+
+	t.Fatalf("login = %d %s, without a session cookie", rec.Code, rec.Body)
+
+	return uuid.Nil, nil
+}
+
+// key creates an API key of the session's account with scopes.
+func (s *server) key(t *testing.T, session *http.Cookie, scopes ...string) string {
+	t.Helper()
+
+	rec := s.do(t, session, "", http.MethodPost, "/api/account/keys", map[string]any{"name": "test", "scopes": scopes})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("creating a key with %v = %d %s", scopes, rec.Code, rec.Body)
+	}
+
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	return created.Key
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	s := newServer(t)
+	ctx := context.Background()
+
+	owner, session := s.signup(t, "owner")
+	seller, _ := s.signup(t, "seller")
+
+	mine := product.Product{AccountID: owner, Title: "Guitar", Description: "Vintage", Price: money.New(10000, money.DefaultCurrency)}
+	theirs := product.Product{AccountID: seller, Title: "Drum", Description: "Loud", Price: money.New(10000, money.DefaultCurrency)}
+	for _, p := range []*product.Product{&mine, &theirs} {
+		if err := s.store.Products.Create(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	endsAt := time.Now().UTC().Add(24 * time.Hour)
+	theirs.EndsAt = &endsAt
+	if err := s.store.Products.Update(ctx, &theirs, seller); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Products.Publish(ctx, theirs.ID, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := []struct {
+		method string
+		path   string
+		body   any
+		scope  string
+	}{
+		{http.MethodGet, "/api/accounts", nil, apikey.ScopeAccountsRead},
+		{http.MethodGet, "/api/account/" + owner.String(), nil, apikey.ScopeAccountsRead},
+		{http.MethodGet, "/api/account/notifications", nil, apikey.ScopeAccountsRead},
+		{http.MethodPut, "/api/account/" + owner.String(), map[string]any{"username": "owner"}, apikey.ScopeAccountsWrite},
+
+		{http.MethodGet, "/api/products", nil, apikey.ScopeProductsRead},
+		{http.MethodGet, "/api/product/" + theirs.ID.String(), nil, apikey.ScopeProductsRead},
+		{http.MethodGet, "/api/products/export", nil, apikey.ScopeProductsRead},
+		{http.MethodPost, "/api/product", map[string]any{}, apikey.ScopeProductsWrite},
+		{http.MethodPut, "/api/product/" + mine.ID.String(), map[string]any{"title": "Bass"}, apikey.ScopeProductsWrite},
+		{http.MethodPost, "/api/product/" + theirs.ID.String() + "/reports", map[string]any{}, apikey.ScopeProductsWrite},
+
+		{http.MethodGet, "/api/product/" + theirs.ID.String() + "/bids", nil, apikey.ScopeBidsRead},
+		{http.MethodGet, "/api/account/" + owner.String() + "/bids", nil, apikey.ScopeBidsRead},
+		{http.MethodPost, "/api/bid/account/" + owner.String() + "/product/" + theirs.ID.String(), map[string]any{"bid_value": "1.00"}, apikey.ScopeBidsWrite},
+		{http.MethodPost, "/api/bid/" + uuid.NewString() + "/reports", map[string]any{}, apikey.ScopeBidsWrite},
+	}
+
+	for _, scope := range apikey.Scopes {
+		key := s.key(t, session, scope)
+
+		for _, route := range routes {
+			rec := s.do(t, nil, key, route.method, route.path, route.body)
+
+			switch {
+			case scope != route.scope && rec.Code != http.StatusForbidden:
+				t.Errorf("%s key: %s %s = %d %s, want 403", scope, route.method, route.path, rec.Code, rec.Body)
+			case scope == route.scope && (rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden):
+				t.Errorf("%s key: %s %s = %d %s, want it let through", scope, route.method, route.path, rec.Code, rec.Body)
+			}
+		}
+	}
+
+	// the session is allowed everything, and anonymous callers only what
+	// is public
+	for _, route := range routes {
+		if rec := s.do(t, session, "", route.method, route.path, route.body); rec.Code == http.StatusUnauthorized || rec.Code == http.StatusForbidden {
+			t.Errorf("session: %s %s = %d %s, want it let through", route.method, route.path, rec.Code, rec.Body)
+		}
+	}
+
+	for _, path := range []string{"/api/products", "/api/account/" + owner.String()} {
+		if rec := s.do(t, nil, "", http.MethodGet, path, nil); rec.Code != http.StatusOK {
+			t.Errorf("anonymous: GET %s = %d %s, want 200", path, rec.Code, rec.Body)
+		}
+	}
+	if rec := s.do(t, nil, "", http.MethodPost, "/api/product", map[string]any{}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous: POST /api/product = %d, want 401", rec.Code)
+	}
+}
+
+func TestAPIKeyOnSessionRoutes(t *testing.T) {
+	s := newServer(t)
+
+	_, session := s.signup(t, "owner")
+	key := s.key(t, session, apikey.Scopes...)
+
+	for _, route := range []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/api/account/keys"},
+		{http.MethodPost, "/api/account/keys"},
+		{http.MethodGet, "/status"},
+		{http.MethodPost, "/api/categories"},
+		{http.MethodGet, "/api/moderation/reports"},
+	} {
+		if rec := s.do(t, nil, key, route.method, route.path, map[string]any{}); rec.Code != http.StatusForbidden {
+			t.Errorf("key with every scope: %s %s = %d %s, want 403", route.method, route.path, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := s.do(t, nil, "alk_invalid", http.MethodGet, "/api/products", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("invalid key: GET /api/products = %d, want 401", rec.Code)
+	}
+}
+
+func TestReadyWorkers(t *testing.T) {
+	s := newServer(t)
+
+	ready := func() map[string]string {
+		t.Helper()
+
+		rec := s.do(t, nil, "", http.MethodGet, "/readyz", nil)
+
+		var res struct {
+			Checks map[string]string `json:"checks"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		ok := true
+		for _, status := range res.Checks {
+			ok = ok && status == "ok"
+		}
+		if ok != (rec.Code == http.StatusOK) {
+			t.Errorf("readyz = %d with checks %v", rec.Code, res.Checks)
+		}
+
+		return res.Checks
+	}
+
+	if got := ready(); got["image_processor"] != "failing" || got["auction_closer"] != "failing" {
+		t.Errorf("before the workers start: checks = %v, want both failing", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := s.router.processor.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := ready(); got["image_processor"] != "ok" || got["auction_closer"] != "failing" {
+		t.Errorf("without the closer: checks = %v, want only it failing", got)
+	}
+
+	s.router.closer.Start(ctx)
+	if got := ready(); got["image_processor"] != "ok" || got["auction_closer"] != "ok" {
+		t.Errorf("with both workers: checks = %v, want both ok", got)
+	}
+
+	cancel()
+	if err := s.router.closer.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := ready(); got["auction_closer"] != "failing" {
+		t.Errorf("once the closer stopped: checks = %v, want it failing", got)
+	}
+}
+
+func TestRequestLogAccount(t *testing.T) {
+	s := newServer(t)
+
+	owner, session := s.signup(t, "owner")
+	key := s.key(t, session, apikey.ScopeProductsRead)
+
+	var logs bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(old) })
+
+	// request records the account of the request, which API routes only
+	// know once they have authenticated it
+	for _, tt := range []struct {
+		name    string
+		cookie  *http.Cookie
+		key     string
+		method  string
+		path    string
+		account string
+	}{
+		{"session", session, "", http.MethodGet, "/api/account/notifications", owner.String()},
+		{"key", nil, key, http.MethodGet, "/api/products", owner.String()},
+		{"key missing a scope", nil, key, http.MethodPost, "/api/product", owner.String()},
+		{"anonymous", nil, "", http.MethodGet, "/api/products", ""},
+	} {
+		logs.Reset()
+		s.do(t, tt.cookie, tt.key, tt.method, tt.path, map[string]any{})
+
+		lines := bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n"))
+
+		var record struct {
+			Msg       string `json:"msg"`
+			AccountID string `json:"account_id"`
+		}
+		if err := json.Unmarshal(lines[len(lines)-1], &record); err != nil {
+			t.Fatal(err)
+		}
+
+		if record.Msg != "request" || record.AccountID != tt.account {
+			t.Errorf("%s: last record = %+v, want request with account %q", tt.name, record, tt.account)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Package imaging renders uploaded photos into the resized copies shown to
+// buyers.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+
+	// decoders for the types uploads may have
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/chai2010/webp"
+	xdraw "golang.org/x/image/draw"
+)
+
+// ErrDecode is returned for files that are not images, or too big to be
+// decoded safely.
+var ErrDecode = errors.New("cannot decode image")
+
+// maxPixels bounds the images decoded, since a small file can declare a
+// huge canvas.
+const maxPixels = 50_000_000
+
+const quality = 85
+
+// Size is a copy to render.
+type Size struct {
+	Name   string
+	Width  int
+	Height int
+	// Crop fills the box, cutting what overflows. Otherwise the image is
+	// scaled to fit inside it.
+	Crop bool
+}
+
+type Derivative struct {
+	Size        string
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Derive renders the image in data at each size, as a JPEG followed by a
+// WebP. Images are never scaled up, and transparent areas become white.
+//
+// Only the pixels are kept, so the copies carry none of the metadata of the
+// upload, like the GPS position phones record. The EXIF orientation is
+// applied first, since dropping it would leave photos sideways.
+func Derive(data []byte, sizes []Size) ([]Derivative, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d is too large", ErrDecode, cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecode, err)
+	}
+
+	src = orient(src, orientation(data))
+
+	derivatives := make([]Derivative, 0, 2*len(sizes))
+
+	for _, size := range sizes {
+		dst := resize(src, size)
+
+		var jpg, wp bytes.Buffer
+		if err = jpeg.Encode(&jpg, dst, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+		// lossy, since the copies are photos
+		if err = webp.Encode(&wp, dst, &webp.Options{Quality: quality}); err != nil {
+			return nil, err
+		}
+
+		for _, d := range []Derivative{
+			{Data: jpg.Bytes(), ContentType: "image/jpeg"},
+			{Data: wp.Bytes(), ContentType: "image/webp"},
+		} {
+			d.Size = size.Name
+			d.Width, d.Height = dst.Bounds().Dx(), dst.Bounds().Dy()
+			derivatives = append(derivatives, d)
+		}
+	}
+
+	return derivatives, nil
+}
+
+// resize scales src into the box of size, on a white background.
+func resize(src image.Image, size Size) *image.RGBA {
+	rect := src.Bounds()
+
+	if size.Crop {
+		rect = centerCrop(rect, size.Width, size.Height)
+	}
+
+	w, h := rect.Dx(), rect.Dy()
+
+	scale := min(float64(size.Width)/float64(w), float64(size.Height)/float64(h), 1)
+	w = max(1, int(float64(w)*scale+0.5))
+	h = max(1, int(float64(h)*scale+0.5))
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, rect, xdraw.Over, nil)
+
+	return dst
+}
+
+// centerCrop returns the largest part of r in the middle with the aspect
+// ratio of w by h.
+func centerCrop(r image.Rectangle, w int, h int) image.Rectangle {
+	cw, ch := r.Dx(), r.Dy()
+
+	if cw*h > ch*w {
+		cw = ch * w / h
+	} else {
+		ch = cw * h / w
+	}
+
+	x := r.Min.X + (r.Dx()-cw)/2
+	y := r.Min.Y + (r.Dy()-ch)/2
+
+	return image.Rect(x, y, x+cw, y+ch)
+}
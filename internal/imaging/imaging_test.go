@@ -0,0 +1,188 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// gpsDatum is recorded in the GPS data of the fixtures, to look for in the
+// copies.
+const gpsDatum = "WGS-84 at the seller's home"
+
+// photo returns a 40x20 JPEG, red on the left half and blue on the right,
+// with EXIF holding orientation and a GPS position like phones record.
+func photo(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 20 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	var pixels bytes.Buffer
+	if err := jpeg.Encode(&pixels, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	app1 := append([]byte("Exif\x00\x00"), exifTIFF(orientation)...)
+
+	// SOI, then APP1, then the rest of the encoded file
+	var out bytes.Buffer
+	out.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(2+len(app1)))
+	out.Write(app1)
+	out.Write(pixels.Bytes()[2:])
+
+	return out.Bytes()
+}
+
+// exifTIFF is the TIFF structure of EXIF: a first IFD with the orientation
+// and a pointer to a GPS IFD with a latitude reference and a map datum.
+func exifTIFF(orientation uint16) []byte {
+	le := binary.LittleEndian
+
+	const (
+		ifd0  = 8
+		gps   = ifd0 + 2 + 2*12 + 4
+		datum = gps + 2 + 2*12 + 4
+	)
+
+	b := make([]byte, datum, datum+len(gpsDatum)+1)
+	copy(b, "II")
+	le.PutUint16(b[2:], 42)
+	le.PutUint32(b[4:], ifd0)
+
+	entry := func(at int, tag uint16, typ uint16, count uint32, value uint32) {
+		le.PutUint16(b[at:], tag)
+		le.PutUint16(b[at+2:], typ)
+		le.PutUint32(b[at+4:], count)
+		le.PutUint32(b[at+8:], value)
+	}
+
+	le.PutUint16(b[ifd0:], 2)
+	entry(ifd0+2, 0x0112, 3, 1, uint32(orientation))
+	entry(ifd0+14, 0x8825, 4, 1, gps)
+
+	le.PutUint16(b[gps:], 2)
+	entry(gps+2, 0x0001, 2, 2, uint32('S'))
+	entry(gps+14, 0x0012, 2, uint32(len(gpsDatum)+1), datum)
+
+	return append(append(b, gpsDatum...), 0)
+}
+
+func TestOrientation(t *testing.T) {
+	for o := uint16(1); o <= 8; o++ {
+		if got := orientation(photo(t, o)); got != int(o) {
+			t.Errorf("orientation = %d, want %d", got, o)
+		}
+	}
+
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"out of range", photo(t, 9)},
+		{"no EXIF", append([]byte{0xFF, 0xD8}, photo(t, 6)[12+len(exifTIFF(6)):]...)},
+		{"not a JPEG", []byte("GIF89a")},
+		{"truncated", photo(t, 6)[:30]},
+	} {
+		if got := orientation(tt.data); got != 1 {
+			t.Errorf("%s: orientation = %d, want 1", tt.name, got)
+		}
+	}
+}
+
+func TestDeriveUpright(t *testing.T) {
+	tests := []struct {
+		orientation uint16
+		w, h        int
+		red, blue   image.Point
+	}{
+		{1, 40, 20, image.Pt(5, 10), image.Pt(35, 10)},
+		{3, 40, 20, image.Pt(35, 10), image.Pt(5, 10)},
+		{6, 20, 40, image.Pt(10, 5), image.Pt(10, 35)},
+		{8, 20, 40, image.Pt(10, 35), image.Pt(10, 5)},
+	}
+
+	for _, tt := range tests {
+		derivatives, err := Derive(photo(t, tt.orientation), []Size{{Name: "full", Width: 100, Height: 100}})
+		if err != nil {
+			t.Fatalf("orientation %d: %v", tt.orientation, err)
+		}
+
+		if len(derivatives) != 2 || derivatives[0].ContentType != "image/jpeg" || derivatives[1].ContentType != "image/webp" {
+			t.Fatalf("orientation %d: derivatives %+v, want a JPEG and a WebP", tt.orientation, derivatives)
+		}
+
+		for _, d := range derivatives {
+			img, format, err := image.Decode(bytes.NewReader(d.Data))
+			if err != nil {
+				t.Fatalf("orientation %d: %s: %v", tt.orientation, d.ContentType, err)
+			}
+
+			if "image/"+format != d.ContentType {
+				t.Errorf("orientation %d: %s holds %s", tt.orientation, d.ContentType, format)
+			}
+
+			if b := img.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h || d.Width != tt.w || d.Height != tt.h {
+				t.Errorf("orientation %d: %s is %dx%d (said %dx%d), want %dx%d", tt.orientation, d.ContentType, b.Dx(), b.Dy(), d.Width, d.Height, tt.w, tt.h)
+				continue
+			}
+
+			if r, _, b, _ := img.At(tt.red.X, tt.red.Y).RGBA(); r>>8 < 200 || b>>8 > 60 {
+				t.Errorf("orientation %d: %s is not red at %v", tt.orientation, d.ContentType, tt.red)
+			}
+			if r, _, b, _ := img.At(tt.blue.X, tt.blue.Y).RGBA(); b>>8 < 200 || r>>8 > 60 {
+				t.Errorf("orientation %d: %s is not blue at %v", tt.orientation, d.ContentType, tt.blue)
+			}
+		}
+	}
+}
+
+func TestDeriveStripsMetadata(t *testing.T) {
+	data := photo(t, 6)
+	if !bytes.Contains(data, []byte(gpsDatum)) {
+		t.Fatal("the fixture carries no GPS data")
+	}
+
+	derivatives, err := Derive(data, []Size{
+		{Name: "thumbnail", Width: 10, Height: 10, Crop: true},
+		{Name: "full", Width: 100, Height: 100},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(derivatives) != 4 {
+		t.Fatalf("%d derivatives, want a JPEG and a WebP of each size", len(derivatives))
+	}
+
+	for _, d := range derivatives {
+		for _, leak := range []string{"Exif", "EXIF", "XMP", gpsDatum} {
+			if bytes.Contains(d.Data, []byte(leak)) {
+				t.Errorf("%s %s contains %q", d.Size, d.ContentType, leak)
+			}
+		}
+	}
+
+	if d := derivatives[0]; d.Size != "thumbnail" || d.Width != 10 || d.Height != 10 {
+		t.Errorf("thumbnail is %s %dx%d, want 10x10", d.Size, d.Width, d.Height)
+	}
+}
+
+func TestDeriveNotAnImage(t *testing.T) {
+	if _, err := Derive([]byte("not an image"), []Size{{Name: "full", Width: 100, Height: 100}}); !errors.Is(err, ErrDecode) {
+		t.Errorf("err = %v, want ErrDecode", err)
+	}
+}
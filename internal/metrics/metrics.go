@@ -0,0 +1,61 @@
+// Package metrics keeps the measurements of the server, with the Prometheus
+// Go client, and serves them.
+package metrics
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds the metrics of the server, with those of the Go runtime
+// and the process. It is its own rather than the client's global one, so
+// only what the server registers is served.
+var Registry = prometheus.NewRegistry()
+
+// factory registers the metrics it makes with Registry.
+var factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves Registry. When token is set, scrapes must send it as a
+// bearer token.
+func Handler(token string) http.Handler {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+	})
+
+	if token == "" {
+		return h
+	}
+
+	want := []byte("Bearer " + token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// compared in constant time so the token cannot be guessed from
+		// how fast wrong ones are refused
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// RegisterDB exposes the connection pool statistics of db, labelled with
+// name.
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
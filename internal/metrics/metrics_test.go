@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func scrape(t *testing.T, token string, auth string) (int, string) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	rec := httptest.NewRecorder()
+	Handler(token).ServeHTTP(rec, req)
+
+	body, _ := io.ReadAll(rec.Body)
+
+	return rec.Code, string(body)
+}
+
+func TestExposition(t *testing.T) {
+	BidsRejected.WithLabelValues(RejectTooLow).Inc()
+
+	code, body := scrape(t, "", "")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", code)
+	}
+
+	for _, want := range []string{
+		"# TYPE auction_bids_rejected_total counter",
+		`auction_bids_rejected_total{reason="too_low"} 1`,
+		// the other reasons are there before any bid is rejected for them
+		`auction_bids_rejected_total{reason="currency"} 0`,
+		`account_logins_total{method="oidc",result="failure"} 0`,
+		"go_goroutines ",
+		"process_start_time_seconds ",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition lacks %q", want)
+		}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	success := testutil.ToFloat64(Logins.WithLabelValues(MethodPassword, "success"))
+	failure := testutil.ToFloat64(Logins.WithLabelValues(MethodPassword, "failure"))
+
+	Login(MethodPassword, true)
+	Login(MethodPassword, false)
+	Login(MethodPassword, false)
+
+	if got := testutil.ToFloat64(Logins.WithLabelValues(MethodPassword, "success")) - success; got != 1 {
+		t.Errorf("successes counted = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(Logins.WithLabelValues(MethodPassword, "failure")) - failure; got != 2 {
+		t.Errorf("failures counted = %v, want 2", got)
+	}
+}
+
+func TestRegisterDB(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	RegisterDB(db, "test")
+
+	_, body := scrape(t, "", "")
+	if !strings.Contains(body, `go_sql_max_open_connections{db_name="test"}`) {
+		t.Error("exposition lacks the pool statistics")
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	tests := []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer secre", http.StatusUnauthorized},
+		{"Bearer secrets", http.StatusUnauthorized},
+		{"secret", http.StatusUnauthorized},
+		{"bearer secret", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		if code, _ := scrape(t, "secret", tt.auth); code != tt.want {
+			t.Errorf("Authorization %q: status = %d, want %d", tt.auth, code, tt.want)
+		}
+	}
+
+	if code, _ := scrape(t, "", ""); code != http.StatusOK {
+		t.Errorf("no token configured: status = %d, want 200", code)
+	}
+}
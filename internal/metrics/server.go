@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTP.
+var (
+	// HTTPRequests is labelled by method, the route pattern the request
+	// matched and the class of its status, such as 2xx.
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by route and status class.",
+	}, []string{"method", "route", "code"})
+	// HTTPDuration is labelled by method and route pattern.
+	HTTPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time spent serving HTTP requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// Reasons a bid is rejected for, the values of the reason label of
+// BidsRejected.
+const (
+	RejectInvalid   = "invalid"
+	RejectNotFound  = "not_found"
+	RejectDraft     = "draft"
+	RejectEnded     = "ended"
+	RejectTooLow    = "too_low"
+	RejectCurrency  = "currency"
+	RejectForbidden = "forbidden"
+)
+
+// Auctions.
+var (
+	BidsPlaced = factory.NewCounter(prometheus.CounterOpts{
+		Name: "auction_bids_placed_total",
+		Help: "Bids accepted.",
+	})
+	BidsRejected = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "auction_bids_rejected_total",
+		Help: "Bids refused, by reason.",
+	}, []string{"reason"})
+	// AuctionsOpened counts products published, directly or once approved.
+	AuctionsOpened = factory.NewCounter(prometheus.CounterOpts{
+		Name: "auction_auctions_opened_total",
+		Help: "Auctions opened.",
+	})
+	// AuctionsClosed counts auctions closed once their end passed.
+	AuctionsClosed = factory.NewCounter(prometheus.CounterOpts{
+		Name: "auction_auctions_closed_total",
+		Help: "Auctions closed.",
+	})
+)
+
+// Login and signup methods, the values of the method label.
+const (
+	MethodPassword = "password"
+	MethodOIDC     = "oidc"
+)
+
+// Accounts.
+var (
+	Signups = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "account_signups_total",
+		Help: "Accounts created, by method.",
+	}, []string{"method"})
+	// Logins is labelled by method and result, success or failure.
+	Logins = factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "account_logins_total",
+		Help: "Login attempts, by method and result.",
+	}, []string{"method", "result"})
+)
+
+func init() {
+	for _, reason := range []string{RejectInvalid, RejectNotFound, RejectDraft, RejectEnded, RejectTooLow, RejectCurrency, RejectForbidden} {
+		BidsRejected.WithLabelValues(reason)
+	}
+
+	for _, method := range []string{MethodPassword, MethodOIDC} {
+		Signups.WithLabelValues(method)
+		Logins.WithLabelValues(method, "success")
+		Logins.WithLabelValues(method, "failure")
+	}
+}
+
+// Login counts a login attempt by method.
+func Login(method string, ok bool) {
+	result := "success"
+	if !ok {
+		result = "failure"
+	}
+
+	Logins.WithLabelValues(method, result).Inc()
+}
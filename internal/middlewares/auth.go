@@ -0,0 +1,124 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Nier704/arthur-leilao-server/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries
+// neither a session cookie nor an API key.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	AccountID uuid.UUID
+	// Role is the role of the account, whichever credential was used.
+	Role string
+	// Verified is whether the account is a verified seller.
+	Verified bool
+	// APIKeyID is set when the request was authenticated with an API key.
+	APIKeyID uuid.UUID
+	// Scopes restricts what an API key may do. Cookie sessions carry no
+	// scopes and are allowed everything.
+	Scopes []string
+}
+
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyID != uuid.Nil
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	if !p.IsAPIKey() || scope == "" {
+		return true
+	}
+
+	return slices.Contains(p.Scopes, scope)
+}
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type principalKey struct{}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// RequireScope rejects anonymous requests and API keys missing scope.
+func RequireScope(a Authenticator, scope string, next http.Handler) http.Handler {
+	return auth(a, scope, false, next)
+}
+
+// OptionalScope lets anonymous requests through, but still rejects invalid
+// credentials and API keys missing scope.
+func OptionalScope(a Authenticator, scope string, next http.Handler) http.Handler {
+	return auth(a, scope, true, next)
+}
+
+// RequireRole rejects requests whose account has none of roles. API keys
+// are rejected too: their scopes do not cover administration, so a key
+// must not inherit the role of its account.
+func RequireRole(a Authenticator, next http.Handler, roles ...string) http.Handler {
+	return RequireScope(a, "", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := PrincipalFromContext(r.Context())
+		if p.IsAPIKey() {
+			Logger(r.Context()).Info("api key used on a role route", "api_key_id", p.APIKeyID, "roles", roles)
+			http.Error(w, "requires a login session", http.StatusForbidden)
+			return
+		}
+
+		if !slices.Contains(roles, p.Role) {
+			Logger(r.Context()).Info("account lacks the role", "roles", roles)
+			http.Error(w, "requires role "+strings.Join(roles, " or "), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+func auth(a Authenticator, scope string, optional bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Tracer().Start(r.Context(), "authenticate")
+		p, err := a.Authenticate(r.WithContext(ctx))
+		if !errors.Is(err, ErrNoCredentials) {
+			tracing.RecordError(span, err)
+		}
+		span.End()
+
+		if errors.Is(err, ErrNoCredentials) && optional {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err != nil {
+			Logger(r.Context()).Info("error authenticating request", "err", err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		setAccount(r.Context(), p.AccountID)
+
+		if !p.HasScope(scope) {
+			Logger(r.Context()).Info("api key is missing a scope", "account_id", p.AccountID, "api_key_id", p.APIKeyID, "scope", scope)
+			http.Error(w, "missing scope "+scope, http.StatusForbidden)
+			return
+		}
+
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("enduser.id", p.AccountID.String()))
+
+		ctx = context.WithValue(r.Context(), principalKey{}, p)
+		ctx = WithLogger(ctx, Logger(ctx).With("account_id", p.AccountID))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Deadline cancels the context of requests still running after d, which
+// stops their queries.
+func Deadline(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ExtendIO gives requests d from now to read their body and write their
+// response, for routes that outlast the read and write timeouts of the
+// server, such as uploads and streamed exports.
+func ExtendIO(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		deadline := time.Now().Add(d)
+
+		// not every writer supports deadlines; the server's then apply
+		rc.SetReadDeadline(deadline)
+		rc.SetWriteDeadline(deadline)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,17 +1,175 @@
 package middlewares
 
 import (
-	"fmt"
+	"context"
+	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// RequestIDHeader carries the id of a request. Callers may set it to
+// correlate their own logs; the response always echoes it.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the incoming ids kept, so clients cannot fill
+// the logs through it.
+const maxRequestIDLength = 128
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+type accountKey struct{}
+
+// Logger returns the logger of the request ctx belongs to, which tags every
+// record with the request id and, once authenticated, the account. Outside
+// requests it returns the default logger.
+func Logger(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+
+	return slog.Default()
+}
+
+// WithLogger returns a copy of ctx carrying l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// RequestIDFromContext returns the id RequestID gave the request.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID gives every request an id, the caller's X-Request-ID when it
+// sent a usable one, and a logger tagged with it.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = WithLogger(ctx, Logger(ctx).With("request_id", id))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// setAccount tells Log which account authenticated the request of ctx.
+// Authentication runs inside Log, whose context does not see the logger
+// it tags, so Log leaves a holder in the context for it.
+func setAccount(ctx context.Context, id uuid.UUID) {
+	if holder, ok := ctx.Value(accountKey{}).(*uuid.UUID); ok {
+		*holder = id
+	}
+}
+
+// Log writes one record per request once it is served, with its status,
+// size, duration and, once authenticated, account. Server errors are
+// logged as errors.
 func Log(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		time_now := time.Now().Format(time.DateTime)
-		str := fmt.Sprintf("%s %s %s %s", r.Method, r.URL, r.Host, time_now)
-		fmt.Println(str)
+		start := time.Now()
+		rw := wrap(w)
+
+		var account uuid.UUID
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), accountKey{}, &account)))
 
-		next.ServeHTTP(w, r)
+		level := slog.LevelInfo
+		if rw.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rw.Status()),
+			slog.Int64("bytes", rw.Bytes()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote", r.RemoteAddr),
+			slog.String("user_agent", r.UserAgent()),
+		}
+		if account != uuid.Nil {
+			attrs = append(attrs, slog.String("account_id", account.String()))
+		}
+
+		Logger(r.Context()).LogAttrs(r.Context(), level, "request", attrs...)
 	})
 }
+
+// ResponseWriter records the status and size of a response. It unwraps to
+// the writer it wraps, so http.ResponseController still reaches Flush.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// wrap returns w as a *ResponseWriter, wrapping it unless an outer
+// middleware already did.
+func wrap(w http.ResponseWriter) *ResponseWriter {
+	if rw, ok := w.(*ResponseWriter); ok {
+		return rw
+	}
+
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status is the status sent, 200 when the handler wrote nothing.
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// Bytes is how much of the body was written.
+func (w *ResponseWriter) Bytes() int64 {
+	return w.bytes
+}
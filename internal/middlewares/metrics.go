@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/metrics"
+)
+
+// Instrument counts the requests to the route registered as pattern and
+// times them. The route label is the path of pattern, so requests to
+// /api/product/{productId} count together.
+func Instrument(pattern string, next http.Handler) http.Handler {
+	route := pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		route = path
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := wrap(w)
+
+		next.ServeHTTP(rw, r)
+
+		code := strconv.Itoa(rw.Status()/100) + "xx"
+
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, code).Inc()
+		metrics.HTTPDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/Nier704/arthur-leilao-server/internal/tracing"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// pathIDs are the path values traced as span attributes, by the attribute
+// they are traced as.
+var pathIDs = map[string]string{
+	"accountId":  "account.id",
+	"productId":  "product.id",
+	"bidId":      "bid.id",
+	"imageId":    "image.id",
+	"categoryId": "category.id",
+}
+
+// Trace starts the server span of the route registered as pattern, with
+// otelhttp, as part of the caller's trace when it sent one.
+func Trace(pattern string, next http.Handler) http.Handler {
+	route := pattern
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		route = path
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		span := trace.SpanFromContext(ctx)
+
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.String("request.id", RequestIDFromContext(ctx)),
+		)
+
+		for name, attr := range pathIDs {
+			if v := r.PathValue(name); v != "" {
+				span.SetAttributes(attribute.String(attr, v))
+			}
+		}
+
+		if sc := span.SpanContext(); sc.IsSampled() {
+			ctx = WithLogger(ctx, Logger(ctx).With("trace_id", sc.TraceID().String()))
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+
+	return otelhttp.NewHandler(h, route,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + route
+		}),
+	)
+}
+
+// Traced runs h in a span named after it, such as
+// product.ProductHandler.AddBid.
+func Traced(h http.HandlerFunc) http.Handler {
+	name := handlerName(h)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Tracer().Start(r.Context(), name)
+		defer span.End()
+
+		h(w, r.WithContext(ctx))
+	})
+}
+
+// handlerName turns the name of a method value, like
+// github.com/x/product.(*ProductHandler).AddBid-fm, into
+// product.ProductHandler.AddBid.
+func handlerName(h http.HandlerFunc) string {
+	name := runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()
+
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+
+	return strings.NewReplacer("(*", "", ")", "").Replace(name)
+}
@@ -0,0 +1,210 @@
+// Package money represents amounts exactly, as integer minor units of a
+// currency, instead of float64.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const DefaultCurrency = "BRL"
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrInvalidCurrency  = errors.New("invalid currency")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// exponents lists the ISO 4217 currencies whose minor unit is not a
+// hundredth, with how many decimals they have; every other currency is
+// assumed to have two. Stored amounts depend on it: changing a currency
+// here needs a migration rescaling them.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+
+	"CLF": 4, "UYW": 4,
+}
+
+type Money struct {
+	// Amount is in minor units, e.g. centavos for BRL.
+	Amount   int64
+	Currency string
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Decimals returns how many minor unit digits currency has.
+func Decimals(currency string) int {
+	if decimals, ok := exponents[currency]; ok {
+		return decimals
+	}
+
+	return 2
+}
+
+func ValidCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+
+	for _, c := range currency {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Parse reads a decimal string such as "1250.5" into currency's minor units.
+// Decimals past the ones the currency has must be zeros: amounts are
+// rejected rather than rounded.
+func Parse(s string, currency string) (Money, error) {
+	if !ValidCurrency(currency) {
+		return Money{}, ErrInvalidCurrency
+	}
+
+	s = strings.TrimSpace(s)
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	decimals := Decimals(currency)
+
+	if len(frac) > decimals && strings.Trim(frac[decimals:], "0") == "" {
+		frac = frac[:decimals]
+	}
+
+	if whole == "" || len(frac) > decimals || strings.ContainsAny(whole+frac, "+-eE ") {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	frac += strings.Repeat("0", decimals-len(frac))
+
+	amount, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+
+	if negative {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// String formats the amount as a decimal string without the currency.
+func (m Money) String() string {
+	decimals := Decimals(m.Currency)
+
+	// unsigned, so the smallest int64 has a magnitude too
+	amount := uint64(m.Amount)
+	sign := ""
+	if m.Amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	if decimals == 0 {
+		return sign + strconv.FormatUint(amount, 10)
+	}
+
+	digits := fmt.Sprintf("%0*d", decimals+1, amount)
+
+	return sign + digits[:len(digits)-decimals] + "." + digits[len(digits)-decimals:]
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// Cmp compares two amounts of the same currency, returning -1, 0 or +1.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.Currency != o.Currency {
+		return 0, ErrCurrencyMismatch
+	}
+
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+type jsonMoney struct {
+	Amount   json.Number `json:"amount"`
+	Currency string      `json:"currency"`
+}
+
+// MarshalJSON encodes {"amount": "12.50", "currency": "BRL"}. The amount is
+// a string so clients never see it as a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.String(), m.Currency})
+}
+
+// UnmarshalJSON accepts the object form, or a bare decimal string or number
+// in the default currency for older clients that still send "price": 12.5.
+// null leaves m as it is, as it does for the standard types.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if isNull(data) {
+		return nil
+	}
+
+	parsed, err := ParseJSON(data, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+
+	return nil
+}
+
+// ParseJSON decodes an amount like UnmarshalJSON, using currency when the
+// value does not name one. null is no amount: the zero Money, with no
+// currency.
+func ParseJSON(data []byte, currency string) (Money, error) {
+	data = bytes.TrimSpace(data)
+
+	if isNull(data) {
+		return Money{}, nil
+	}
+
+	var v jsonMoney
+
+	if len(data) > 0 && data[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return Money{}, err
+		}
+	} else {
+		v.Amount = json.Number(strings.Trim(string(data), `"`))
+	}
+
+	if v.Currency == "" {
+		v.Currency = currency
+	}
+
+	return Parse(v.Amount.String(), strings.ToUpper(v.Currency))
+}
+
+func isNull(data []byte) bool {
+	return string(bytes.TrimSpace(data)) == "null"
+}
@@ -0,0 +1,199 @@
+package money_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+)
+
+func TestDecimals(t *testing.T) {
+	for currency, want := range map[string]int{
+		"BRL": 2, "USD": 2, "EUR": 2,
+		"JPY": 0, "KRW": 0, "ISK": 0, "VND": 0,
+		"BHD": 3, "KWD": 3, "JOD": 3, "OMR": 3, "TND": 3,
+		"CLF": 4,
+	} {
+		if got := money.Decimals(currency); got != want {
+			t.Errorf("Decimals(%s) = %d, want %d", currency, got, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in       string
+		currency string
+		want     int64
+		err      error
+	}{
+		{"1250.5", "BRL", 125050, nil},
+		{"1250.50", "BRL", 125050, nil},
+		{"1250", "BRL", 125000, nil},
+		{"0.01", "BRL", 1, nil},
+		{" 12.5 ", "BRL", 1250, nil},
+		{"1.", "BRL", 100, nil},
+		{"-12.5", "BRL", -1250, nil},
+		{"-0.01", "BRL", -1, nil},
+		{"1000", "JPY", 1000, nil},
+		{"1.234", "KWD", 1234, nil},
+		{"1.5", "BHD", 1500, nil},
+		{"-0.005", "JOD", -5, nil},
+		{"1.2345", "CLF", 12345, nil},
+
+		// zeros past the currency's decimals change nothing
+		{"12.500", "BRL", 1250, nil},
+		{"1000.00", "JPY", 1000, nil},
+		{"1.2340", "KWD", 1234, nil},
+
+		// anything else would have to be rounded
+		{"1.005", "BRL", 0, money.ErrInvalidAmount},
+		{"0.999", "BRL", 0, money.ErrInvalidAmount},
+		{"1.5", "JPY", 0, money.ErrInvalidAmount},
+		{"1.2345", "KWD", 0, money.ErrInvalidAmount},
+		{"-1.001", "BRL", 0, money.ErrInvalidAmount},
+
+		{"", "BRL", 0, money.ErrInvalidAmount},
+		{".5", "BRL", 0, money.ErrInvalidAmount},
+		{"-", "BRL", 0, money.ErrInvalidAmount},
+		{"--1", "BRL", 0, money.ErrInvalidAmount},
+		{"+1", "BRL", 0, money.ErrInvalidAmount},
+		{"1e3", "BRL", 0, money.ErrInvalidAmount},
+		{"1,50", "BRL", 0, money.ErrInvalidAmount},
+		{"1.2.3", "BRL", 0, money.ErrInvalidAmount},
+		{"ten", "BRL", 0, money.ErrInvalidAmount},
+		{"92233720368547758.08", "BRL", 0, money.ErrInvalidAmount},
+		{"1", "brl", 0, money.ErrInvalidCurrency},
+		{"1", "REAL", 0, money.ErrInvalidCurrency},
+	}
+
+	for _, tt := range tests {
+		got, err := money.Parse(tt.in, tt.currency)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q, %s) error = %v, want %v", tt.in, tt.currency, err, tt.err)
+			continue
+		}
+
+		if tt.err == nil && got != money.New(tt.want, tt.currency) {
+			t.Errorf("Parse(%q, %s) = %+v, want %d", tt.in, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		m    money.Money
+		want string
+	}{
+		{money.New(125050, "BRL"), "1250.50"},
+		{money.New(1, "BRL"), "0.01"},
+		{money.New(0, "BRL"), "0.00"},
+		{money.New(-1, "BRL"), "-0.01"},
+		{money.New(-125050, "BRL"), "-1250.50"},
+		{money.New(1000, "JPY"), "1000"},
+		{money.New(-5, "JPY"), "-5"},
+		{money.New(1234, "KWD"), "1.234"},
+		{money.New(5, "BHD"), "0.005"},
+		{money.New(-1500, "JOD"), "-1.500"},
+		{money.New(12345, "CLF"), "1.2345"},
+		{money.New(math.MaxInt64, "BRL"), "92233720368547758.07"},
+		{money.New(math.MinInt64, "BRL"), "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.m, got, tt.want)
+		}
+
+		// every amount reads back as itself
+		if back, err := money.Parse(tt.want, tt.m.Currency); tt.m.Amount != math.MinInt64 && (err != nil || back != tt.m) {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v", tt.want, back, err, tt.m)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		m    money.Money
+		want string
+	}{
+		{money.New(1250, "BRL"), `{"amount":"12.50","currency":"BRL"}`},
+		{money.New(-1250, "BRL"), `{"amount":"-12.50","currency":"BRL"}`},
+		{money.New(1234, "KWD"), `{"amount":"1.234","currency":"KWD"}`},
+		{money.New(500, "JPY"), `{"amount":"500","currency":"JPY"}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.m)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("Marshal(%+v) = %s, %v; want %s", tt.m, data, err, tt.want)
+		}
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want money.Money
+		err  bool
+	}{
+		{`{"amount":"12.50","currency":"USD"}`, money.New(1250, "USD"), false},
+		{`{"amount":12.5,"currency":"usd"}`, money.New(1250, "USD"), false},
+		{`{"amount":"1.234","currency":"KWD"}`, money.New(1234, "KWD"), false},
+		{`{"amount":"-3"}`, money.New(-300, "EUR"), false},
+		{`"12.5"`, money.New(1250, "EUR"), false},
+		{`12.5`, money.New(1250, "EUR"), false},
+		{` 7 `, money.New(700, "EUR"), false},
+		{`null`, money.Money{}, false},
+		{` null `, money.Money{}, false},
+
+		{`{"amount":"12.505","currency":"USD"}`, money.Money{}, true},
+		{`12.505`, money.Money{}, true},
+		{`1e2`, money.Money{}, true},
+		{`"null"`, money.Money{}, true},
+		{`{"amount":"1","currency":"EURO"}`, money.Money{}, true},
+		{`{"amount":`, money.Money{}, true},
+		{`true`, money.Money{}, true},
+		{``, money.Money{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := money.ParseJSON([]byte(tt.in), "EUR")
+		if (err != nil) != tt.err {
+			t.Errorf("ParseJSON(%s) error = %v, want an error: %v", tt.in, err, tt.err)
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("ParseJSON(%s) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var v struct {
+		Price   money.Money  `json:"price"`
+		Minimum *money.Money `json:"minimum"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"price":12.5,"minimum":{"amount":"1.234","currency":"BHD"}}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Price != money.New(1250, money.DefaultCurrency) || v.Minimum == nil || *v.Minimum != money.New(1234, "BHD") {
+		t.Fatalf("decoded %+v, %+v", v.Price, v.Minimum)
+	}
+
+	// null is no value: it keeps what is there, and clears a pointer
+	if err := json.Unmarshal([]byte(`{"price":null,"minimum":null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Price != money.New(1250, money.DefaultCurrency) || v.Minimum != nil {
+		t.Errorf("after null: %+v, %+v", v.Price, v.Minimum)
+	}
+
+	if err := json.Unmarshal([]byte(`{"price":"1.001"}`), &v); !errors.Is(err, money.ErrInvalidAmount) {
+		t.Errorf("too many decimals: err = %v, want ErrInvalidAmount", err)
+	}
+}
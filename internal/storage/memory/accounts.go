@@ -0,0 +1,151 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/google/uuid"
+)
+
+var _ account.AccountRepository = (*AccountRepository)(nil)
+
+type AccountRepository struct {
+	DB *DB
+}
+
+func NewAccountRepository(db *DB) *AccountRepository {
+	return &AccountRepository{
+		DB: db,
+	}
+}
+
+func (r *AccountRepository) GetAll(ctx context.Context) ([]account.Account, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	accounts := make([]account.Account, 0, len(r.DB.accounts))
+	for _, acc := range r.DB.accounts {
+		accounts = append(accounts, acc)
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Username < accounts[j].Username
+	})
+
+	return accounts, nil
+}
+
+func (r *AccountRepository) GetByID(ctx context.Context, id uuid.UUID) (*account.Account, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	acc, ok := r.DB.accounts[id]
+	if !ok {
+		return nil, account.ErrNotFound
+	}
+
+	return &acc, nil
+}
+
+func (r *AccountRepository) GetByUsername(ctx context.Context, username string) (*account.Account, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	for _, acc := range r.DB.accounts {
+		if acc.Username == username {
+			return &acc, nil
+		}
+	}
+
+	return nil, account.ErrNotFound
+}
+
+func (r *AccountRepository) Create(ctx context.Context, acc *account.Account) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if r.usernameTaken(acc.Username, uuid.Nil) {
+		return account.ErrUsernameTaken
+	}
+
+	acc.ID = uuid.New()
+	acc.Role = account.RoleUser
+	acc.Verified = false
+	r.DB.accounts[acc.ID] = *acc
+
+	return nil
+}
+
+func (r *AccountRepository) Update(ctx context.Context, acc *account.Account) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	existing, ok := r.DB.accounts[acc.ID]
+	if !ok {
+		return account.ErrNotFound
+	}
+
+	if r.usernameTaken(acc.Username, acc.ID) {
+		return account.ErrUsernameTaken
+	}
+
+	existing.Username = acc.Username
+	existing.Password = acc.Password
+	r.DB.accounts[acc.ID] = existing
+
+	return nil
+}
+
+func (r *AccountRepository) SetRole(ctx context.Context, id uuid.UUID, role string) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	acc, ok := r.DB.accounts[id]
+	if !ok {
+		return account.ErrNotFound
+	}
+
+	acc.Role = role
+	r.DB.accounts[id] = acc
+
+	return nil
+}
+
+func (r *AccountRepository) SetVerified(ctx context.Context, id uuid.UUID, verified bool) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	acc, ok := r.DB.accounts[id]
+	if !ok {
+		return account.ErrNotFound
+	}
+
+	acc.Verified = verified
+	r.DB.accounts[id] = acc
+
+	return nil
+}
+
+func (r *AccountRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if _, ok := r.DB.accounts[id]; !ok {
+		return account.ErrNotFound
+	}
+
+	r.DB.deleteAccount(id)
+
+	return nil
+}
+
+func (r *AccountRepository) usernameTaken(username string, except uuid.UUID) bool {
+	for id, acc := range r.DB.accounts {
+		if acc.Username == username && id != except {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/apikey"
+	"github.com/google/uuid"
+)
+
+var _ apikey.ApiKeyRepository = (*ApiKeyRepository)(nil)
+
+type ApiKeyRepository struct {
+	DB *DB
+}
+
+func NewApiKeyRepository(db *DB) *ApiKeyRepository {
+	return &ApiKeyRepository{
+		DB: db,
+	}
+}
+
+func (r *ApiKeyRepository) Create(ctx context.Context, key *apikey.ApiKey) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if _, ok := r.DB.accounts[key.AccountID]; !ok {
+		return apikey.ErrNotFound
+	}
+
+	key.ID = uuid.New()
+	key.CreatedAt = time.Now()
+	r.DB.apiKeys[key.ID] = *key
+
+	return nil
+}
+
+func (r *ApiKeyRepository) GetByAccount(ctx context.Context, accountID uuid.UUID) ([]apikey.ApiKey, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	keys := make([]apikey.ApiKey, 0)
+	for _, key := range r.DB.apiKeys {
+		if key.AccountID == accountID {
+			key.Hash = ""
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (r *ApiKeyRepository) Revoke(ctx context.Context, id uuid.UUID, accountID uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	key, ok := r.DB.apiKeys[id]
+	if !ok || key.AccountID != accountID || key.RevokedAt != nil {
+		return apikey.ErrNotFound
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	r.DB.apiKeys[id] = key
+
+	return nil
+}
+
+func (r *ApiKeyRepository) Use(ctx context.Context, hash string, at time.Time) (*apikey.ApiKey, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	for id, key := range r.DB.apiKeys {
+		if key.Hash != hash || key.RevokedAt != nil {
+			continue
+		}
+
+		key.LastUsedAt = &at
+		r.DB.apiKeys[id] = key
+
+		return &key, nil
+	}
+
+	return nil, apikey.ErrNotFound
+}
@@ -0,0 +1,244 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/google/uuid"
+)
+
+var _ product.BidRepository = (*BidRepository)(nil)
+
+// BidRepository keeps bids in the order they were placed. Inserts happen
+// under the write lock, so concurrent bids are totally ordered.
+type BidRepository struct {
+	DB *DB
+}
+
+func NewBidRepository(db *DB) *BidRepository {
+	return &BidRepository{
+		DB: db,
+	}
+}
+
+func (r *BidRepository) Create(ctx context.Context, b *product.Bid) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	_, accountOk := r.DB.accounts[b.AccountID]
+	p, productOk := r.DB.products[b.ProductID]
+	if !accountOk || !productOk {
+		return product.ErrNotFound
+	}
+
+	now := time.Now().UTC()
+	_, closed := r.DB.closed[b.ProductID]
+
+	switch {
+	case p.PublishedAt == nil:
+		return product.ErrNotPublished
+	case closed, p.EndsAt != nil && !now.Before(*p.EndsAt):
+		return product.ErrAuctionEnded
+	}
+
+	active := -1
+	for i, other := range r.DB.bids {
+		if other.ProductID == b.ProductID && other.Status == product.BidActive {
+			active = i
+		}
+	}
+
+	if active >= 0 {
+		if b.BidValue.Amount <= r.DB.bids[active].BidValue.Amount {
+			return product.ErrBidTooLow
+		}
+
+		r.DB.bids[active].Status = product.BidOutbid
+	}
+
+	b.Status = product.BidActive
+
+	b.ID = uuid.New()
+	b.CreatedAt = now
+
+	stored := *b
+	stored.DisplayValue = nil
+	r.DB.bids = append(r.DB.bids, stored)
+
+	return nil
+}
+
+func (r *BidRepository) GetByID(ctx context.Context, id uuid.UUID) (*product.Bid, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	for _, b := range r.DB.bids {
+		if b.ID == id {
+			return &b, nil
+		}
+	}
+
+	return nil, product.ErrBidNotFound
+}
+
+// GetByAccount returns the account's bids, newest first.
+func (r *BidRepository) GetByAccount(ctx context.Context, accountID uuid.UUID) ([]product.Bid, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	bids := make([]product.Bid, 0)
+	for i := len(r.DB.bids) - 1; i >= 0; i-- {
+		if r.DB.bids[i].AccountID == accountID {
+			bids = append(bids, r.DB.bids[i])
+		}
+	}
+
+	return bids, nil
+}
+
+func (r *BidRepository) GetByProduct(ctx context.Context, productID uuid.UUID, limit int, offset int) ([]product.Bid, int, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	bids := make([]product.Bid, 0)
+	total := 0
+
+	for i := len(r.DB.bids) - 1; i >= 0; i-- {
+		if r.DB.bids[i].ProductID != productID {
+			continue
+		}
+
+		if total >= offset && len(bids) < limit {
+			bids = append(bids, r.DB.bids[i])
+		}
+		total++
+	}
+
+	return bids, total, nil
+}
+
+// Get returns the account's highest bid on the product, the earliest one
+// winning ties.
+func (r *BidRepository) Get(ctx context.Context, accountID uuid.UUID, productID uuid.UUID) (*product.Bid, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	var best *product.Bid
+	for i, b := range r.DB.bids {
+		if b.AccountID != accountID || b.ProductID != productID {
+			continue
+		}
+
+		if best == nil || b.BidValue.Amount > best.BidValue.Amount {
+			best = &r.DB.bids[i]
+		}
+	}
+
+	if best == nil {
+		return nil, product.ErrNotFound
+	}
+
+	bid := *best
+
+	return &bid, nil
+}
+
+func (r *BidRepository) Retract(ctx context.Context, id uuid.UUID, reason string, at time.Time, limit product.RetractionLimit) (*product.Bid, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	i := slices.IndexFunc(r.DB.bids, func(b product.Bid) bool { return b.ID == id })
+	if i < 0 {
+		return nil, product.ErrBidNotFound
+	}
+
+	b := &r.DB.bids[i]
+	if b.Status == product.BidRetracted || b.Status == product.BidWinning {
+		return nil, product.ErrBidNotRetractable
+	}
+
+	if r.countRetractions(b.AccountID, limit.Since) >= limit.Max {
+		return nil, product.ErrRetractionLimit
+	}
+
+	wasActive := b.Status == product.BidActive
+
+	b.Status = product.BidRetracted
+	b.RetractedAt = &at
+	b.RetractionReason = reason
+
+	if !wasActive {
+		return nil, nil
+	}
+
+	// bids are in placement order, so strictly greater keeps the earliest
+	var next *product.Bid
+	for j := range r.DB.bids {
+		other := &r.DB.bids[j]
+		if other.ProductID != b.ProductID || other.Status != product.BidOutbid {
+			continue
+		}
+
+		if next == nil || other.BidValue.Amount > next.BidValue.Amount {
+			next = other
+		}
+	}
+
+	if next == nil {
+		return nil, nil
+	}
+
+	next.Status = product.BidActive
+	promoted := *next
+
+	return &promoted, nil
+}
+
+func (r *BidRepository) CountRetractions(ctx context.Context, accountID uuid.UUID, since time.Time) (int, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	return r.countRetractions(accountID, since), nil
+}
+
+// countRetractions is CountRetractions with the lock held.
+func (r *BidRepository) countRetractions(accountID uuid.UUID, since time.Time) int {
+	count := 0
+	for _, b := range r.DB.bids {
+		if b.AccountID == accountID && b.RetractedAt != nil && !b.RetractedAt.Before(since) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (r *BidRepository) RemoveMessage(ctx context.Context, id uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	for i := range r.DB.bids {
+		if r.DB.bids[i].ID == id {
+			r.DB.bids[i].BidMessage = product.RemovedMessage
+			return nil
+		}
+	}
+
+	return product.ErrBidNotFound
+}
+
+func (r *BidRepository) Bidders(ctx context.Context, productID uuid.UUID) ([]uuid.UUID, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	bidders := make([]uuid.UUID, 0)
+	for _, b := range r.DB.bids {
+		if b.ProductID == productID && !slices.Contains(bidders, b.AccountID) {
+			bidders = append(bidders, b.AccountID)
+		}
+	}
+
+	return bidders, nil
+}
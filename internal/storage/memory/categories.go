@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/google/uuid"
+)
+
+var _ category.CategoryRepository = (*CategoryRepository)(nil)
+
+type CategoryRepository struct {
+	DB *DB
+}
+
+func NewCategoryRepository(db *DB) *CategoryRepository {
+	return &CategoryRepository{
+		DB: db,
+	}
+}
+
+func (r *CategoryRepository) GetAll(ctx context.Context) ([]category.Category, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	categories := make([]category.Category, 0, len(r.DB.categories))
+	for _, c := range r.DB.categories {
+		categories = append(categories, c)
+	}
+
+	sort.Slice(categories, func(i, j int) bool {
+		a, b := strings.ToLower(categories[i].Name), strings.ToLower(categories[j].Name)
+		if a != b {
+			return a < b
+		}
+		return categories[i].ID.String() < categories[j].ID.String()
+	})
+
+	return categories, nil
+}
+
+func (r *CategoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*category.Category, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	c, ok := r.DB.categories[id]
+	if !ok {
+		return nil, category.ErrNotFound
+	}
+
+	return &c, nil
+}
+
+func (r *CategoryRepository) Create(ctx context.Context, c *category.Category) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	c.ID = uuid.New()
+
+	if err := r.check(*c); err != nil {
+		return err
+	}
+
+	r.DB.categories[c.ID] = *c
+
+	return nil
+}
+
+func (r *CategoryRepository) Update(ctx context.Context, c *category.Category) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if _, ok := r.DB.categories[c.ID]; !ok {
+		return category.ErrNotFound
+	}
+
+	if err := r.check(*c); err != nil {
+		return err
+	}
+
+	r.DB.categories[c.ID] = *c
+
+	return nil
+}
+
+// check mirrors the foreign key and unique indexes on categories. The
+// caller must hold the write lock.
+func (r *CategoryRepository) check(c category.Category) error {
+	if c.ParentID.Valid {
+		if _, ok := r.DB.categories[c.ParentID.UUID]; !ok {
+			return category.ErrNotFound
+		}
+	}
+
+	for _, other := range r.DB.categories {
+		if other.ID != c.ID && other.ParentID == c.ParentID && strings.EqualFold(other.Name, c.Name) {
+			return category.ErrNameTaken
+		}
+	}
+
+	return nil
+}
+
+func (r *CategoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if _, ok := r.DB.categories[id]; !ok {
+		return category.ErrNotFound
+	}
+
+	for _, c := range r.DB.categories {
+		if c.ParentID.Valid && c.ParentID.UUID == id {
+			return category.ErrInUse
+		}
+	}
+
+	if r.DB.hasProducts(id) {
+		return category.ErrInUse
+	}
+
+	delete(r.DB.categories, id)
+
+	return nil
+}
+
+func (r *CategoryRepository) HasProducts(ctx context.Context, id uuid.UUID) (bool, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	return r.DB.hasProducts(id), nil
+}
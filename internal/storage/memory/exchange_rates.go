@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/exchange"
+)
+
+var _ exchange.RateRepository = (*RateRepository)(nil)
+
+type RateRepository struct {
+	DB *DB
+}
+
+func NewRateRepository(db *DB) *RateRepository {
+	return &RateRepository{
+		DB: db,
+	}
+}
+
+func (r *RateRepository) GetAll(ctx context.Context) ([]exchange.Rate, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	rates := make([]exchange.Rate, 0, len(r.DB.rates))
+	for _, rate := range r.DB.rates {
+		rates = append(rates, rate)
+	}
+
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].Base != rates[j].Base {
+			return rates[i].Base < rates[j].Base
+		}
+		return rates[i].Quote < rates[j].Quote
+	})
+
+	return rates, nil
+}
+
+func (r *RateRepository) Set(ctx context.Context, rate *exchange.Rate) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	r.DB.rates[[2]string{rate.Base, rate.Quote}] = *rate
+
+	return nil
+}
+
+func (r *RateRepository) Delete(ctx context.Context, base string, quote string) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	key := [2]string{base, quote}
+	if _, ok := r.DB.rates[key]; !ok {
+		return exchange.ErrNoRate
+	}
+
+	delete(r.DB.rates, key)
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/google/uuid"
+)
+
+// addRevision records changes to a product, if there are any. The caller
+// must hold the write lock.
+func (db *DB) addRevision(productID uuid.UUID, editorID uuid.UUID, changes []product.Change, at time.Time) {
+	if len(changes) == 0 {
+		return
+	}
+
+	db.revisions = append(db.revisions, product.Revision{
+		ID:        uuid.New(),
+		ProductID: productID,
+		EditorID:  uuid.NullUUID{UUID: editorID, Valid: true},
+		Changes:   slices.Clone(changes),
+		CreatedAt: at,
+	})
+}
+
+func (r *ProductRepository) GetRevisions(ctx context.Context, productID uuid.UUID) ([]product.Revision, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	revisions := make([]product.Revision, 0)
+	for _, rev := range r.DB.revisions {
+		if rev.ProductID == productID {
+			rev.Changes = slices.Clone(rev.Changes)
+			revisions = append(revisions, rev)
+		}
+	}
+
+	slices.SortFunc(revisions, func(a, b product.Revision) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), bytes.Compare(b.ID[:], a.ID[:]))
+	})
+
+	return revisions, nil
+}
+
+func (r *ProductRepository) AddAddendum(ctx context.Context, a *product.Addendum, editorID uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if _, ok := r.DB.products[a.ProductID]; !ok {
+		return product.ErrNotFound
+	}
+
+	a.ID = uuid.New()
+	a.CreatedAt = time.Now().UTC()
+
+	r.DB.addenda = append(r.DB.addenda, *a)
+	r.DB.addRevision(a.ProductID, editorID, []product.Change{{Field: product.FieldAddendum, New: a.Text}}, a.CreatedAt)
+
+	return nil
+}
+
+func (r *ProductRepository) GetAddenda(ctx context.Context, productID uuid.UUID) ([]product.Addendum, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	addenda := make([]product.Addendum, 0)
+	for _, a := range r.DB.addenda {
+		if a.ProductID == productID {
+			addenda = append(addenda, a)
+		}
+	}
+
+	slices.SortFunc(addenda, func(a, b product.Addendum) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), bytes.Compare(a.ID[:], b.ID[:]))
+	})
+
+	return addenda, nil
+}
@@ -0,0 +1,53 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/jwt"
+)
+
+var _ jwt.IdentityRepository = (*IdentityRepository)(nil)
+
+type IdentityRepository struct {
+	DB *DB
+}
+
+func NewIdentityRepository(db *DB) *IdentityRepository {
+	return &IdentityRepository{
+		DB: db,
+	}
+}
+
+func (r *IdentityRepository) Get(ctx context.Context, provider string, subject string) (*jwt.Identity, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	i, ok := r.DB.identities[[2]string{provider, subject}]
+	if !ok {
+		return nil, jwt.ErrIdentityNotFound
+	}
+
+	return &i, nil
+}
+
+func (r *IdentityRepository) Create(ctx context.Context, i *jwt.Identity) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	key := [2]string{i.Provider, i.Subject}
+
+	if _, ok := r.DB.identities[key]; ok {
+		return errors.New("identity already linked")
+	}
+
+	if _, ok := r.DB.accounts[i.AccountID]; !ok {
+		return errors.New("account does not exist")
+	}
+
+	i.CreatedAt = time.Now()
+	r.DB.identities[key] = *i
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/google/uuid"
+)
+
+var _ product.ImageRepository = (*ImageRepository)(nil)
+
+type ImageRepository struct {
+	DB *DB
+}
+
+func NewImageRepository(db *DB) *ImageRepository {
+	return &ImageRepository{
+		DB: db,
+	}
+}
+
+func (r *ImageRepository) Create(ctx context.Context, img *product.Image) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if _, ok := r.DB.products[img.ProductID]; !ok {
+		return product.ErrNotFound
+	}
+
+	img.Position = len(r.DB.productImages(img.ProductID))
+	img.Status = product.ImageProcessing
+	img.CreatedAt = time.Now().UTC()
+	img.Variants = make(map[string]product.Variant)
+
+	r.DB.images[img.ID] = cloneImage(*img)
+
+	return nil
+}
+
+func (r *ImageRepository) GetByID(ctx context.Context, id uuid.UUID) (*product.Image, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	img, ok := r.DB.images[id]
+	if !ok {
+		return nil, product.ErrImageNotFound
+	}
+
+	img = cloneImage(img)
+
+	return &img, nil
+}
+
+func (r *ImageRepository) GetByProduct(ctx context.Context, productID uuid.UUID) ([]product.Image, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	return r.DB.productImages(productID), nil
+}
+
+func (r *ImageRepository) GetByProducts(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID][]product.Image, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	byProduct := make(map[uuid.UUID][]product.Image, len(productIDs))
+	for _, id := range productIDs {
+		if images := r.DB.productImages(id); len(images) > 0 {
+			byProduct[id] = images
+		}
+	}
+
+	return byProduct, nil
+}
+
+func (r *ImageRepository) GetProcessing(ctx context.Context) ([]product.Image, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	images := make([]product.Image, 0)
+	for _, img := range r.DB.images {
+		if img.Status == product.ImageProcessing {
+			images = append(images, cloneImage(img))
+		}
+	}
+
+	slices.SortFunc(images, func(a, b product.Image) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), bytes.Compare(a.ID[:], b.ID[:]))
+	})
+
+	return images, nil
+}
+
+func (r *ImageRepository) SetVariants(ctx context.Context, id uuid.UUID, variants map[string]product.Variant) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	img, ok := r.DB.images[id]
+	if !ok {
+		return product.ErrImageNotFound
+	}
+
+	img.Status = product.ImageReady
+	img.Variants = maps.Clone(variants)
+	r.DB.images[id] = img
+
+	return nil
+}
+
+func (r *ImageRepository) SetStatus(ctx context.Context, id uuid.UUID, status string) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	img, ok := r.DB.images[id]
+	if !ok {
+		return product.ErrImageNotFound
+	}
+
+	img.Status = status
+	r.DB.images[id] = img
+
+	return nil
+}
+
+func (r *ImageRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	img, ok := r.DB.images[id]
+	if !ok {
+		return product.ErrImageNotFound
+	}
+
+	delete(r.DB.images, id)
+
+	for _, other := range r.DB.productImages(img.ProductID) {
+		if other.Position > img.Position {
+			other.Position--
+			r.DB.images[other.ID] = other
+		}
+	}
+
+	return nil
+}
+
+func (r *ImageRepository) Reorder(ctx context.Context, productID uuid.UUID, ids []uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if _, ok := r.DB.products[productID]; !ok {
+		return product.ErrNotFound
+	}
+
+	images := r.DB.productImages(productID)
+	if len(images) != len(ids) {
+		return product.ErrInvalidOrder
+	}
+
+	for i, id := range ids {
+		img, ok := r.DB.images[id]
+		if !ok || img.ProductID != productID || slices.Contains(ids[:i], id) {
+			return product.ErrInvalidOrder
+		}
+	}
+
+	for position, id := range ids {
+		img := r.DB.images[id]
+		img.Position = position
+		r.DB.images[id] = img
+	}
+
+	return nil
+}
+
+// productImages returns the images of a product in order. The caller must
+// hold the lock.
+func (db *DB) productImages(productID uuid.UUID) []product.Image {
+	images := make([]product.Image, 0)
+	for _, img := range db.images {
+		if img.ProductID == productID {
+			images = append(images, cloneImage(img))
+		}
+	}
+
+	slices.SortFunc(images, func(a, b product.Image) int {
+		return a.Position - b.Position
+	})
+
+	return images
+}
+
+// cloneImage copies img so callers cannot change the stored variants.
+func cloneImage(img product.Image) product.Image {
+	img.Variants = maps.Clone(img.Variants)
+	if img.Variants == nil {
+		img.Variants = make(map[string]product.Variant)
+	}
+
+	return img
+}
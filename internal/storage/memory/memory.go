@@ -0,0 +1,193 @@
+// Package memory implements the repositories in process memory, with the
+// same uniqueness and cascade semantics as the SQL schema. It is meant for
+// tests and local demos; nothing survives a restart.
+package memory
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/apikey"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/exchange"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/jwt"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/moderation"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/notification"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/google/uuid"
+)
+
+// DB holds every table behind a single lock, so operations spanning several
+// tables (cascades, bid ordering) are atomic like a SQL transaction.
+type DB struct {
+	mu sync.RWMutex
+
+	accounts       map[uuid.UUID]account.Account
+	products       map[uuid.UUID]product.Product
+	closed         map[uuid.UUID]time.Time
+	accountProduct map[[2]uuid.UUID]struct{}
+	bids           []product.Bid
+	apiKeys        map[uuid.UUID]apikey.ApiKey
+	identities     map[[2]string]jwt.Identity
+	rates          map[[2]string]exchange.Rate
+	notifications  []notification.Notification
+	categories     map[uuid.UUID]category.Category
+	images         map[uuid.UUID]product.Image
+	reports        []moderation.Report
+	revisions      []product.Revision
+	addenda        []product.Addendum
+}
+
+func New() *DB {
+	return &DB{
+		accounts:       make(map[uuid.UUID]account.Account),
+		products:       make(map[uuid.UUID]product.Product),
+		closed:         make(map[uuid.UUID]time.Time),
+		accountProduct: make(map[[2]uuid.UUID]struct{}),
+		apiKeys:        make(map[uuid.UUID]apikey.ApiKey),
+		identities:     make(map[[2]string]jwt.Identity),
+		rates:          make(map[[2]string]exchange.Rate),
+		categories:     make(map[uuid.UUID]category.Category),
+		images:         make(map[uuid.UUID]product.Image),
+	}
+}
+
+// withBidCount fills in p.BidCount. The caller must hold the lock.
+func (db *DB) withBidCount(p product.Product) product.Product {
+	p.BidCount = 0
+	for _, b := range db.bids {
+		if b.ProductID == p.ID && b.Status != product.BidRetracted {
+			p.BidCount++
+		}
+	}
+
+	return p
+}
+
+// categoryExists reports whether a product may reference id, mirroring the
+// foreign key. The caller must hold the lock.
+func (db *DB) categoryExists(id uuid.NullUUID) bool {
+	if !id.Valid {
+		return true
+	}
+
+	_, ok := db.categories[id.UUID]
+
+	return ok
+}
+
+// hasProducts reports whether any product is in the category. The caller
+// must hold the lock.
+func (db *DB) hasProducts(categoryID uuid.UUID) bool {
+	for _, p := range db.products {
+		if p.CategoryID.Valid && p.CategoryID.UUID == categoryID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteAccount removes an account and everything referencing it, mirroring
+// the ON DELETE CASCADE foreign keys. The caller must hold the write lock.
+func (db *DB) deleteAccount(id uuid.UUID) {
+	delete(db.accounts, id)
+
+	for pid, p := range db.products {
+		if p.AccountID == id {
+			db.deleteProduct(pid)
+		}
+	}
+
+	for key := range db.accountProduct {
+		if key[0] == id {
+			delete(db.accountProduct, key)
+		}
+	}
+
+	db.bids = filterBids(db.bids, func(b product.Bid) bool { return b.AccountID != id })
+
+	for kid, k := range db.apiKeys {
+		if k.AccountID == id {
+			delete(db.apiKeys, kid)
+		}
+	}
+
+	for key, i := range db.identities {
+		if i.AccountID == id {
+			delete(db.identities, key)
+		}
+	}
+
+	kept := db.notifications[:0]
+	for _, n := range db.notifications {
+		if n.AccountID != id {
+			kept = append(kept, n)
+		}
+	}
+	db.notifications = kept
+
+	for i, rev := range db.revisions {
+		if rev.EditorID.Valid && rev.EditorID.UUID == id {
+			db.revisions[i].EditorID = uuid.NullUUID{}
+		}
+	}
+
+	db.pruneReports()
+}
+
+// deleteProduct removes a product, its associations and its bids. The caller
+// must hold the write lock.
+func (db *DB) deleteProduct(id uuid.UUID) {
+	delete(db.products, id)
+	delete(db.closed, id)
+
+	for key := range db.accountProduct {
+		if key[1] == id {
+			delete(db.accountProduct, key)
+		}
+	}
+
+	db.bids = filterBids(db.bids, func(b product.Bid) bool { return b.ProductID != id })
+
+	for iid, img := range db.images {
+		if img.ProductID == id {
+			delete(db.images, iid)
+		}
+	}
+
+	db.revisions = slices.DeleteFunc(db.revisions, func(rev product.Revision) bool { return rev.ProductID == id })
+	db.addenda = slices.DeleteFunc(db.addenda, func(a product.Addendum) bool { return a.ProductID == id })
+
+	db.pruneReports()
+}
+
+// pruneReports drops the reports whose reporter or content is gone and
+// forgets moderators who are gone, mirroring the foreign keys. The caller
+// must hold the write lock.
+func (db *DB) pruneReports() {
+	kept := db.reports[:0]
+	for _, r := range db.reports {
+		if _, ok := db.accounts[r.ReporterID]; !ok || !db.targetExists(r.Target) {
+			continue
+		}
+		if _, ok := db.accounts[r.ResolvedBy.UUID]; r.ResolvedBy.Valid && !ok {
+			r.ResolvedBy = uuid.NullUUID{}
+		}
+		kept = append(kept, r)
+	}
+	db.reports = kept
+}
+
+func filterBids(bids []product.Bid, keep func(product.Bid) bool) []product.Bid {
+	kept := bids[:0]
+	for _, b := range bids {
+		if keep(b) {
+			kept = append(kept, b)
+		}
+	}
+
+	return kept
+}
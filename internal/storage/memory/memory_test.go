@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/Nier704/arthur-leilao-server/internal/storage"
+	"github.com/Nier704/arthur-leilao-server/internal/storage/storagetest"
+)
+
+func TestContract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *storage.Store {
+		return storage.NewMemoryStore()
+	})
+}
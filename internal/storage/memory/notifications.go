@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/notification"
+	"github.com/google/uuid"
+)
+
+var _ notification.NotificationRepository = (*NotificationRepository)(nil)
+
+type NotificationRepository struct {
+	DB *DB
+}
+
+func NewNotificationRepository(db *DB) *NotificationRepository {
+	return &NotificationRepository{
+		DB: db,
+	}
+}
+
+func (r *NotificationRepository) Create(ctx context.Context, n *notification.Notification) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	n.ID = uuid.New()
+	n.CreatedAt = time.Now().UTC()
+	r.DB.notifications = append(r.DB.notifications, *n)
+
+	return nil
+}
+
+func (r *NotificationRepository) GetByAccount(ctx context.Context, accountID uuid.UUID) ([]notification.Notification, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	notifications := make([]notification.Notification, 0)
+	for i := len(r.DB.notifications) - 1; i >= 0; i-- {
+		if r.DB.notifications[i].AccountID == accountID {
+			notifications = append(notifications, r.DB.notifications[i])
+		}
+	}
+
+	return notifications, nil
+}
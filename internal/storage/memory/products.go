@@ -0,0 +1,413 @@
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/google/uuid"
+)
+
+var _ product.ProductRepository = (*ProductRepository)(nil)
+
+type ProductRepository struct {
+	DB *DB
+}
+
+func NewProductRepository(db *DB) *ProductRepository {
+	return &ProductRepository{
+		DB: db,
+	}
+}
+
+func (r *ProductRepository) GetAll(ctx context.Context) ([]product.Product, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	products := make([]product.Product, 0, len(r.DB.products))
+	for _, p := range r.DB.products {
+		products = append(products, r.DB.withBidCount(p))
+	}
+
+	return products, nil
+}
+
+func (r *ProductRepository) List(ctx context.Context, opts product.ListOptions) (*product.ProductPage, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	matching := make([]product.Product, 0)
+	for _, p := range r.DB.products {
+		if listed(p, opts) {
+			matching = append(matching, r.DB.withBidCount(p))
+		}
+	}
+
+	slices.SortFunc(matching, func(a, b product.Product) int {
+		return compareCursors(product.CursorOf(a, opts.Sort), product.CursorOf(b, opts.Sort))
+	})
+
+	page := &product.ProductPage{Total: len(matching)}
+
+	if opts.After != nil {
+		start, _ := slices.BinarySearchFunc(matching, *opts.After, func(p product.Product, c product.Cursor) int {
+			if compareCursors(product.CursorOf(p, opts.Sort), c) <= 0 {
+				return -1
+			}
+			return 1
+		})
+		matching = matching[start:]
+	}
+
+	if len(matching) > opts.Limit {
+		matching = matching[:opts.Limit]
+		page.HasMore = true
+	}
+
+	page.Products = matching
+
+	return page, nil
+}
+
+func (r *ProductRepository) Search(ctx context.Context, query string, limit int, offset int) ([]product.SearchResult, int, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	products := make([]product.Product, 0, len(r.DB.products))
+	for _, p := range r.DB.products {
+		if p.PublishedAt != nil {
+			products = append(products, r.DB.withBidCount(p))
+		}
+	}
+
+	results, total := product.RankSearch(products, product.SearchTerms(query), limit, offset)
+
+	return results, total, nil
+}
+
+func listed(p product.Product, opts product.ListOptions) bool {
+	if opts.Drafts != (p.PublishedAt == nil) {
+		return false
+	}
+
+	if opts.MinPrice != nil && (p.Price.Currency != opts.MinPrice.Currency || p.Price.Amount < opts.MinPrice.Amount) {
+		return false
+	}
+
+	if opts.MaxPrice != nil && (p.Price.Currency != opts.MaxPrice.Currency || p.Price.Amount > opts.MaxPrice.Amount) {
+		return false
+	}
+
+	if opts.Seller.Valid && p.AccountID != opts.Seller.UUID {
+		return false
+	}
+
+	if len(opts.Categories) > 0 && (!p.CategoryID.Valid || !slices.Contains(opts.Categories, p.CategoryID.UUID)) {
+		return false
+	}
+
+	if opts.Tag != "" && !slices.Contains(p.Tags, opts.Tag) {
+		return false
+	}
+
+	open := p.EndsAt == nil || p.EndsAt.After(opts.Now)
+
+	switch opts.Status {
+	case product.StatusOpen:
+		if !open {
+			return false
+		}
+	case product.StatusEnded:
+		if open {
+			return false
+		}
+	}
+
+	if opts.EndingBefore != nil && (p.EndsAt == nil || !open || p.EndsAt.After(*opts.EndingBefore)) {
+		return false
+	}
+
+	return opts.Sort != product.SortEndingSoon || p.EndsAt != nil
+}
+
+// compareCursors orders two positions in a listing the way the SQL store's
+// ORDER BY does.
+func compareCursors(a product.Cursor, b product.Cursor) int {
+	var c int
+
+	switch a.Sort {
+	case product.SortEndingSoon:
+		c = a.EndsAt.Compare(*b.EndsAt)
+	case product.SortPriceAsc, product.SortPriceDesc:
+		c = cmp.Or(strings.Compare(a.Price.Currency, b.Price.Currency), cmp.Compare(a.Price.Amount, b.Price.Amount))
+	case product.SortMostBids:
+		c = cmp.Compare(*a.BidCount, *b.BidCount)
+	default:
+		c = a.CreatedAt.Compare(*b.CreatedAt)
+	}
+
+	c = cmp.Or(c, bytes.Compare(a.ID[:], b.ID[:]))
+
+	switch a.Sort {
+	case product.SortEndingSoon, product.SortPriceAsc:
+		return c
+	}
+
+	return -c
+}
+
+func (r *ProductRepository) GetByID(ctx context.Context, id uuid.UUID) (*product.Product, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	p, ok := r.DB.products[id]
+	if !ok {
+		return nil, product.ErrNotFound
+	}
+
+	p = r.DB.withBidCount(p)
+
+	return &p, nil
+}
+
+func (r *ProductRepository) GetByAccount(ctx context.Context, accountID uuid.UUID) ([]product.Product, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	products := make([]product.Product, 0)
+	for key := range r.DB.accountProduct {
+		if key[0] == accountID {
+			products = append(products, r.DB.withBidCount(r.DB.products[key[1]]))
+		}
+	}
+
+	return products, nil
+}
+
+func (r *ProductRepository) Create(ctx context.Context, p *product.Product) error {
+	products := []product.Product{*p}
+	if err := r.CreateMany(ctx, products); err != nil {
+		return err
+	}
+
+	*p = products[0]
+
+	return nil
+}
+
+func (r *ProductRepository) CreateMany(ctx context.Context, products []product.Product) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	for _, p := range products {
+		if _, ok := r.DB.accounts[p.AccountID]; !ok {
+			return product.ErrNotFound
+		}
+
+		if !r.DB.categoryExists(p.CategoryID) {
+			return product.ErrNotFound
+		}
+	}
+
+	for i := range products {
+		p := &products[i]
+
+		p.ID = uuid.New()
+		p.CreatedAt = time.Now().UTC()
+		p.BidCount = 0
+
+		stored := *p
+		stored.Tags = append([]string{}, p.Tags...)
+		stored.DisplayPrice = nil
+		stored.Images = nil
+		stored.ReviewStatus, stored.ReviewReason = "", ""
+		stored.Addenda = nil
+		r.DB.products[p.ID] = stored
+
+		r.DB.addRevision(p.ID, p.AccountID, product.Diff(nil, &stored), p.CreatedAt)
+	}
+
+	return nil
+}
+
+func (r *ProductRepository) Update(ctx context.Context, p *product.Product, editorID uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	existing, ok := r.DB.products[p.ID]
+	if !ok || !r.DB.categoryExists(p.CategoryID) {
+		return product.ErrNotFound
+	}
+
+	changes := product.Diff(&existing, p)
+
+	if len(product.LockedChanges(changes)) > 0 && slices.ContainsFunc(r.DB.bids, func(b product.Bid) bool { return b.ProductID == p.ID }) {
+		return product.ErrLocked
+	}
+
+	existing.Title = p.Title
+	existing.Description = p.Description
+	existing.Price = p.Price
+	existing.CategoryID = p.CategoryID
+	existing.Tags = append([]string{}, p.Tags...)
+	existing.EndsAt = p.EndsAt
+	r.DB.products[p.ID] = existing
+
+	p.AccountID = existing.AccountID
+
+	r.DB.addRevision(p.ID, editorID, changes, time.Now().UTC())
+
+	return nil
+}
+
+func (r *ProductRepository) Publish(ctx context.Context, id uuid.UUID, at time.Time) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	p, ok := r.DB.products[id]
+	if !ok {
+		return product.ErrNotFound
+	}
+
+	if p.PublishedAt != nil {
+		return product.ErrAlreadyPublished
+	}
+
+	at = at.UTC()
+	p.PublishedAt = &at
+	r.DB.products[id] = p
+
+	return nil
+}
+
+func (r *ProductRepository) Submit(ctx context.Context, id uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	p, ok := r.DB.products[id]
+	if !ok {
+		return product.ErrNotFound
+	}
+
+	if p.PublishedAt != nil {
+		return product.ErrAlreadyPublished
+	}
+
+	p.ReviewStatus, p.ReviewReason = product.ReviewPending, ""
+	r.DB.products[id] = p
+
+	return nil
+}
+
+func (r *ProductRepository) GetPending(ctx context.Context) ([]product.Product, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	products := make([]product.Product, 0)
+	for _, p := range r.DB.products {
+		if p.ReviewStatus == product.ReviewPending {
+			products = append(products, r.DB.withBidCount(p))
+		}
+	}
+
+	slices.SortFunc(products, func(a, b product.Product) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), bytes.Compare(a.ID[:], b.ID[:]))
+	})
+
+	return products, nil
+}
+
+func (r *ProductRepository) Approve(ctx context.Context, id uuid.UUID, reason string, at time.Time) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	p, ok := r.DB.products[id]
+	if !ok {
+		return product.ErrNotFound
+	}
+
+	if p.ReviewStatus != product.ReviewPending || p.PublishedAt != nil {
+		return product.ErrNotPending
+	}
+
+	at = at.UTC()
+	p.ReviewStatus, p.ReviewReason, p.PublishedAt = product.ReviewApproved, reason, &at
+	r.DB.products[id] = p
+
+	return nil
+}
+
+func (r *ProductRepository) Reject(ctx context.Context, id uuid.UUID, reason string) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	p, ok := r.DB.products[id]
+	if !ok {
+		return product.ErrNotFound
+	}
+
+	if p.ReviewStatus != product.ReviewPending && p.PublishedAt == nil {
+		return product.ErrNotPending
+	}
+
+	p.ReviewStatus, p.ReviewReason, p.PublishedAt = product.ReviewRejected, reason, nil
+	r.DB.products[id] = p
+
+	return nil
+}
+
+func (r *ProductRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if _, ok := r.DB.products[id]; !ok {
+		return product.ErrNotFound
+	}
+
+	r.DB.deleteProduct(id)
+
+	return nil
+}
+
+func (r *ProductRepository) CloseEnded(ctx context.Context, now time.Time) (int, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	closed := 0
+
+	for id, p := range r.DB.products {
+		if _, ok := r.DB.closed[id]; ok || p.PublishedAt == nil || p.EndsAt == nil || p.EndsAt.After(now) {
+			continue
+		}
+
+		for i := range r.DB.bids {
+			if b := &r.DB.bids[i]; b.ProductID == id && b.Status == product.BidActive {
+				b.Status = product.BidWinning
+			}
+		}
+
+		r.DB.closed[id] = now.UTC()
+		closed++
+	}
+
+	return closed, nil
+}
+
+func (r *ProductRepository) Associate(ctx context.Context, accountID uuid.UUID, productID uuid.UUID) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	_, accountOk := r.DB.accounts[accountID]
+	_, productOk := r.DB.products[productID]
+	if !accountOk || !productOk {
+		return product.ErrNotFound
+	}
+
+	r.DB.accountProduct[[2]uuid.UUID{accountID, productID}] = struct{}{}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/moderation"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/google/uuid"
+)
+
+var _ moderation.ReportRepository = (*ReportRepository)(nil)
+
+type ReportRepository struct {
+	DB *DB
+}
+
+func NewReportRepository(db *DB) *ReportRepository {
+	return &ReportRepository{
+		DB: db,
+	}
+}
+
+func (r *ReportRepository) Create(ctx context.Context, report *moderation.Report) error {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	if _, ok := r.DB.accounts[report.ReporterID]; !ok || !r.DB.targetExists(report.Target) {
+		return moderation.ErrTargetNotFound
+	}
+
+	for _, existing := range r.DB.reports {
+		if existing.ReporterID == report.ReporterID && existing.Target.ID == report.Target.ID && existing.Status == moderation.ReportOpen {
+			return moderation.ErrAlreadyReported
+		}
+	}
+
+	report.ID = uuid.New()
+	report.Status = moderation.ReportOpen
+	report.CreatedAt = time.Now().UTC()
+	report.ResolvedBy = uuid.NullUUID{}
+	report.ResolvedAt = nil
+	report.Note = ""
+
+	r.DB.reports = append(r.DB.reports, *report)
+
+	return nil
+}
+
+func (r *ReportRepository) GetByTarget(ctx context.Context, target moderation.Target) ([]moderation.Report, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	return r.DB.reportsWhere(func(report moderation.Report) bool { return report.Target == target }), nil
+}
+
+func (r *ReportRepository) Summarize(ctx context.Context, status string, limit int, offset int) ([]moderation.Summary, int, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	byTarget := make(map[moderation.Target]*moderation.Summary)
+	summaries := make([]*moderation.Summary, 0)
+
+	for _, report := range r.DB.reportsWhere(func(report moderation.Report) bool { return report.Status == status }) {
+		s, ok := byTarget[report.Target]
+		if !ok {
+			s = &moderation.Summary{
+				Target:  report.Target,
+				Excerpt: r.DB.excerpt(report.Target),
+				Reasons: make([]string, 0),
+			}
+			byTarget[report.Target] = s
+			summaries = append(summaries, s)
+		}
+		s.Add(report)
+	}
+
+	slices.SortFunc(summaries, func(a, b *moderation.Summary) int {
+		return cmp.Or(
+			cmp.Compare(b.Reports, a.Reports),
+			b.LastReportedAt.Compare(a.LastReportedAt),
+			bytes.Compare(a.Target.ID[:], b.Target.ID[:]),
+		)
+	})
+
+	total := len(summaries)
+
+	page := make([]moderation.Summary, 0)
+	for _, s := range summaries[min(offset, total):min(offset+limit, total)] {
+		page = append(page, *s)
+	}
+
+	return page, total, nil
+}
+
+func (r *ReportRepository) Count(ctx context.Context) (map[string]int, error) {
+	r.DB.mu.RLock()
+	defer r.DB.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, report := range r.DB.reports {
+		counts[report.Status]++
+	}
+
+	return counts, nil
+}
+
+func (r *ReportRepository) Resolve(ctx context.Context, target moderation.Target, status string, moderatorID uuid.UUID, note string, at time.Time) (int, error) {
+	r.DB.mu.Lock()
+	defer r.DB.mu.Unlock()
+
+	at = at.UTC()
+	closed := 0
+
+	for i, report := range r.DB.reports {
+		if report.Target != target || report.Status != moderation.ReportOpen {
+			continue
+		}
+
+		report.Status = status
+		report.ResolvedBy = uuid.NullUUID{UUID: moderatorID, Valid: true}
+		report.ResolvedAt = &at
+		report.Note = note
+		r.DB.reports[i] = report
+		closed++
+	}
+
+	return closed, nil
+}
+
+// reportsWhere returns the reports matching keep, newest first. The caller
+// must hold the lock.
+func (db *DB) reportsWhere(keep func(moderation.Report) bool) []moderation.Report {
+	reports := make([]moderation.Report, 0)
+	for _, report := range db.reports {
+		if keep(report) {
+			reports = append(reports, report)
+		}
+	}
+
+	slices.SortFunc(reports, func(a, b moderation.Report) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), bytes.Compare(b.ID[:], a.ID[:]))
+	})
+
+	return reports
+}
+
+// targetExists reports whether the reported content exists, mirroring the
+// foreign keys. The caller must hold the lock.
+func (db *DB) targetExists(target moderation.Target) bool {
+	switch target.Type {
+	case moderation.TargetProduct:
+		_, ok := db.products[target.ID]
+		return ok
+	case moderation.TargetBid:
+		return slices.ContainsFunc(db.bids, func(b product.Bid) bool { return b.ID == target.ID })
+	}
+
+	return false
+}
+
+// excerpt is the title of a product or the message of a bid. The caller
+// must hold the lock.
+func (db *DB) excerpt(target moderation.Target) string {
+	if target.Type == moderation.TargetProduct {
+		return db.products[target.ID].Title
+	}
+
+	for _, b := range db.bids {
+		if b.ID == target.ID {
+			return b.BidMessage
+		}
+	}
+
+	return ""
+}
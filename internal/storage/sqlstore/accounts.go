@@ -0,0 +1,152 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/google/uuid"
+)
+
+const accountColumns = `id, username, password, role, verified`
+
+var _ account.AccountRepository = (*AccountRepository)(nil)
+
+type AccountRepository struct {
+	DB *DB
+}
+
+func NewAccountRepository(db *DB) *AccountRepository {
+	return &AccountRepository{
+		DB: db,
+	}
+}
+
+func scanAccount(row interface{ Scan(...any) error }, acc *account.Account) error {
+	return row.Scan(&acc.ID, &acc.Username, &acc.Password, &acc.Role, &acc.Verified)
+}
+
+func (r *AccountRepository) GetAll(ctx context.Context) ([]account.Account, error) {
+	sql := `SELECT ` + accountColumns + ` FROM accounts ORDER BY username;`
+
+	rows, err := r.DB.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := make([]account.Account, 0)
+
+	for rows.Next() {
+		var acc account.Account
+		if err = scanAccount(rows, &acc); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, acc)
+	}
+
+	return accounts, rows.Err()
+}
+
+func (r *AccountRepository) GetByID(ctx context.Context, id uuid.UUID) (*account.Account, error) {
+	sql := `SELECT ` + accountColumns + ` FROM accounts WHERE id = $1;`
+
+	return r.getOne(ctx, sql, id)
+}
+
+func (r *AccountRepository) GetByUsername(ctx context.Context, username string) (*account.Account, error) {
+	sql := `SELECT ` + accountColumns + ` FROM accounts WHERE username = $1;`
+
+	return r.getOne(ctx, sql, username)
+}
+
+func (r *AccountRepository) getOne(ctx context.Context, query string, arg any) (*account.Account, error) {
+	var acc account.Account
+
+	err := scanAccount(r.DB.QueryRowContext(ctx, query, arg), &acc)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, account.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &acc, nil
+}
+
+func (r *AccountRepository) Create(ctx context.Context, acc *account.Account) error {
+	sql := `
+		INSERT INTO accounts
+		(id, username, password, role)
+		VALUES ($1, $2, $3, $4);
+	`
+
+	id := uuid.New()
+
+	_, err := r.DB.ExecContext(ctx, sql, id, acc.Username, acc.Password, account.RoleUser)
+	if r.DB.Dialect.IsUniqueViolation(err) {
+		return account.ErrUsernameTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	acc.ID = id
+	acc.Role = account.RoleUser
+	acc.Verified = false
+
+	return nil
+}
+
+func (r *AccountRepository) Update(ctx context.Context, acc *account.Account) error {
+	sql := `
+		UPDATE accounts
+		SET username = $1,
+			password = $2
+		WHERE id = $3;
+	`
+
+	result, err := r.DB.ExecContext(ctx, sql, acc.Username, acc.Password, acc.ID)
+	if r.DB.Dialect.IsUniqueViolation(err) {
+		return account.ErrUsernameTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	return expectOne(result, account.ErrNotFound)
+}
+
+func (r *AccountRepository) SetRole(ctx context.Context, id uuid.UUID, role string) error {
+	sql := `UPDATE accounts SET role = $1 WHERE id = $2;`
+
+	result, err := r.DB.ExecContext(ctx, sql, role, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOne(result, account.ErrNotFound)
+}
+
+func (r *AccountRepository) SetVerified(ctx context.Context, id uuid.UUID, verified bool) error {
+	sql := `UPDATE accounts SET verified = $1 WHERE id = $2;`
+
+	result, err := r.DB.ExecContext(ctx, sql, verified, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOne(result, account.ErrNotFound)
+}
+
+func (r *AccountRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	sql := `DELETE FROM accounts WHERE id = $1;`
+
+	result, err := r.DB.ExecContext(ctx, sql, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOne(result, account.ErrNotFound)
+}
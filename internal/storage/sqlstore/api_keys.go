@@ -0,0 +1,118 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/apikey"
+	"github.com/google/uuid"
+)
+
+var _ apikey.ApiKeyRepository = (*ApiKeyRepository)(nil)
+
+type ApiKeyRepository struct {
+	DB *DB
+}
+
+func NewApiKeyRepository(db *DB) *ApiKeyRepository {
+	return &ApiKeyRepository{
+		DB: db,
+	}
+}
+
+func (r *ApiKeyRepository) Create(ctx context.Context, key *apikey.ApiKey) error {
+	sql := `
+		INSERT INTO api_keys
+		(id, account_id, name, prefix, hash, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+
+	id := uuid.New()
+	now := time.Now().UTC()
+
+	_, err := r.DB.ExecContext(ctx, sql, id, key.AccountID, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, " "), now)
+	if r.DB.Dialect.IsForeignKeyViolation(err) {
+		return apikey.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	key.ID = id
+	key.CreatedAt = now
+
+	return nil
+}
+
+func (r *ApiKeyRepository) GetByAccount(ctx context.Context, accountID uuid.UUID) ([]apikey.ApiKey, error) {
+	sql := `
+		SELECT id, account_id, name, prefix, scopes, created_at, last_used_at, revoked_at
+		FROM api_keys
+		WHERE account_id = $1
+		ORDER BY created_at;
+	`
+
+	rows, err := r.DB.QueryContext(ctx, sql, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]apikey.ApiKey, 0)
+
+	for rows.Next() {
+		var key apikey.ApiKey
+		var scopes string
+		if err = rows.Scan(&key.ID, &key.AccountID, &key.Name, &key.Prefix, &scopes, &key.CreatedAt, &key.LastUsedAt, &key.RevokedAt); err != nil {
+			return nil, err
+		}
+		key.Scopes = strings.Fields(scopes)
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *ApiKeyRepository) Revoke(ctx context.Context, id uuid.UUID, accountID uuid.UUID) error {
+	sql := `
+		UPDATE api_keys
+		SET revoked_at = $3
+		WHERE id = $1 AND account_id = $2 AND revoked_at IS NULL;
+	`
+
+	result, err := r.DB.ExecContext(ctx, sql, id, accountID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	return expectOne(result, apikey.ErrNotFound)
+}
+
+func (r *ApiKeyRepository) Use(ctx context.Context, hash string, at time.Time) (*apikey.ApiKey, error) {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE hash = $1 AND revoked_at IS NULL
+		RETURNING id, account_id, name, prefix, scopes, created_at, last_used_at;
+	`
+
+	var key apikey.ApiKey
+	var scopes string
+
+	err := r.DB.QueryRowContext(ctx, query, hash, at.UTC()).
+		Scan(&key.ID, &key.AccountID, &key.Name, &key.Prefix, &scopes, &key.CreatedAt, &key.LastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apikey.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key.Hash = hash
+	key.Scopes = strings.Fields(scopes)
+
+	return &key, nil
+}
@@ -0,0 +1,314 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/google/uuid"
+)
+
+const bidColumns = `id, account_id, product_id, bid_minor, currency, bid_message, status, ip, user_agent, created_at, retracted_at, retraction_reason`
+
+var _ product.BidRepository = (*BidRepository)(nil)
+
+type BidRepository struct {
+	DB *DB
+}
+
+func NewBidRepository(db *DB) *BidRepository {
+	return &BidRepository{
+		DB: db,
+	}
+}
+
+func scanBid(row interface{ Scan(...any) error }, b *product.Bid) error {
+	return row.Scan(&b.ID, &b.AccountID, &b.ProductID, &b.BidValue.Amount, &b.BidValue.Currency, &b.BidMessage, &b.Status, &b.IP, &b.UserAgent, &b.CreatedAt, &b.RetractedAt, &b.RetractionReason)
+}
+
+func (r *BidRepository) Create(ctx context.Context, b *product.Bid) error {
+	return r.DB.InTx(ctx, func(tx *Tx) error {
+		// lock the product so concurrent bids on it are placed one at a
+		// time, and it cannot be closed or moved in the meantime
+		var publishedAt, endsAt, closedAt *time.Time
+
+		query := `SELECT published_at, ends_at, closed_at FROM products WHERE id = $1` + tx.Dialect.ForUpdate() + `;`
+
+		err := tx.QueryRowContext(ctx, query, b.ProductID).Scan(&publishedAt, &endsAt, &closedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return product.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+
+		switch {
+		case publishedAt == nil:
+			return product.ErrNotPublished
+		case closedAt != nil, endsAt != nil && !now.Before(*endsAt):
+			return product.ErrAuctionEnded
+		}
+
+		var active sql.NullInt64
+
+		err = tx.QueryRowContext(ctx, `SELECT bid_minor FROM account_bid WHERE product_id = $1 AND status = $2;`, b.ProductID, product.BidActive).Scan(&active)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if active.Valid && b.BidValue.Amount <= active.Int64 {
+			return product.ErrBidTooLow
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE account_bid SET status = $1 WHERE product_id = $2 AND status = $3;`, product.BidOutbid, b.ProductID, product.BidActive)
+		if err != nil {
+			return err
+		}
+
+		sql := `
+			INSERT INTO account_bid
+			(id, account_id, product_id, bid_minor, currency, bid_message, status, ip, user_agent, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+		`
+
+		id := uuid.New()
+
+		_, err = tx.ExecContext(ctx, sql, id, b.AccountID, b.ProductID, b.BidValue.Amount, b.BidValue.Currency, b.BidMessage, product.BidActive, b.IP, b.UserAgent, now)
+		if tx.Dialect.IsForeignKeyViolation(err) {
+			return product.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		b.ID = id
+		b.Status = product.BidActive
+		b.CreatedAt = now
+
+		return nil
+	})
+}
+
+func (r *BidRepository) GetByID(ctx context.Context, id uuid.UUID) (*product.Bid, error) {
+	query := `SELECT ` + bidColumns + ` FROM account_bid WHERE id = $1;`
+
+	var b product.Bid
+
+	err := scanBid(r.DB.QueryRowContext(ctx, query, id), &b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, product.ErrBidNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (r *BidRepository) GetByAccount(ctx context.Context, accountID uuid.UUID) ([]product.Bid, error) {
+	sql := `SELECT ` + bidColumns + ` FROM account_bid WHERE account_id = $1 ORDER BY created_at DESC, id DESC;`
+
+	return r.getMany(ctx, sql, accountID)
+}
+
+func (r *BidRepository) GetByProduct(ctx context.Context, productID uuid.UUID, limit int, offset int) ([]product.Bid, int, error) {
+	var total int
+
+	err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM account_bid WHERE product_id = $1;`, productID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sql := `
+		SELECT ` + bidColumns + ` FROM account_bid
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3;
+	`
+
+	bids, err := r.getMany(ctx, sql, productID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return bids, total, nil
+}
+
+func (r *BidRepository) getMany(ctx context.Context, query string, args ...any) ([]product.Bid, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bids := make([]product.Bid, 0)
+
+	for rows.Next() {
+		var b product.Bid
+		if err = scanBid(rows, &b); err != nil {
+			return nil, err
+		}
+		bids = append(bids, b)
+	}
+
+	return bids, rows.Err()
+}
+
+func (r *BidRepository) Get(ctx context.Context, accountID uuid.UUID, productID uuid.UUID) (*product.Bid, error) {
+	query := `
+		SELECT ` + bidColumns + ` FROM account_bid
+		WHERE account_id = $1 AND product_id = $2
+		ORDER BY bid_minor DESC, created_at, id
+		LIMIT 1;
+	`
+
+	var b product.Bid
+
+	err := scanBid(r.DB.QueryRowContext(ctx, query, accountID, productID), &b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, product.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (r *BidRepository) Retract(ctx context.Context, id uuid.UUID, reason string, at time.Time, limit product.RetractionLimit) (*product.Bid, error) {
+	var promoted *product.Bid
+
+	err := r.DB.InTx(ctx, func(tx *Tx) error {
+		var productID, accountID uuid.UUID
+		var status string
+
+		err := tx.QueryRowContext(ctx, `SELECT product_id, account_id, status FROM account_bid WHERE id = $1;`, id).Scan(&productID, &accountID, &status)
+		if errors.Is(err, sql.ErrNoRows) {
+			return product.ErrBidNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// lock the bids of the account, so its retractions are counted one
+		// at a time
+		if _, err = tx.ExecContext(ctx, `SELECT id FROM account_bid WHERE account_id = $1`+tx.Dialect.ForUpdate()+`;`, accountID); err != nil {
+			return err
+		}
+
+		// lock the product like Create does, then reread the status under it
+		err = tx.QueryRowContext(ctx, `SELECT id FROM products WHERE id = $1`+tx.Dialect.ForUpdate()+`;`, productID).Scan(&productID)
+		if err != nil {
+			return err
+		}
+
+		if err = tx.QueryRowContext(ctx, `SELECT status FROM account_bid WHERE id = $1;`, id).Scan(&status); err != nil {
+			return err
+		}
+
+		if status == product.BidRetracted || status == product.BidWinning {
+			return product.ErrBidNotRetractable
+		}
+
+		var count int
+
+		err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM account_bid WHERE account_id = $1 AND retracted_at >= $2;`, accountID, limit.Since).Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if count >= limit.Max {
+			return product.ErrRetractionLimit
+		}
+
+		update := `
+			UPDATE account_bid
+			SET status = $1,
+				retracted_at = $2,
+				retraction_reason = $3
+			WHERE id = $4;
+		`
+
+		if _, err = tx.ExecContext(ctx, update, product.BidRetracted, at, reason, id); err != nil {
+			return err
+		}
+
+		if status != product.BidActive {
+			return nil
+		}
+
+		query := `
+			SELECT ` + bidColumns + ` FROM account_bid
+			WHERE product_id = $1 AND status = $2
+			ORDER BY bid_minor DESC, created_at, id
+			LIMIT 1;
+		`
+
+		var next product.Bid
+
+		err = scanBid(tx.QueryRowContext(ctx, query, productID, product.BidOutbid), &next)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, `UPDATE account_bid SET status = $1 WHERE id = $2;`, product.BidActive, next.ID); err != nil {
+			return err
+		}
+
+		next.Status = product.BidActive
+		promoted = &next
+
+		return nil
+	})
+
+	return promoted, err
+}
+
+func (r *BidRepository) CountRetractions(ctx context.Context, accountID uuid.UUID, since time.Time) (int, error) {
+	sql := `SELECT COUNT(*) FROM account_bid WHERE account_id = $1 AND retracted_at >= $2;`
+
+	var count int
+	err := r.DB.QueryRowContext(ctx, sql, accountID, since).Scan(&count)
+
+	return count, err
+}
+
+func (r *BidRepository) RemoveMessage(ctx context.Context, id uuid.UUID) error {
+	sql := `UPDATE account_bid SET bid_message = $1 WHERE id = $2;`
+
+	result, err := r.DB.ExecContext(ctx, sql, product.RemovedMessage, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOne(result, product.ErrBidNotFound)
+}
+
+func (r *BidRepository) Bidders(ctx context.Context, productID uuid.UUID) ([]uuid.UUID, error) {
+	sql := `SELECT DISTINCT account_id FROM account_bid WHERE product_id = $1;`
+
+	rows, err := r.DB.QueryContext(ctx, sql, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bidders := make([]uuid.UUID, 0)
+
+	for rows.Next() {
+		var id uuid.UUID
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		bidders = append(bidders, id)
+	}
+
+	return bidders, rows.Err()
+}
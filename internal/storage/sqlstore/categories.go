@@ -0,0 +1,122 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/google/uuid"
+)
+
+var _ category.CategoryRepository = (*CategoryRepository)(nil)
+
+type CategoryRepository struct {
+	DB *DB
+}
+
+func NewCategoryRepository(db *DB) *CategoryRepository {
+	return &CategoryRepository{
+		DB: db,
+	}
+}
+
+func (r *CategoryRepository) GetAll(ctx context.Context) ([]category.Category, error) {
+	sql := `SELECT id, parent_id, name FROM categories ORDER BY lower(name), id;`
+
+	rows, err := r.DB.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]category.Category, 0)
+
+	for rows.Next() {
+		var c category.Category
+		if err = rows.Scan(&c.ID, &c.ParentID, &c.Name); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+func (r *CategoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*category.Category, error) {
+	query := `SELECT id, parent_id, name FROM categories WHERE id = $1;`
+
+	var c category.Category
+
+	err := r.DB.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.ParentID, &c.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, category.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (r *CategoryRepository) Create(ctx context.Context, c *category.Category) error {
+	sql := `INSERT INTO categories (id, parent_id, name) VALUES ($1, $2, $3);`
+
+	id := uuid.New()
+
+	_, err := r.DB.ExecContext(ctx, sql, id, c.ParentID, c.Name)
+	if err = categoryError(r.DB.Dialect, err); err != nil {
+		return err
+	}
+
+	c.ID = id
+
+	return nil
+}
+
+func (r *CategoryRepository) Update(ctx context.Context, c *category.Category) error {
+	sql := `UPDATE categories SET parent_id = $1, name = $2 WHERE id = $3;`
+
+	result, err := r.DB.ExecContext(ctx, sql, c.ParentID, c.Name, c.ID)
+	if err = categoryError(r.DB.Dialect, err); err != nil {
+		return err
+	}
+
+	return expectOne(result, category.ErrNotFound)
+}
+
+func (r *CategoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	sql := `DELETE FROM categories WHERE id = $1;`
+
+	result, err := r.DB.ExecContext(ctx, sql, id)
+	if r.DB.Dialect.IsForeignKeyViolation(err) {
+		return category.ErrInUse
+	}
+	if err != nil {
+		return err
+	}
+
+	return expectOne(result, category.ErrNotFound)
+}
+
+func (r *CategoryRepository) HasProducts(ctx context.Context, id uuid.UUID) (bool, error) {
+	sql := `SELECT EXISTS (SELECT 1 FROM products WHERE category_id = $1);`
+
+	var exists bool
+	err := r.DB.QueryRowContext(ctx, sql, id).Scan(&exists)
+
+	return exists, err
+}
+
+// categoryError maps the constraint violations of inserting or updating a
+// category: a missing parent and a name taken by a sibling.
+func categoryError(dialect Dialect, err error) error {
+	switch {
+	case dialect.IsForeignKeyViolation(err):
+		return category.ErrNotFound
+	case dialect.IsUniqueViolation(err):
+		return category.ErrNameTaken
+	}
+
+	return err
+}
@@ -0,0 +1,75 @@
+package sqlstore
+
+import (
+	"context"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/exchange"
+)
+
+var _ exchange.RateRepository = (*RateRepository)(nil)
+
+type RateRepository struct {
+	DB *DB
+}
+
+func NewRateRepository(db *DB) *RateRepository {
+	return &RateRepository{
+		DB: db,
+	}
+}
+
+func (r *RateRepository) GetAll(ctx context.Context) ([]exchange.Rate, error) {
+	sql := `SELECT base, quote, rate, updated_at FROM exchange_rates ORDER BY base, quote;`
+
+	rows, err := r.DB.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := make([]exchange.Rate, 0)
+
+	for rows.Next() {
+		var rate exchange.Rate
+		if err = rows.Scan(&rate.Base, &rate.Quote, &rate.Rate, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		// Postgres pads NUMERIC with trailing zeros
+		parsed, err := exchange.ParseRate(rate.Rate)
+		if err != nil {
+			return nil, err
+		}
+		rate.Rate = exchange.FormatRate(parsed)
+
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
+
+func (r *RateRepository) Set(ctx context.Context, rate *exchange.Rate) error {
+	sql := `
+		INSERT INTO exchange_rates
+		(base, quote, rate, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (base, quote) DO UPDATE
+		SET rate = excluded.rate,
+			updated_at = excluded.updated_at;
+	`
+
+	_, err := r.DB.ExecContext(ctx, sql, rate.Base, rate.Quote, rate.Rate, rate.UpdatedAt)
+
+	return err
+}
+
+func (r *RateRepository) Delete(ctx context.Context, base string, quote string) error {
+	sql := `DELETE FROM exchange_rates WHERE base = $1 AND quote = $2;`
+
+	result, err := r.DB.ExecContext(ctx, sql, base, quote)
+	if err != nil {
+		return err
+	}
+
+	return expectOne(result, exchange.ErrNoRate)
+}
@@ -0,0 +1,170 @@
+package sqlstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/google/uuid"
+)
+
+// addRevision records changes to a product, if there are any.
+func addRevision(ctx context.Context, tx *Tx, productID uuid.UUID, editorID uuid.UUID, changes []product.Change, at time.Time) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	id := uuid.New()
+
+	sql := `INSERT INTO product_revisions (id, product_id, editor_id, created_at) VALUES ($1, $2, $3, $4);`
+	if _, err := tx.ExecContext(ctx, sql, id, productID, editorID, at); err != nil {
+		return err
+	}
+
+	sql = `
+		INSERT INTO product_revision_changes
+		(revision_id, position, field, old_value, new_value)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	for position, c := range changes {
+		if _, err := tx.ExecContext(ctx, sql, id, position, c.Field, c.Old, c.New); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ProductRepository) GetRevisions(ctx context.Context, productID uuid.UUID) ([]product.Revision, error) {
+	sql := `
+		SELECT id, product_id, editor_id, created_at
+		FROM product_revisions
+		WHERE product_id = $1
+		ORDER BY created_at DESC, id DESC;
+	`
+
+	rows, err := r.DB.QueryContext(ctx, sql, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]product.Revision, 0)
+
+	for rows.Next() {
+		var rev product.Revision
+		if err = rows.Scan(&rev.ID, &rev.ProductID, &rev.EditorID, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = r.loadChanges(ctx, revisions); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// loadChanges fills in the Changes of revisions.
+func (r *ProductRepository) loadChanges(ctx context.Context, revisions []product.Revision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	byID := make(map[uuid.UUID]*product.Revision, len(revisions))
+	placeholders := make([]string, len(revisions))
+	args := make([]any, len(revisions))
+
+	for i := range revisions {
+		revisions[i].Changes = make([]product.Change, 0)
+		byID[revisions[i].ID] = &revisions[i]
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = revisions[i].ID
+	}
+
+	query := `
+		SELECT revision_id, field, old_value, new_value
+		FROM product_revision_changes
+		WHERE revision_id IN (` + strings.Join(placeholders, `, `) + `)
+		ORDER BY revision_id, position;
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var c product.Change
+		if err = rows.Scan(&id, &c.Field, &c.Old, &c.New); err != nil {
+			return err
+		}
+		byID[id].Changes = append(byID[id].Changes, c)
+	}
+
+	return rows.Err()
+}
+
+func (r *ProductRepository) AddAddendum(ctx context.Context, a *product.Addendum, editorID uuid.UUID) error {
+	id := uuid.New()
+	now := time.Now().UTC()
+
+	err := r.DB.InTx(ctx, func(tx *Tx) error {
+		sql := `INSERT INTO product_addenda (id, product_id, text, created_at) VALUES ($1, $2, $3, $4);`
+
+		_, err := tx.ExecContext(ctx, sql, id, a.ProductID, a.Text, now)
+		if tx.Dialect.IsForeignKeyViolation(err) {
+			return product.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return addRevision(ctx, tx, a.ProductID, editorID, []product.Change{{Field: product.FieldAddendum, New: a.Text}}, now)
+	})
+	if err != nil {
+		return err
+	}
+
+	a.ID = id
+	a.CreatedAt = now
+
+	return nil
+}
+
+func (r *ProductRepository) GetAddenda(ctx context.Context, productID uuid.UUID) ([]product.Addendum, error) {
+	sql := `
+		SELECT id, product_id, text, created_at
+		FROM product_addenda
+		WHERE product_id = $1
+		ORDER BY created_at, id;
+	`
+
+	rows, err := r.DB.QueryContext(ctx, sql, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	addenda := make([]product.Addendum, 0)
+
+	for rows.Next() {
+		var a product.Addendum
+		if err = rows.Scan(&a.ID, &a.ProductID, &a.Text, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		addenda = append(addenda, a)
+	}
+
+	return addenda, rows.Err()
+}
@@ -0,0 +1,61 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/jwt"
+)
+
+var _ jwt.IdentityRepository = (*IdentityRepository)(nil)
+
+type IdentityRepository struct {
+	DB *DB
+}
+
+func NewIdentityRepository(db *DB) *IdentityRepository {
+	return &IdentityRepository{
+		DB: db,
+	}
+}
+
+func (r *IdentityRepository) Get(ctx context.Context, provider string, subject string) (*jwt.Identity, error) {
+	query := `
+		SELECT provider, subject, account_id, email, created_at
+		FROM account_identities
+		WHERE provider = $1 AND subject = $2;
+	`
+
+	var i jwt.Identity
+
+	err := r.DB.QueryRowContext(ctx, query, provider, subject).
+		Scan(&i.Provider, &i.Subject, &i.AccountID, &i.Email, &i.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, jwt.ErrIdentityNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+func (r *IdentityRepository) Create(ctx context.Context, i *jwt.Identity) error {
+	sql := `
+		INSERT INTO account_identities
+		(provider, subject, account_id, email, created_at)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	now := time.Now().UTC()
+
+	if _, err := r.DB.ExecContext(ctx, sql, i.Provider, i.Subject, i.AccountID, i.Email, now); err != nil {
+		return err
+	}
+
+	i.CreatedAt = now
+
+	return nil
+}
@@ -0,0 +1,336 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/google/uuid"
+)
+
+var _ product.ImageRepository = (*ImageRepository)(nil)
+
+type ImageRepository struct {
+	DB *DB
+}
+
+func NewImageRepository(db *DB) *ImageRepository {
+	return &ImageRepository{
+		DB: db,
+	}
+}
+
+const imageColumns = `id, product_id, blob_key, content_type, size, position, status, created_at`
+
+func scanImage(row interface{ Scan(...any) error }, img *product.Image) error {
+	return row.Scan(&img.ID, &img.ProductID, &img.Key, &img.ContentType, &img.Size, &img.Position, &img.Status, &img.CreatedAt)
+}
+
+func (r *ImageRepository) Create(ctx context.Context, img *product.Image) error {
+	now := time.Now().UTC()
+
+	return r.DB.InTx(ctx, func(tx *Tx) error {
+		// locking the product serializes uploads, so positions do not clash
+		if err := lockProduct(ctx, tx, img.ProductID); err != nil {
+			return err
+		}
+
+		var position int
+
+		query := `SELECT COALESCE(MAX(position) + 1, 0) FROM product_images WHERE product_id = $1;`
+		if err := tx.QueryRowContext(ctx, query, img.ProductID).Scan(&position); err != nil {
+			return err
+		}
+
+		sql := `INSERT INTO product_images (` + imageColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);`
+		if _, err := tx.ExecContext(ctx, sql, img.ID, img.ProductID, img.Key, img.ContentType, img.Size, position, product.ImageProcessing, now); err != nil {
+			return err
+		}
+
+		img.Position = position
+		img.Status = product.ImageProcessing
+		img.CreatedAt = now
+		img.Variants = make(map[string]product.Variant)
+
+		return nil
+	})
+}
+
+func (r *ImageRepository) GetByID(ctx context.Context, id uuid.UUID) (*product.Image, error) {
+	query := `SELECT ` + imageColumns + ` FROM product_images WHERE id = $1;`
+
+	var img product.Image
+
+	err := scanImage(r.DB.QueryRowContext(ctx, query, id), &img)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, product.ErrImageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	images := []product.Image{img}
+	if err = r.loadVariants(ctx, images); err != nil {
+		return nil, err
+	}
+
+	return &images[0], nil
+}
+
+func (r *ImageRepository) GetByProduct(ctx context.Context, productID uuid.UUID) ([]product.Image, error) {
+	sql := `SELECT ` + imageColumns + ` FROM product_images WHERE product_id = $1 ORDER BY position;`
+
+	return r.getMany(ctx, sql, productID)
+}
+
+func (r *ImageRepository) GetByProducts(ctx context.Context, productIDs []uuid.UUID) (map[uuid.UUID][]product.Image, error) {
+	byProduct := make(map[uuid.UUID][]product.Image, len(productIDs))
+	if len(productIDs) == 0 {
+		return byProduct, nil
+	}
+
+	placeholders := make([]string, len(productIDs))
+	args := make([]any, len(productIDs))
+
+	for i, id := range productIDs {
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = id
+	}
+
+	sql := `
+		SELECT ` + imageColumns + `
+		FROM product_images
+		WHERE product_id IN (` + strings.Join(placeholders, `, `) + `)
+		ORDER BY product_id, position;
+	`
+
+	images, err := r.getMany(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, img := range images {
+		byProduct[img.ProductID] = append(byProduct[img.ProductID], img)
+	}
+
+	return byProduct, nil
+}
+
+func (r *ImageRepository) GetProcessing(ctx context.Context) ([]product.Image, error) {
+	sql := `SELECT ` + imageColumns + ` FROM product_images WHERE status = $1 ORDER BY created_at, id;`
+
+	return r.getMany(ctx, sql, product.ImageProcessing)
+}
+
+func (r *ImageRepository) SetVariants(ctx context.Context, id uuid.UUID, variants map[string]product.Variant) error {
+	return r.DB.InTx(ctx, func(tx *Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE product_images SET status = $1 WHERE id = $2;`, product.ImageReady, id)
+		if err != nil {
+			return err
+		}
+
+		if err = expectOne(result, product.ErrImageNotFound); err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM product_image_variants WHERE image_id = $1;`, id); err != nil {
+			return err
+		}
+
+		sql := `
+			INSERT INTO product_image_variants
+			(image_id, size, blob_key, content_type, width, height, webp_key)
+			VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''));
+		`
+
+		for size, v := range variants {
+			if _, err = tx.ExecContext(ctx, sql, id, size, v.Key, v.ContentType, v.Width, v.Height, v.WebPKey); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *ImageRepository) SetStatus(ctx context.Context, id uuid.UUID, status string) error {
+	result, err := r.DB.ExecContext(ctx, `UPDATE product_images SET status = $1 WHERE id = $2;`, status, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOne(result, product.ErrImageNotFound)
+}
+
+func (r *ImageRepository) getMany(ctx context.Context, query string, args ...any) ([]product.Image, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := make([]product.Image, 0)
+
+	for rows.Next() {
+		var img product.Image
+		if err = scanImage(rows, &img); err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = r.loadVariants(ctx, images); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
+
+// loadVariants fills in the Variants of images.
+func (r *ImageRepository) loadVariants(ctx context.Context, images []product.Image) error {
+	if len(images) == 0 {
+		return nil
+	}
+
+	byID := make(map[uuid.UUID]*product.Image, len(images))
+	placeholders := make([]string, len(images))
+	args := make([]any, len(images))
+
+	for i := range images {
+		images[i].Variants = make(map[string]product.Variant)
+		byID[images[i].ID] = &images[i]
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = images[i].ID
+	}
+
+	query := `
+		SELECT image_id, size, blob_key, content_type, width, height, COALESCE(webp_key, '')
+		FROM product_image_variants
+		WHERE image_id IN (` + strings.Join(placeholders, `, `) + `);
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var size string
+		var v product.Variant
+		if err = rows.Scan(&id, &size, &v.Key, &v.ContentType, &v.Width, &v.Height, &v.WebPKey); err != nil {
+			return err
+		}
+		byID[id].Variants[size] = v
+	}
+
+	return rows.Err()
+}
+
+func (r *ImageRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.DB.InTx(ctx, func(tx *Tx) error {
+		var productID uuid.UUID
+		var position int
+
+		query := `SELECT product_id, position FROM product_images WHERE id = $1;`
+
+		err := tx.QueryRowContext(ctx, query, id).Scan(&productID, &position)
+		if errors.Is(err, sql.ErrNoRows) {
+			return product.ErrImageNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = lockProduct(ctx, tx, productID); err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM product_images WHERE id = $1;`, id); err != nil {
+			return err
+		}
+
+		update := `UPDATE product_images SET position = position - 1 WHERE product_id = $1 AND position > $2;`
+		_, err = tx.ExecContext(ctx, update, productID, position)
+
+		return err
+	})
+}
+
+func (r *ImageRepository) Reorder(ctx context.Context, productID uuid.UUID, ids []uuid.UUID) error {
+	return r.DB.InTx(ctx, func(tx *Tx) error {
+		if err := lockProduct(ctx, tx, productID); err != nil {
+			return err
+		}
+
+		rows, err := tx.QueryContext(ctx, `SELECT id FROM product_images WHERE product_id = $1;`, productID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var current []uuid.UUID
+
+		for rows.Next() {
+			var id uuid.UUID
+			if err = rows.Scan(&id); err != nil {
+				return err
+			}
+			current = append(current, id)
+		}
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		if !sameImages(current, ids) {
+			return product.ErrInvalidOrder
+		}
+
+		for position, id := range ids {
+			if _, err = tx.ExecContext(ctx, `UPDATE product_images SET position = $1 WHERE id = $2;`, position, id); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// lockProduct locks the row of a product until tx ends, or returns
+// ErrNotFound.
+func lockProduct(ctx context.Context, tx *Tx, id uuid.UUID) error {
+	query := `SELECT id FROM products WHERE id = $1` + tx.Dialect.ForUpdate() + `;`
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return product.ErrNotFound
+	}
+
+	return err
+}
+
+// sameImages reports whether ids lists each of current exactly once.
+func sameImages(current []uuid.UUID, ids []uuid.UUID) bool {
+	if len(current) != len(ids) {
+		return false
+	}
+
+	for i, id := range ids {
+		if !slices.Contains(current, id) || slices.Contains(ids[:i], id) {
+			return false
+		}
+	}
+
+	return true
+}
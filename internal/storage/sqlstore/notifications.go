@@ -0,0 +1,68 @@
+package sqlstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/notification"
+	"github.com/google/uuid"
+)
+
+var _ notification.NotificationRepository = (*NotificationRepository)(nil)
+
+type NotificationRepository struct {
+	DB *DB
+}
+
+func NewNotificationRepository(db *DB) *NotificationRepository {
+	return &NotificationRepository{
+		DB: db,
+	}
+}
+
+func (r *NotificationRepository) Create(ctx context.Context, n *notification.Notification) error {
+	sql := `
+		INSERT INTO notifications
+		(id, account_id, kind, message, product_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`
+
+	id := uuid.New()
+	now := time.Now().UTC()
+
+	if _, err := r.DB.ExecContext(ctx, sql, id, n.AccountID, n.Kind, n.Message, n.ProductID, now); err != nil {
+		return err
+	}
+
+	n.ID = id
+	n.CreatedAt = now
+
+	return nil
+}
+
+func (r *NotificationRepository) GetByAccount(ctx context.Context, accountID uuid.UUID) ([]notification.Notification, error) {
+	sql := `
+		SELECT id, account_id, kind, message, product_id, created_at
+		FROM notifications
+		WHERE account_id = $1
+		ORDER BY created_at DESC, id DESC;
+	`
+
+	rows, err := r.DB.QueryContext(ctx, sql, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]notification.Notification, 0)
+
+	for rows.Next() {
+		var n notification.Notification
+		if err = rows.Scan(&n.ID, &n.AccountID, &n.Kind, &n.Message, &n.ProductID, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
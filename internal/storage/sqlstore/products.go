@@ -0,0 +1,619 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/google/uuid"
+)
+
+const productColumns = `p.id, p.account_id, p.title, p.description, p.price_minor, p.currency, p.category_id, p.ends_at, p.created_at, p.published_at, p.review_status, p.review_reason, ` + productBidCount
+
+const productBidCount = `(SELECT COUNT(*) FROM account_bid b WHERE b.product_id = p.id AND b.status <> 'retracted')`
+
+var _ product.ProductRepository = (*ProductRepository)(nil)
+
+type ProductRepository struct {
+	DB *DB
+}
+
+func NewProductRepository(db *DB) *ProductRepository {
+	return &ProductRepository{
+		DB: db,
+	}
+}
+
+func scanProduct(row interface{ Scan(...any) error }, p *product.Product) error {
+	return row.Scan(productFields(p)...)
+}
+
+// productFields returns the destinations of productColumns.
+func productFields(p *product.Product) []any {
+	return []any{&p.ID, &p.AccountID, &p.Title, &p.Description, &p.Price.Amount, &p.Price.Currency, &p.CategoryID, &p.EndsAt, &p.CreatedAt, &p.PublishedAt, &p.ReviewStatus, &p.ReviewReason, &p.BidCount}
+}
+
+func (r *ProductRepository) GetAll(ctx context.Context) ([]product.Product, error) {
+	sql := `SELECT ` + productColumns + ` FROM products p;`
+
+	return r.getMany(ctx, sql)
+}
+
+func (r *ProductRepository) List(ctx context.Context, opts product.ListOptions) (*product.ProductPage, error) {
+	var where []string
+	var args []any
+
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	now := opts.Now.UTC()
+
+	if opts.Drafts {
+		where = append(where, `p.published_at IS NULL`)
+	} else {
+		where = append(where, `p.published_at IS NOT NULL`)
+	}
+
+	if opts.MinPrice != nil {
+		where = append(where, `p.currency = `+arg(opts.MinPrice.Currency)+` AND p.price_minor >= `+arg(opts.MinPrice.Amount))
+	}
+
+	if opts.MaxPrice != nil {
+		where = append(where, `p.currency = `+arg(opts.MaxPrice.Currency)+` AND p.price_minor <= `+arg(opts.MaxPrice.Amount))
+	}
+
+	if opts.Seller.Valid {
+		where = append(where, `p.account_id = `+arg(opts.Seller.UUID))
+	}
+
+	if len(opts.Categories) > 0 {
+		ids := make([]string, 0, len(opts.Categories))
+		for _, id := range opts.Categories {
+			ids = append(ids, arg(id))
+		}
+		where = append(where, `p.category_id IN (`+strings.Join(ids, `, `)+`)`)
+	}
+
+	if opts.Tag != "" {
+		where = append(where, `EXISTS (SELECT 1 FROM product_tags pt JOIN tags t ON t.id = pt.tag_id WHERE pt.product_id = p.id AND t.name = `+arg(opts.Tag)+`)`)
+	}
+
+	switch opts.Status {
+	case product.StatusOpen:
+		where = append(where, `(p.ends_at IS NULL OR p.ends_at > `+arg(now)+`)`)
+	case product.StatusEnded:
+		where = append(where, `p.ends_at <= `+arg(now))
+	}
+
+	if opts.EndingBefore != nil {
+		where = append(where, `p.ends_at > `+arg(now)+` AND p.ends_at <= `+arg(opts.EndingBefore.UTC()))
+	}
+
+	if opts.Sort == product.SortEndingSoon {
+		where = append(where, `p.ends_at IS NOT NULL`)
+	}
+
+	page := &product.ProductPage{}
+
+	err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM products p`+whereClause(where)+`;`, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, desc := productOrder(opts.Sort)
+
+	if opts.After != nil {
+		var values []string
+		for _, v := range cursorValues(*opts.After) {
+			values = append(values, arg(v))
+		}
+
+		cmp := ` > `
+		if desc {
+			cmp = ` < `
+		}
+
+		where = append(where, `(`+strings.Join(keys, `, `)+`)`+cmp+`(`+strings.Join(values, `, `)+`)`)
+	}
+
+	order := strings.Join(keys, `, `)
+	if desc {
+		order = strings.Join(keys, ` DESC, `) + ` DESC`
+	}
+
+	query := `SELECT ` + productColumns + ` FROM products p` + whereClause(where) + ` ORDER BY ` + order + ` LIMIT ` + arg(opts.Limit+1) + `;`
+
+	page.Products, err = r.getMany(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Products) > opts.Limit {
+		page.Products = page.Products[:opts.Limit]
+		page.HasMore = true
+	}
+
+	return page, nil
+}
+
+// utc converts t to UTC. SQLite compares times as text, so they are all
+// written in one zone.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	u := t.UTC()
+
+	return &u
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return ` WHERE ` + strings.Join(conditions, ` AND `)
+}
+
+// productOrder returns the columns a listing is sorted by and whether they
+// descend.
+func productOrder(sort string) ([]string, bool) {
+	switch sort {
+	case product.SortEndingSoon:
+		return []string{`p.ends_at`, `p.id`}, false
+	case product.SortPriceAsc:
+		return []string{`p.currency`, `p.price_minor`, `p.id`}, false
+	case product.SortPriceDesc:
+		return []string{`p.currency`, `p.price_minor`, `p.id`}, true
+	case product.SortMostBids:
+		return []string{productBidCount, `p.id`}, true
+	}
+
+	return []string{`p.created_at`, `p.id`}, true
+}
+
+// cursorValues returns the values of c lined up with productOrder.
+func cursorValues(c product.Cursor) []any {
+	switch c.Sort {
+	case product.SortEndingSoon:
+		return []any{c.EndsAt.UTC(), c.ID}
+	case product.SortPriceAsc, product.SortPriceDesc:
+		return []any{c.Price.Currency, c.Price.Amount, c.ID}
+	case product.SortMostBids:
+		return []any{*c.BidCount, c.ID}
+	}
+
+	return []any{c.CreatedAt.UTC(), c.ID}
+}
+
+func (r *ProductRepository) GetByAccount(ctx context.Context, accountID uuid.UUID) ([]product.Product, error) {
+	sql := `
+		SELECT ` + productColumns + `
+		FROM products p
+		JOIN account_product ap ON ap.product_id = p.id
+		WHERE ap.account_id = $1;
+	`
+
+	return r.getMany(ctx, sql, accountID)
+}
+
+func (r *ProductRepository) getMany(ctx context.Context, query string, args ...any) ([]product.Product, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make([]product.Product, 0)
+
+	for rows.Next() {
+		var p product.Product
+		if err = scanProduct(rows, &p); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	ptrs := make([]*product.Product, len(products))
+	for i := range products {
+		ptrs[i] = &products[i]
+	}
+
+	return products, r.loadTags(ctx, ptrs...)
+}
+
+// loadTags fills in the tags of products.
+func (r *ProductRepository) loadTags(ctx context.Context, products ...*product.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	byID := make(map[uuid.UUID]*product.Product, len(products))
+	placeholders := make([]string, len(products))
+	args := make([]any, len(products))
+
+	for i, p := range products {
+		p.Tags = make([]string, 0)
+		byID[p.ID] = p
+		placeholders[i] = "$" + strconv.Itoa(i+1)
+		args[i] = p.ID
+	}
+
+	query := `
+		SELECT pt.product_id, t.name
+		FROM product_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.product_id IN (` + strings.Join(placeholders, `, `) + `)
+		ORDER BY t.name;
+	`
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var tag string
+		if err = rows.Scan(&id, &tag); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, tag)
+	}
+
+	return rows.Err()
+}
+
+// setTags replaces the tags of a product, creating the tags nobody used yet.
+func setTags(ctx context.Context, tx *Tx, productID uuid.UUID, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_tags WHERE product_id = $1;`, productID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err := tx.ExecContext(ctx, `INSERT INTO tags (id, name) VALUES ($1, $2) ON CONFLICT (name) DO NOTHING;`, uuid.New(), tag)
+		if err != nil {
+			return err
+		}
+
+		var tagID uuid.UUID
+		if err = tx.QueryRowContext(ctx, `SELECT id FROM tags WHERE name = $1;`, tag).Scan(&tagID); err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, `INSERT INTO product_tags (product_id, tag_id) VALUES ($1, $2);`, productID, tagID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *ProductRepository) GetByID(ctx context.Context, id uuid.UUID) (*product.Product, error) {
+	query := `SELECT ` + productColumns + ` FROM products p WHERE p.id = $1;`
+
+	var p product.Product
+
+	err := scanProduct(r.DB.QueryRowContext(ctx, query, id), &p)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, product.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = r.loadTags(ctx, &p); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (r *ProductRepository) Create(ctx context.Context, p *product.Product) error {
+	products := []product.Product{*p}
+	if err := r.CreateMany(ctx, products); err != nil {
+		return err
+	}
+
+	*p = products[0]
+
+	return nil
+}
+
+func (r *ProductRepository) CreateMany(ctx context.Context, products []product.Product) error {
+	// ids are only handed out once the transaction commits
+	created := slices.Clone(products)
+
+	err := r.DB.InTx(ctx, func(tx *Tx) error {
+		for i := range created {
+			if err := insertProduct(ctx, tx, &created[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	copy(products, created)
+
+	return nil
+}
+
+// insertProduct inserts p, its tags and its first revision, and sets its
+// ID and CreatedAt.
+func insertProduct(ctx context.Context, tx *Tx, p *product.Product) error {
+	sql := `
+		INSERT INTO products
+		(id, title, account_id, description, price_minor, currency, category_id, ends_at, created_at, published_at)
+		VALUES
+		($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+	`
+
+	id := uuid.New()
+	now := time.Now().UTC()
+
+	_, err := tx.ExecContext(ctx, sql, id, p.Title, p.AccountID, p.Description, p.Price.Amount, p.Price.Currency, p.CategoryID, utc(p.EndsAt), now, utc(p.PublishedAt))
+	if tx.Dialect.IsForeignKeyViolation(err) {
+		return product.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = setTags(ctx, tx, id, p.Tags); err != nil {
+		return err
+	}
+
+	if err = addRevision(ctx, tx, id, p.AccountID, product.Diff(nil, p), now); err != nil {
+		return err
+	}
+
+	p.ID = id
+	p.CreatedAt = now
+
+	return nil
+}
+
+func (r *ProductRepository) Update(ctx context.Context, p *product.Product, editorID uuid.UUID) error {
+	return r.DB.InTx(ctx, func(tx *Tx) error {
+		// locking the product keeps bids out until the edit is recorded
+		if err := lockProduct(ctx, tx, p.ID); err != nil {
+			return err
+		}
+
+		var existing product.Product
+
+		query := `SELECT ` + productColumns + ` FROM products p WHERE p.id = $1;`
+		if err := scanProduct(tx.QueryRowContext(ctx, query, p.ID), &existing); err != nil {
+			return err
+		}
+
+		tags, err := tagsOf(ctx, tx, p.ID)
+		if err != nil {
+			return err
+		}
+		existing.Tags = tags
+
+		changes := product.Diff(&existing, p)
+
+		if len(product.LockedChanges(changes)) > 0 {
+			var bids int
+
+			query = `SELECT COUNT(*) FROM account_bid WHERE product_id = $1;`
+			if err = tx.QueryRowContext(ctx, query, p.ID).Scan(&bids); err != nil {
+				return err
+			}
+
+			if bids > 0 {
+				return product.ErrLocked
+			}
+		}
+
+		update := `
+			UPDATE products
+			SET title = $1,
+				description = $2,
+				price_minor = $3,
+				currency = $4,
+				category_id = $5,
+				ends_at = $6
+			WHERE id = $7;
+		`
+
+		_, err = tx.ExecContext(ctx, update, p.Title, p.Description, p.Price.Amount, p.Price.Currency, p.CategoryID, utc(p.EndsAt), p.ID)
+		if tx.Dialect.IsForeignKeyViolation(err) {
+			return product.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = setTags(ctx, tx, p.ID, p.Tags); err != nil {
+			return err
+		}
+
+		p.AccountID = existing.AccountID
+
+		return addRevision(ctx, tx, p.ID, editorID, changes, time.Now().UTC())
+	})
+}
+
+// tagsOf returns the tags of a product, sorted.
+func tagsOf(ctx context.Context, tx *Tx, productID uuid.UUID) ([]string, error) {
+	query := `
+		SELECT t.name
+		FROM product_tags pt
+		JOIN tags t ON t.id = pt.tag_id
+		WHERE pt.product_id = $1
+		ORDER BY t.name;
+	`
+
+	rows, err := tx.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0)
+
+	for rows.Next() {
+		var tag string
+		if err = rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (r *ProductRepository) Publish(ctx context.Context, id uuid.UUID, at time.Time) error {
+	result, err := r.DB.ExecContext(ctx, `UPDATE products SET published_at = $1 WHERE id = $2 AND published_at IS NULL;`, at.UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	return r.changed(ctx, result, id, product.ErrAlreadyPublished)
+}
+
+func (r *ProductRepository) Submit(ctx context.Context, id uuid.UUID) error {
+	update := `UPDATE products SET review_status = $1, review_reason = '' WHERE id = $2 AND published_at IS NULL;`
+
+	result, err := r.DB.ExecContext(ctx, update, product.ReviewPending, id)
+	if err != nil {
+		return err
+	}
+
+	return r.changed(ctx, result, id, product.ErrAlreadyPublished)
+}
+
+func (r *ProductRepository) GetPending(ctx context.Context) ([]product.Product, error) {
+	sql := `SELECT ` + productColumns + ` FROM products p WHERE p.review_status = $1 ORDER BY p.created_at, p.id;`
+
+	return r.getMany(ctx, sql, product.ReviewPending)
+}
+
+func (r *ProductRepository) Approve(ctx context.Context, id uuid.UUID, reason string, at time.Time) error {
+	update := `
+		UPDATE products
+		SET review_status = $1, review_reason = $2, published_at = $3
+		WHERE id = $4 AND review_status = $5 AND published_at IS NULL;
+	`
+
+	result, err := r.DB.ExecContext(ctx, update, product.ReviewApproved, reason, at.UTC(), id, product.ReviewPending)
+	if err != nil {
+		return err
+	}
+
+	return r.changed(ctx, result, id, product.ErrNotPending)
+}
+
+func (r *ProductRepository) Reject(ctx context.Context, id uuid.UUID, reason string) error {
+	update := `
+		UPDATE products
+		SET review_status = $1, review_reason = $2, published_at = NULL
+		WHERE id = $3 AND (review_status = $4 OR published_at IS NOT NULL);
+	`
+
+	result, err := r.DB.ExecContext(ctx, update, product.ReviewRejected, reason, id, product.ReviewPending)
+	if err != nil {
+		return err
+	}
+
+	return r.changed(ctx, result, id, product.ErrNotPending)
+}
+
+// changed returns nil when result updated the product. Otherwise it returns
+// ErrNotFound if the product is missing, or unchanged.
+func (r *ProductRepository) changed(ctx context.Context, result sql.Result, id uuid.UUID, unchanged error) error {
+	err := expectOne(result, unchanged)
+	if !errors.Is(err, unchanged) {
+		return err
+	}
+
+	if _, err = r.GetByID(ctx, id); err != nil {
+		return err
+	}
+
+	return unchanged
+}
+
+func (r *ProductRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	sql := `DELETE FROM products WHERE id = $1;`
+
+	result, err := r.DB.ExecContext(ctx, sql, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOne(result, product.ErrNotFound)
+}
+
+func (r *ProductRepository) CloseEnded(ctx context.Context, now time.Time) (int, error) {
+	var closed int64
+
+	err := r.DB.InTx(ctx, func(tx *Tx) error {
+		// a replica closing the same auctions waits on these rows, then
+		// finds nothing left to close
+		sql := `
+			UPDATE account_bid
+			SET status = $1
+			WHERE status = $2 AND product_id IN (
+				SELECT id FROM products
+				WHERE closed_at IS NULL AND published_at IS NOT NULL AND ends_at <= $3
+			);
+		`
+
+		if _, err := tx.ExecContext(ctx, sql, product.BidWinning, product.BidActive, now.UTC()); err != nil {
+			return err
+		}
+
+		sql = `
+			UPDATE products
+			SET closed_at = $1
+			WHERE closed_at IS NULL AND published_at IS NOT NULL AND ends_at <= $1;
+		`
+
+		result, err := tx.ExecContext(ctx, sql, now.UTC())
+		if err != nil {
+			return err
+		}
+
+		closed, err = result.RowsAffected()
+		return err
+	})
+
+	return int(closed), err
+}
+
+func (r *ProductRepository) Associate(ctx context.Context, accountID uuid.UUID, productID uuid.UUID) error {
+	sql := `
+		INSERT INTO account_product (account_id, product_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING;
+	`
+
+	_, err := r.DB.ExecContext(ctx, sql, accountID, productID)
+	if r.DB.Dialect.IsForeignKeyViolation(err) {
+		return product.ErrNotFound
+	}
+
+	return err
+}
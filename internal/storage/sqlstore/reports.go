@@ -0,0 +1,257 @@
+package sqlstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/moderation"
+	"github.com/google/uuid"
+)
+
+var _ moderation.ReportRepository = (*ReportRepository)(nil)
+
+type ReportRepository struct {
+	DB *DB
+}
+
+func NewReportRepository(db *DB) *ReportRepository {
+	return &ReportRepository{
+		DB: db,
+	}
+}
+
+const reportColumns = `id, reporter_id, product_id, bid_id, reason, status, created_at, resolved_by, resolved_at, note`
+
+func scanReport(row interface{ Scan(...any) error }, r *moderation.Report) error {
+	var productID, bidID uuid.NullUUID
+
+	err := row.Scan(&r.ID, &r.ReporterID, &productID, &bidID, &r.Reason, &r.Status, &r.CreatedAt, &r.ResolvedBy, &r.ResolvedAt, &r.Note)
+	if err != nil {
+		return err
+	}
+
+	r.Target = targetOf(productID, bidID)
+
+	return nil
+}
+
+func targetOf(productID uuid.NullUUID, bidID uuid.NullUUID) moderation.Target {
+	if productID.Valid {
+		return moderation.Target{Type: moderation.TargetProduct, ID: productID.UUID}
+	}
+
+	return moderation.Target{Type: moderation.TargetBid, ID: bidID.UUID}
+}
+
+// targetColumn is the column referencing the content of target.
+func targetColumn(target moderation.Target) string {
+	if target.Type == moderation.TargetBid {
+		return `bid_id`
+	}
+
+	return `product_id`
+}
+
+func (r *ReportRepository) Create(ctx context.Context, report *moderation.Report) error {
+	sql := `
+		INSERT INTO content_reports
+		(id, reporter_id, product_id, bid_id, reason, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+
+	id := uuid.New()
+	now := time.Now().UTC()
+
+	var productID, bidID uuid.NullUUID
+
+	switch report.Target.Type {
+	case moderation.TargetProduct:
+		productID = uuid.NullUUID{UUID: report.Target.ID, Valid: true}
+	case moderation.TargetBid:
+		bidID = uuid.NullUUID{UUID: report.Target.ID, Valid: true}
+	default:
+		return moderation.ErrTargetNotFound
+	}
+
+	_, err := r.DB.ExecContext(ctx, sql, id, report.ReporterID, productID, bidID, report.Reason, moderation.ReportOpen, now)
+	switch {
+	case r.DB.Dialect.IsForeignKeyViolation(err):
+		return moderation.ErrTargetNotFound
+	case r.DB.Dialect.IsUniqueViolation(err):
+		return moderation.ErrAlreadyReported
+	case err != nil:
+		return err
+	}
+
+	report.ID = id
+	report.Status = moderation.ReportOpen
+	report.CreatedAt = now
+	report.ResolvedBy = uuid.NullUUID{}
+	report.ResolvedAt = nil
+	report.Note = ""
+
+	return nil
+}
+
+func (r *ReportRepository) GetByTarget(ctx context.Context, target moderation.Target) ([]moderation.Report, error) {
+	sql := `
+		SELECT ` + reportColumns + `
+		FROM content_reports
+		WHERE ` + targetColumn(target) + ` = $1
+		ORDER BY created_at DESC, id DESC;
+	`
+
+	return r.getMany(ctx, sql, target.ID)
+}
+
+func (r *ReportRepository) Summarize(ctx context.Context, status string, limit int, offset int) ([]moderation.Summary, int, error) {
+	var total int
+
+	query := `
+		SELECT COUNT(*) FROM (
+			SELECT 1 FROM content_reports WHERE status = $1 GROUP BY product_id, bid_id
+		) t;
+	`
+	if err := r.DB.QueryRowContext(ctx, query, status).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	sql := `
+		SELECT r.product_id, r.bid_id, COALESCE(p.title, b.bid_message, '')
+		FROM content_reports r
+		LEFT JOIN products p ON p.id = r.product_id
+		LEFT JOIN account_bid b ON b.id = r.bid_id
+		WHERE r.status = $1
+		GROUP BY r.product_id, r.bid_id, p.title, b.bid_message
+		ORDER BY COUNT(*) DESC, MAX(r.created_at) DESC, COALESCE(r.product_id, r.bid_id)
+		LIMIT $2 OFFSET $3;
+	`
+
+	rows, err := r.DB.QueryContext(ctx, sql, status, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	summaries := make([]moderation.Summary, 0)
+
+	for rows.Next() {
+		var productID, bidID uuid.NullUUID
+		var s moderation.Summary
+		if err = rows.Scan(&productID, &bidID, &s.Excerpt); err != nil {
+			return nil, 0, err
+		}
+		s.Target = targetOf(productID, bidID)
+		s.Reasons = make([]string, 0)
+		summaries = append(summaries, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	if err = r.addReports(ctx, summaries, status); err != nil {
+		return nil, 0, err
+	}
+
+	return summaries, total, nil
+}
+
+// addReports counts the reports in status on each of summaries into it.
+func (r *ReportRepository) addReports(ctx context.Context, summaries []moderation.Summary, status string) error {
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	byTarget := make(map[uuid.UUID]*moderation.Summary, len(summaries))
+	placeholders := make([]string, len(summaries))
+	args := []any{status}
+
+	for i := range summaries {
+		byTarget[summaries[i].Target.ID] = &summaries[i]
+		placeholders[i] = "$" + strconv.Itoa(i+2)
+		args = append(args, summaries[i].Target.ID)
+	}
+
+	in := strings.Join(placeholders, `, `)
+
+	query := `
+		SELECT ` + reportColumns + `
+		FROM content_reports
+		WHERE status = $1 AND (product_id IN (` + in + `) OR bid_id IN (` + in + `))
+		ORDER BY created_at DESC, id DESC;
+	`
+
+	reports, err := r.getMany(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		byTarget[report.Target.ID].Add(report)
+	}
+
+	return nil
+}
+
+func (r *ReportRepository) Count(ctx context.Context) (map[string]int, error) {
+	sql := `SELECT status, COUNT(*) FROM content_reports GROUP BY status;`
+
+	rows, err := r.DB.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+
+	for rows.Next() {
+		var status string
+		var n int
+		if err = rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+
+	return counts, rows.Err()
+}
+
+func (r *ReportRepository) Resolve(ctx context.Context, target moderation.Target, status string, moderatorID uuid.UUID, note string, at time.Time) (int, error) {
+	sql := `
+		UPDATE content_reports
+		SET status = $1, resolved_by = $2, resolved_at = $3, note = $4
+		WHERE ` + targetColumn(target) + ` = $5 AND status = $6;
+	`
+
+	result, err := r.DB.ExecContext(ctx, sql, status, moderatorID, at.UTC(), note, target.ID, moderation.ReportOpen)
+	if err != nil {
+		return 0, err
+	}
+
+	closed, err := result.RowsAffected()
+
+	return int(closed), err
+}
+
+func (r *ReportRepository) getMany(ctx context.Context, query string, args ...any) ([]moderation.Report, error) {
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]moderation.Report, 0)
+
+	for rows.Next() {
+		var report moderation.Report
+		if err = scanReport(rows, &report); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
@@ -0,0 +1,100 @@
+package sqlstore
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+)
+
+// searchConfig is the text search configuration created by the migrations:
+// portuguese stemming over unaccented words.
+const searchConfig = `'portuguese_unaccent'`
+
+// headlineOptions shape the snippets of ts_headline, marking matches with
+// the delimiters product.Highlight replaces.
+const headlineOptions = `StartSel=` + product.HighlightStart + `, StopSel=` + product.HighlightStop + `, MaxWords=30, MinWords=10`
+
+func (r *ProductRepository) Search(ctx context.Context, query string, limit int, offset int) ([]product.SearchResult, int, error) {
+	if !r.DB.Dialect.FullTextSearch() {
+		return r.searchWords(ctx, query, limit, offset)
+	}
+
+	var total int
+
+	err := r.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM products p WHERE p.published_at IS NOT NULL AND p.search @@ websearch_to_tsquery(`+searchConfig+`, $1);`, query).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sql := `
+		SELECT ` + productColumns + `,
+			ts_rank_cd(p.search, q),
+			ts_headline(` + searchConfig + `, translate(p.title || ' ' || p.description, $5, ''), q, $2)
+		FROM products p, websearch_to_tsquery(` + searchConfig + `, $1) q
+		WHERE p.published_at IS NOT NULL AND p.search @@ q
+		ORDER BY ts_rank_cd(p.search, q) DESC, p.id
+		LIMIT $3 OFFSET $4;
+	`
+
+	// the delimiters are removed from the text, so a seller cannot mark it
+	rows, err := r.DB.QueryContext(ctx, sql, query, headlineOptions, limit, offset, product.HighlightStart+product.HighlightStop)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := make([]product.SearchResult, 0)
+
+	for rows.Next() {
+		var res product.SearchResult
+		if err = rows.Scan(append(productFields(&res.Product), &res.Rank, &res.Snippet)...); err != nil {
+			return nil, 0, err
+		}
+		res.Snippet = product.Highlight(res.Snippet)
+		results = append(results, res)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	products := make([]*product.Product, len(results))
+	for i := range results {
+		products[i] = &results[i].Product
+	}
+
+	if err = r.loadTags(ctx, products...); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// searchWords narrows the products down in SQL with fold(), registered on
+// every SQLite connection, and ranks them with product.RankSearch.
+func (r *ProductRepository) searchWords(ctx context.Context, query string, limit int, offset int) ([]product.SearchResult, int, error) {
+	terms := product.SearchTerms(query)
+	if len(terms) == 0 {
+		return []product.SearchResult{}, 0, nil
+	}
+
+	where := []string{`p.published_at IS NOT NULL`}
+	var args []any
+
+	// terms are letters and digits only, so they need no LIKE escaping
+	for i, term := range terms {
+		args = append(args, "%"+term+"%")
+		where = append(where, `fold(p.title || ' ' || p.description) LIKE $`+strconv.Itoa(i+1))
+	}
+
+	products, err := r.getMany(ctx, `SELECT `+productColumns+` FROM products p`+whereClause(where)+`;`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	results, total := product.RankSearch(products, terms, limit, offset)
+
+	return results, total, nil
+}
@@ -0,0 +1,324 @@
+// Package sqlstore implements the repositories on top of database/sql. The
+// queries are shared by PostgreSQL and SQLite; a Dialect covers the places
+// where they differ.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/Nier704/arthur-leilao-server/internal/tracing"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type Dialect interface {
+	// Rebind rewrites the $n placeholders the queries are written with.
+	Rebind(query string) string
+	IsUniqueViolation(err error) bool
+	IsForeignKeyViolation(err error) bool
+	// ForUpdate is appended to a SELECT to lock the rows it reads until the
+	// transaction ends.
+	ForUpdate() string
+	// FullTextSearch reports whether products have the search column the
+	// migrations keep up to date.
+	FullTextSearch() bool
+	// System names the database in traces, as db.system.
+	System() string
+}
+
+// DB runs queries written for PostgreSQL against any dialect.
+type DB struct {
+	*sql.DB
+	Dialect Dialect
+}
+
+func New(conn *sql.DB, dialect Dialect) *DB {
+	return &DB{
+		DB:      conn,
+		Dialect: dialect,
+	}
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(ctx, db.Dialect, query)
+	defer span.End()
+
+	result, err := db.DB.ExecContext(ctx, db.Dialect.Rebind(query), args...)
+	tracing.RecordError(span, err)
+
+	return result, err
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*Rows, error) {
+	ctx, span := startQuery(ctx, db.Dialect, query)
+
+	rows, err := db.DB.QueryContext(ctx, db.Dialect.Rebind(query), args...)
+
+	return traceRows(span, rows, err)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	ctx, span := startQuery(ctx, db.Dialect, query)
+
+	return &Row{Row: db.DB.QueryRowContext(ctx, db.Dialect.Rebind(query), args...), span: span}
+}
+
+// Rows are the results of a query. The span of the query lasts until they
+// are closed, so it covers reading them.
+type Rows struct {
+	*sql.Rows
+	span trace.Span
+}
+
+func traceRows(span trace.Span, rows *sql.Rows, err error) (*Rows, error) {
+	if err != nil {
+		tracing.RecordError(span, err)
+		span.End()
+		return nil, err
+	}
+
+	return &Rows{Rows: rows, span: span}, nil
+}
+
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+
+	tracing.RecordError(r.span, r.Rows.Err())
+	r.span.End()
+
+	return err
+}
+
+// Row is the result of a query for a single row. The span of the query
+// lasts until it is scanned.
+type Row struct {
+	*sql.Row
+	span trace.Span
+}
+
+func (r *Row) Scan(dest ...any) error {
+	err := r.Row.Scan(dest...)
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		tracing.RecordError(r.span, err)
+	}
+	r.span.End()
+
+	return err
+}
+
+// Tx is a transaction running queries written for PostgreSQL.
+type Tx struct {
+	*sql.Tx
+	Dialect Dialect
+
+	// span is the span of the transaction, the parent of its statements.
+	span trace.Span
+}
+
+// InTx runs fn in a transaction, committing it if fn returns nil.
+func (db *DB) InTx(ctx context.Context, fn func(tx *Tx) error) error {
+	var span trace.Span = noop.Span{}
+	if trace.SpanFromContext(ctx).IsRecording() {
+		ctx, span = tracing.Tracer().Start(ctx, "transaction",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("db.system", db.Dialect.System())),
+		)
+		defer span.End()
+	}
+
+	sqlTx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	if err = fn(&Tx{Tx: sqlTx, Dialect: db.Dialect, span: span}); err != nil {
+		sqlTx.Rollback()
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	err = sqlTx.Commit()
+	tracing.RecordError(span, err)
+
+	return err
+}
+
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := startQuery(tx.within(ctx), tx.Dialect, query)
+	defer span.End()
+
+	result, err := tx.Tx.ExecContext(ctx, tx.Dialect.Rebind(query), args...)
+	tracing.RecordError(span, err)
+
+	return result, err
+}
+
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*Rows, error) {
+	ctx, span := startQuery(tx.within(ctx), tx.Dialect, query)
+
+	rows, err := tx.Tx.QueryContext(ctx, tx.Dialect.Rebind(query), args...)
+
+	return traceRows(span, rows, err)
+}
+
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	ctx, span := startQuery(tx.within(ctx), tx.Dialect, query)
+
+	return &Row{Row: tx.Tx.QueryRowContext(ctx, tx.Dialect.Rebind(query), args...), span: span}
+}
+
+// within makes the statements of tx children of its span, as they are run
+// with the context InTx was called with.
+func (tx *Tx) within(ctx context.Context) context.Context {
+	if !tx.span.IsRecording() {
+		return ctx
+	}
+
+	return trace.ContextWithSpan(ctx, tx.span)
+}
+
+var sqlTable = regexp.MustCompile(`(?i)\b(?:FROM|INTO|UPDATE)\s+(\w+)`)
+
+// startQuery starts the span of a statement, named after its operation and
+// first table. Statements are only traced as part of a traced operation,
+// so background work does not start traces of its own; the span returned
+// then does nothing.
+func startQuery(ctx context.Context, d Dialect, query string) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, noop.Span{}
+	}
+
+	statement := strings.Join(strings.Fields(query), " ")
+	operation, _, _ := strings.Cut(statement, " ")
+	operation = strings.ToUpper(operation)
+
+	name := operation
+	if table := mainTable(statement); table != "" {
+		name += " " + table
+	}
+
+	return tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", d.System()),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", statement),
+		),
+	)
+}
+
+// mainTable returns the first table the statement names outside
+// parentheses, so subqueries in the column list do not name the span.
+func mainTable(statement string) string {
+	matches := sqlTable.FindAllStringSubmatchIndex(statement, -1)
+	if matches == nil {
+		return ""
+	}
+
+	for _, m := range matches {
+		depth := strings.Count(statement[:m[0]], "(") - strings.Count(statement[:m[0]], ")")
+		if depth == 0 {
+			return statement[m[2]:m[3]]
+		}
+	}
+
+	return statement[matches[0][2]:matches[0][3]]
+}
+
+var (
+	Postgres Dialect = postgresDialect{}
+	SQLite   Dialect = sqliteDialect{}
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) Rebind(query string) string {
+	return query
+}
+
+func (postgresDialect) IsUniqueViolation(err error) bool {
+	return isPgError(err, "23505")
+}
+
+func (postgresDialect) IsForeignKeyViolation(err error) bool {
+	return isPgError(err, "23503")
+}
+
+func (postgresDialect) ForUpdate() string {
+	return " FOR UPDATE"
+}
+
+func (postgresDialect) FullTextSearch() bool {
+	return true
+}
+
+func (postgresDialect) System() string {
+	return "postgresql"
+}
+
+func isPgError(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
+
+type sqliteDialect struct{}
+
+var dollarPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// Rebind turns $n into ?n. SQLite reads $n as a named parameter numbered by
+// first appearance, which breaks queries using placeholders out of order.
+func (sqliteDialect) Rebind(query string) string {
+	return dollarPlaceholder.ReplaceAllString(query, "?$1")
+}
+
+func (sqliteDialect) IsUniqueViolation(err error) bool {
+	return isSqliteError(err, sqlite3.ErrConstraintUnique) || isSqliteError(err, sqlite3.ErrConstraintPrimaryKey)
+}
+
+func (sqliteDialect) IsForeignKeyViolation(err error) bool {
+	return isSqliteError(err, sqlite3.ErrConstraintForeignKey)
+}
+
+// ForUpdate is empty: transactions begin IMMEDIATE (see the connection
+// string), so a writer already holds the database lock.
+func (sqliteDialect) ForUpdate() string {
+	return ""
+}
+
+// FullTextSearch is false: go-sqlite3 leaves out FTS5 unless built with the
+// sqlite_fts5 tag, so products are matched word by word instead.
+func (sqliteDialect) FullTextSearch() bool {
+	return false
+}
+
+func (sqliteDialect) System() string {
+	return "sqlite"
+}
+
+func isSqliteError(err error, code sqlite3.ErrNoExtended) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == code
+}
+
+// expectOne returns notFound when result affected no rows.
+func expectOne(result sql.Result, notFound error) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return notFound
+	}
+
+	return nil
+}
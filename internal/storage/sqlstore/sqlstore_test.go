@@ -0,0 +1,137 @@
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nier704/arthur-leilao-server/db"
+	"github.com/Nier704/arthur-leilao-server/internal/storage"
+	"github.com/Nier704/arthur-leilao-server/internal/storage/sqlstore"
+	"github.com/Nier704/arthur-leilao-server/internal/storage/storagetest"
+	"github.com/Nier704/arthur-leilao-server/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSQLite(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) *storage.Store {
+		conn, err := db.NewSQLiteConnection(filepath.Join(t.TempDir(), "leilao.db"))
+		if err != nil {
+			t.Fatalf("opening sqlite: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+
+		m, err := db.NewMigrator(conn, db.SQLite)
+		if err != nil {
+			t.Fatalf("loading migrations: %v", err)
+		}
+
+		if _, err = m.Up(context.Background()); err != nil {
+			t.Fatalf("migrating: %v", err)
+		}
+
+		return storage.NewSQLStore(conn, sqlstore.SQLite)
+	})
+}
+
+// TestPostgres runs the contract against the database TEST_POSTGRES_DSN
+// points at. Every migration is rolled back before each test, which drops
+// what the database holds, so it must be one set aside for tests.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("opening postgres: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	m, err := db.NewMigrator(conn, db.Postgres)
+	if err != nil {
+		t.Fatalf("loading migrations: %v", err)
+	}
+
+	storagetest.Run(t, func(t *testing.T) *storage.Store {
+		ctx := context.Background()
+
+		if _, err := m.Down(ctx, math.MaxInt); err != nil {
+			t.Fatalf("rolling back: %v", err)
+		}
+
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatalf("migrating: %v", err)
+		}
+
+		return storage.NewSQLStore(conn, sqlstore.Postgres)
+	})
+}
+
+// TestQuerySpans checks that the span of a query lasts until its rows are
+// read.
+func TestQuerySpans(t *testing.T) {
+	conn, err := db.NewSQLiteConnection(filepath.Join(t.TempDir(), "leilao.db"))
+	if err != nil {
+		t.Fatalf("opening sqlite: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider("leilao", 1, sdktrace.WithSyncer(exporter))
+
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	store := sqlstore.New(conn, sqlstore.SQLite)
+
+	// outside a trace nothing is recorded
+	var n int
+	if err = store.QueryRowContext(context.Background(), `SELECT 1;`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := tracing.Tracer().Start(context.Background(), "parent")
+	defer parent.End()
+
+	rows, err := store.QueryContext(ctx, `SELECT 1 UNION ALL SELECT 2;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Fatalf("%d spans ended before the rows were read", len(spans))
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows.Close()
+
+	row := store.QueryRowContext(ctx, `SELECT 3 WHERE 1 = 0;`)
+
+	if spans := exporter.GetSpans(); len(spans) != 1 || spans[0].Name != "SELECT" || spans[0].Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Fatalf("spans after reading the rows = %+v, want the query under its parent", spans)
+	}
+
+	// a missing row is not a failure
+	if err = row.Scan(&n); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Scan = %v, want ErrNoRows", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 || spans[1].Status.Code == codes.Error {
+		t.Fatalf("spans after scanning the row = %+v, want a second one without error", spans)
+	}
+}
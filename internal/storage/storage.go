@@ -0,0 +1,147 @@
+// Package storage wires the repositories of the configured backend.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/Nier704/arthur-leilao-server/config"
+	"github.com/Nier704/arthur-leilao-server/db"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/apikey"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/exchange"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/jwt"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/moderation"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/notification"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/Nier704/arthur-leilao-server/internal/storage/memory"
+	"github.com/Nier704/arthur-leilao-server/internal/storage/sqlstore"
+)
+
+type Store struct {
+	Accounts      account.AccountRepository
+	Products      product.ProductRepository
+	Bids          product.BidRepository
+	ApiKeys       apikey.ApiKeyRepository
+	Identities    jwt.IdentityRepository
+	Rates         exchange.RateRepository
+	Notifications notification.NotificationRepository
+	Categories    category.CategoryRepository
+	Images        product.ImageRepository
+	Reports       moderation.ReportRepository
+
+	// DB is the underlying connection pool, nil for the memory backend.
+	DB *sql.DB
+	// Migrations migrated DB, nil for the memory backend.
+	Migrations *db.Migrator
+}
+
+// OpenDB connects to a SQL backend and returns the dialect to migrate it with.
+func OpenDB(cfg *config.StorageConfig) (*sql.DB, db.Dialect, error) {
+	var (
+		conn    *sql.DB
+		dialect db.Dialect
+		err     error
+	)
+
+	switch cfg.Backend {
+	case "postgres":
+		conn, err = db.NewPostgreConnection(cfg.DB)
+		dialect = db.Postgres
+
+	case "sqlite":
+		conn, err = db.NewSQLiteConnection(cfg.SQLitePath)
+		dialect = db.SQLite
+
+	default:
+		return nil, db.Dialect{}, fmt.Errorf("storage backend %q is not a SQL database", cfg.Backend)
+	}
+	if err != nil {
+		return nil, db.Dialect{}, err
+	}
+
+	db.ConfigurePool(conn, cfg.DB)
+
+	return conn, dialect, nil
+}
+
+// Open connects to the configured backend. SQL backends are migrated to the
+// latest schema before use.
+func Open(ctx context.Context, cfg *config.StorageConfig) (*Store, error) {
+	if cfg.Backend == "memory" {
+		return NewMemoryStore(), nil
+	}
+
+	conn, dialect, err := OpenDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := db.NewMigrator(conn, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.Up(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mig := range applied {
+		slog.Info("applied migration", "version", mig.Version, "name", mig.Name)
+	}
+
+	store := NewSQLStore(conn, sqlstore.Postgres)
+	if dialect.Name == db.SQLite.Name {
+		store = NewSQLStore(conn, sqlstore.SQLite)
+	}
+	store.Migrations = m
+
+	return store, nil
+}
+
+func NewSQLStore(conn *sql.DB, dialect sqlstore.Dialect) *Store {
+	db := sqlstore.New(conn, dialect)
+
+	return &Store{
+		Accounts:      sqlstore.NewAccountRepository(db),
+		Products:      sqlstore.NewProductRepository(db),
+		Bids:          sqlstore.NewBidRepository(db),
+		ApiKeys:       sqlstore.NewApiKeyRepository(db),
+		Identities:    sqlstore.NewIdentityRepository(db),
+		Rates:         sqlstore.NewRateRepository(db),
+		Notifications: sqlstore.NewNotificationRepository(db),
+		Categories:    sqlstore.NewCategoryRepository(db),
+		Images:        sqlstore.NewImageRepository(db),
+		Reports:       sqlstore.NewReportRepository(db),
+		DB:            conn,
+	}
+}
+
+func NewMemoryStore() *Store {
+	mem := memory.New()
+
+	return &Store{
+		Accounts:      memory.NewAccountRepository(mem),
+		Products:      memory.NewProductRepository(mem),
+		Bids:          memory.NewBidRepository(mem),
+		ApiKeys:       memory.NewApiKeyRepository(mem),
+		Identities:    memory.NewIdentityRepository(mem),
+		Rates:         memory.NewRateRepository(mem),
+		Notifications: memory.NewNotificationRepository(mem),
+		Categories:    memory.NewCategoryRepository(mem),
+		Images:        memory.NewImageRepository(mem),
+		Reports:       memory.NewReportRepository(mem),
+	}
+}
+
+func (s *Store) Close() error {
+	if s.DB == nil {
+		return nil
+	}
+
+	return s.DB.Close()
+}
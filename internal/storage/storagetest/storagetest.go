@@ -0,0 +1,1500 @@
+// Package storagetest is the contract every storage backend must satisfy.
+// Backends run it from their own tests:
+//
+//	storagetest.Run(t, func(t *testing.T) *storage.Store { ... })
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Nier704/arthur-leilao-server/internal/domain/account"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/apikey"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/category"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/exchange"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/jwt"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/moderation"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/notification"
+	"github.com/Nier704/arthur-leilao-server/internal/domain/product"
+	"github.com/Nier704/arthur-leilao-server/internal/money"
+	"github.com/Nier704/arthur-leilao-server/internal/storage"
+	"github.com/google/uuid"
+)
+
+// Run runs the contract against fresh, empty stores returned by newStore.
+func Run(t *testing.T, newStore func(t *testing.T) *storage.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *storage.Store)
+	}{
+		{"Accounts", testAccounts},
+		{"AccountCascade", testAccountCascade},
+		{"Products", testProducts},
+		{"ProductListing", testProductListing},
+		{"ProductSearch", testProductSearch},
+		{"Categories", testCategories},
+		{"Images", testImages},
+		{"History", testHistory},
+		{"Bids", testBids},
+		{"ApiKeys", testApiKeys},
+		{"Identities", testIdentities},
+		{"Rates", testRates},
+		{"Retraction", testRetraction},
+		{"RetractionRace", testRetractionRace},
+		{"Close", testClose},
+		{"Notifications", testNotifications},
+		{"Review", testReview},
+		{"Reports", testReports},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func mustAccount(t *testing.T, s *storage.Store, username string) account.Account {
+	t.Helper()
+
+	acc := account.Account{Username: username, Password: "hash"}
+	if err := s.Accounts.Create(context.Background(), &acc); err != nil {
+		t.Fatalf("creating account %s: %v", username, err)
+	}
+
+	return acc
+}
+
+func mustProduct(t *testing.T, s *storage.Store, owner uuid.UUID) product.Product {
+	t.Helper()
+
+	p := product.Product{AccountID: owner, Title: "Guitar", Description: "Vintage", Price: money.New(15025, money.DefaultCurrency)}
+	if err := s.Products.Create(context.Background(), &p); err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+
+	return p
+}
+
+// mustListed creates a product of owner published an hour ago, ending at
+// endsAt.
+func mustListed(t *testing.T, s *storage.Store, owner uuid.UUID, endsAt time.Time) product.Product {
+	t.Helper()
+
+	p := product.Product{AccountID: owner, Title: "Guitar", Description: "Vintage", Price: money.New(15025, money.DefaultCurrency), EndsAt: &endsAt}
+	if err := s.Products.Create(context.Background(), &p); err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+
+	at := time.Now().UTC().Add(-time.Hour)
+	if err := s.Products.Publish(context.Background(), p.ID, at); err != nil {
+		t.Fatalf("publishing product: %v", err)
+	}
+	p.PublishedAt = &at
+
+	return p
+}
+
+func testAccounts(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	acc := mustAccount(t, s, "ana")
+	if acc.ID == uuid.Nil || acc.Role != account.RoleUser {
+		t.Fatalf("Create = %+v; want an id and RoleUser", acc)
+	}
+
+	dup := account.Account{Username: "ana", Password: "x"}
+	if err := s.Accounts.Create(ctx, &dup); !errors.Is(err, account.ErrUsernameTaken) {
+		t.Fatalf("duplicate username: got %v, want ErrUsernameTaken", err)
+	}
+
+	got, err := s.Accounts.GetByUsername(ctx, "ana")
+	if err != nil || got.ID != acc.ID {
+		t.Fatalf("GetByUsername = %v, %v", got, err)
+	}
+
+	other := mustAccount(t, s, "bia")
+	other.Username = "ana"
+	if err := s.Accounts.Update(ctx, &other); !errors.Is(err, account.ErrUsernameTaken) {
+		t.Fatalf("update to taken username: got %v, want ErrUsernameTaken", err)
+	}
+
+	if err := s.Accounts.SetRole(ctx, acc.ID, account.RoleAdmin); err != nil {
+		t.Fatalf("SetRole: %v", err)
+	}
+
+	if err := s.Accounts.SetRole(ctx, uuid.New(), account.RoleAdmin); !errors.Is(err, account.ErrNotFound) {
+		t.Fatalf("SetRole unknown account: got %v, want ErrNotFound", err)
+	}
+
+	acc.Username = "ana2"
+	acc.Role = account.RoleUser
+	if err := s.Accounts.Update(ctx, &acc); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	got, err = s.Accounts.GetByID(ctx, acc.ID)
+	if err != nil || got.Username != "ana2" || got.Role != account.RoleAdmin {
+		t.Fatalf("GetByID after update = %v, %v; want the role untouched by Update", got, err)
+	}
+
+	all, err := s.Accounts.GetAll(ctx)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("GetAll = %d accounts, %v", len(all), err)
+	}
+
+	if err := s.Accounts.Delete(ctx, acc.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := s.Accounts.GetByID(ctx, acc.ID); !errors.Is(err, account.ErrNotFound) {
+		t.Fatalf("GetByID after delete: got %v, want ErrNotFound", err)
+	}
+
+	if err := s.Accounts.Delete(ctx, acc.ID); !errors.Is(err, account.ErrNotFound) {
+		t.Fatalf("second Delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func testAccountCascade(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+	bidder := mustAccount(t, s, "bidder")
+	p := mustListed(t, s, seller.ID, time.Now().Add(time.Hour))
+
+	if err := s.Products.Associate(ctx, bidder.ID, p.ID); err != nil {
+		t.Fatalf("Associate: %v", err)
+	}
+
+	bid := product.Bid{AccountID: bidder.ID, ProductID: p.ID, BidValue: money.New(20000, money.DefaultCurrency), BidMessage: "mine"}
+	if err := s.Bids.Create(ctx, &bid); err != nil {
+		t.Fatalf("creating bid: %v", err)
+	}
+
+	if err := s.Accounts.Delete(ctx, seller.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := s.Products.GetByID(ctx, p.ID); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("seller's product survived account deletion: %v", err)
+	}
+
+	bids, err := s.Bids.GetByAccount(ctx, bidder.ID)
+	if err != nil || len(bids) != 0 {
+		t.Fatalf("bids on deleted product survived: %v, %v", bids, err)
+	}
+
+	products, err := s.Products.GetByAccount(ctx, bidder.ID)
+	if err != nil || len(products) != 0 {
+		t.Fatalf("association with deleted product survived: %v, %v", products, err)
+	}
+}
+
+func testProducts(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+
+	orphan := product.Product{AccountID: uuid.New(), Title: "x", Description: "x", Price: money.New(100, money.DefaultCurrency)}
+	if err := s.Products.Create(ctx, &orphan); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("product of unknown account: got %v, want ErrNotFound", err)
+	}
+
+	p := mustProduct(t, s, seller.ID)
+
+	got, err := s.Products.GetByID(ctx, p.ID)
+	if err != nil || got.Title != p.Title || got.Price != p.Price || got.AccountID != seller.ID || got.PublishedAt != nil {
+		t.Fatalf("GetByID = %+v, %v; want a draft", got, err)
+	}
+
+	published := time.Date(2029, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := s.Products.Publish(ctx, p.ID, published); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	if err := s.Products.Publish(ctx, p.ID, published); !errors.Is(err, product.ErrAlreadyPublished) {
+		t.Fatalf("Publish twice: got %v, want ErrAlreadyPublished", err)
+	}
+
+	if err := s.Products.Publish(ctx, uuid.New(), published); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("Publish unknown product: got %v, want ErrNotFound", err)
+	}
+
+	p.Title = "Bass"
+	// well past the old NUMERIC(7,2) ceiling
+	p.Price = money.New(123456789012, "USD")
+	ends := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	p.EndsAt = &ends
+	p.AccountID = uuid.Nil
+	if err := s.Products.Update(ctx, &p, seller.ID); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if p.AccountID != seller.ID {
+		t.Fatalf("Update did not report the owner: %v", p.AccountID)
+	}
+
+	got, err = s.Products.GetByID(ctx, p.ID)
+	if err != nil || got.Price != p.Price || got.EndsAt == nil || !got.EndsAt.Equal(ends) {
+		t.Fatalf("GetByID after Update = %+v, %v; want price %v ending %v", got, err, p.Price, ends)
+	}
+
+	if got.PublishedAt == nil || !got.PublishedAt.Equal(published) {
+		t.Fatalf("PublishedAt after Update = %v, want %v", got.PublishedAt, published)
+	}
+
+	missing := product.Product{ID: uuid.New(), Title: "x", Description: "x", Price: money.New(100, money.DefaultCurrency)}
+	if err := s.Products.Update(ctx, &missing, seller.ID); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("Update unknown product: got %v, want ErrNotFound", err)
+	}
+
+	if err := s.Products.Associate(ctx, seller.ID, uuid.New()); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("Associate unknown product: got %v, want ErrNotFound", err)
+	}
+
+	if err := s.Products.Associate(ctx, seller.ID, p.ID); err != nil {
+		t.Fatalf("Associate: %v", err)
+	}
+
+	if err := s.Products.Associate(ctx, seller.ID, p.ID); err != nil {
+		t.Fatalf("Associate twice: %v", err)
+	}
+
+	mine, err := s.Products.GetByAccount(ctx, seller.ID)
+	if err != nil || len(mine) != 1 || mine[0].Title != "Bass" {
+		t.Fatalf("GetByAccount = %+v, %v", mine, err)
+	}
+
+	if err := s.Products.Delete(ctx, p.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	all, err := s.Products.GetAll(ctx)
+	if err != nil || len(all) != 0 {
+		t.Fatalf("GetAll after delete = %+v, %v", all, err)
+	}
+
+	batch := []product.Product{
+		{AccountID: seller.ID, Title: "Drum", Description: "x", Price: money.New(100, money.DefaultCurrency), Tags: []string{"percussion"}},
+		{AccountID: uuid.New(), Title: "Flute", Description: "x", Price: money.New(100, money.DefaultCurrency)},
+	}
+	if err := s.Products.CreateMany(ctx, batch); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("CreateMany with an unknown account: got %v, want ErrNotFound", err)
+	}
+
+	if all, err = s.Products.GetAll(ctx); err != nil || len(all) != 0 {
+		t.Fatalf("GetAll after a failed CreateMany = %+v, %v; want nothing created", all, err)
+	}
+
+	batch[1].AccountID = seller.ID
+	if err := s.Products.CreateMany(ctx, batch); err != nil {
+		t.Fatalf("CreateMany: %v", err)
+	}
+
+	for _, p := range batch {
+		got, err := s.Products.GetByID(ctx, p.ID)
+		if err != nil || got.Title != p.Title || got.PublishedAt != nil {
+			t.Fatalf("GetByID after CreateMany = %+v, %v; want draft %q", got, err, p.Title)
+		}
+
+		if revisions, err := s.Products.GetRevisions(ctx, p.ID); err != nil || len(revisions) != 1 {
+			t.Fatalf("GetRevisions after CreateMany = %+v, %v; want the creation", revisions, err)
+		}
+	}
+}
+
+func testBids(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+	bidder := mustAccount(t, s, "bidder")
+	p := mustListed(t, s, seller.ID, time.Now().Add(time.Hour))
+
+	var placed []product.Bid
+	for _, v := range []int64{1000, 2000, 3050} {
+		b := product.Bid{AccountID: bidder.ID, ProductID: p.ID, BidValue: money.New(v, money.DefaultCurrency), BidMessage: "bid", IP: "10.0.0.1", UserAgent: "test"}
+		if err := s.Bids.Create(ctx, &b); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		if b.ID == uuid.Nil || b.CreatedAt.IsZero() {
+			t.Fatalf("Create = %+v; want an id and a timestamp", b)
+		}
+		placed = append(placed, b)
+		// keep created_at strictly increasing on coarse clocks
+		time.Sleep(time.Millisecond)
+	}
+
+	if placed[2].Status != product.BidActive {
+		t.Fatalf("status on arrival = %s; want active", placed[2].Status)
+	}
+
+	for _, v := range []int64{3050, 2500} {
+		low := product.Bid{AccountID: bidder.ID, ProductID: p.ID, BidValue: money.New(v, money.DefaultCurrency), BidMessage: "bid"}
+		if err := s.Bids.Create(ctx, &low); !errors.Is(err, product.ErrBidTooLow) {
+			t.Fatalf("bid of %d under an active 3050: got %v, want ErrBidTooLow", v, err)
+		}
+	}
+
+	first, err := s.Bids.GetByID(ctx, placed[0].ID)
+	if err != nil || first.Status != product.BidOutbid || first.IP != "10.0.0.1" || first.UserAgent != "test" {
+		t.Fatalf("GetByID = %+v, %v; want the first bid outbid", first, err)
+	}
+
+	if _, err := s.Bids.GetByID(ctx, uuid.New()); !errors.Is(err, product.ErrBidNotFound) {
+		t.Fatalf("GetByID unknown bid: got %v, want ErrBidNotFound", err)
+	}
+
+	page, total, err := s.Bids.GetByProduct(ctx, p.ID, 2, 1)
+	if err != nil || total != 3 || len(page) != 2 || page[0].ID != placed[1].ID || page[1].ID != placed[0].ID {
+		t.Fatalf("GetByProduct = %+v, %d, %v; want the two oldest bids, newest first", page, total, err)
+	}
+
+	unknown := product.Bid{AccountID: bidder.ID, ProductID: uuid.New(), BidValue: money.New(100, money.DefaultCurrency), BidMessage: "bid"}
+	if err := s.Bids.Create(ctx, &unknown); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("bid on unknown product: got %v, want ErrNotFound", err)
+	}
+
+	best, err := s.Bids.Get(ctx, bidder.ID, p.ID)
+	if err != nil || best.BidValue != money.New(3050, money.DefaultCurrency) {
+		t.Fatalf("Get = %+v, %v; want the highest bid", best, err)
+	}
+
+	bids, err := s.Bids.GetByAccount(ctx, bidder.ID)
+	if err != nil || len(bids) != 3 {
+		t.Fatalf("GetByAccount = %+v, %v", bids, err)
+	}
+
+	if _, err := s.Bids.Get(ctx, seller.ID, p.ID); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("Get without bids: got %v, want ErrNotFound", err)
+	}
+
+	draft := product.Bid{AccountID: bidder.ID, ProductID: mustProduct(t, s, seller.ID).ID, BidValue: money.New(100, money.DefaultCurrency), BidMessage: "bid"}
+	if err := s.Bids.Create(ctx, &draft); !errors.Is(err, product.ErrNotPublished) {
+		t.Fatalf("bid on a draft: got %v, want ErrNotPublished", err)
+	}
+
+	// ended, though not closed yet
+	ended := product.Bid{AccountID: bidder.ID, ProductID: mustListed(t, s, seller.ID, time.Now().Add(-time.Minute)).ID, BidValue: money.New(100, money.DefaultCurrency), BidMessage: "bid"}
+	if err := s.Bids.Create(ctx, &ended); !errors.Is(err, product.ErrAuctionEnded) {
+		t.Fatalf("bid after ends_at: got %v, want ErrAuctionEnded", err)
+	}
+}
+
+func testApiKeys(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	owner := mustAccount(t, s, "owner")
+
+	key := apikey.ApiKey{
+		AccountID: owner.ID,
+		Name:      "bot",
+		Prefix:    "alk_test",
+		Hash:      apikey.HashKey("alk_test_secret"),
+		Scopes:    []string{apikey.ScopeProductsRead, apikey.ScopeBidsRead},
+	}
+	if err := s.ApiKeys.Create(ctx, &key); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	at := time.Now().Truncate(time.Second)
+
+	used, err := s.ApiKeys.Use(ctx, key.Hash, at)
+	if err != nil || used.ID != key.ID || len(used.Scopes) != 2 {
+		t.Fatalf("Use = %+v, %v", used, err)
+	}
+
+	if used.LastUsedAt == nil || !used.LastUsedAt.Equal(at) {
+		t.Fatalf("Use did not record last use: %v", used.LastUsedAt)
+	}
+
+	other := mustAccount(t, s, "other")
+	if err := s.ApiKeys.Revoke(ctx, key.ID, other.ID); !errors.Is(err, apikey.ErrNotFound) {
+		t.Fatalf("Revoke someone else's key: got %v, want ErrNotFound", err)
+	}
+
+	if err := s.ApiKeys.Revoke(ctx, key.ID, owner.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	if _, err := s.ApiKeys.Use(ctx, key.Hash, at); !errors.Is(err, apikey.ErrNotFound) {
+		t.Fatalf("Use revoked key: got %v, want ErrNotFound", err)
+	}
+
+	keys, err := s.ApiKeys.GetByAccount(ctx, owner.ID)
+	if err != nil || len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Fatalf("GetByAccount = %+v, %v", keys, err)
+	}
+}
+
+func testIdentities(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	acc := mustAccount(t, s, "social")
+
+	if _, err := s.Identities.Get(ctx, "google", "123"); !errors.Is(err, jwt.ErrIdentityNotFound) {
+		t.Fatalf("Get unknown identity: got %v, want ErrIdentityNotFound", err)
+	}
+
+	identity := jwt.Identity{Provider: "google", Subject: "123", AccountID: acc.ID, Email: "a@b.c"}
+	if err := s.Identities.Create(ctx, &identity); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := s.Identities.Create(ctx, &identity); err == nil {
+		t.Fatal("linking the same identity twice succeeded")
+	}
+
+	got, err := s.Identities.Get(ctx, "google", "123")
+	if err != nil || got.AccountID != acc.ID {
+		t.Fatalf("Get = %+v, %v", got, err)
+	}
+}
+
+func testRates(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	rate := exchange.Rate{Base: "USD", Quote: "BRL", Rate: "5.1", UpdatedAt: time.Now().UTC()}
+	if err := s.Rates.Set(ctx, &rate); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	rate.Rate = "5.4321"
+	if err := s.Rates.Set(ctx, &rate); err != nil {
+		t.Fatalf("Set again: %v", err)
+	}
+
+	rates, err := s.Rates.GetAll(ctx)
+	if err != nil || len(rates) != 1 || rates[0].Rate != "5.4321" {
+		t.Fatalf("GetAll = %+v, %v", rates, err)
+	}
+
+	table, err := exchange.LoadTable(ctx, s.Rates)
+	if err != nil {
+		t.Fatalf("LoadTable: %v", err)
+	}
+
+	brl, err := table.Convert(money.New(1000, "USD"), "BRL")
+	if err != nil || brl != money.New(5432, "BRL") {
+		t.Fatalf("Convert USD to BRL = %v, %v", brl, err)
+	}
+
+	usd, err := table.Convert(money.New(5432, "BRL"), "USD")
+	if err != nil || usd != money.New(1000, "USD") {
+		t.Fatalf("Convert BRL to USD = %v, %v", usd, err)
+	}
+
+	if err := s.Rates.Delete(ctx, "USD", "BRL"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if err := s.Rates.Delete(ctx, "USD", "BRL"); !errors.Is(err, exchange.ErrNoRate) {
+		t.Fatalf("second Delete: got %v, want ErrNoRate", err)
+	}
+}
+
+func testRetraction(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+	ana := mustAccount(t, s, "ana")
+	bia := mustAccount(t, s, "bia")
+	p := mustListed(t, s, seller.ID, time.Now().Add(time.Hour))
+
+	var placed []product.Bid
+	for _, b := range []product.Bid{
+		{AccountID: ana.ID, BidValue: money.New(1000, money.DefaultCurrency)},
+		{AccountID: bia.ID, BidValue: money.New(1500, money.DefaultCurrency)},
+		{AccountID: bia.ID, BidValue: money.New(2000, money.DefaultCurrency)},
+		{AccountID: ana.ID, BidValue: money.New(100000, money.DefaultCurrency)},
+	} {
+		b.ProductID = p.ID
+		b.BidMessage = "bid"
+		if err := s.Bids.Create(ctx, &b); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		placed = append(placed, b)
+		time.Sleep(time.Millisecond)
+	}
+
+	now := time.Now().UTC()
+	limit := product.RetractionLimit{Max: 1, Since: now.Add(-time.Hour)}
+
+	promoted, err := s.Bids.Retract(ctx, placed[3].ID, "meant 100", now, limit)
+	if err != nil || promoted == nil || promoted.ID != placed[2].ID || promoted.Status != product.BidActive {
+		t.Fatalf("Retract = %+v, %v; want the next highest bid promoted", promoted, err)
+	}
+
+	got, err := s.Bids.GetByID(ctx, placed[3].ID)
+	if err != nil || got.Status != product.BidRetracted || got.RetractionReason != "meant 100" || got.RetractedAt == nil {
+		t.Fatalf("GetByID after Retract = %+v, %v", got, err)
+	}
+
+	if _, err := s.Bids.Retract(ctx, placed[3].ID, "again", now, limit); !errors.Is(err, product.ErrBidNotRetractable) {
+		t.Fatalf("second Retract: got %v, want ErrBidNotRetractable", err)
+	}
+
+	if _, err := s.Bids.Retract(ctx, uuid.New(), "x", now, limit); !errors.Is(err, product.ErrBidNotFound) {
+		t.Fatalf("Retract unknown bid: got %v, want ErrBidNotFound", err)
+	}
+
+	promoted, err = s.Bids.Retract(ctx, placed[1].ID, "outbid anyway", now, limit)
+	if err != nil || promoted != nil {
+		t.Fatalf("Retract an outbid bid = %+v, %v; want nothing promoted", promoted, err)
+	}
+
+	if _, err := s.Bids.Retract(ctx, placed[0].ID, "one too many", now, limit); !errors.Is(err, product.ErrRetractionLimit) {
+		t.Fatalf("Retract past the limit: got %v, want ErrRetractionLimit", err)
+	}
+
+	if got, err := s.Bids.GetByID(ctx, placed[0].ID); err != nil || got.Status != product.BidOutbid {
+		t.Fatalf("GetByID after a refused Retract = %+v, %v; want it still outbid", got, err)
+	}
+
+	count, err := s.Bids.CountRetractions(ctx, ana.ID, now.Add(-time.Minute))
+	if err != nil || count != 1 {
+		t.Fatalf("CountRetractions = %d, %v; want 1", count, err)
+	}
+
+	count, err = s.Bids.CountRetractions(ctx, ana.ID, now.Add(time.Minute))
+	if err != nil || count != 0 {
+		t.Fatalf("CountRetractions after the retraction = %d, %v; want 0", count, err)
+	}
+
+	// retractions before Since do not count
+	later := product.RetractionLimit{Max: 1, Since: now.Add(time.Minute)}
+	if _, err := s.Bids.Retract(ctx, placed[0].ID, "a new period", now.Add(time.Hour), later); err != nil {
+		t.Fatalf("Retract in a new period: %v", err)
+	}
+
+	bidders, err := s.Bids.Bidders(ctx, p.ID)
+	if err != nil || len(bidders) != 2 {
+		t.Fatalf("Bidders = %v, %v; want ana and bia", bidders, err)
+	}
+}
+
+// testRetractionRace retracts bids of one account on several products at
+// once; the limit must hold even though each retraction counts the
+// others.
+func testRetractionRace(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+	ana := mustAccount(t, s, "ana")
+
+	var bids []product.Bid
+	for range 6 {
+		p := mustListed(t, s, seller.ID, time.Now().Add(time.Hour))
+
+		b := product.Bid{AccountID: ana.ID, ProductID: p.ID, BidValue: money.New(20000, money.DefaultCurrency), BidMessage: "bid"}
+		if err := s.Bids.Create(ctx, &b); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		bids = append(bids, b)
+	}
+
+	now := time.Now().UTC()
+	limit := product.RetractionLimit{Max: 2, Since: now.Add(-time.Hour)}
+
+	start := make(chan struct{})
+	errs := make([]error, len(bids))
+
+	var wg sync.WaitGroup
+	for i, b := range bids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, errs[i] = s.Bids.Retract(ctx, b.ID, "changed my mind", now, limit)
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	retracted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			retracted++
+		case !errors.Is(err, product.ErrRetractionLimit):
+			t.Fatalf("Retract: %v", err)
+		}
+	}
+
+	count, err := s.Bids.CountRetractions(ctx, ana.ID, limit.Since)
+	if err != nil || retracted != limit.Max || count != limit.Max {
+		t.Fatalf("%d retractions went through and %d (%v) are stored; want %d", retracted, count, err, limit.Max)
+	}
+}
+
+func testClose(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+	ana := mustAccount(t, s, "ana")
+	bia := mustAccount(t, s, "bia")
+
+	// bids go in before the first auction ends, and CloseEnded is then
+	// run as if it had
+	now := time.Now().UTC()
+	ended, running, draft := now.Add(time.Hour), now.Add(2*time.Hour), now.Add(time.Hour)
+
+	var products []product.Product
+	for _, endsAt := range []*time.Time{&ended, &running, &draft, nil} {
+		p := product.Product{AccountID: seller.ID, Title: "Guitar", Description: "Vintage", Price: money.New(100, money.DefaultCurrency), EndsAt: endsAt}
+		if err := s.Products.Create(ctx, &p); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		products = append(products, p)
+	}
+
+	for _, i := range []int{0, 1, 3} {
+		if err := s.Products.Publish(ctx, products[i].ID, now.Add(-time.Hour)); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	var bids []product.Bid
+	for _, p := range products[:2] {
+		for _, b := range []product.Bid{
+			{AccountID: ana.ID, BidValue: money.New(1000, money.DefaultCurrency)},
+			{AccountID: bia.ID, BidValue: money.New(2000, money.DefaultCurrency)},
+		} {
+			b.ProductID = p.ID
+			b.BidMessage = "bid"
+			if err := s.Bids.Create(ctx, &b); err != nil {
+				t.Fatalf("Create bid: %v", err)
+			}
+			bids = append(bids, b)
+		}
+	}
+
+	closed, err := s.Products.CloseEnded(ctx, ended)
+	if err != nil || closed != 1 {
+		t.Fatalf("CloseEnded = %d, %v; want only the ended, published auction", closed, err)
+	}
+
+	for i, want := range []string{product.BidOutbid, product.BidWinning, product.BidOutbid, product.BidActive} {
+		got, err := s.Bids.GetByID(ctx, bids[i].ID)
+		if err != nil || got.Status != want {
+			t.Fatalf("bid %d after CloseEnded = %+v, %v; want %s", i, got, err, want)
+		}
+	}
+
+	if _, err := s.Bids.Retract(ctx, bids[1].ID, "too late", now, product.RetractionLimit{Max: 1, Since: now}); !errors.Is(err, product.ErrBidNotRetractable) {
+		t.Fatalf("Retract a winning bid: got %v, want ErrBidNotRetractable", err)
+	}
+
+	late := product.Bid{AccountID: ana.ID, ProductID: products[0].ID, BidValue: money.New(5000, money.DefaultCurrency), BidMessage: "bid"}
+	if err := s.Bids.Create(ctx, &late); !errors.Is(err, product.ErrAuctionEnded) {
+		t.Fatalf("bid on a closed auction: got %v, want ErrAuctionEnded", err)
+	}
+
+	closed, err = s.Products.CloseEnded(ctx, ended.Add(time.Minute))
+	if err != nil || closed != 0 {
+		t.Fatalf("second CloseEnded = %d, %v; want nothing left to close", closed, err)
+	}
+
+	closed, err = s.Products.CloseEnded(ctx, running.Add(time.Minute))
+	if err != nil || closed != 1 {
+		t.Fatalf("CloseEnded after the second auction ended = %d, %v; want 1", closed, err)
+	}
+}
+
+func testNotifications(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	acc := mustAccount(t, s, "ana")
+
+	for _, kind := range []string{notification.KindBidRetracted, notification.KindHighBidder} {
+		n := notification.Notification{AccountID: acc.ID, Kind: kind, Message: "hi", ProductID: uuid.NullUUID{UUID: uuid.New(), Valid: true}}
+		if err := s.Notifications.Create(ctx, &n); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	all, err := s.Notifications.GetByAccount(ctx, acc.ID)
+	if err != nil || len(all) != 2 || all[0].Kind != notification.KindHighBidder || !all[0].ProductID.Valid {
+		t.Fatalf("GetByAccount = %+v, %v; want both, newest first", all, err)
+	}
+
+	if err := s.Accounts.Delete(ctx, acc.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	all, err = s.Notifications.GetByAccount(ctx, acc.ID)
+	if err != nil || len(all) != 0 {
+		t.Fatalf("notifications of a deleted account survived: %+v, %v", all, err)
+	}
+}
+
+func testProductListing(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	ana := mustAccount(t, s, "ana")
+	bia := mustAccount(t, s, "bia")
+
+	now := time.Now().UTC()
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	var ids []uuid.UUID
+	for _, p := range []product.Product{
+		{AccountID: ana.ID, Price: money.New(1000, "BRL"), EndsAt: at(time.Hour)},
+		{AccountID: ana.ID, Price: money.New(5000, "BRL"), EndsAt: at(-time.Hour)},
+		{AccountID: bia.ID, Price: money.New(3000, "BRL")},
+		{AccountID: bia.ID, Price: money.New(4000, "BRL"), EndsAt: at(48 * time.Hour)},
+		{AccountID: bia.ID, Price: money.New(200, "USD"), EndsAt: at(2 * time.Hour)},
+	} {
+		p.Title, p.Description, p.PublishedAt = "x", "x", &now
+		if err := s.Products.Create(ctx, &p); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		ids = append(ids, p.ID)
+		time.Sleep(time.Millisecond)
+	}
+
+	for i, n := range []int{2, 0, 1, 0, 0} {
+		for j := range n {
+			b := product.Bid{AccountID: bia.ID, ProductID: ids[0], BidValue: money.New(2000+int64(j)*100, "BRL"), BidMessage: "x"}
+			if i == 2 {
+				b.ProductID, b.AccountID = ids[2], ana.ID
+			}
+			if err := s.Bids.Create(ctx, &b); err != nil {
+				t.Fatalf("Create bid: %v", err)
+			}
+		}
+	}
+
+	draft := product.Product{AccountID: ana.ID, Title: "x", Description: "x", Price: money.New(100, "BRL")}
+	if err := s.Products.Create(ctx, &draft); err != nil {
+		t.Fatalf("Create draft: %v", err)
+	}
+	ids = append(ids, draft.ID)
+
+	// list walks every page two products at a time.
+	list := func(opts product.ListOptions) ([]uuid.UUID, int) {
+		t.Helper()
+
+		opts.Now, opts.Limit = now, 2
+
+		var got []uuid.UUID
+		for {
+			page, err := s.Products.List(ctx, opts)
+			if err != nil {
+				t.Fatalf("List(%+v): %v", opts, err)
+			}
+
+			for _, p := range page.Products {
+				got = append(got, p.ID)
+			}
+
+			if !page.HasMore {
+				return got, page.Total
+			}
+
+			c := product.CursorOf(page.Products[len(page.Products)-1], opts.Sort)
+			opts.After = &c
+		}
+	}
+
+	brl := money.New(2000, "BRL")
+	ending := now.Add(24 * time.Hour)
+
+	for _, tc := range []struct {
+		name string
+		opts product.ListOptions
+		want []int
+	}{
+		{"newest", product.ListOptions{Sort: product.SortNewest}, []int{4, 3, 2, 1, 0}},
+		{"ending soon", product.ListOptions{Sort: product.SortEndingSoon}, []int{1, 0, 4, 3}},
+		{"price", product.ListOptions{Sort: product.SortPriceAsc}, []int{0, 2, 3, 1, 4}},
+		{"price desc", product.ListOptions{Sort: product.SortPriceDesc}, []int{4, 1, 3, 2, 0}},
+		{"open", product.ListOptions{Sort: product.SortNewest, Status: product.StatusOpen}, []int{4, 3, 2, 0}},
+		{"ended", product.ListOptions{Sort: product.SortNewest, Status: product.StatusEnded}, []int{1}},
+		{"seller", product.ListOptions{Sort: product.SortNewest, Seller: uuid.NullUUID{UUID: ana.ID, Valid: true}}, []int{1, 0}},
+		{"min price", product.ListOptions{Sort: product.SortPriceAsc, MinPrice: &brl}, []int{2, 3, 1}},
+		{"max price", product.ListOptions{Sort: product.SortPriceAsc, MaxPrice: &brl}, []int{0}},
+		{"ending within a day", product.ListOptions{Sort: product.SortEndingSoon, EndingBefore: &ending}, []int{0, 4}},
+		{"drafts", product.ListOptions{Sort: product.SortNewest, Seller: uuid.NullUUID{UUID: ana.ID, Valid: true}, Drafts: true}, []int{5}},
+	} {
+		got, total := list(tc.opts)
+
+		var want []uuid.UUID
+		for _, i := range tc.want {
+			want = append(want, ids[i])
+		}
+
+		if !slices.Equal(got, want) || total != len(want) {
+			t.Errorf("%s: got %v (total %d), want %v", tc.name, got, total, want)
+		}
+	}
+
+	// ties on the bid count fall back to the id, descending
+	got, _ := list(product.ListOptions{Sort: product.SortMostBids})
+	if len(got) != 5 || got[0] != ids[0] || got[1] != ids[2] {
+		t.Errorf("most bids: got %v, want %v then %v first", got, ids[0], ids[2])
+	}
+
+	p, err := s.Products.GetByID(ctx, ids[0])
+	if err != nil || p.BidCount != 2 || p.CreatedAt.IsZero() {
+		t.Fatalf("GetByID = %+v, %v; want 2 bids and a creation time", p, err)
+	}
+}
+
+func testProductSearch(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+	now := time.Now()
+
+	var ids []uuid.UUID
+	for _, p := range []product.Product{
+		{Title: "Violão Giannini", Description: "Violão de madeira maciça, cordas novas", PublishedAt: &now},
+		{Title: "Guitarra elétrica", Description: "Acompanha capa para violão <b>\uE000grátis\uE001</b>", PublishedAt: &now},
+		{Title: "Bicicleta", Description: "Aro 29", PublishedAt: &now},
+		// drafts are never found
+		{Title: "Violão Tagima", Description: "Violão de madeira"},
+	} {
+		p.AccountID = seller.ID
+		p.Price = money.New(10000, money.DefaultCurrency)
+		if err := s.Products.Create(ctx, &p); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		ids = append(ids, p.ID)
+	}
+
+	results, total, err := s.Products.Search(ctx, "VIOLAO", 10, 0)
+	if err != nil || total != 2 || len(results) != 2 || results[0].ID != ids[0] || results[1].ID != ids[1] {
+		t.Fatalf("Search = %+v, %d, %v; want the title match first", results, total, err)
+	}
+
+	if !strings.Contains(results[0].Snippet, "<mark>Violão</mark>") || !strings.Contains(results[1].Snippet, "&lt;b&gt;") {
+		t.Errorf("snippets %q and %q are not highlighted and escaped", results[0].Snippet, results[1].Snippet)
+	}
+
+	// delimiters typed by the seller mark nothing
+	if !strings.Contains(results[1].Snippet, "&lt;b&gt;grátis") {
+		t.Errorf("snippet %q marks the delimiters of the description", results[1].Snippet)
+	}
+
+	results, total, err = s.Products.Search(ctx, "violão madeira", 10, 0)
+	if err != nil || total != 1 || results[0].ID != ids[0] {
+		t.Fatalf("Search with two words = %+v, %d, %v; want only the product with both", results, total, err)
+	}
+
+	results, total, err = s.Products.Search(ctx, "violao", 1, 1)
+	if err != nil || total != 2 || len(results) != 1 || results[0].ID != ids[1] {
+		t.Fatalf("second page = %+v, %d, %v", results, total, err)
+	}
+
+	p := product.Product{ID: ids[2], Title: "Bicicleta com violão", Description: "Aro 29", Price: money.New(10000, money.DefaultCurrency)}
+	if err := s.Products.Update(ctx, &p, seller.ID); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if _, total, err = s.Products.Search(ctx, "violao", 10, 0); err != nil || total != 3 {
+		t.Fatalf("Search after Update found %d, %v; want 3", total, err)
+	}
+}
+
+func testCategories(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	mustCategory := func(name string, parent uuid.NullUUID) category.Category {
+		t.Helper()
+
+		c := category.Category{Name: name, ParentID: parent}
+		if err := s.Categories.Create(ctx, &c); err != nil {
+			t.Fatalf("Create %s: %v", name, err)
+		}
+
+		return c
+	}
+
+	music := mustCategory("Music", uuid.NullUUID{})
+	under := func(c category.Category) uuid.NullUUID { return uuid.NullUUID{UUID: c.ID, Valid: true} }
+	guitars := mustCategory("Guitars", under(music))
+	drums := mustCategory("Drums", under(music))
+
+	dup := category.Category{Name: "guitars", ParentID: under(music)}
+	if err := s.Categories.Create(ctx, &dup); !errors.Is(err, category.ErrNameTaken) {
+		t.Fatalf("duplicate sibling name: got %v, want ErrNameTaken", err)
+	}
+
+	dup = category.Category{Name: "MUSIC"}
+	if err := s.Categories.Create(ctx, &dup); !errors.Is(err, category.ErrNameTaken) {
+		t.Fatalf("duplicate root name: got %v, want ErrNameTaken", err)
+	}
+
+	// the same name under another parent is fine
+	mustCategory("Drums", under(guitars))
+
+	orphan := category.Category{Name: "x", ParentID: uuid.NullUUID{UUID: uuid.New(), Valid: true}}
+	if err := s.Categories.Create(ctx, &orphan); !errors.Is(err, category.ErrNotFound) {
+		t.Fatalf("unknown parent: got %v, want ErrNotFound", err)
+	}
+
+	drums.Name = "Percussion"
+	if err := s.Categories.Update(ctx, &drums); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	all, err := s.Categories.GetAll(ctx)
+	if err != nil || len(all) != 4 || all[0].Name != "Drums" || all[2].Name != "Music" || all[3].Name != "Percussion" {
+		t.Fatalf("GetAll = %+v, %v; want them sorted by name", all, err)
+	}
+
+	tree := category.NewTree(all)
+	if got := tree.Descendants(music.ID); len(got) != 4 || tree.IsLeaf(guitars.ID) || !tree.IsLeaf(drums.ID) {
+		t.Fatalf("Descendants(music) = %v", got)
+	}
+
+	seller := mustAccount(t, s, "seller")
+	now := time.Now()
+	p := product.Product{
+		AccountID:   seller.ID,
+		Title:       "Drum kit",
+		Description: "x",
+		Price:       money.New(100, money.DefaultCurrency),
+		CategoryID:  under(drums),
+		Tags:        []string{"used", "vintage"},
+		PublishedAt: &now,
+	}
+	if err := s.Products.Create(ctx, &p); err != nil {
+		t.Fatalf("Create product: %v", err)
+	}
+
+	got, err := s.Products.GetByID(ctx, p.ID)
+	if err != nil || got.CategoryID != p.CategoryID || !slices.Equal(got.Tags, p.Tags) {
+		t.Fatalf("GetByID = %+v, %v", got, err)
+	}
+
+	p.Tags = []string{"new"}
+	if err := s.Products.Update(ctx, &p, seller.ID); err != nil {
+		t.Fatalf("Update product: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts product.ListOptions
+		want int
+	}{
+		{"music and below", product.ListOptions{Categories: tree.Descendants(music.ID)}, 1},
+		{"guitars", product.ListOptions{Categories: tree.Descendants(guitars.ID)}, 0},
+		{"tag", product.ListOptions{Tag: "new"}, 1},
+		{"replaced tag", product.ListOptions{Tag: "used"}, 0},
+	} {
+		tc.opts.Sort, tc.opts.Limit, tc.opts.Now = product.SortNewest, 10, time.Now()
+
+		page, err := s.Products.List(ctx, tc.opts)
+		if err != nil || page.Total != tc.want {
+			t.Errorf("%s: List = %+v, %v; want %d", tc.name, page, err, tc.want)
+		}
+	}
+
+	if has, err := s.Categories.HasProducts(ctx, drums.ID); err != nil || !has {
+		t.Fatalf("HasProducts = %v, %v; want true", has, err)
+	}
+
+	if err := s.Categories.Delete(ctx, drums.ID); !errors.Is(err, category.ErrInUse) {
+		t.Fatalf("Delete a category with products: got %v, want ErrInUse", err)
+	}
+
+	if err := s.Categories.Delete(ctx, music.ID); !errors.Is(err, category.ErrInUse) {
+		t.Fatalf("Delete a category with subcategories: got %v, want ErrInUse", err)
+	}
+
+	if err := s.Products.Delete(ctx, p.ID); err != nil {
+		t.Fatalf("Delete product: %v", err)
+	}
+
+	if err := s.Categories.Delete(ctx, drums.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := s.Categories.GetByID(ctx, drums.ID); !errors.Is(err, category.ErrNotFound) {
+		t.Fatalf("GetByID after Delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func testImages(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+	p := mustProduct(t, s, seller.ID)
+
+	var ids []uuid.UUID
+
+	for i := range 3 {
+		img := product.Image{ID: uuid.New(), ProductID: p.ID, ContentType: "image/png", Size: 10}
+		img.Key = product.ImageKey(p.ID, img.ID, img.ContentType)
+
+		if err := s.Images.Create(ctx, &img); err != nil || img.Position != i || img.Status != product.ImageProcessing {
+			t.Fatalf("Create image %d: position %d, status %s, %v", i, img.Position, img.Status, err)
+		}
+
+		ids = append(ids, img.ID)
+	}
+
+	orphan := product.Image{ID: uuid.New(), ProductID: uuid.New(), Key: "x.png", ContentType: "image/png"}
+	if err := s.Images.Create(ctx, &orphan); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("image of a missing product: got %v, want ErrNotFound", err)
+	}
+
+	got, err := s.Images.GetByID(ctx, ids[1])
+	if err != nil || got.Key != product.ImageKey(p.ID, ids[1], "image/png") || got.Size != 10 {
+		t.Fatalf("GetByID = %+v, %v", got, err)
+	}
+
+	if _, err := s.Images.GetByID(ctx, uuid.New()); !errors.Is(err, product.ErrImageNotFound) {
+		t.Fatalf("GetByID missing: got %v, want ErrImageNotFound", err)
+	}
+
+	processing, err := s.Images.GetProcessing(ctx)
+	if err != nil || len(processing) != 3 || processing[0].ID != ids[0] {
+		t.Fatalf("GetProcessing = %+v, %v", processing, err)
+	}
+
+	variants := map[string]product.Variant{
+		"thumbnail": {Key: "t.jpg", ContentType: "image/jpeg", Width: 200, Height: 200},
+		"full":      {Key: "f.jpg", ContentType: "image/jpeg", Width: 1600, Height: 1200, WebPKey: "f.webp"},
+	}
+	if err := s.Images.SetVariants(ctx, ids[0], variants); err != nil {
+		t.Fatalf("SetVariants: %v", err)
+	}
+
+	if err := s.Images.SetStatus(ctx, ids[2], product.ImageFailed); err != nil {
+		t.Fatalf("SetStatus: %v", err)
+	}
+
+	if err := s.Images.SetVariants(ctx, uuid.New(), variants); !errors.Is(err, product.ErrImageNotFound) {
+		t.Fatalf("SetVariants missing: got %v, want ErrImageNotFound", err)
+	}
+
+	got, err = s.Images.GetByID(ctx, ids[0])
+	if err != nil || got.Status != product.ImageReady || len(got.Variants) != 2 || got.Variants["full"] != variants["full"] || got.Variants["thumbnail"] != variants["thumbnail"] {
+		t.Fatalf("GetByID after SetVariants = %+v, %v", got, err)
+	}
+
+	if !slices.Contains(got.Keys(), "t.jpg") || !slices.Contains(got.Keys(), "f.webp") || !slices.Contains(got.Keys(), got.Key) {
+		t.Fatalf("Keys = %v", got.Keys())
+	}
+
+	processing, err = s.Images.GetProcessing(ctx)
+	if err != nil || len(processing) != 1 || processing[0].ID != ids[1] {
+		t.Fatalf("GetProcessing after processing = %+v, %v", processing, err)
+	}
+
+	other := mustProduct(t, s, seller.ID)
+
+	byProduct, err := s.Images.GetByProducts(ctx, []uuid.UUID{p.ID, other.ID})
+	if err != nil || len(byProduct[p.ID]) != 3 || len(byProduct[other.ID]) != 0 || byProduct[p.ID][0].Variants["thumbnail"].Width != 200 {
+		t.Fatalf("GetByProducts = %+v, %v", byProduct, err)
+	}
+
+	order := func() []uuid.UUID {
+		t.Helper()
+
+		images, err := s.Images.GetByProduct(ctx, p.ID)
+		if err != nil {
+			t.Fatalf("GetByProduct: %v", err)
+		}
+
+		var got []uuid.UUID
+		for i, img := range images {
+			if img.Position != i {
+				t.Fatalf("image %d has position %d", i, img.Position)
+			}
+			got = append(got, img.ID)
+		}
+
+		return got
+	}
+
+	reversed := []uuid.UUID{ids[2], ids[1], ids[0]}
+	if err := s.Images.Reorder(ctx, p.ID, reversed); err != nil {
+		t.Fatalf("Reorder: %v", err)
+	}
+
+	if got := order(); !slices.Equal(got, reversed) {
+		t.Fatalf("order after Reorder = %v, want %v", got, reversed)
+	}
+
+	for _, bad := range [][]uuid.UUID{
+		{ids[0], ids[1]},
+		{ids[0], ids[0], ids[1]},
+		{ids[0], ids[1], uuid.New()},
+	} {
+		if err := s.Images.Reorder(ctx, p.ID, bad); !errors.Is(err, product.ErrInvalidOrder) {
+			t.Errorf("Reorder(%v): got %v, want ErrInvalidOrder", bad, err)
+		}
+	}
+
+	if err := s.Images.Delete(ctx, ids[1]); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if got := order(); !slices.Equal(got, []uuid.UUID{ids[2], ids[0]}) {
+		t.Fatalf("order after Delete = %v", got)
+	}
+
+	if err := s.Images.Delete(ctx, ids[1]); !errors.Is(err, product.ErrImageNotFound) {
+		t.Fatalf("Delete twice: got %v, want ErrImageNotFound", err)
+	}
+
+	if err := s.Products.Delete(ctx, p.ID); err != nil {
+		t.Fatalf("Delete product: %v", err)
+	}
+
+	if images, err := s.Images.GetByProduct(ctx, p.ID); err != nil || len(images) != 0 {
+		t.Fatalf("images of a deleted product = %v, %v", images, err)
+	}
+}
+
+func testReview(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+	if seller.Verified {
+		t.Fatalf("new account is verified")
+	}
+
+	if err := s.Accounts.SetVerified(ctx, seller.ID, true); err != nil {
+		t.Fatalf("SetVerified: %v", err)
+	}
+
+	if got, err := s.Accounts.GetByID(ctx, seller.ID); err != nil || !got.Verified {
+		t.Fatalf("GetByID after SetVerified = %+v, %v", got, err)
+	}
+
+	if err := s.Accounts.SetVerified(ctx, uuid.New(), true); !errors.Is(err, account.ErrNotFound) {
+		t.Fatalf("SetVerified missing: got %v, want ErrNotFound", err)
+	}
+
+	first := mustProduct(t, s, seller.ID)
+	time.Sleep(time.Millisecond)
+	second := mustProduct(t, s, seller.ID)
+
+	for _, id := range []uuid.UUID{second.ID, first.ID} {
+		if err := s.Products.Submit(ctx, id); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+
+	pending, err := s.Products.GetPending(ctx)
+	if err != nil || len(pending) != 2 || pending[0].ID != first.ID || pending[0].ReviewStatus != product.ReviewPending || pending[0].PublishedAt != nil {
+		t.Fatalf("GetPending = %+v, %v; want both drafts, oldest first", pending, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	if err := s.Products.Approve(ctx, first.ID, "looks fine", now); err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+
+	got, err := s.Products.GetByID(ctx, first.ID)
+	if err != nil || got.ReviewStatus != product.ReviewApproved || got.ReviewReason != "looks fine" || got.PublishedAt == nil || !got.PublishedAt.Equal(now) {
+		t.Fatalf("GetByID after Approve = %+v, %v", got, err)
+	}
+
+	if err := s.Products.Approve(ctx, first.ID, "", now); !errors.Is(err, product.ErrNotPending) {
+		t.Fatalf("Approve twice: got %v, want ErrNotPending", err)
+	}
+
+	if err := s.Products.Submit(ctx, first.ID); !errors.Is(err, product.ErrAlreadyPublished) {
+		t.Fatalf("Submit published: got %v, want ErrAlreadyPublished", err)
+	}
+
+	// published products can be taken down
+	if err := s.Products.Reject(ctx, first.ID, "counterfeit"); err != nil {
+		t.Fatalf("Reject published: %v", err)
+	}
+
+	if err := s.Products.Reject(ctx, second.ID, "blurry photos"); err != nil {
+		t.Fatalf("Reject pending: %v", err)
+	}
+
+	got, err = s.Products.GetByID(ctx, second.ID)
+	if err != nil || got.ReviewStatus != product.ReviewRejected || got.ReviewReason != "blurry photos" || got.PublishedAt != nil {
+		t.Fatalf("GetByID after Reject = %+v, %v", got, err)
+	}
+
+	if err := s.Products.Reject(ctx, second.ID, "again"); !errors.Is(err, product.ErrNotPending) {
+		t.Fatalf("Reject a rejected draft: got %v, want ErrNotPending", err)
+	}
+
+	for _, err := range []error{
+		s.Products.Submit(ctx, uuid.New()),
+		s.Products.Approve(ctx, uuid.New(), "", now),
+		s.Products.Reject(ctx, uuid.New(), "x"),
+	} {
+		if !errors.Is(err, product.ErrNotFound) {
+			t.Fatalf("review of a missing product: got %v, want ErrNotFound", err)
+		}
+	}
+
+	if pending, err = s.Products.GetPending(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("GetPending after review = %+v, %v; want none", pending, err)
+	}
+}
+
+func testReports(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+	ana := mustAccount(t, s, "ana")
+	bob := mustAccount(t, s, "bob")
+	mod := mustAccount(t, s, "mod")
+
+	guitar := mustListed(t, s, seller.ID, time.Now().Add(time.Hour))
+	piano := mustProduct(t, s, seller.ID)
+
+	bid := product.Bid{AccountID: ana.ID, ProductID: guitar.ID, BidValue: money.New(1000, money.DefaultCurrency), BidMessage: "rude words"}
+	if err := s.Bids.Create(ctx, &bid); err != nil {
+		t.Fatalf("Create bid: %v", err)
+	}
+
+	report := func(reporter uuid.UUID, target moderation.Target, reason string) error {
+		t.Helper()
+		r := moderation.Report{ReporterID: reporter, Target: target, Reason: reason}
+		err := s.Reports.Create(ctx, &r)
+		if err == nil && (r.ID == uuid.Nil || r.Status != moderation.ReportOpen || r.CreatedAt.IsZero()) {
+			t.Fatalf("Create = %+v; want an open report with an id and a timestamp", r)
+		}
+		time.Sleep(time.Millisecond)
+		return err
+	}
+
+	onGuitar := moderation.Target{Type: moderation.TargetProduct, ID: guitar.ID}
+	onPiano := moderation.Target{Type: moderation.TargetProduct, ID: piano.ID}
+	onBid := moderation.Target{Type: moderation.TargetBid, ID: bid.ID}
+
+	for _, r := range []struct {
+		reporter uuid.UUID
+		target   moderation.Target
+		reason   string
+	}{
+		{ana.ID, onGuitar, "fake"},
+		{bob.ID, onGuitar, "stolen"},
+		{bob.ID, onPiano, "spam"},
+		{bob.ID, onBid, "insults"},
+	} {
+		if err := report(r.reporter, r.target, r.reason); err != nil {
+			t.Fatalf("Create %s: %v", r.reason, err)
+		}
+	}
+
+	if err := report(ana.ID, onGuitar, "again"); !errors.Is(err, moderation.ErrAlreadyReported) {
+		t.Fatalf("second open report: got %v, want ErrAlreadyReported", err)
+	}
+
+	for _, target := range []moderation.Target{
+		{Type: moderation.TargetProduct, ID: uuid.New()},
+		{Type: moderation.TargetBid, ID: uuid.New()},
+	} {
+		if err := report(ana.ID, target, "x"); !errors.Is(err, moderation.ErrTargetNotFound) {
+			t.Fatalf("report on a missing %s: got %v, want ErrTargetNotFound", target.Type, err)
+		}
+	}
+
+	reports, err := s.Reports.GetByTarget(ctx, onGuitar)
+	if err != nil || len(reports) != 2 || reports[0].Reason != "stolen" || reports[0].ReporterID != bob.ID || reports[0].Target != onGuitar {
+		t.Fatalf("GetByTarget = %+v, %v; want both reports, newest first", reports, err)
+	}
+
+	summaries, total, err := s.Reports.Summarize(ctx, moderation.ReportOpen, 2, 0)
+	if err != nil || total != 3 || len(summaries) != 2 {
+		t.Fatalf("Summarize = %+v, %d, %v; want 2 of 3 targets", summaries, total, err)
+	}
+
+	top := summaries[0]
+	if top.Target != onGuitar || top.Reports != 2 || top.Excerpt != "Guitar" || !slices.Equal(top.Reasons, []string{"stolen", "fake"}) || !top.FirstReportedAt.Before(top.LastReportedAt) {
+		t.Fatalf("most reported = %+v; want the guitar", top)
+	}
+
+	// ties go to the latest report
+	if summaries[1].Target != onBid || summaries[1].Excerpt != "rude words" {
+		t.Fatalf("second = %+v; want the bid", summaries[1])
+	}
+
+	if summaries, _, err = s.Reports.Summarize(ctx, moderation.ReportOpen, 2, 2); err != nil || len(summaries) != 1 || summaries[0].Target != onPiano {
+		t.Fatalf("Summarize second page = %+v, %v; want the piano", summaries, err)
+	}
+
+	at := time.Now().UTC().Truncate(time.Second)
+
+	closed, err := s.Reports.Resolve(ctx, onGuitar, moderation.ReportResolved, mod.ID, "taken down", at)
+	if err != nil || closed != 2 {
+		t.Fatalf("Resolve = %d, %v; want 2", closed, err)
+	}
+
+	if closed, err = s.Reports.Resolve(ctx, onGuitar, moderation.ReportDismissed, mod.ID, "", at); err != nil || closed != 0 {
+		t.Fatalf("Resolve again = %d, %v; want 0", closed, err)
+	}
+
+	reports, err = s.Reports.GetByTarget(ctx, onGuitar)
+	if err != nil || reports[0].Status != moderation.ReportResolved || reports[0].ResolvedBy.UUID != mod.ID || reports[0].ResolvedAt == nil || !reports[0].ResolvedAt.Equal(at) || reports[0].Note != "taken down" {
+		t.Fatalf("GetByTarget after Resolve = %+v, %v", reports, err)
+	}
+
+	// a closed report does not stop a new one
+	if err := report(ana.ID, onGuitar, "back again"); err != nil {
+		t.Fatalf("report after Resolve: %v", err)
+	}
+
+	counts, err := s.Reports.Count(ctx)
+	if err != nil || counts[moderation.ReportOpen] != 3 || counts[moderation.ReportResolved] != 2 {
+		t.Fatalf("Count = %v, %v", counts, err)
+	}
+
+	if err := s.Accounts.Delete(ctx, mod.ID); err != nil {
+		t.Fatalf("Delete moderator: %v", err)
+	}
+
+	reports, err = s.Reports.GetByTarget(ctx, onGuitar)
+	if err != nil || len(reports) != 3 || reports[1].ResolvedBy.Valid {
+		t.Fatalf("reports after deleting the moderator = %+v, %v", reports, err)
+	}
+
+	// deleting the guitar takes its bid, and the reports on both, with it
+	if err := s.Products.Delete(ctx, guitar.ID); err != nil {
+		t.Fatalf("Delete product: %v", err)
+	}
+
+	summaries, total, err = s.Reports.Summarize(ctx, moderation.ReportOpen, 10, 0)
+	if err != nil || total != 1 || summaries[0].Target != onPiano {
+		t.Fatalf("Summarize after Delete = %+v, %d, %v; want the piano only", summaries, total, err)
+	}
+
+	if err := s.Accounts.Delete(ctx, bob.ID); err != nil {
+		t.Fatalf("Delete reporter: %v", err)
+	}
+
+	if counts, err = s.Reports.Count(ctx); err != nil || len(counts) != 0 {
+		t.Fatalf("Count after deleting every reporter = %v, %v", counts, err)
+	}
+}
+
+func testHistory(t *testing.T, s *storage.Store) {
+	ctx := context.Background()
+
+	seller := mustAccount(t, s, "seller")
+	admin := mustAccount(t, s, "admin")
+	bidder := mustAccount(t, s, "bidder")
+	p := mustProduct(t, s, seller.ID)
+
+	revisions, err := s.Products.GetRevisions(ctx, p.ID)
+	if err != nil || len(revisions) != 1 || revisions[0].EditorID.UUID != seller.ID || len(revisions[0].Changes) != 3 {
+		t.Fatalf("GetRevisions after Create = %+v, %v; want the creation", revisions, err)
+	}
+
+	if c := revisions[0].Changes[2]; c != (product.Change{Field: product.FieldPrice, New: "150.25 BRL"}) {
+		t.Fatalf("creation price = %+v", c)
+	}
+
+	time.Sleep(time.Millisecond)
+
+	p.Title = "Bass"
+	if err := s.Products.Update(ctx, &p, admin.ID); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	// saving without changes adds nothing
+	if err := s.Products.Update(ctx, &p, seller.ID); err != nil {
+		t.Fatalf("Update unchanged: %v", err)
+	}
+
+	revisions, err = s.Products.GetRevisions(ctx, p.ID)
+	if err != nil || len(revisions) != 2 || revisions[0].EditorID.UUID != admin.ID || !slices.Equal(revisions[0].Changes, []product.Change{{Field: product.FieldTitle, Old: "Guitar", New: "Bass"}}) {
+		t.Fatalf("GetRevisions after Update = %+v, %v", revisions, err)
+	}
+
+	if err := s.Products.Publish(ctx, p.ID, time.Now().UTC()); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	bid := product.Bid{AccountID: bidder.ID, ProductID: p.ID, BidValue: money.New(20000, money.DefaultCurrency), BidMessage: "bid"}
+	if err := s.Bids.Create(ctx, &bid); err != nil {
+		t.Fatalf("Create bid: %v", err)
+	}
+
+	for _, edit := range []func(p *product.Product){
+		func(p *product.Product) { p.Price = money.New(100, money.DefaultCurrency) },
+		func(p *product.Product) { p.Description = "Broken" },
+		func(p *product.Product) {
+			end := time.Now().Add(72 * time.Hour).UTC().Truncate(time.Second)
+			p.EndsAt = &end
+		},
+	} {
+		changed := p
+		edit(&changed)
+		if err := s.Products.Update(ctx, &changed, seller.ID); !errors.Is(err, product.ErrLocked) {
+			t.Fatalf("material change with bids: got %v, want ErrLocked", err)
+		}
+	}
+
+	got, err := s.Products.GetByID(ctx, p.ID)
+	if err != nil || got.Price != p.Price || got.Description != p.Description || got.EndsAt != nil {
+		t.Fatalf("GetByID after locked Update = %+v, %v; want it unchanged", got, err)
+	}
+
+	p.Title = "Fender bass"
+	if err := s.Products.Update(ctx, &p, seller.ID); err != nil {
+		t.Fatalf("Update title with bids: %v", err)
+	}
+
+	for _, text := range []string{"Comes with a case", "Strings replaced"} {
+		a := product.Addendum{ProductID: p.ID, Text: text}
+		if err := s.Products.AddAddendum(ctx, &a, seller.ID); err != nil || a.ID == uuid.Nil || a.CreatedAt.IsZero() {
+			t.Fatalf("AddAddendum = %+v, %v", a, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	missing := product.Addendum{ProductID: uuid.New(), Text: "x"}
+	if err := s.Products.AddAddendum(ctx, &missing, seller.ID); !errors.Is(err, product.ErrNotFound) {
+		t.Fatalf("AddAddendum missing product: got %v, want ErrNotFound", err)
+	}
+
+	addenda, err := s.Products.GetAddenda(ctx, p.ID)
+	if err != nil || len(addenda) != 2 || addenda[0].Text != "Comes with a case" {
+		t.Fatalf("GetAddenda = %+v, %v; want both, oldest first", addenda, err)
+	}
+
+	revisions, err = s.Products.GetRevisions(ctx, p.ID)
+	if err != nil || len(revisions) != 5 || revisions[0].Changes[0] != (product.Change{Field: product.FieldAddendum, New: "Strings replaced"}) {
+		t.Fatalf("GetRevisions after AddAddendum = %+v, %v", revisions, err)
+	}
+
+	if err := s.Accounts.Delete(ctx, admin.ID); err != nil {
+		t.Fatalf("Delete editor: %v", err)
+	}
+
+	revisions, err = s.Products.GetRevisions(ctx, p.ID)
+	if err != nil || len(revisions) != 5 || revisions[3].EditorID.Valid {
+		t.Fatalf("GetRevisions after deleting the editor = %+v, %v; want it forgotten", revisions, err)
+	}
+
+	if err := s.Products.Delete(ctx, p.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	revisions, err = s.Products.GetRevisions(ctx, p.ID)
+	if err != nil || len(revisions) != 0 {
+		t.Fatalf("history of a deleted product survived: %+v, %v", revisions, err)
+	}
+
+	if addenda, err = s.Products.GetAddenda(ctx, p.ID); err != nil || len(addenda) != 0 {
+		t.Fatalf("addenda of a deleted product survived: %+v, %v", addenda, err)
+	}
+}
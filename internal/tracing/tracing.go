@@ -0,0 +1,93 @@
+// Package tracing sets up OpenTelemetry. Spans are exported to a collector
+// over OTLP/HTTP, or printed to stdout, and trace context is propagated
+// with the W3C traceparent and tracestate headers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Nier704/arthur-leilao-server/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName names the instrumentation of this module in exported spans.
+const ScopeName = "github.com/Nier704/arthur-leilao-server"
+
+// Tracer starts the spans of this module, through the global provider.
+// Until Open installs one, spans are not recorded but the trace of a caller
+// is still carried on.
+func Tracer() trace.Tracer {
+	return otel.Tracer(ScopeName)
+}
+
+// RecordError adds err to span as an exception event and marks the span
+// failed. A nil err does nothing.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// NewProvider returns a provider exporting through exporter, sampling the
+// ratio of new traces given, from 0 to 1. Traces started elsewhere keep the
+// decision of their caller.
+func NewProvider(service string, ratio float64, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res, _ := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", service)))
+
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	}, opts...)
+
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// Open installs the W3C propagator, and the provider cfg asks for as the
+// global one. It returns the function that exports the spans still
+// buffered and stops the provider, which does nothing when tracing is off.
+func Open(ctx context.Context, cfg *config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch cfg.Exporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+
+	case "console":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx,
+			otlptracehttp.WithEndpointURL(strings.TrimSuffix(cfg.Endpoint, "/")+"/v1/traces"),
+			otlptracehttp.WithHeaders(cfg.Headers),
+		)
+
+	default:
+		return nil, fmt.Errorf("unknown traces exporter %q", cfg.Exporter)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("creating the %s exporter: %w", cfg.Exporter, err)
+	}
+
+	provider := NewProvider(cfg.Service, cfg.Ratio, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
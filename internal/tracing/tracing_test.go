@@ -0,0 +1,251 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Nier704/arthur-leilao-server/config"
+	"github.com/Nier704/arthur-leilao-server/internal/middlewares"
+	"github.com/Nier704/arthur-leilao-server/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// The example of the W3C Trace Context recommendation.
+const (
+	exampleTraceID    = "4bf92f3577b34da6a3ce929d0e0e4736"
+	exampleSpanID     = "00f067aa0ba902b7"
+	exampleTracestate = "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7"
+)
+
+// setProvider installs the propagator Open sets up, and a provider
+// sampling ratio of new traces that records the spans ended, for the rest
+// of the test.
+func setProvider(t *testing.T, ratio float64) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	if _, err := tracing.Open(context.Background(), &config.TracingConfig{Exporter: "none"}); err != nil {
+		t.Fatal(err)
+	}
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider("leilao", ratio, sdktrace.WithSyncer(exporter))
+
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		provider.Shutdown(context.Background())
+	})
+
+	return exporter
+}
+
+func inject(ctx context.Context) http.Header {
+	h := http.Header{}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+
+	return h
+}
+
+func extract(h http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(h))
+}
+
+func TestPropagation(t *testing.T) {
+	// a sampled caller is followed even though new traces are not sampled
+	exporter := setProvider(t, 0)
+
+	in := http.Header{}
+	in.Set("traceparent", "00-"+exampleTraceID+"-"+exampleSpanID+"-01")
+	in.Set("tracestate", exampleTracestate)
+
+	ctx, span := tracing.Tracer().Start(extract(in), "GET /")
+	if !span.IsRecording() {
+		t.Fatal("the span of a sampled caller was not sampled")
+	}
+
+	out := inject(ctx)
+
+	want := "00-" + exampleTraceID + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got := out.Get("traceparent"); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+	if got := out.Get("tracestate"); got != exampleTracestate {
+		t.Errorf("tracestate = %q, want the caller's %q", got, exampleTracestate)
+	}
+
+	span.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Parent.SpanID().String() != exampleSpanID || !spans[0].Parent.IsRemote() {
+		t.Fatalf("exported %+v, want one child of the caller", spans)
+	}
+
+	// invalid headers start a new trace
+	for _, v := range []string{
+		"00-" + exampleTraceID + "-" + exampleSpanID,
+		"00-00000000000000000000000000000000-" + exampleSpanID + "-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-" + exampleSpanID + "-01",
+	} {
+		h := http.Header{}
+		h.Set("traceparent", v)
+
+		if sc := trace.SpanContextFromContext(extract(h)); sc.IsValid() {
+			t.Errorf("extracted %v from %q", sc.TraceID(), v)
+		}
+	}
+}
+
+func TestSampling(t *testing.T) {
+	tests := []struct {
+		name   string
+		ratio  float64
+		parent string
+		want   bool
+	}{
+		{"new trace at 0", 0, "", false},
+		{"new trace at 1", 1, "", true},
+		{"sampled caller at 0", 0, "01", true},
+		{"unsampled caller at 1", 1, "00", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := setProvider(t, tt.ratio)
+
+			h := http.Header{}
+			if tt.parent != "" {
+				h.Set("traceparent", "00-"+exampleTraceID+"-"+exampleSpanID+"-"+tt.parent)
+			}
+
+			ctx, span := tracing.Tracer().Start(extract(h), "root")
+			span.End()
+
+			spans, flags := 0, "-00"
+			if tt.want {
+				spans, flags = 1, "-01"
+			}
+
+			if span.SpanContext().IsSampled() != tt.want || len(exporter.GetSpans()) != spans {
+				t.Errorf("sampled = %v with %d spans exported, want %v", span.SpanContext().IsSampled(), len(exporter.GetSpans()), tt.want)
+			}
+
+			// the trace is carried on either way, so callees decide alike
+			if got := inject(ctx).Get("traceparent"); len(got) != 55 || got[52:] != flags {
+				t.Errorf("traceparent = %q, want flags %s", got, flags)
+			}
+		})
+	}
+}
+
+func TestServerSpan(t *testing.T) {
+	exporter := setProvider(t, 1)
+
+	var outgoing http.Header
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/product/{productId}", middlewares.Trace("GET /api/product/{productId}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outgoing = inject(r.Context())
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/product/42", nil)
+	req.Header.Set("traceparent", "00-"+exampleTraceID+"-"+exampleSpanID+"-01")
+	req.Header.Set("tracestate", exampleTracestate)
+
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("exported %d spans, want the server span", len(spans))
+	}
+
+	s := spans[0]
+	if s.Name != "GET /api/product/{productId}" || s.SpanKind != trace.SpanKindServer || s.SpanContext.TraceID().String() != exampleTraceID {
+		t.Errorf("span %q kind %v in %s", s.Name, s.SpanKind, s.SpanContext.TraceID())
+	}
+	if s.Status.Code != codes.Error {
+		t.Errorf("status = %v, want an error for a 500", s.Status)
+	}
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, a := range s.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	for key, want := range map[attribute.Key]string{"http.route": "/api/product/{productId}", "product.id": "42"} {
+		if got := attrs[key].AsString(); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	if got := outgoing.Get("tracestate"); got != exampleTracestate {
+		t.Errorf("handler propagates tracestate %q, want %q", got, exampleTracestate)
+	}
+}
+
+func TestOpenOTLP(t *testing.T) {
+	var mu sync.Mutex
+	var got []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			t.Errorf("request %s %s, want POST /v1/traces", r.Method, r.URL.Path)
+		}
+		if key := r.Header.Get("Api-Key"); key != "secret" {
+			t.Errorf("Api-Key = %q, want the configured header", key)
+		}
+
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		got = append(got, body...)
+		mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	shutdown, err := tracing.Open(context.Background(), &config.TracingConfig{
+		Exporter: "otlp",
+		Endpoint: srv.URL + "/",
+		Headers:  map[string]string{"Api-Key": "secret"},
+		Service:  "leilao",
+		Ratio:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := tracing.Tracer().Start(context.Background(), "exported span")
+	span.End()
+
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// the payload is protobuf, which keeps strings as they are
+	for _, want := range []string{"leilao", "exported span", tracing.ScopeName} {
+		if !bytes.Contains(got, []byte(want)) {
+			t.Errorf("payload lacks %q", want)
+		}
+	}
+}
+
+func TestOpenUnknownExporter(t *testing.T) {
+	if _, err := tracing.Open(context.Background(), &config.TracingConfig{Exporter: "zipkin"}); err == nil {
+		t.Error("opened an unknown exporter")
+	}
+}
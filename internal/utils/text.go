@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// accents maps the accented lowercase letters of Portuguese and its
+// neighbours to their base letter.
+var accents = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a',
+	'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i',
+	'ó': 'o', 'ò': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u',
+	'ç': 'c', 'ñ': 'n', 'ý': 'y', 'ÿ': 'y',
+}
+
+// Fold lowercases s and strips its accents, so "Violão" and "violao"
+// compare equal. Every rune maps to exactly one rune.
+func Fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if base, ok := accents[r]; ok {
+			return base
+		}
+		return r
+	}, s)
+}